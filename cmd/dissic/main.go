@@ -2,12 +2,24 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/engvik/dissic/internal/api"
 	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/deezer"
 	"github.com/engvik/dissic/internal/dissic"
+	"github.com/engvik/dissic/internal/feed"
+	"github.com/engvik/dissic/internal/lemmy"
+	"github.com/engvik/dissic/internal/library"
+	"github.com/engvik/dissic/internal/mastodon"
 	"github.com/engvik/dissic/internal/reddit"
+	"github.com/engvik/dissic/internal/sink"
+	"github.com/engvik/dissic/internal/source"
 	"github.com/engvik/dissic/internal/spotify"
+	"github.com/engvik/dissic/internal/state"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -26,24 +38,102 @@ func main() {
 
 	log.WithFields(log.Fields{"service": "dissic"}).Infof("dissic %s", cfg.Version)
 
+	// Apply changes made at runtime in earlier runs
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		log.Fatalf("error loading state: %s", err)
+	}
+
+	st.Apply(cfg)
+
 	// Set up spotify service
 	s, err := spotify.New(cfg)
 	if err != nil {
 		log.Fatalf("error creating spotify client: %s", err)
 	}
 
+	// Set up the sinks for playlists on other services. Requests to the
+	// other services and sources time out, so a hanging one can't block
+	// the posts behind it.
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	var sinks []sink.Sink
+	if cfg.Deezer.AccessToken != "" {
+		sinks = append(sinks, deezer.New(cfg.Deezer.AccessToken, httpClient))
+	}
+
+	if cfg.Library.Dir != "" {
+		l, err := library.New(cfg.Library.Dir, cfg.Library.PlaylistDir)
+		if err != nil {
+			log.Fatalf("error indexing music library: %s", err)
+		}
+
+		sinks = append(sinks, l)
+	}
+
 	// Set up reddit service
-	r, err := reddit.New(cfg, s.MusicChan)
+	music := make(chan spotify.Music)
+
+	r, err := reddit.New(cfg, music)
 	if err != nil {
 		log.Fatalf("error creating reddit client: %s", err)
 	}
 
+	for _, sub := range st.Paused {
+		r.Pause(sub)
+	}
+
 	// Set up http server
 	mux := http.NewServeMux()
 	mux.HandleFunc("/spotifyAuth", s.AuthHandler())
 
 	// Set up dissic service
 	d := dissic.New(cfg, s, r, mux)
+	d.Music = music
+	d.Sinks = sink.NewRouter(sinks...)
+
+	// Set up the RSS and Atom feeds, Lemmy communities and Mastodon
+	// timelines, picking up from the posts seen in earlier runs
+	d.Seen = source.NewSeen(st.Seen)
+
+	if len(cfg.RSS.Feeds) > 0 {
+		f := feed.New(cfg, music, httpClient)
+		f.Seen = d.Seen
+		d.Sources = append(d.Sources, f)
+	}
+
+	if len(cfg.Lemmy.Communities) > 0 {
+		l := lemmy.New(cfg, music, httpClient)
+		l.Seen = d.Seen
+		d.Sources = append(d.Sources, l)
+	}
+
+	if len(cfg.Mastodon.Timelines) > 0 {
+		m := mastodon.New(cfg, music, httpClient)
+		m.Seen = d.Seen
+		d.Sources = append(d.Sources, m)
+	}
+
+	for key, t := range st.Rotations {
+		d.Rotations[key] = t
+	}
+
+	// Set up management api
+	if cfg.APIToken != "" {
+		d.API = &http.Server{
+			Addr:    fmt.Sprintf(":%d", cfg.APIPort),
+			Handler: api.New(cfg.APIToken, d),
+		}
+	}
+
+	// Run a one-off command if given
+	if args := flag.Args(); len(args) > 0 {
+		if err := d.Command(ctx, args); err != nil {
+			log.Fatalf("error running command: %s", err)
+		}
+
+		return
+	}
 
 	// Start dissic service
 	d.Start(ctx)
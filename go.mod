@@ -4,6 +4,7 @@ go 1.14
 
 require (
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/mitchellh/mapstructure v1.1.2
 	github.com/pkg/browser v0.0.0-20180916011732-0a3d74bf9ce4
 	github.com/sirupsen/logrus v1.6.0
 	github.com/turnage/graw v0.0.0-20200404033202-65715eea1cd0
@@ -0,0 +1,88 @@
+// Package activity keeps a short in-memory log of what dissic has been
+// doing recently, e.g. which posts were matched and added to playlists.
+package activity
+
+import (
+	"sync"
+	"time"
+)
+
+// Statuses used for activity entries.
+const (
+	StatusAdded     = "added"
+	StatusUnmatched = "unmatched"
+	StatusFailed    = "failed"
+	StatusRejected  = "rejected"
+	StatusSkipped   = "skipped"
+)
+
+// Entry is a single activity log entry.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Subreddit string    `json:"subreddit"`
+	Title     string    `json:"title"`
+	Playlist  string    `json:"playlist,omitempty"`
+	TrackID   string    `json:"track_id,omitempty"`
+	Strategy  string    `json:"strategy,omitempty"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// Log is a fixed size log of activity entries. When full, the oldest
+// entries are dropped.
+type Log struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewLog returns a log holding at most size entries.
+func NewLog(size int) *Log {
+	return &Log{entries: make([]Entry, size)}
+}
+
+// Add adds an entry to the log. The time is set if missing.
+func (l *Log) Add(e Entry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) == 0 {
+		return
+	}
+
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Recent returns up to limit entries, newest first. A limit of zero or less
+// returns all entries.
+func (l *Log) Recent(limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+
+	res := make([]Entry, 0, limit)
+	for i := 1; i <= limit; i++ {
+		idx := (l.next - i + len(l.entries)) % len(l.entries)
+		res = append(res, l.entries[idx])
+	}
+
+	return res
+}
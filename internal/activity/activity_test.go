@@ -0,0 +1,68 @@
+package activity
+
+import "testing"
+
+func TestRecent(t *testing.T) {
+	tests := []struct {
+		n     string
+		size  int
+		add   []string
+		limit int
+		exp   []string
+	}{
+		{
+			"should return nothing from empty log",
+			3,
+			nil,
+			0,
+			[]string{},
+		},
+		{
+			"should return newest first",
+			3,
+			[]string{"a", "b"},
+			0,
+			[]string{"b", "a"},
+		},
+		{
+			"should drop oldest entries when full",
+			3,
+			[]string{"a", "b", "c", "d"},
+			0,
+			[]string{"d", "c", "b"},
+		},
+		{
+			"should respect limit",
+			3,
+			[]string{"a", "b", "c", "d"},
+			2,
+			[]string{"d", "c"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			l := NewLog(tc.size)
+
+			for _, title := range tc.add {
+				l.Add(Entry{Title: title})
+			}
+
+			entries := l.Recent(tc.limit)
+
+			if len(entries) != len(tc.exp) {
+				t.Fatalf("unexpected slice length: got %d, exp %d", len(entries), len(tc.exp))
+			}
+
+			for i, e := range entries {
+				if e.Title != tc.exp[i] {
+					t.Errorf("unexpected value: got %s, exp %s, pos %d", e.Title, tc.exp[i], i)
+				}
+
+				if e.Time.IsZero() {
+					t.Errorf("expected time to be set, pos %d", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Package api contains the JSON management API used to control dissic
+// at runtime, e.g. adding subreddits to playlists or pausing sources.
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/cache"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrNotFound is returned by a manager when a playlist or source doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Playlist describes a playlist and the sources feeding it.
+type Playlist struct {
+	Key     string   `json:"key"`
+	Name    string   `json:"name,omitempty"`
+	ID      string   `json:"id,omitempty"`
+	Sources []Source `json:"sources"`
+}
+
+// Source describes a source feeding a playlist, a subreddit, a feed, a
+// Lemmy community or a Mastodon timeline.
+type Source struct {
+	Subreddit string `json:"subreddit,omitempty"`
+	Feed      string `json:"feed,omitempty"`
+	Community string `json:"community,omitempty"`
+	Timeline  string `json:"timeline,omitempty"`
+	Paused    bool   `json:"paused"`
+}
+
+// Manager is what the API manages, implemented by the dissic service.
+type Manager interface {
+	Playlists() []Playlist
+	AddSubreddit(playlist string, subreddit string) error
+	RemoveSubreddit(playlist string, subreddit string) error
+	PauseSource(subreddit string) error
+	ResumeSource(subreddit string) error
+	Backfill(subreddit string, limit int) (int, error)
+	Activity(limit int) []activity.Entry
+	CacheStats() cache.Stats
+}
+
+// Handler serves the management API.
+type Handler struct {
+	Token   string
+	Manager Manager
+	Logger  *log.Entry
+}
+
+// New returns a new management API handler protected by token.
+func New(token string, m Manager) *Handler {
+	return &Handler{
+		Token:   token,
+		Manager: m,
+		Logger:  log.WithFields(log.Fields{"service": "api"}),
+	}
+}
+
+// ServeHTTP authenticates the request and routes it to the right endpoint.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api"), "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "playlists":
+		h.route(w, r, map[string]http.HandlerFunc{http.MethodGet: h.listPlaylists})
+	case len(parts) == 3 && parts[0] == "playlists" && parts[2] == "subreddits":
+		h.route(w, r, map[string]http.HandlerFunc{
+			http.MethodPost: func(w http.ResponseWriter, r *http.Request) { h.addSubreddit(w, r, parts[1]) },
+		})
+	case len(parts) == 4 && parts[0] == "playlists" && parts[2] == "subreddits":
+		h.route(w, r, map[string]http.HandlerFunc{
+			http.MethodDelete: func(w http.ResponseWriter, r *http.Request) { h.removeSubreddit(w, r, parts[1], parts[3]) },
+		})
+	case len(parts) == 3 && parts[0] == "sources":
+		h.route(w, r, map[string]http.HandlerFunc{
+			http.MethodPost: func(w http.ResponseWriter, r *http.Request) { h.sourceAction(w, r, parts[1], parts[2]) },
+		})
+	case len(parts) == 1 && parts[0] == "activity":
+		h.route(w, r, map[string]http.HandlerFunc{http.MethodGet: h.activity})
+	case len(parts) == 1 && parts[0] == "cache":
+		h.route(w, r, map[string]http.HandlerFunc{http.MethodGet: h.cacheStats})
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.Token == "" {
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+func (h *Handler) route(w http.ResponseWriter, r *http.Request, methods map[string]http.HandlerFunc) {
+	fn, ok := methods[r.Method]
+	if !ok {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	fn(w, r)
+}
+
+func (h *Handler) listPlaylists(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.Manager.Playlists())
+}
+
+func (h *Handler) addSubreddit(w http.ResponseWriter, r *http.Request, playlist string) {
+	var req struct {
+		Subreddit string `json:"subreddit"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Subreddit == "" {
+		writeError(w, http.StatusBadRequest, "body must contain a subreddit")
+		return
+	}
+
+	if err := h.Manager.AddSubreddit(playlist, req.Subreddit); err != nil {
+		h.writeManagerError(w, err)
+		return
+	}
+
+	h.Logger.Infof("added r/%s to playlist %s", req.Subreddit, playlist)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) removeSubreddit(w http.ResponseWriter, r *http.Request, playlist string, subreddit string) {
+	if err := h.Manager.RemoveSubreddit(playlist, subreddit); err != nil {
+		h.writeManagerError(w, err)
+		return
+	}
+
+	h.Logger.Infof("removed r/%s from playlist %s", subreddit, playlist)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) sourceAction(w http.ResponseWriter, r *http.Request, subreddit string, action string) {
+	switch action {
+	case "pause":
+		if err := h.Manager.PauseSource(subreddit); err != nil {
+			h.writeManagerError(w, err)
+			return
+		}
+	case "resume":
+		if err := h.Manager.ResumeSource(subreddit); err != nil {
+			h.writeManagerError(w, err)
+			return
+		}
+	case "backfill":
+		limit := 25
+		if l := r.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 || n > 100 {
+				writeError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+				return
+			}
+
+			limit = n
+		}
+
+		n, err := h.Manager.Backfill(subreddit, limit)
+		if err != nil {
+			h.writeManagerError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, map[string]int{"queued": n})
+		return
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	h.Logger.Infof("%s r/%s", action, subreddit)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) activity(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "limit must be a number")
+			return
+		}
+
+		limit = n
+	}
+
+	writeJSON(w, http.StatusOK, h.Manager.Activity(limit))
+}
+
+func (h *Handler) cacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.Manager.CacheStats())
+}
+
+func (h *Handler) writeManagerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.Logger.Errorf("request failed: %s", err)
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithFields(log.Fields{"service": "api"}).Errorf("encoding response: %s", err)
+	}
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/cache"
+)
+
+type testManager struct {
+	playlists map[string][]string
+	paused    map[string]bool
+	backfill  map[string]int
+}
+
+func newTestManager() *testManager {
+	return &testManager{
+		playlists: map[string][]string{"test": {"music"}},
+		paused:    make(map[string]bool),
+		backfill:  make(map[string]int),
+	}
+}
+
+func (m *testManager) Playlists() []Playlist {
+	var res []Playlist
+
+	for key, subs := range m.playlists {
+		p := Playlist{Key: key, Name: key}
+		for _, sub := range subs {
+			p.Sources = append(p.Sources, Source{Subreddit: sub, Paused: m.paused[sub]})
+		}
+
+		res = append(res, p)
+	}
+
+	return res
+}
+
+func (m *testManager) AddSubreddit(playlist string, subreddit string) error {
+	if _, ok := m.playlists[playlist]; !ok {
+		return fmt.Errorf("playlist %s: %w", playlist, ErrNotFound)
+	}
+
+	m.playlists[playlist] = append(m.playlists[playlist], subreddit)
+
+	return nil
+}
+
+func (m *testManager) RemoveSubreddit(playlist string, subreddit string) error {
+	subs, ok := m.playlists[playlist]
+	if !ok {
+		return fmt.Errorf("playlist %s: %w", playlist, ErrNotFound)
+	}
+
+	for i, sub := range subs {
+		if sub == subreddit {
+			m.playlists[playlist] = append(subs[:i], subs[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("subreddit %s: %w", subreddit, ErrNotFound)
+}
+
+func (m *testManager) PauseSource(subreddit string) error {
+	m.paused[subreddit] = true
+	return nil
+}
+
+func (m *testManager) ResumeSource(subreddit string) error {
+	delete(m.paused, subreddit)
+	return nil
+}
+
+func (m *testManager) Backfill(subreddit string, limit int) (int, error) {
+	m.backfill[subreddit] = limit
+	return limit, nil
+}
+
+func (m *testManager) Activity(limit int) []activity.Entry {
+	return []activity.Entry{{Subreddit: "music", Title: "Artist - Title", Status: activity.StatusAdded}}
+}
+
+func (m *testManager) CacheStats() cache.Stats {
+	return cache.Stats{Hits: 3, Misses: 1}
+}
+
+func do(t *testing.T, h http.Handler, method string, path string, token string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestAuthorization(t *testing.T) {
+	tests := []struct {
+		n        string
+		token    string
+		reqToken string
+		exp      int
+	}{
+		{"should reject missing token", "secret", "", http.StatusUnauthorized},
+		{"should reject wrong token", "secret", "wrong", http.StatusUnauthorized},
+		{"should reject when no token is configured", "", "", http.StatusUnauthorized},
+		{"should accept correct token", "secret", "secret", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			h := New(tc.token, newTestManager())
+			w := do(t, h, http.MethodGet, "/api/playlists", tc.reqToken, "")
+
+			if w.Code != tc.exp {
+				t.Errorf("unexpected status code: got %d, exp %d", w.Code, tc.exp)
+			}
+		})
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	m := newTestManager()
+	h := New("secret", m)
+
+	t.Run("should list playlists", func(t *testing.T) {
+		w := do(t, h, http.MethodGet, "/api/playlists", "secret", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status code: got %d, exp %d", w.Code, http.StatusOK)
+		}
+
+		var res []Playlist
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatalf("unexpected error decoding response: %s", err)
+		}
+
+		if len(res) != 1 || res[0].Key != "test" || len(res[0].Sources) != 1 {
+			t.Errorf("unexpected playlists: %+v", res)
+		}
+	})
+
+	t.Run("should add subreddit", func(t *testing.T) {
+		w := do(t, h, http.MethodPost, "/api/playlists/test/subreddits", "secret", `{"subreddit":"jazz"}`)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("unexpected status code: got %d, exp %d", w.Code, http.StatusNoContent)
+		}
+
+		if len(m.playlists["test"]) != 2 {
+			t.Errorf("subreddit not added: %v", m.playlists["test"])
+		}
+	})
+
+	t.Run("should reject adding without subreddit", func(t *testing.T) {
+		w := do(t, h, http.MethodPost, "/api/playlists/test/subreddits", "secret", `{}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("unexpected status code: got %d, exp %d", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("should return not found for unknown playlist", func(t *testing.T) {
+		w := do(t, h, http.MethodPost, "/api/playlists/unknown/subreddits", "secret", `{"subreddit":"jazz"}`)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("unexpected status code: got %d, exp %d", w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("should remove subreddit", func(t *testing.T) {
+		w := do(t, h, http.MethodDelete, "/api/playlists/test/subreddits/jazz", "secret", "")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("unexpected status code: got %d, exp %d", w.Code, http.StatusNoContent)
+		}
+
+		if len(m.playlists["test"]) != 1 {
+			t.Errorf("subreddit not removed: %v", m.playlists["test"])
+		}
+	})
+
+	t.Run("should pause and resume source", func(t *testing.T) {
+		w := do(t, h, http.MethodPost, "/api/sources/music/pause", "secret", "")
+		if w.Code != http.StatusNoContent || !m.paused["music"] {
+			t.Fatalf("source not paused: status %d", w.Code)
+		}
+
+		w = do(t, h, http.MethodPost, "/api/sources/music/resume", "secret", "")
+		if w.Code != http.StatusNoContent || m.paused["music"] {
+			t.Fatalf("source not resumed: status %d", w.Code)
+		}
+	})
+
+	t.Run("should trigger backfill", func(t *testing.T) {
+		w := do(t, h, http.MethodPost, "/api/sources/music/backfill?limit=10", "secret", "")
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("unexpected status code: got %d, exp %d", w.Code, http.StatusAccepted)
+		}
+
+		if m.backfill["music"] != 10 {
+			t.Errorf("unexpected backfill limit: got %d, exp %d", m.backfill["music"], 10)
+		}
+	})
+
+	t.Run("should reject invalid backfill limit", func(t *testing.T) {
+		w := do(t, h, http.MethodPost, "/api/sources/music/backfill?limit=1000", "secret", "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("unexpected status code: got %d, exp %d", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("should list activity", func(t *testing.T) {
+		w := do(t, h, http.MethodGet, "/api/activity", "secret", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status code: got %d, exp %d", w.Code, http.StatusOK)
+		}
+
+		var res []activity.Entry
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatalf("unexpected error decoding response: %s", err)
+		}
+
+		if len(res) != 1 {
+			t.Errorf("unexpected slice length: got %d, exp %d", len(res), 1)
+		}
+	})
+
+	t.Run("should show cache stats", func(t *testing.T) {
+		w := do(t, h, http.MethodGet, "/api/cache", "secret", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status code: got %d, exp %d", w.Code, http.StatusOK)
+		}
+
+		var res cache.Stats
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatalf("unexpected error decoding response: %s", err)
+		}
+
+		if res.Hits != 3 || res.Misses != 1 {
+			t.Errorf("unexpected stats: %+v", res)
+		}
+	})
+
+	t.Run("should reject wrong method", func(t *testing.T) {
+		w := do(t, h, http.MethodDelete, "/api/playlists", "secret", "")
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("unexpected status code: got %d, exp %d", w.Code, http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+// Package cache persists Spotify search results and titles that never
+// matched, so songs posted again, or to several subreddits, don't cost
+// another round of searches.
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// version is the version of the cache file format. It's raised when
+// fields are added to Track, so results cached without them are dropped
+// instead of being read as unknown.
+const version = 3
+
+// Track is a search result as cached. Only what matching, the block and
+// allow lists and playlist filters need is kept.
+type Track struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Artists     []Artist `json:"artists"`
+	Album       string   `json:"album,omitempty"`
+	AlbumID     string   `json:"album_id,omitempty"`
+	ReleaseDate string   `json:"release_date,omitempty"`
+	ISRC        string   `json:"isrc,omitempty"`
+	Explicit    bool     `json:"explicit,omitempty"`
+	Popularity  int      `json:"popularity,omitempty"`
+	DurationMs  int      `json:"duration_ms,omitempty"`
+	// AvailableMarkets are the markets the track can be played in, when
+	// Spotify lists them.
+	AvailableMarkets []string `json:"available_markets,omitempty"`
+}
+
+// Artist is an artist of a cached track.
+type Artist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type result struct {
+	Tracks  []Track   `json:"tracks"`
+	Expires time.Time `json:"expires"`
+}
+
+// file is the cache file content.
+type file struct {
+	Version   int                  `json:"version"`
+	Results   map[string]result    `json:"results"`
+	Unmatched map[string]time.Time `json:"unmatched"`
+}
+
+// Stats are the cache hit and miss counts since start, and its size.
+type Stats struct {
+	Hits          int `json:"hits"`
+	Misses        int `json:"misses"`
+	UnmatchedHits int `json:"unmatched_hits"`
+	Results       int `json:"results"`
+	Unmatched     int `json:"unmatched"`
+}
+
+// Cache is a search result cache. Results are kept for TTL and unmatched
+// titles for UnmatchedTTL; a zero TTL disables that part of the cache.
+// It's safe for concurrent use.
+type Cache struct {
+	TTL          time.Duration
+	UnmatchedTTL time.Duration
+
+	mu    sync.Mutex
+	path  string
+	data  file
+	stats Stats
+	dirty bool
+	now   func() time.Time
+}
+
+// Load reads the cache file at path, dropping expired entries. A missing
+// file, or one written in an older format, results in an empty cache, and
+// an empty path in a cache that's never saved.
+func Load(path string, ttl time.Duration, unmatchedTTL time.Duration) (*Cache, error) {
+	c := Cache{
+		TTL:          ttl,
+		UnmatchedTTL: unmatchedTTL,
+		path:         path,
+		now:          time.Now,
+	}
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("reading cache file: %s, %w", path, err)
+		}
+
+		if err == nil {
+			if err := json.Unmarshal(data, &c.data); err != nil {
+				return nil, fmt.Errorf("unmarshal cache file: %s, %w", path, err)
+			}
+		}
+	}
+
+	if c.data.Version != version {
+		c.data = file{Version: version}
+	}
+
+	if c.data.Results == nil {
+		c.data.Results = make(map[string]result)
+	}
+
+	if c.data.Unmatched == nil {
+		c.data.Unmatched = make(map[string]time.Time)
+	}
+
+	c.prune()
+
+	return &c, nil
+}
+
+// Key normalizes a search query or title for use as a cache key.
+func Key(parts ...string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.Join(parts, " ")), " "))
+}
+
+// Results returns the cached results for a key, if any.
+func (c *Cache) Results(key string) ([]Track, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.data.Results[key]
+	if !ok || !c.now().Before(r.Expires) {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+
+	return r.Tracks, true
+}
+
+// AddResults caches the results for a key.
+func (c *Cache) AddResults(key string, tracks []Track) {
+	if c.TTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data.Results[key] = result{Tracks: tracks, Expires: c.now().Add(c.TTL)}
+	c.dirty = true
+}
+
+// IsUnmatched reports whether a title recently failed to match.
+func (c *Cache) IsUnmatched(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, ok := c.data.Unmatched[key]
+	if !ok || !c.now().Before(expires) {
+		return false
+	}
+
+	c.stats.UnmatchedHits++
+
+	return true
+}
+
+// AddUnmatched remembers a title that failed to match.
+func (c *Cache) AddUnmatched(key string) {
+	if c.UnmatchedTTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data.Unmatched[key] = c.now().Add(c.UnmatchedTTL)
+	c.dirty = true
+}
+
+// Stats returns the cache stats.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Results = len(c.data.Results)
+	s.Unmatched = len(c.data.Unmatched)
+
+	return s
+}
+
+// Save writes the cache to its file if it has changed, dropping expired
+// entries. The file is replaced atomically.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+
+	c.prune()
+
+	data, err := json.Marshal(c.data)
+	if err != nil {
+		return fmt.Errorf("marshal cache: %w", err)
+	}
+
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing cache file: %s, %w", tmp, err)
+	}
+
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("replacing cache file: %s, %w", c.path, err)
+	}
+
+	c.dirty = false
+
+	return nil
+}
+
+func (c *Cache) prune() {
+	now := c.now()
+
+	for k, r := range c.data.Results {
+		if !now.Before(r.Expires) {
+			delete(c.data.Results, k)
+		}
+	}
+
+	for k, expires := range c.data.Unmatched {
+		if !now.Before(expires) {
+			delete(c.data.Unmatched, k)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dissic-cache")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cache.json")
+	now := time.Now()
+
+	c, err := Load(path, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error loading: %s", err)
+	}
+	c.now = func() time.Time { return now }
+
+	key := Key("artist:\"Daft Punk\"  track:\"Around the World\"", "NO")
+	tracks := []Track{{ID: "track1", Name: "Around the World", Artists: []Artist{{ID: "daftpunk", Name: "Daft Punk"}}}}
+
+	t.Run("should miss empty cache", func(t *testing.T) {
+		if _, ok := c.Results(key); ok {
+			t.Errorf("unexpected hit")
+		}
+
+		if c.IsUnmatched("unknown - unknown") {
+			t.Errorf("unexpected unmatched hit")
+		}
+	})
+
+	c.AddResults(key, tracks)
+	c.AddUnmatched("unknown - unknown")
+
+	t.Run("should hit cached results", func(t *testing.T) {
+		got, ok := c.Results(Key("ARTIST:\"daft punk\" track:\"around the world\" no"))
+		if !ok {
+			t.Fatalf("expected hit")
+		}
+
+		if len(got) != 1 || got[0].ID != "track1" {
+			t.Errorf("unexpected results: %+v", got)
+		}
+
+		if !c.IsUnmatched("unknown - unknown") {
+			t.Errorf("expected unmatched hit")
+		}
+	})
+
+	t.Run("should persist", func(t *testing.T) {
+		if err := c.Save(); err != nil {
+			t.Fatalf("unexpected error saving: %s", err)
+		}
+
+		loaded, err := Load(path, time.Hour, time.Minute)
+		if err != nil {
+			t.Fatalf("unexpected error loading: %s", err)
+		}
+
+		if _, ok := loaded.Results(key); !ok {
+			t.Errorf("expected hit after load")
+		}
+	})
+
+	t.Run("should expire entries", func(t *testing.T) {
+		now = now.Add(2 * time.Minute)
+
+		if _, ok := c.Results(key); !ok {
+			t.Errorf("expected hit before ttl")
+		}
+
+		if c.IsUnmatched("unknown - unknown") {
+			t.Errorf("unexpected unmatched hit after ttl")
+		}
+
+		now = now.Add(time.Hour)
+
+		if _, ok := c.Results(key); ok {
+			t.Errorf("unexpected hit after ttl")
+		}
+	})
+
+	t.Run("should count hits and misses", func(t *testing.T) {
+		exp := Stats{Hits: 2, Misses: 2, UnmatchedHits: 1, Results: 1, Unmatched: 1}
+		if got := c.Stats(); got != exp {
+			t.Errorf("unexpected stats: got %+v, exp %+v", got, exp)
+		}
+	})
+}
+
+func TestLoadOlderVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dissic-cache")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cache.json")
+	expires := time.Now().Add(time.Hour).Format(time.RFC3339)
+	data := `{"results": {"key": {"tracks": [{"id": "track1", "name": "Around the World", "artists": [{"id": "daftpunk", "name": "Daft Punk"}]}], "expires": "` + expires + `"}}, "unmatched": {"unknown": "` + expires + `"}}`
+
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+
+	c, err := Load(path, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error loading: %s", err)
+	}
+
+	if _, ok := c.Results("key"); ok {
+		t.Errorf("unexpected hit for results cached without a version")
+	}
+
+	if c.IsUnmatched("unknown") {
+		t.Errorf("unexpected unmatched hit for titles cached without a version")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	c, err := Load("", 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error loading: %s", err)
+	}
+
+	c.AddResults("key", []Track{{ID: "track1"}})
+	c.AddUnmatched("key")
+
+	if _, ok := c.Results("key"); ok {
+		t.Errorf("unexpected hit")
+	}
+
+	if c.IsUnmatched("key") {
+		t.Errorf("unexpected unmatched hit")
+	}
+
+	if err := c.Save(); err != nil {
+		t.Errorf("unexpected error saving: %s", err)
+	}
+}
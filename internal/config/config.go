@@ -7,7 +7,15 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/engvik/dissic/internal/scheduler"
+	"github.com/engvik/dissic/internal/source"
 	"github.com/kelseyhightower/envconfig"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
@@ -19,17 +27,33 @@ type environment struct {
 	RedditUsername      string `envconfig:"REDDIT_USERNAME"`
 	SpotifyClientID     string `envconfig:"SPOTIFY_CLIENT_ID"`
 	SpotifyClientSecret string `envconfig:"SPOTIFY_CLIENT_SECRET"`
+	DeezerAccessToken   string `envconfig:"DEEZER_ACCESS_TOKEN"`
 	ConfigFile          string `envconfig:"DISSIC_CONFIG"`
+	APIToken            string `envconfig:"DISSIC_API_TOKEN"`
 }
 
 // Config holds the entire dissic config (config.yaml and env vars)
 type Config struct {
-	Reddit              Reddit     `yaml:"reddit"`
-	Spotify             Spotify    `yaml:"spotify"`
-	Playlists           []Playlist `yaml:"playlists"`
-	HTTPPort            int        `yaml:"http-port"`
-	Verbose             bool       `yaml:"verbose"`
-	AuthOpenBrowser     bool       `yaml:"auth-open-browser"`
+	Reddit          Reddit     `yaml:"reddit"`
+	Spotify         Spotify    `yaml:"spotify"`
+	Deezer          Deezer     `yaml:"deezer"`
+	Library         Library    `yaml:"library"`
+	RSS             RSS        `yaml:"rss"`
+	Lemmy           Lemmy      `yaml:"lemmy"`
+	Mastodon        Mastodon   `yaml:"mastodon"`
+	Playlists       []Playlist `yaml:"playlists"`
+	HTTPPort        int        `yaml:"http-port"`
+	Verbose         bool       `yaml:"verbose"`
+	AuthOpenBrowser bool       `yaml:"auth-open-browser"`
+	APIToken        string     `yaml:"api-token"`
+	APIPort         int        `yaml:"api-port"`
+	StateFile       string     `yaml:"state-file"`
+	HistoryFile     string     `yaml:"history-file"`
+	Block           Lists      `yaml:"block"`
+	Allow           Lists      `yaml:"allow"`
+	// ReconcileInterval is how often the playlists are compared with the
+	// history to suppress the tracks removed by users.
+	ReconcileInterval   scheduler.Interval `yaml:"reconcile-interval"`
 	Version             string
 	PlaylistDescription string
 }
@@ -47,13 +71,433 @@ type Reddit struct {
 type Spotify struct {
 	ClientID     string `yaml:"client-id"`
 	ClientSecret string `yaml:"client-secret"`
+	SearchLimit  int    `yaml:"search-limit"`
+	Market       string `yaml:"market"`
+	CacheFile    string `yaml:"cache-file"`
+	CacheTTL     int    `yaml:"cache-ttl"`
+	UnmatchedTTL int    `yaml:"unmatched-ttl"`
+}
+
+// Deezer holds the Deezer related configuration.
+type Deezer struct {
+	// AccessToken is an OAuth token with the manage_library and
+	// offline_access permissions.
+	AccessToken string `yaml:"access-token"`
+}
+
+// Library holds the configuration of the local music library.
+type Library struct {
+	// Dir is the music folder matched against.
+	Dir string `yaml:"dir"`
+	// PlaylistDir is where the M3U8 playlists are kept, defaults to Dir.
+	PlaylistDir string `yaml:"playlist-dir"`
+}
+
+// RSS holds the RSS and Atom feed configuration.
+type RSS struct {
+	// Interval is the number of minutes between each time the feeds are
+	// checked for new items.
+	Interval int    `yaml:"interval"`
+	Feeds    []Feed `yaml:"feeds"`
+}
+
+// Feed is an RSS or Atom feed, like a music blog or a Bandcamp feed.
+// Playlists refer to it by name.
+type Feed struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// Lemmy holds the Lemmy configuration.
+type Lemmy struct {
+	// Interval is the number of minutes between each time the communities
+	// are checked for new posts.
+	Interval    int         `yaml:"interval"`
+	Communities []Community `yaml:"communities"`
+}
+
+// Community is a Lemmy community. Playlists refer to it by name, which
+// defaults to the name of the community.
+type Community struct {
+	Name string `yaml:"name"`
+	// Instance is the URL of the Lemmy instance the community is read
+	// through.
+	Instance string `yaml:"instance"`
+	// Community is the name of the community, like "music", or
+	// "music@lemmy.ml" for a community on another instance.
+	Community string `yaml:"community"`
+	// Sort is the order the posts are listed in, New by default.
+	Sort string `yaml:"sort"`
+}
+
+// lemmySorts are the post orders Lemmy lists posts in.
+var lemmySorts = []string{
+	"Active", "Hot", "New", "Old", "Scaled", "Controversial", "MostComments", "NewComments",
+	"TopHour", "TopSixHour", "TopTwelveHour", "TopDay", "TopWeek", "TopMonth",
+	"TopThreeMonths", "TopSixMonths", "TopNineMonths", "TopYear", "TopAll",
+}
+
+// Mastodon holds the Mastodon configuration.
+type Mastodon struct {
+	// Interval is the number of minutes between each time the timelines
+	// are checked for new statuses.
+	Interval  int        `yaml:"interval"`
+	Timelines []Timeline `yaml:"timelines"`
+}
+
+// Timeline is a hashtag timeline or the statuses of an account on a
+// Mastodon compatible instance. Playlists refer to it by name, which
+// defaults to the hashtag or account.
+type Timeline struct {
+	Name string `yaml:"name"`
+	// Instance is the URL of the instance the timeline is read from.
+	Instance string `yaml:"instance"`
+	// Hashtag is the hashtag followed, like "NowPlaying". A timeline
+	// follows either a hashtag or an account.
+	Hashtag string `yaml:"hashtag"`
+	// Account is the account followed, like "user", or "user@example.com"
+	// for an account on another instance.
+	Account string `yaml:"account"`
+	// AccessToken is only needed for instances that don't serve their
+	// timelines publicly.
+	AccessToken string          `yaml:"access-token"`
+	Filters     TimelineFilters `yaml:"filters"`
+}
+
+// TimelineFilters leave statuses of a timeline out before they're matched.
+type TimelineFilters struct {
+	ExcludeReplies bool `yaml:"exclude-replies"`
+	// ExcludeReblogs leaves out the statuses an account boosts.
+	ExcludeReblogs bool `yaml:"exclude-reblogs"`
+	// Languages are the languages of the statuses passed on, all if empty.
+	Languages []string `yaml:"languages"`
+	// RequireLink leaves out statuses without a link to a music service.
+	RequireLink bool `yaml:"require-link"`
 }
 
 // Playlist contains the playlist configuration
 type Playlist struct {
-	Name       string   `yaml:"name"`
-	ID         string   `yaml:"id"`
+	Name string `yaml:"name"`
+	ID   string `yaml:"id"`
+	// Service is the music service the playlist is kept on. Playlists on
+	// services other than Spotify only support the name, ID, sources and
+	// a static description, and have every track posted added.
+	Service    string   `yaml:"service"`
 	Subreddits []string `yaml:"subreddits"`
+	// Feeds are the names of the RSS and Atom feeds feeding the playlist.
+	Feeds []string `yaml:"feeds"`
+	// Communities are the names of the Lemmy communities feeding the
+	// playlist.
+	Communities []string `yaml:"communities"`
+	// Timelines are the names of the Mastodon timelines feeding the
+	// playlist.
+	Timelines []string `yaml:"timelines"`
+	Filters   Filters  `yaml:"filters"`
+	Block     Lists    `yaml:"block"`
+	Allow     Lists    `yaml:"allow"`
+	// DedupeScope is the playlists a track already added to keeps it out
+	// of this one: only this playlist, the playlists in DedupeGroup, or
+	// all playlists.
+	DedupeScope string `yaml:"dedupe-scope"`
+	DedupeGroup string `yaml:"dedupe-group"`
+	// DedupeAction is whether a track already in another playlist in the
+	// scope is skipped, or moved to this playlist.
+	DedupeAction string `yaml:"dedupe-action"`
+	// Rotate archives the playlist and starts a new empty one at the
+	// start of every day, week or month.
+	Rotate scheduler.Interval `yaml:"rotate"`
+	// RotateMode is whether the playlist is renamed to the archive name,
+	// or its tracks copied to a new playlist with the archive name.
+	RotateMode string `yaml:"rotate-mode"`
+	// ArchiveName is the name template of archived playlists. {name},
+	// {subreddits} and {period} are replaced.
+	ArchiveName string `yaml:"archive-name"`
+	// Type is "digest" for a playlist rebuilt at the start of every
+	// Period from the top scoring posts of the previous one, instead of
+	// having tracks added as they're posted.
+	Type   string             `yaml:"type"`
+	Period scheduler.Interval `yaml:"period"`
+	// Size is the number of tracks in a digest.
+	Size int `yaml:"size"`
+	// Order is the order of the tracks. New tracks are appended by
+	// default, or inserted first with "newest". The other orders are
+	// applied at the start of every OrderInterval.
+	Order         string             `yaml:"order"`
+	OrderInterval scheduler.Interval `yaml:"order-interval"`
+	// Public and Collaborative are set on the playlist on startup, when
+	// configured. A collaborative playlist can't be public.
+	Public        *bool `yaml:"public"`
+	Collaborative *bool `yaml:"collaborative"`
+	// Description is the description template. {name}, {subreddits},
+	// {updated} and {tracks} are replaced, and the description is
+	// refreshed every DescriptionInterval.
+	Description         string             `yaml:"description"`
+	DescriptionInterval scheduler.Interval `yaml:"description-interval"`
+	// Cover is the path to a JPEG cover image, or "generate" for a
+	// generated one. It's uploaded if the playlist has no image.
+	Cover string `yaml:"cover"`
+}
+
+// Music services.
+const (
+	ServiceSpotify = "spotify"
+	ServiceDeezer  = "deezer"
+	ServiceLocal   = "local"
+)
+
+// IsSpotify reports whether the playlist is kept on Spotify, the default
+// service.
+func (p *Playlist) IsSpotify() bool {
+	return p.Service == "" || p.Service == ServiceSpotify
+}
+
+// UsesService reports whether any playlist is kept on a service.
+func (c *Config) UsesService(service string) bool {
+	for _, p := range c.Playlists {
+		if p.Service == service || (service == ServiceSpotify && p.IsSpotify()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sources returns the keys of the subreddits, feeds, communities and
+// timelines feeding the playlist, which posts are routed to it by.
+func (p *Playlist) Sources() []string {
+	keys := make([]string, 0, len(p.Subreddits)+len(p.Feeds)+len(p.Communities)+len(p.Timelines))
+	for _, sub := range p.Subreddits {
+		keys = append(keys, strings.ToLower(sub))
+	}
+
+	for _, feed := range p.Feeds {
+		keys = append(keys, source.Key(source.RSS, feed))
+	}
+
+	for _, community := range p.Communities {
+		keys = append(keys, source.Key(source.Lemmy, community))
+	}
+
+	for _, timeline := range p.Timelines {
+		keys = append(keys, source.Key(source.Mastodon, timeline))
+	}
+
+	return keys
+}
+
+// CoverGenerate is the cover of playlists with a generated cover image.
+const CoverGenerate = "generate"
+
+// RenderDescription returns the playlist description with the
+// placeholders replaced.
+func (p *Playlist) RenderDescription(updated time.Time, tracks int) string {
+	r := strings.NewReplacer(
+		"{name}", p.Name,
+		"{subreddits}", p.subredditList(),
+		"{updated}", updated.Format("2006-01-02"),
+		"{tracks}", strconv.Itoa(tracks),
+	)
+
+	return r.Replace(p.Description)
+}
+
+// RefreshesDescription reports whether the description has placeholders
+// and is refreshed on a schedule.
+func (p *Playlist) RefreshesDescription() bool {
+	return strings.Contains(p.Description, "{")
+}
+
+// Playlist orders.
+const (
+	OrderAdded       = "added"
+	OrderNewest      = "newest"
+	OrderScore       = "score"
+	OrderReleaseDate = "release-date"
+	OrderShuffle     = "shuffle"
+)
+
+// Reorders reports whether the playlist is reordered on a schedule.
+func (p *Playlist) Reorders() bool {
+	switch p.Order {
+	case OrderScore, OrderReleaseDate, OrderShuffle:
+		return !p.IsDigest()
+	}
+
+	return false
+}
+
+// PlaylistDigest is the type of digest playlists.
+const PlaylistDigest = "digest"
+
+// IsDigest reports whether the playlist is a digest.
+func (p *Playlist) IsDigest() bool {
+	return p.Type == PlaylistDigest
+}
+
+// Rotate modes.
+const (
+	RotateRename = "rename"
+	RotateCopy   = "copy"
+)
+
+// Dedupe scopes.
+const (
+	DedupePlaylist = "playlist"
+	DedupeGroup    = "group"
+	DedupeAll      = "all"
+)
+
+// Dedupe actions.
+const (
+	DedupeSkip = "skip"
+	DedupeMove = "move"
+)
+
+// Lists are artists, tracks and title patterns to block or allow. Tracks
+// matching a block list are never added. When an allow list isn't empty,
+// only tracks matching it are added.
+type Lists struct {
+	// Artists are Spotify artist IDs or names.
+	Artists []string `yaml:"artists"`
+	// Tracks are Spotify track IDs.
+	Tracks []string `yaml:"tracks"`
+	// Titles are regular expressions matched against post titles and
+	// "artist - track" of the matched track.
+	Titles []string `yaml:"titles"`
+}
+
+// IsEmpty reports whether the lists have no entries.
+func (l Lists) IsEmpty() bool {
+	return len(l.Artists) == 0 && len(l.Tracks) == 0 && len(l.Titles) == 0
+}
+
+// AddArtist adds an artist to the lists unless already there, and reports
+// whether it was added.
+func (l *Lists) AddArtist(artist string) bool {
+	for _, a := range l.Artists {
+		if strings.EqualFold(a, artist) {
+			return false
+		}
+	}
+
+	l.Artists = append(l.Artists, artist)
+
+	return true
+}
+
+func (l Lists) validate() error {
+	for _, t := range l.Titles {
+		if _, err := regexp.Compile(t); err != nil {
+			return fmt.Errorf("invalid title pattern: %s, %w", t, err)
+		}
+	}
+
+	return nil
+}
+
+// Filters are constraints a matched track must meet to be added to a
+// playlist. Unset filters don't constrain anything.
+type Filters struct {
+	Year             Range `yaml:"year"`
+	Explicit         *bool `yaml:"explicit"`
+	Popularity       Range `yaml:"popularity"`
+	Duration         Range `yaml:"duration"`
+	Tempo            Range `yaml:"tempo"`
+	Energy           Range `yaml:"energy"`
+	Danceability     Range `yaml:"danceability"`
+	Valence          Range `yaml:"valence"`
+	Instrumentalness Range `yaml:"instrumentalness"`
+}
+
+// NeedsAudioFeatures reports whether any audio feature filters are set.
+func (f Filters) NeedsAudioFeatures() bool {
+	for _, r := range []Range{f.Tempo, f.Energy, f.Danceability, f.Valence, f.Instrumentalness} {
+		if !r.IsZero() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Range is an inclusive range. A zero min or max is unbounded.
+type Range struct {
+	Min float64 `yaml:"min"`
+	Max float64 `yaml:"max"`
+}
+
+// IsZero reports whether the range is unbounded.
+func (r Range) IsZero() bool {
+	return r.Min == 0 && r.Max == 0
+}
+
+// Contains reports whether v is in the range.
+func (r Range) Contains(v float64) bool {
+	return (r.Min == 0 || v >= r.Min) && (r.Max == 0 || v <= r.Max)
+}
+
+func (r Range) String() string {
+	switch {
+	case r.Max == 0:
+		return fmt.Sprintf("%g-", r.Min)
+	case r.Min == 0:
+		return fmt.Sprintf("-%g", r.Max)
+	default:
+		return fmt.Sprintf("%g-%g", r.Min, r.Max)
+	}
+}
+
+func (f Filters) validate() error {
+	ranges := map[string]Range{
+		"year": f.Year, "popularity": f.Popularity, "duration": f.Duration, "tempo": f.Tempo,
+		"energy": f.Energy, "danceability": f.Danceability, "valence": f.Valence,
+		"instrumentalness": f.Instrumentalness,
+	}
+
+	for name, r := range ranges {
+		if r.Min < 0 || r.Max < 0 || (r.Max != 0 && r.Min > r.Max) {
+			return fmt.Errorf("invalid %s filter: %s", name, r)
+		}
+	}
+
+	for name, r := range map[string]Range{"energy": f.Energy, "danceability": f.Danceability, "valence": f.Valence, "instrumentalness": f.Instrumentalness} {
+		if r.Min > 1 || r.Max > 1 {
+			return fmt.Errorf("invalid %s filter, must be between 0 and 1: %s", name, r)
+		}
+	}
+
+	return nil
+}
+
+// Archive returns the name of the archive of the playlist for a period.
+func (p *Playlist) Archive(period string) string {
+	r := strings.NewReplacer(
+		"{name}", p.Name,
+		"{subreddits}", p.subredditList(),
+		"{period}", period,
+	)
+
+	return r.Replace(p.ArchiveName)
+}
+
+// subredditList returns the subreddits as "r/music, r/jazz".
+func (p *Playlist) subredditList() string {
+	subreddits := make([]string, 0, len(p.Subreddits))
+	for _, sub := range p.Subreddits {
+		subreddits = append(subreddits, "r/"+sub)
+	}
+
+	return strings.Join(subreddits, ", ")
+}
+
+// Key returns the key identifying the playlist. It's the Spotify ID if
+// provided, otherwise the name.
+func (p *Playlist) Key() string {
+	if p.ID != "" {
+		return p.ID
+	}
+
+	return p.Name
 }
 
 // Load reads config from file and environment variables. It also adds
@@ -98,11 +542,168 @@ func Load() (*Config, error) {
 	return &cfg, nil
 }
 
+// Playlist returns the playlist identified by key, or nil if there is none.
+func (c *Config) Playlist(key string) *Playlist {
+	for i := range c.Playlists {
+		if c.Playlists[i].Key() == key {
+			return &c.Playlists[i]
+		}
+	}
+
+	return nil
+}
+
+// UpdateSubreddits refreshes the list of subreddits to watch after the
+// playlists have been changed.
+func (c *Config) UpdateSubreddits() {
+	c.Reddit.Subreddits = c.getSubreddits()
+}
+
+// CleanSubreddit normalizes a subreddit name by removing the r/ prefix
+// and lower casing it.
+func CleanSubreddit(sub string) string {
+	sub = strings.TrimPrefix(strings.TrimSpace(sub), "/")
+	sub = strings.TrimPrefix(sub, "r/")
+
+	return strings.ToLower(sub)
+}
+
+func (c *Config) hasFeed(name string) bool {
+	for _, f := range c.RSS.Feeds {
+		if strings.EqualFold(f.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Config) hasCommunity(name string) bool {
+	for _, community := range c.Lemmy.Communities {
+		if strings.EqualFold(community.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Config) hasTimeline(name string) bool {
+	for _, t := range c.Mastodon.Timelines {
+		if strings.EqualFold(t.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Mastodon) validate() error {
+	if m.Interval < 0 {
+		return errors.New("mastodon interval must be 1 or higher")
+	}
+
+	seen := make(map[string]bool)
+
+	for i, t := range m.Timelines {
+		if (t.Hashtag == "") == (t.Account == "") {
+			return fmt.Errorf("mastodon timeline number %d must have either a hashtag or an account", i)
+		}
+
+		if u, err := url.Parse(t.Instance); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("mastodon timeline %s has invalid instance: %s", t.Name, t.Instance)
+		}
+
+		name := strings.ToLower(t.Name)
+		if seen[name] {
+			return fmt.Errorf("mastodon timeline %s is configured more than once", t.Name)
+		}
+
+		seen[name] = true
+	}
+
+	return nil
+}
+
+func (l *Lemmy) validate() error {
+	if l.Interval < 0 {
+		return errors.New("lemmy interval must be 1 or higher")
+	}
+
+	seen := make(map[string]bool)
+
+	for i, c := range l.Communities {
+		if c.Community == "" || c.Instance == "" {
+			return fmt.Errorf("lemmy community number %d is missing community or instance", i)
+		}
+
+		if u, err := url.Parse(c.Instance); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("lemmy community %s has invalid instance: %s", c.Name, c.Instance)
+		}
+
+		if !validLemmySort(c.Sort) {
+			return fmt.Errorf("lemmy community %s has invalid sort: %s", c.Name, c.Sort)
+		}
+
+		name := strings.ToLower(c.Name)
+		if seen[name] {
+			return fmt.Errorf("lemmy community %s is configured more than once", c.Name)
+		}
+
+		seen[name] = true
+	}
+
+	return nil
+}
+
+func validLemmySort(sort string) bool {
+	for _, s := range lemmySorts {
+		if s == sort {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *RSS) validate() error {
+	if r.Interval < 0 {
+		return errors.New("rss interval must be 1 or higher")
+	}
+
+	seen := make(map[string]bool)
+
+	for i, f := range r.Feeds {
+		if f.Name == "" || f.URL == "" {
+			return fmt.Errorf("feed number %d is missing name or url", i)
+		}
+
+		if u, err := url.Parse(f.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("feed %s has invalid url: %s", f.Name, f.URL)
+		}
+
+		name := strings.ToLower(f.Name)
+		if seen[name] {
+			return fmt.Errorf("feed %s is configured more than once", f.Name)
+		}
+
+		seen[name] = true
+	}
+
+	return nil
+}
+
 func (c *Config) getSubreddits() []string {
 	var subs []string
+	seen := make(map[string]bool)
 
 	for _, p := range c.Playlists {
 		for _, sub := range p.Subreddits {
+			if seen[sub] {
+				continue
+			}
+
+			seen[sub] = true
 			subs = append(subs, sub)
 		}
 	}
@@ -122,6 +723,14 @@ func (c *Config) addEnvironment(e environment) {
 	if c.Spotify.ClientSecret == "" {
 		c.Spotify.ClientSecret = e.SpotifyClientSecret
 	}
+
+	if c.Deezer.AccessToken == "" {
+		c.Deezer.AccessToken = e.DeezerAccessToken
+	}
+
+	if c.APIToken == "" {
+		c.APIToken = e.APIToken
+	}
 }
 
 func (c *Config) validate() error {
@@ -133,22 +742,170 @@ func (c *Config) validate() error {
 		return errors.New("reddit request rate must be 2 or higher")
 	}
 
-	if c.Spotify.ClientID == "" {
+	if c.APIToken != "" && c.APIPort == c.HTTPPort {
+		return errors.New("api port must differ from http port")
+	}
+
+	// spotify credentials are only needed for playlists on spotify
+	if c.Spotify.ClientID == "" && c.UsesService(ServiceSpotify) {
 		return errors.New("spotify client id is missing")
 	}
 
-	if c.Spotify.ClientSecret == "" {
+	if c.Spotify.ClientSecret == "" && c.UsesService(ServiceSpotify) {
 		return errors.New("spotify client secret is missing")
 	}
 
+	if c.Spotify.SearchLimit < 1 || c.Spotify.SearchLimit > 50 {
+		return errors.New("spotify search limit must be between 1 and 50")
+	}
+
+	if c.Spotify.Market != "" && len(c.Spotify.Market) != 2 {
+		return errors.New("spotify market must be a two letter country code")
+	}
+
 	for i, p := range c.Playlists {
 		if p.ID == "" && p.Name == "" {
 			return fmt.Errorf("playlist number %d is missing ID or name", i)
 		}
 
-		if len(p.Subreddits) <= 0 {
-			return fmt.Errorf("no subreddits passed to playlist number %d", i)
+		if len(p.Sources()) <= 0 {
+			return fmt.Errorf("no subreddits, feeds, communities or timelines passed to playlist number %d", i)
+		}
+
+		for _, feed := range p.Feeds {
+			if !c.hasFeed(feed) {
+				return fmt.Errorf("playlist number %d has unknown feed: %s", i, feed)
+			}
+		}
+
+		for _, community := range p.Communities {
+			if !c.hasCommunity(community) {
+				return fmt.Errorf("playlist number %d has unknown community: %s", i, community)
+			}
+		}
+
+		for _, timeline := range p.Timelines {
+			if !c.hasTimeline(timeline) {
+				return fmt.Errorf("playlist number %d has unknown timeline: %s", i, timeline)
+			}
+		}
+
+		if err := p.Filters.validate(); err != nil {
+			return fmt.Errorf("playlist number %d: %w", i, err)
+		}
+
+		if err := p.Block.validate(); err != nil {
+			return fmt.Errorf("playlist number %d block list: %w", i, err)
+		}
+
+		if err := p.Allow.validate(); err != nil {
+			return fmt.Errorf("playlist number %d allow list: %w", i, err)
+		}
+
+		switch p.DedupeScope {
+		case "", DedupePlaylist, DedupeAll:
+		case DedupeGroup:
+			if p.DedupeGroup == "" {
+				return fmt.Errorf("playlist number %d is missing dedupe group", i)
+			}
+		default:
+			return fmt.Errorf("playlist number %d has invalid dedupe scope: %s", i, p.DedupeScope)
+		}
+
+		if p.DedupeAction != "" && p.DedupeAction != DedupeSkip && p.DedupeAction != DedupeMove {
+			return fmt.Errorf("playlist number %d has invalid dedupe action: %s", i, p.DedupeAction)
+		}
+
+		if p.Rotate != "" && !p.Rotate.Valid() {
+			return fmt.Errorf("playlist number %d has invalid rotate interval: %s", i, p.Rotate)
+		}
+
+		if p.Rotate != "" && p.RotateMode != RotateCopy && (p.Name == "" || p.ID != "") {
+			return fmt.Errorf("playlist number %d must be configured by name to be rotated by renaming", i)
+		}
+
+		if p.RotateMode != "" && p.RotateMode != RotateRename && p.RotateMode != RotateCopy {
+			return fmt.Errorf("playlist number %d has invalid rotate mode: %s", i, p.RotateMode)
+		}
+
+		if p.Type != "" && p.Type != PlaylistDigest {
+			return fmt.Errorf("playlist number %d has invalid type: %s", i, p.Type)
+		}
+
+		if p.IsDigest() && !p.Period.Valid() {
+			return fmt.Errorf("playlist number %d has invalid digest period: %s", i, p.Period)
+		}
+
+		if p.Size < 0 {
+			return fmt.Errorf("playlist number %d has invalid size: %d", i, p.Size)
+		}
+
+		switch p.Order {
+		case "", OrderAdded, OrderNewest, OrderScore, OrderReleaseDate, OrderShuffle:
+		default:
+			return fmt.Errorf("playlist number %d has invalid order: %s", i, p.Order)
+		}
+
+		if p.OrderInterval != "" && !p.OrderInterval.Valid() {
+			return fmt.Errorf("playlist number %d has invalid order interval: %s", i, p.OrderInterval)
+		}
+
+		if p.Public != nil && *p.Public && p.Collaborative != nil && *p.Collaborative {
+			return fmt.Errorf("playlist number %d can't be both public and collaborative", i)
 		}
+
+		if p.DescriptionInterval != "" && !p.DescriptionInterval.Valid() {
+			return fmt.Errorf("playlist number %d has invalid description interval: %s", i, p.DescriptionInterval)
+		}
+
+		if ext := strings.ToLower(filepath.Ext(p.Cover)); p.Cover != "" && p.Cover != CoverGenerate && ext != ".jpg" && ext != ".jpeg" {
+			return fmt.Errorf("playlist number %d has invalid cover: %s, must be a jpeg file or %s", i, p.Cover, CoverGenerate)
+		}
+
+		switch p.Service {
+		case "", ServiceSpotify:
+		case ServiceDeezer:
+			if c.Deezer.AccessToken == "" {
+				return fmt.Errorf("playlist number %d is on deezer, but the deezer access token is missing", i)
+			}
+		case ServiceLocal:
+			if c.Library.Dir == "" {
+				return fmt.Errorf("playlist number %d is local, but the library dir is missing", i)
+			}
+		default:
+			return fmt.Errorf("playlist number %d has invalid service: %s", i, p.Service)
+		}
+
+		if !p.IsSpotify() && (p.Rotate != "" || p.IsDigest() || p.Reorders() || p.Order == OrderNewest ||
+			p.Public != nil || p.Collaborative != nil || p.Cover != "" || p.RefreshesDescription() ||
+			p.Filters != (Filters{}) || !p.Block.IsEmpty() || !p.Allow.IsEmpty() ||
+			(p.DedupeScope != "" && p.DedupeScope != DedupePlaylist) || p.DedupeGroup != "" || p.DedupeAction == DedupeMove) {
+			return fmt.Errorf("playlist number %d on %s only supports name, id, subreddits and a static description", i, p.Service)
+		}
+	}
+
+	if err := c.RSS.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Lemmy.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Mastodon.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Block.validate(); err != nil {
+		return fmt.Errorf("block list: %w", err)
+	}
+
+	if c.ReconcileInterval != "" && !c.ReconcileInterval.Valid() {
+		return fmt.Errorf("invalid reconcile interval: %s", c.ReconcileInterval)
+	}
+
+	if err := c.Allow.validate(); err != nil {
+		return fmt.Errorf("allow list: %w", err)
 	}
 
 	return nil
@@ -157,8 +914,102 @@ func (c *Config) validate() error {
 func (c *Config) setDefaultValues() {
 	c.Version = version
 	c.PlaylistDescription = "Auto-generated playlist. Generate your own with dissic: https://github.com/engvik/dissic"
+
+	for i, p := range c.Playlists {
+		for j, sub := range p.Subreddits {
+			c.Playlists[i].Subreddits[j] = CleanSubreddit(sub)
+		}
+
+		if p.Service == "" {
+			c.Playlists[i].Service = ServiceSpotify
+		}
+
+		if p.DedupeScope == "" {
+			c.Playlists[i].DedupeScope = DedupePlaylist
+		}
+
+		if p.DedupeAction == "" {
+			c.Playlists[i].DedupeAction = DedupeSkip
+		}
+
+		if p.RotateMode == "" {
+			c.Playlists[i].RotateMode = RotateRename
+		}
+
+		if p.ArchiveName == "" {
+			c.Playlists[i].ArchiveName = "{name} — {period}"
+		}
+
+		if p.Size == 0 {
+			c.Playlists[i].Size = 50
+		}
+
+		if p.Order == "" {
+			c.Playlists[i].Order = OrderAdded
+		}
+
+		if p.OrderInterval == "" {
+			c.Playlists[i].OrderInterval = scheduler.Daily
+		}
+
+		if p.Description == "" {
+			c.Playlists[i].Description = c.PlaylistDescription
+		}
+
+		if p.DescriptionInterval == "" {
+			c.Playlists[i].DescriptionInterval = scheduler.Daily
+		}
+	}
+
 	c.Reddit.Subreddits = c.getSubreddits()
 
+	if c.APIPort == 0 {
+		c.APIPort = c.HTTPPort + 1
+	}
+
+	if c.StateFile == "" {
+		c.StateFile = "dissic-state.json"
+	}
+
+	if c.HistoryFile == "" {
+		c.HistoryFile = "dissic-history.jsonl"
+	}
+
+	if c.ReconcileInterval == "" {
+		c.ReconcileInterval = scheduler.Daily
+	}
+
+	if c.RSS.Interval == 0 {
+		c.RSS.Interval = 15
+	}
+
+	if c.Lemmy.Interval == 0 {
+		c.Lemmy.Interval = 5
+	}
+
+	if c.Mastodon.Interval == 0 {
+		c.Mastodon.Interval = 5
+	}
+
+	for i, t := range c.Mastodon.Timelines {
+		c.Mastodon.Timelines[i].Hashtag = strings.TrimPrefix(t.Hashtag, "#")
+		c.Mastodon.Timelines[i].Account = strings.TrimPrefix(t.Account, "@")
+
+		if t.Name == "" {
+			c.Mastodon.Timelines[i].Name = c.Mastodon.Timelines[i].Hashtag + c.Mastodon.Timelines[i].Account
+		}
+	}
+
+	for i, community := range c.Lemmy.Communities {
+		if community.Name == "" {
+			c.Lemmy.Communities[i].Name = community.Community
+		}
+
+		if community.Sort == "" {
+			c.Lemmy.Communities[i].Sort = "New"
+		}
+	}
+
 	if c.Reddit.RequestRate == 0 {
 		c.Reddit.RequestRate = 5
 	}
@@ -170,6 +1021,28 @@ func (c *Config) setDefaultValues() {
 	if c.Reddit.RetryAttemptWaitTime == 0 {
 		c.Reddit.RetryAttemptWaitTime = 10
 	}
+
+	if c.Spotify.SearchLimit == 0 {
+		c.Spotify.SearchLimit = 10
+	}
+
+	c.Spotify.Market = strings.ToUpper(c.Spotify.Market)
+
+	if c.Library.PlaylistDir == "" {
+		c.Library.PlaylistDir = c.Library.Dir
+	}
+
+	if c.Spotify.CacheFile == "" {
+		c.Spotify.CacheFile = "dissic-cache.json"
+	}
+
+	if c.Spotify.CacheTTL == 0 {
+		c.Spotify.CacheTTL = 168
+	}
+
+	if c.Spotify.UnmatchedTTL == 0 {
+		c.Spotify.UnmatchedTTL = 24
+	}
 }
 
 func readConfigFile(path string) ([]byte, error) {
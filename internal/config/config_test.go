@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -26,6 +27,7 @@ reddit:
 spotify:
     client-id: "test1337"
     client-secret: "1337test"
+    search-limit: 10
 
 playlists:
     -
@@ -148,13 +150,339 @@ func TestValidate(t *testing.T) {
 			}(*cfg),
 			"spotify client secret is missing",
 		},
+		{
+			"should not validate spotify search limit",
+			func(cfg Config) *Config {
+				cfg.Spotify.SearchLimit = 51
+				return &cfg
+			}(*cfg),
+			"spotify search limit must be between 1 and 50",
+		},
+		{
+			"should not validate spotify market",
+			func(cfg Config) *Config {
+				cfg.Spotify.Market = "norway"
+				return &cfg
+			}(*cfg),
+			"spotify market must be a two letter country code",
+		},
+		{
+			"should not validate inverted filter range",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Filters: Filters{Year: Range{Min: 2020, Max: 2010}}}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0: invalid year filter: 2020-2010",
+		},
+		{
+			"should not validate audio feature filter above 1",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Filters: Filters{Energy: Range{Min: 50}}}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0: invalid energy filter, must be between 0 and 1: 50-",
+		},
+		{
+			"should not validate dedupe scope",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, DedupeScope: "everywhere"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid dedupe scope: everywhere",
+		},
+		{
+			"should not validate dedupe group scope without group",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, DedupeScope: DedupeGroup}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 is missing dedupe group",
+		},
+		{
+			"should not validate dedupe action",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, DedupeAction: "copy"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid dedupe action: copy",
+		},
+		{
+			"should not validate rotate interval",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Rotate: "yearly"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid rotate interval: yearly",
+		},
+		{
+			"should not validate renaming rotation without name",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{ID: "test", Subreddits: []string{"music"}, Rotate: "weekly"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 must be configured by name to be rotated by renaming",
+		},
+		{
+			"should not validate rotate mode",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, RotateMode: "move"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid rotate mode: move",
+		},
+		{
+			"should not validate playlist type",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Type: "smart"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid type: smart",
+		},
+		{
+			"should not validate digest without period",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Type: PlaylistDigest}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid digest period: ",
+		},
+		{
+			"should not validate order",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Order: "alphabetical"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid order: alphabetical",
+		},
+		{
+			"should not validate order interval",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Order: OrderShuffle, OrderInterval: "hourly"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid order interval: hourly",
+		},
+		{
+			"should not validate public collaborative playlist",
+			func(cfg Config) *Config {
+				yes := true
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Public: &yes, Collaborative: &yes}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 can't be both public and collaborative",
+		},
+		{
+			"should not validate invalid description interval",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, DescriptionInterval: "hourly"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid description interval: hourly",
+		},
+		{
+			"should not validate invalid cover",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Cover: "cover.png"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid cover: cover.png, must be a jpeg file or generate",
+		},
+		{
+			"should not validate invalid service",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Service: "tidal"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has invalid service: tidal",
+		},
+		{
+			"should not validate deezer playlist without access token",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Service: ServiceDeezer}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 is on deezer, but the deezer access token is missing",
+		},
+		{
+			"should not validate api on the http port",
+			func(cfg Config) *Config {
+				cfg.APIToken = "token"
+				cfg.APIPort = cfg.HTTPPort
+				return &cfg
+			}(*cfg),
+			"api port must differ from http port",
+		},
+		{
+			"should not validate rotating deezer playlist",
+			func(cfg Config) *Config {
+				cfg.Deezer.AccessToken = "token"
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Service: ServiceDeezer, Rotate: "weekly"}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 on deezer only supports name, id, subreddits and a static description",
+		},
+		{
+			"should not validate deezer playlist with block list",
+			func(cfg Config) *Config {
+				cfg.Deezer.AccessToken = "token"
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Service: ServiceDeezer, Block: Lists{Artists: []string{"Daft Punk"}}}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 on deezer only supports name, id, subreddits and a static description",
+		},
+		{
+			"should not validate deezer playlist moving duplicates",
+			func(cfg Config) *Config {
+				cfg.Deezer.AccessToken = "token"
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Service: ServiceDeezer, DedupeScope: DedupeAll, DedupeAction: DedupeMove}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 on deezer only supports name, id, subreddits and a static description",
+		},
+		{
+			"should not validate local playlist without library dir",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Service: ServiceLocal}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 is local, but the library dir is missing",
+		},
+		{
+			"should validate local playlist without spotify credentials",
+			func(cfg Config) *Config {
+				cfg.Spotify.ClientID = ""
+				cfg.Spotify.ClientSecret = ""
+				cfg.Library.Dir = "music"
+				cfg.Playlists = []Playlist{{Name: "test", Subreddits: []string{"music"}, Service: ServiceLocal}}
+				return &cfg
+			}(*cfg),
+			"",
+		},
+		{
+			"should validate playlist fed by a feed",
+			func(cfg Config) *Config {
+				cfg.RSS.Feeds = []Feed{{Name: "Blog", URL: "https://blog.example.com/feed"}}
+				cfg.Playlists = []Playlist{{Name: "test", Feeds: []string{"blog"}}}
+				return &cfg
+			}(*cfg),
+			"",
+		},
+		{
+			"should not validate playlist without subreddits or feeds",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test"}}
+				return &cfg
+			}(*cfg),
+			"no subreddits, feeds, communities or timelines passed to playlist number 0",
+		},
+		{
+			"should not validate unknown feed",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Feeds: []string{"blog"}}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has unknown feed: blog",
+		},
+		{
+			"should not validate feed with invalid url",
+			func(cfg Config) *Config {
+				cfg.RSS.Feeds = []Feed{{Name: "Blog", URL: "blog.example.com/feed"}}
+				return &cfg
+			}(*cfg),
+			"feed Blog has invalid url: blog.example.com/feed",
+		},
+		{
+			"should not validate feed configured twice",
+			func(cfg Config) *Config {
+				cfg.RSS.Feeds = []Feed{
+					{Name: "Blog", URL: "https://blog.example.com/feed"},
+					{Name: "blog", URL: "https://blog.example.com/atom"},
+				}
+				return &cfg
+			}(*cfg),
+			"feed blog is configured more than once",
+		},
+		{
+			"should validate playlist fed by a lemmy community",
+			func(cfg Config) *Config {
+				cfg.Lemmy.Communities = []Community{{Name: "music", Instance: "https://lemmy.world", Community: "music@lemmy.ml", Sort: "New"}}
+				cfg.Playlists = []Playlist{{Name: "test", Communities: []string{"Music"}}}
+				return &cfg
+			}(*cfg),
+			"",
+		},
+		{
+			"should not validate unknown community",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Communities: []string{"music"}}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has unknown community: music",
+		},
+		{
+			"should not validate community with invalid sort",
+			func(cfg Config) *Config {
+				cfg.Lemmy.Communities = []Community{{Name: "music", Instance: "https://lemmy.world", Community: "music", Sort: "Newest"}}
+				return &cfg
+			}(*cfg),
+			"lemmy community music has invalid sort: Newest",
+		},
+		{
+			"should not validate community with invalid instance",
+			func(cfg Config) *Config {
+				cfg.Lemmy.Communities = []Community{{Name: "music", Instance: "lemmy.world", Community: "music", Sort: "New"}}
+				return &cfg
+			}(*cfg),
+			"lemmy community music has invalid instance: lemmy.world",
+		},
+		{
+			"should validate playlist fed by a mastodon timeline",
+			func(cfg Config) *Config {
+				cfg.Mastodon.Timelines = []Timeline{{Name: "nowplaying", Instance: "https://mastodon.social", Hashtag: "NowPlaying"}}
+				cfg.Playlists = []Playlist{{Name: "test", Timelines: []string{"NowPlaying"}}}
+				return &cfg
+			}(*cfg),
+			"",
+		},
+		{
+			"should not validate unknown timeline",
+			func(cfg Config) *Config {
+				cfg.Playlists = []Playlist{{Name: "test", Timelines: []string{"nowplaying"}}}
+				return &cfg
+			}(*cfg),
+			"playlist number 0 has unknown timeline: nowplaying",
+		},
+		{
+			"should not validate timeline with hashtag and account",
+			func(cfg Config) *Config {
+				cfg.Mastodon.Timelines = []Timeline{{Name: "np", Instance: "https://mastodon.social", Hashtag: "NowPlaying", Account: "dj"}}
+				return &cfg
+			}(*cfg),
+			"mastodon timeline number 0 must have either a hashtag or an account",
+		},
+		{
+			"should not validate invalid title pattern",
+			func(cfg Config) *Config {
+				cfg.Block = Lists{Titles: []string{"(?i)official ("}}
+				return &cfg
+			}(*cfg),
+			"block list: invalid title pattern: (?i)official (, error parsing regexp: missing closing ): `(?i)official (`",
+		},
+		{
+			"should not validate invalid reconcile interval",
+			func(cfg Config) *Config {
+				cfg.ReconcileInterval = "hourly"
+				return &cfg
+			}(*cfg),
+			"invalid reconcile interval: hourly",
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.n, func(t *testing.T) {
 			err := tc.cfg.validate()
 
-			if err != nil && err.Error() != tc.exp {
+			if (err == nil && tc.exp != "") || (err != nil && err.Error() != tc.exp) {
 				t.Errorf("unexpected result: got %s, exp %s", err, tc.exp)
 			}
 		})
@@ -170,6 +498,8 @@ func TestSetDefaultValues(t *testing.T) {
 	cfg.Reddit.RequestRate = 0
 	cfg.Reddit.MaxRetryAttempts = 0
 	cfg.Reddit.RetryAttemptWaitTime = 0
+	cfg.Spotify.SearchLimit = 0
+	cfg.Spotify.Market = "no"
 
 	expRequestRate := 5
 	expMaxRetryAttempts := 10
@@ -189,6 +519,98 @@ func TestSetDefaultValues(t *testing.T) {
 		if cfg.Reddit.RetryAttemptWaitTime != expRetryAttemptWaitTime {
 			t.Errorf("unexpected value: got %d, exp %d", cfg.Reddit.RetryAttemptWaitTime, expMaxRetryAttempts)
 		}
+
+		if cfg.Spotify.SearchLimit != 10 {
+			t.Errorf("unexpected value: got %d, exp %d", cfg.Spotify.SearchLimit, 10)
+		}
+
+		if cfg.Spotify.Market != "NO" {
+			t.Errorf("unexpected value: got %s, exp %s", cfg.Spotify.Market, "NO")
+		}
+
+		for _, p := range cfg.Playlists {
+			if p.DedupeScope != DedupePlaylist || p.DedupeAction != DedupeSkip {
+				t.Errorf("unexpected dedupe: got %s/%s, exp %s/%s", p.DedupeScope, p.DedupeAction, DedupePlaylist, DedupeSkip)
+			}
+		}
 	})
 
 }
+
+func TestCleanSubreddit(t *testing.T) {
+	tests := []struct {
+		n   string
+		sub string
+		exp string
+	}{
+		{"should keep clean name", "music", "music"},
+		{"should lower case name", "Music", "music"},
+		{"should remove r/ prefix", "r/Music", "music"},
+		{"should remove /r/ prefix", "/r/Music", "music"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			if sub := CleanSubreddit(tc.sub); sub != tc.exp {
+				t.Errorf("unexpected value: got %s, exp %s", sub, tc.exp)
+			}
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+	p := Playlist{Subreddits: []string{"Music", "jazz"}, Feeds: []string{"Bandcamp"}, Communities: []string{"music"}, Timelines: []string{"NowPlaying"}}
+	exp := []string{"music", "jazz", "rss:bandcamp", "lemmy:music", "mastodon:nowplaying"}
+
+	keys := p.Sources()
+	if len(keys) != len(exp) {
+		t.Fatalf("unexpected slice length: got %d, exp %d", len(keys), len(exp))
+	}
+
+	for i, key := range keys {
+		if key != exp[i] {
+			t.Errorf("unexpected value: got %s, exp %s, pos %d", key, exp[i], i)
+		}
+	}
+}
+
+func TestArchive(t *testing.T) {
+	tests := []struct {
+		n   string
+		p   Playlist
+		exp string
+	}{
+		{"default", Playlist{Name: "Fresh", ArchiveName: "{name} — {period}"}, "Fresh — 2026-W42"},
+		{"subreddits", Playlist{Subreddits: []string{"music", "jazz"}, ArchiveName: "{subreddits} — {period}"}, "r/music, r/jazz — 2026-W42"},
+		{"no placeholders", Playlist{Name: "Fresh", ArchiveName: "Archive"}, "Archive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			if got := tt.p.Archive("2026-W42"); got != tt.exp {
+				t.Errorf("unexpected value: got %s, exp %s", got, tt.exp)
+			}
+		})
+	}
+}
+
+func TestRenderDescription(t *testing.T) {
+	updated := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		n   string
+		p   Playlist
+		exp string
+	}{
+		{"all placeholders", Playlist{Name: "Fresh", Subreddits: []string{"music", "jazz"}, Description: "{name}: {tracks} tracks from {subreddits}, updated {updated}"}, "Fresh: 12 tracks from r/music, r/jazz, updated 2026-10-19"},
+		{"no placeholders", Playlist{Name: "Fresh", Description: "Fresh tracks"}, "Fresh tracks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			if got := tt.p.RenderDescription(updated, 12); got != tt.exp {
+				t.Errorf("unexpected value: got %s, exp %s", got, tt.exp)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// Package deezer keeps playlists on Deezer, as a sink for the tracks
+// posted to the subreddits.
+package deezer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/sink"
+	"github.com/engvik/dissic/internal/title"
+	log "github.com/sirupsen/logrus"
+)
+
+// apiURL is the base URL of the Deezer API.
+const apiURL = "https://api.deezer.com"
+
+// pageLimit is the number of items asked for per page.
+const pageLimit = 100
+
+// Client is a Deezer sink authenticated by an access token.
+type Client struct {
+	Logger *log.Entry
+
+	http  *http.Client
+	token string
+}
+
+// New returns a client for the user with the access token.
+func New(token string, httpClient *http.Client) *Client {
+	return &Client{
+		Logger: log.WithFields(log.Fields{"service": "deezer"}),
+		http:   httpClient,
+		token:  token,
+	}
+}
+
+type artist struct {
+	Name string `json:"name"`
+}
+
+type album struct {
+	Title string `json:"title"`
+}
+
+type track struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	ISRC     string `json:"isrc"`
+	Duration int    `json:"duration"`
+	Readable bool   `json:"readable"`
+	Artist   artist `json:"artist"`
+	Album    album  `json:"album"`
+}
+
+type playlist struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// apiError is the error Deezer responds with, with status 200.
+type apiError struct {
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+// Service returns the name of the Deezer service.
+func (c *Client) Service() string {
+	return config.ServiceDeezer
+}
+
+// ResolveTrack finds the track a post is about by a Deezer link, the
+// ISRC, the structured fields and then the titles, moving on when one
+// isn't found.
+func (c *Client) ResolveTrack(q sink.Query) (sink.Track, error) {
+	if id, ok := parseTrackURL(q.URL); ok {
+		var t track
+		err := c.do(http.MethodGet, "/track/"+id, nil, &t)
+		if err == nil && t.Readable {
+			return toTrack(t), nil
+		}
+
+		c.Logger.Infof("\ttrack by url: %s", q.URL)
+	}
+
+	if q.ISRC != "" {
+		var t track
+		err := c.do(http.MethodGet, "/track/isrc:"+url.PathEscape(q.ISRC), nil, &t)
+		if err == nil && t.Readable {
+			return toTrack(t), nil
+		}
+
+		c.Logger.Infof("\ttrack by isrc: %s", q.ISRC)
+	}
+
+	for _, s := range q.SearchTitles() {
+		t, err := title.Parse(s)
+		if err != nil {
+			c.Logger.Infof("\tparse title: %s, title: %s", err, s)
+			continue
+		}
+
+		for _, query := range searchQueries(t) {
+			tracks, err := c.search(query)
+			if err != nil {
+				c.Logger.Infof("search: %s", err)
+				continue
+			}
+
+			if found, ok := sink.Match(t, readable(tracks)); ok {
+				c.Logger.Infof("\ttrack found: %s (%s), query: %s", s, found.ID, query)
+				return found, nil
+			}
+		}
+	}
+
+	return sink.Track{}, errors.New("no track found")
+}
+
+// searchQueries returns the queries to try for a parsed title, strictest
+// first.
+func searchQueries(t title.Title) []string {
+	return []string{
+		fmt.Sprintf("artist:%s track:%s", quote(t.Artists[0]), quote(t.Track)),
+		strings.Join(append(append([]string(nil), t.Artists...), t.Track), " "),
+	}
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "") + `"`
+}
+
+// readable returns the tracks that can be played.
+func readable(tracks []track) []sink.Track {
+	result := make([]sink.Track, 0, len(tracks))
+	for _, t := range tracks {
+		if t.Readable {
+			result = append(result, toTrack(t))
+		}
+	}
+
+	return result
+}
+
+func (c *Client) search(query string) ([]track, error) {
+	var page struct {
+		Data []track `json:"data"`
+	}
+
+	if err := c.do(http.MethodGet, "/search/track", url.Values{"q": {query}}, &page); err != nil {
+		return nil, err
+	}
+
+	return page.Data, nil
+}
+
+// parseTrackURL returns the track ID of a Deezer track link, e.g.
+// https://www.deezer.com/en/track/3135556.
+func parseTrackURL(s string) (string, bool) {
+	u, err := url.Parse(s)
+	if err != nil || !strings.HasSuffix(u.Host, "deezer.com") {
+		return "", false
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[len(parts)-2] != "track" {
+		return "", false
+	}
+
+	id := parts[len(parts)-1]
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return "", false
+	}
+
+	return id, true
+}
+
+// EnsurePlaylist returns the ID of the user's playlist with a name,
+// creating it with the description if there's none.
+func (c *Client) EnsurePlaylist(name string, description string) (string, error) {
+	for index := 0; ; {
+		var page struct {
+			Data []playlist `json:"data"`
+			Next string     `json:"next"`
+		}
+
+		if err := c.do(http.MethodGet, "/user/me/playlists", pageValues(index), &page); err != nil {
+			return "", fmt.Errorf("getting playlists: %w", err)
+		}
+
+		for _, p := range page.Data {
+			if p.Title == name {
+				c.Logger.Infof("found playlist: %s (%d)", p.Title, p.ID)
+				return strconv.FormatInt(p.ID, 10), nil
+			}
+		}
+
+		if page.Next == "" {
+			break
+		}
+
+		index += len(page.Data)
+	}
+
+	var created struct {
+		ID int64 `json:"id"`
+	}
+
+	if err := c.do(http.MethodPost, "/user/me/playlists", url.Values{"title": {name}}, &created); err != nil {
+		return "", fmt.Errorf("creating playlist: %w", err)
+	}
+
+	playlistID := strconv.FormatInt(created.ID, 10)
+
+	if description != "" {
+		if err := c.do(http.MethodPost, "/playlist/"+playlistID, url.Values{"description": {description}}, nil); err != nil {
+			return "", fmt.Errorf("changing description: playlist %s: %w", playlistID, err)
+		}
+	}
+
+	c.Logger.Infof("created playlist: %s (%s)", name, playlistID)
+
+	return playlistID, nil
+}
+
+// AddTracks appends tracks to a playlist.
+func (c *Client) AddTracks(playlistID string, trackIDs ...string) error {
+	params := url.Values{"songs": {strings.Join(trackIDs, ",")}}
+
+	return c.do(http.MethodPost, "/playlist/"+playlistID+"/tracks", params, nil)
+}
+
+// RemoveTracks removes tracks from a playlist.
+func (c *Client) RemoveTracks(playlistID string, trackIDs ...string) error {
+	params := url.Values{"songs": {strings.Join(trackIDs, ",")}}
+
+	return c.do(http.MethodDelete, "/playlist/"+playlistID+"/tracks", params, nil)
+}
+
+// Tracks returns the tracks in a playlist.
+func (c *Client) Tracks(playlistID string) ([]sink.Track, error) {
+	var tracks []sink.Track
+
+	for index := 0; ; {
+		var page struct {
+			Data []track `json:"data"`
+			Next string  `json:"next"`
+		}
+
+		if err := c.do(http.MethodGet, "/playlist/"+playlistID+"/tracks", pageValues(index), &page); err != nil {
+			return nil, err
+		}
+
+		for _, t := range page.Data {
+			tracks = append(tracks, toTrack(t))
+		}
+
+		if page.Next == "" {
+			return tracks, nil
+		}
+
+		index += len(page.Data)
+	}
+}
+
+func pageValues(index int) url.Values {
+	return url.Values{"index": {strconv.Itoa(index)}, "limit": {strconv.Itoa(pageLimit)}}
+}
+
+// do sends a request to the API, and decodes the response into v unless
+// it's nil.
+func (c *Client) do(method string, path string, params url.Values, v interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+
+	params.Set("access_token", c.token)
+
+	req, err := http.NewRequest(method, apiURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		// the URL has the access token, so it's left out of the error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s: reading response: %w", method, path, err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", method, path, res.Status)
+	}
+
+	var apiErr apiError
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error != nil {
+		return fmt.Errorf("%s %s: %s: %s", method, path, apiErr.Error.Type, apiErr.Error.Message)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+
+	return nil
+}
+
+func toTrack(t track) sink.Track {
+	return sink.Track{
+		ID:       strconv.FormatInt(t.ID, 10),
+		Artists:  []string{t.Artist.Name},
+		Title:    t.Title,
+		Album:    t.Album.Title,
+		ISRC:     t.ISRC,
+		Duration: time.Duration(t.Duration) * time.Second,
+	}
+}
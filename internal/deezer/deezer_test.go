@@ -0,0 +1,181 @@
+package deezer
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/engvik/dissic/internal/deezer/deezertest"
+	"github.com/engvik/dissic/internal/sink"
+)
+
+func newTestClient(t *testing.T, fake *deezertest.Fake) *Client {
+	t.Helper()
+
+	srv := deezertest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	return New(fake.Token, srv.HTTPClient())
+}
+
+func TestResolveTrack(t *testing.T) {
+	fake := deezertest.New("token")
+	teardrop := deezertest.NewTrack(3, "Teardrop", "Massive Attack")
+	teardrop.ISRC = "GBAAA9800001"
+	unreadable := deezertest.NewTrack(4, "Holocene", "Bon Iver")
+	unreadable.Readable = false
+	fake.AddTrack(
+		deezertest.NewTrack(1, "Around the World", "Daft Punk"),
+		deezertest.NewTrack(2, "Around the World (Radio Edit)", "Daft Punk"),
+		teardrop,
+		unreadable,
+	)
+
+	c := newTestClient(t, fake)
+
+	tests := []struct {
+		n   string
+		q   sink.Query
+		exp string
+	}{
+		{"should resolve by url", sink.Query{URL: "https://www.deezer.com/en/track/2"}, "2"},
+		{"should resolve by isrc", sink.Query{ISRC: "GBAAA9800001", Titles: []string{"Daft Punk - Around the World"}}, "3"},
+		{"should resolve by fields", sink.Query{Artist: "Massive Attack", Title: "Teardrop"}, "3"},
+		{"should resolve by title", sink.Query{Titles: []string{"Daft Punk - Around the World [House] (1997)"}}, "1"},
+		{"should prefer version asked for", sink.Query{Titles: []string{"Daft Punk - Around the World (Radio Edit)"}}, "2"},
+		{"should fall back from unknown url", sink.Query{URL: "https://www.deezer.com/track/99", Titles: []string{"Massive Attack - Teardrop"}}, "3"},
+		{"should not resolve unreadable track", sink.Query{Titles: []string{"Bon Iver - Holocene"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			track, err := c.ResolveTrack(tt.q)
+			if tt.exp == "" {
+				if err == nil {
+					t.Errorf("expected error, got %s", track.ID)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if track.ID != tt.exp {
+				t.Errorf("unexpected value: got %s, exp %s", track.ID, tt.exp)
+			}
+		})
+	}
+}
+
+func TestPlaylists(t *testing.T) {
+	fake := deezertest.New("token")
+	fake.AddTrack(deezertest.NewTrack(1, "Around the World", "Daft Punk"), deezertest.NewTrack(2, "Teardrop", "Massive Attack"))
+	existingID := fake.AddPlaylist("existing", 1)
+
+	c := newTestClient(t, fake)
+
+	t.Run("should find existing playlist", func(t *testing.T) {
+		id, err := c.EnsurePlaylist("existing", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if id != "1001" || existingID != 1001 {
+			t.Errorf("unexpected value: got %s, exp %d", id, existingID)
+		}
+	})
+
+	t.Run("should create playlist with description", func(t *testing.T) {
+		id, err := c.EnsurePlaylist("new", "fresh tracks")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		p := fake.PlaylistByTitle("new")
+		if p == nil || id != "1002" || p.Description != "fresh tracks" {
+			t.Errorf("unexpected playlist %s: %+v", id, p)
+		}
+	})
+
+	t.Run("should add, list and remove tracks", func(t *testing.T) {
+		if err := c.AddTracks("1001", "2"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if err := c.AddTracks("1001", "2"); err == nil {
+			t.Errorf("expected error adding track already in playlist")
+		}
+
+		tracks, err := c.Tracks("1001")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(tracks) != 2 || tracks[1].ID != "2" || tracks[1].Artists[0] != "Massive Attack" {
+			t.Errorf("unexpected tracks: %+v", tracks)
+		}
+
+		if err := c.RemoveTracks("1001", "1"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if ids := fake.PlaylistTrackIDs(1001); len(ids) != 1 || ids[0] != 2 {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should fail with invalid token", func(t *testing.T) {
+		c.token = "expired"
+		defer func() { c.token = "token" }()
+
+		if _, err := c.Tracks("1001"); err == nil {
+			t.Errorf("expected error for invalid token")
+		}
+	})
+}
+
+func TestTracksPaging(t *testing.T) {
+	fake := deezertest.New("token")
+
+	var ids []int64
+	for i := int64(1); i <= 250; i++ {
+		fake.AddTrack(deezertest.NewTrack(i, "Track", "Artist"))
+		ids = append(ids, i)
+	}
+
+	playlistID := fake.AddPlaylist("long", ids...)
+
+	c := newTestClient(t, fake)
+
+	tracks, err := c.Tracks("1001")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(tracks) != 250 || playlistID != 1001 {
+		t.Errorf("unexpected value: got %d, exp %d", len(tracks), 250)
+	}
+}
+
+// failingTransport fails every request, like a network that's down.
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRequestErrorHidesToken(t *testing.T) {
+	c := New("secret-token", &http.Client{Transport: failingTransport{}})
+
+	_, err := c.EnsurePlaylist("music", "")
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("unexpected token in error: %s", err)
+	}
+}
@@ -0,0 +1,324 @@
+// Package deezertest provides an in-memory fake of the parts of the Deezer
+// API used by dissic, served by an httptest server, so the deezer package
+// can be tested without network access.
+package deezertest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Artist is a track artist.
+type Artist struct {
+	Name string `json:"name"`
+}
+
+// Album is the album of a track.
+type Album struct {
+	Title string `json:"title"`
+}
+
+// Track is a catalog track. Duration is in seconds.
+type Track struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	ISRC     string `json:"isrc,omitempty"`
+	Duration int    `json:"duration"`
+	Readable bool   `json:"readable"`
+	Artist   Artist `json:"artist"`
+	Album    Album  `json:"album"`
+}
+
+// Playlist is a playlist of the user.
+type Playlist struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	trackIDs    []int64
+}
+
+// Fake is an in-memory Deezer with a catalog of tracks and the playlists
+// of the user with Token. It's safe for concurrent use.
+type Fake struct {
+	Token string
+
+	mu        sync.Mutex
+	tracks    map[int64]Track
+	catalog   []int64
+	playlists []*Playlist
+	searches  []string
+	nextID    int64
+}
+
+// New returns an empty fake accepting token.
+func New(token string) *Fake {
+	return &Fake{
+		Token:  token,
+		tracks: make(map[int64]Track),
+		nextID: 1000,
+	}
+}
+
+// NewTrack is a helper for creating a readable catalog track.
+func NewTrack(id int64, title string, artist string) Track {
+	return Track{ID: id, Title: title, Readable: true, Artist: Artist{Name: artist}}
+}
+
+// AddTrack adds tracks to the catalog.
+func (f *Fake) AddTrack(tracks ...Track) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range tracks {
+		if _, ok := f.tracks[t.ID]; !ok {
+			f.catalog = append(f.catalog, t.ID)
+		}
+
+		f.tracks[t.ID] = t
+	}
+}
+
+// AddPlaylist adds a playlist with tracks, and returns its ID.
+func (f *Fake) AddPlaylist(title string, trackIDs ...int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.createPlaylist(title, trackIDs...).ID
+}
+
+// PlaylistByTitle returns a copy of the playlist with a title, or nil.
+func (f *Fake) PlaylistByTitle(title string) *Playlist {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, p := range f.playlists {
+		if p.Title == title {
+			cp := *p
+			return &cp
+		}
+	}
+
+	return nil
+}
+
+// PlaylistTrackIDs returns the IDs of the tracks in a playlist, in order.
+func (f *Fake) PlaylistTrackIDs(playlistID int64) []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := f.playlist(playlistID)
+	if p == nil {
+		return nil
+	}
+
+	return append([]int64(nil), p.trackIDs...)
+}
+
+// Searches returns the search queries made, in order.
+func (f *Fake) Searches() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.searches...)
+}
+
+// Track returns a catalog track by ID.
+func (f *Fake) Track(id int64) (Track, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tracks[id]
+	if !ok {
+		return Track{}, errNoData
+	}
+
+	return t, nil
+}
+
+// TrackByISRC returns the first catalog track with an ISRC.
+func (f *Fake) TrackByISRC(isrc string) (Track, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range f.catalog {
+		if t := f.tracks[id]; strings.EqualFold(t.ISRC, isrc) {
+			return t, nil
+		}
+	}
+
+	return Track{}, errNoData
+}
+
+// fieldPattern matches the field filters of a search, e.g. artist:"Name".
+var fieldPattern = regexp.MustCompile(`(artist|track|album):"([^"]*)"`)
+
+// Search returns the catalog tracks matching a query. Field filters must
+// be contained in the field, other words in the title, artist or album.
+func (f *Fake) Search(query string) []Track {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.searches = append(f.searches, query)
+
+	fields := make(map[string]string)
+	for _, m := range fieldPattern.FindAllStringSubmatch(query, -1) {
+		fields[m[1]] = strings.ToLower(m[2])
+	}
+
+	words := strings.Fields(strings.ToLower(fieldPattern.ReplaceAllString(query, "")))
+
+	var tracks []Track
+
+	for _, id := range f.catalog {
+		t := f.tracks[id]
+		title, artist, album := strings.ToLower(t.Title), strings.ToLower(t.Artist.Name), strings.ToLower(t.Album.Title)
+
+		if !strings.Contains(title, fields["track"]) || !strings.Contains(artist, fields["artist"]) || !strings.Contains(album, fields["album"]) {
+			continue
+		}
+
+		matches := true
+		for _, w := range words {
+			if !strings.Contains(title+" "+artist+" "+album, w) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			tracks = append(tracks, t)
+		}
+	}
+
+	return tracks
+}
+
+// Playlists returns the playlists of the user.
+func (f *Fake) Playlists() []Playlist {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	playlists := make([]Playlist, 0, len(f.playlists))
+	for _, p := range f.playlists {
+		playlists = append(playlists, *p)
+	}
+
+	return playlists
+}
+
+// CreatePlaylist creates a playlist, and returns its ID.
+func (f *Fake) CreatePlaylist(title string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.createPlaylist(title).ID
+}
+
+// SetDescription changes the description of a playlist.
+func (f *Fake) SetDescription(playlistID int64, description string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := f.playlist(playlistID)
+	if p == nil {
+		return errNoData
+	}
+
+	p.Description = description
+
+	return nil
+}
+
+// PlaylistTracks returns the tracks in a playlist.
+func (f *Fake) PlaylistTracks(playlistID int64) ([]Track, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := f.playlist(playlistID)
+	if p == nil {
+		return nil, errNoData
+	}
+
+	tracks := make([]Track, 0, len(p.trackIDs))
+	for _, id := range p.trackIDs {
+		tracks = append(tracks, f.tracks[id])
+	}
+
+	return tracks, nil
+}
+
+// AddTracks appends catalog tracks to a playlist. Like Deezer, adding a
+// track already in the playlist is an error.
+func (f *Fake) AddTracks(playlistID int64, trackIDs ...int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := f.playlist(playlistID)
+	if p == nil {
+		return errNoData
+	}
+
+	for _, id := range trackIDs {
+		if _, ok := f.tracks[id]; !ok {
+			return errNoData
+		}
+
+		for _, existing := range p.trackIDs {
+			if existing == id {
+				return fmt.Errorf("this song already exists in this playlist: %d", id)
+			}
+		}
+	}
+
+	p.trackIDs = append(p.trackIDs, trackIDs...)
+
+	return nil
+}
+
+// RemoveTracks removes tracks from a playlist.
+func (f *Fake) RemoveTracks(playlistID int64, trackIDs ...int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := f.playlist(playlistID)
+	if p == nil {
+		return errNoData
+	}
+
+	remove := make(map[int64]bool, len(trackIDs))
+	for _, id := range trackIDs {
+		remove[id] = true
+	}
+
+	kept := p.trackIDs[:0]
+	for _, id := range p.trackIDs {
+		if !remove[id] {
+			kept = append(kept, id)
+		}
+	}
+
+	p.trackIDs = kept
+
+	return nil
+}
+
+func (f *Fake) createPlaylist(title string, trackIDs ...int64) *Playlist {
+	f.nextID++
+
+	p := Playlist{ID: f.nextID, Title: title, trackIDs: trackIDs}
+	f.playlists = append(f.playlists, &p)
+
+	return &p
+}
+
+func (f *Fake) playlist(id int64) *Playlist {
+	for _, p := range f.playlists {
+		if p.ID == id {
+			return p
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package deezertest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// errNoData is the error Deezer returns for missing objects.
+var errNoData = errors.New("no data")
+
+// defaultLimit is the number of items Deezer returns when no limit is given.
+const defaultLimit = 25
+
+// Server is an httptest server serving a fake as the Deezer API.
+type Server struct {
+	*httptest.Server
+	Fake *Fake
+}
+
+// NewServer starts a server serving f. Close it when done.
+func NewServer(f *Fake) *Server {
+	s := Server{Fake: f}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return &s
+}
+
+// HTTPClient returns an http client sending its requests to the server
+// instead of api.deezer.com.
+func (s *Server) HTTPClient() *http.Client {
+	target, _ := url.Parse(s.URL)
+
+	return &http.Client{Transport: rewriteTransport{target: target}}
+}
+
+// rewriteTransport sends all requests to target.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	r.Host = t.target.Host
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if q.Get("access_token") != s.Fake.Token {
+		writeError(w, "OAuthException", "invalid OAuth access token", 300)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "search" && parts[1] == "track":
+		writePage(w, r, s.Fake.Search(q.Get("q")))
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "track" && strings.HasPrefix(parts[1], "isrc:"):
+		respond(w)(s.Fake.TrackByISRC(strings.TrimPrefix(parts[1], "isrc:")))
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "track":
+		respond(w)(s.Fake.Track(parseID(parts[1])))
+	case r.Method == http.MethodGet && r.URL.Path == "/user/me/playlists":
+		writePage(w, r, s.Fake.Playlists())
+	case r.Method == http.MethodPost && r.URL.Path == "/user/me/playlists":
+		respond(w)(map[string]int64{"id": s.Fake.CreatePlaylist(q.Get("title"))}, nil)
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[0] == "playlist":
+		respond(w)(true, s.Fake.SetDescription(parseID(parts[1]), q.Get("description")))
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "playlist" && parts[2] == "tracks":
+		tracks, err := s.Fake.PlaylistTracks(parseID(parts[1]))
+		if err != nil {
+			respond(w)(nil, err)
+			return
+		}
+
+		writePage(w, r, tracks)
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "playlist" && parts[2] == "tracks":
+		respond(w)(true, s.Fake.AddTracks(parseID(parts[1]), parseIDs(q.Get("songs"))...))
+	case r.Method == http.MethodDelete && len(parts) == 3 && parts[0] == "playlist" && parts[2] == "tracks":
+		respond(w)(true, s.Fake.RemoveTracks(parseID(parts[1]), parseIDs(q.Get("songs"))...))
+	default:
+		writeError(w, "DataException", "no data", 800)
+	}
+}
+
+// writePage writes the items from the index query parameter, with a next
+// URL if there are more.
+func writePage(w http.ResponseWriter, r *http.Request, items interface{}) {
+	b, _ := json.Marshal(items)
+
+	var all []json.RawMessage
+	_ = json.Unmarshal(b, &all)
+
+	index, _ := strconv.Atoi(r.URL.Query().Get("index"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLimit
+	}
+
+	page := struct {
+		Data  []json.RawMessage `json:"data"`
+		Total int               `json:"total"`
+		Next  string            `json:"next,omitempty"`
+	}{Data: []json.RawMessage{}, Total: len(all)}
+
+	if index < len(all) {
+		end := index + limit
+		if end > len(all) {
+			end = len(all)
+		}
+
+		page.Data = all[index:end]
+
+		if end < len(all) {
+			next := *r.URL
+			q := next.Query()
+			q.Set("index", strconv.Itoa(end))
+			next.RawQuery = q.Encode()
+			page.Next = next.String()
+		}
+	}
+
+	respond(w)(page, nil)
+}
+
+// respond writes v as JSON, or err as a Deezer error. Deezer reports
+// errors with status 200.
+func respond(w http.ResponseWriter) func(v interface{}, err error) {
+	return func(v interface{}, err error) {
+		if err != nil {
+			writeError(w, "DataException", err.Error(), 800)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
+
+func writeError(w http.ResponseWriter, errType string, msg string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"type": errType, "message": msg, "code": code},
+	})
+}
+
+func parseID(s string) int64 {
+	id, _ := strconv.ParseInt(s, 10, 64)
+	return id
+}
+
+func parseIDs(s string) []int64 {
+	var ids []int64
+	for _, id := range strings.Split(s, ",") {
+		if id != "" {
+			ids = append(ids, parseID(id))
+		}
+	}
+
+	return ids
+}
@@ -0,0 +1,413 @@
+package dissic
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/export"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/importer"
+	"github.com/engvik/dissic/internal/state"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrUsage is returned for commands called with invalid arguments.
+var ErrUsage = errors.New("invalid usage")
+
+// Command runs a one-off command, given as the arguments left after the
+// flags, instead of starting the service.
+func (s *Service) Command(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command: %w", ErrUsage)
+	}
+
+	switch args[0] {
+	case "block":
+		return s.blockCommand(ctx, args[1:])
+	case "suppressed":
+		return s.suppressedCommand(ctx, args[1:])
+	case "unsuppress":
+		return s.unsuppressCommand(ctx, args[1:])
+	case "rollback":
+		return s.rollbackCommand(ctx, args[1:])
+	case "export":
+		return s.exportCommand(ctx, args[1:])
+	case "import":
+		return s.importCommand(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s, %w", args[0], ErrUsage)
+	}
+}
+
+// blockCommand blocks the artist of a track in one of the playlists:
+//
+//	dissic block [-remove] <track id|url|uri>
+func (s *Service) blockCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("block", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	remove := fs.Bool("remove", false, "remove the artist's tracks from all playlists")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("block: %s, %w", err, ErrUsage)
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("block [-remove] <track id|url|uri>: %w", ErrUsage)
+	}
+
+	s.authenticate(ctx)
+
+	return s.BlockArtist(fs.Arg(0), *remove)
+}
+
+// BlockArtist adds the artist of a track in one of the playlists to the
+// global block list, and optionally removes the artist's tracks from all
+// playlists. The artists blocked this way are persisted in the state
+// file, apart from the block list in the config.
+func (s *Service) BlockArtist(track string, remove bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	artistID, name, err := s.Spotify.TrackArtist(track)
+	if err != nil {
+		return fmt.Errorf("finding artist: %w", err)
+	}
+
+	if s.Config.Block.AddArtist(artistID) {
+		s.Spotify.SetLists(s.Config.Block, s.Config.Allow)
+
+		err := s.updateState(func(st *state.State) {
+			st.AddBlockedArtist(artistID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	log.WithFields(log.Fields{"service": "dissic"}).Infof("blocked artist: %s (%s)", name, artistID)
+
+	if !remove {
+		return nil
+	}
+
+	removed, err := s.Spotify.RemoveArtistTracks(artistID)
+	if err != nil {
+		return fmt.Errorf("removing tracks by %s: %w", name, err)
+	}
+
+	log.WithFields(log.Fields{"service": "dissic"}).Infof("removed %d tracks by %s", removed, name)
+
+	return nil
+}
+
+// suppressedCommand lists the tracks suppressed for having been removed
+// from a playlist by a user, in all playlists or the one given:
+//
+//	dissic suppressed [playlist]
+func (s *Service) suppressedCommand(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("suppressed [playlist]: %w", ErrUsage)
+	}
+
+	s.authenticate(ctx)
+
+	var key string
+	if len(args) == 1 {
+		key = args[0]
+	}
+
+	return s.ListSuppressed(key)
+}
+
+// ListSuppressed writes the tracks suppressed in the playlist with key, or
+// in all playlists if key is empty, to the command output.
+func (s *Service) ListSuppressed(key string) error {
+	entries, err := s.Spotify.Suppressed(key)
+	if err != nil {
+		return fmt.Errorf("listing suppressed tracks: %w", err)
+	}
+
+	w := tabwriter.NewWriter(s.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SUPPRESSED\tPLAYLIST\tTRACK\tTITLE")
+
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Time.Format("2006-01-02 15:04"), e.Playlist, e.TrackID, e.Title)
+	}
+
+	return w.Flush()
+}
+
+// unsuppressCommand lets a suppressed track be added again, to all
+// playlists or the one given:
+//
+//	dissic unsuppress <track id|url|uri> [playlist]
+func (s *Service) unsuppressCommand(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("unsuppress <track id|url|uri> [playlist]: %w", ErrUsage)
+	}
+
+	s.authenticate(ctx)
+
+	var key string
+	if len(args) == 2 {
+		key = args[1]
+	}
+
+	n, err := s.Spotify.Unsuppress(args[0], key)
+	if err != nil {
+		return fmt.Errorf("unsuppressing track: %w", err)
+	}
+
+	log.WithFields(log.Fields{"service": "dissic"}).Infof("unsuppressed %s in %d playlists", args[0], n)
+
+	return nil
+}
+
+// rollbackCommand reverts a playlist to its state at a time, or removes
+// the tracks added in a run from all playlists or the one given:
+//
+//	dissic rollback [-dry-run] -to <time> <playlist>
+//	dissic rollback [-dry-run] -run <run id> [playlist]
+func (s *Service) rollbackCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	dryRun := fs.Bool("dry-run", false, "only list the changes")
+	to := fs.String("to", "", "time to revert the playlist to")
+	runID := fs.String("run", "", "run to remove the added tracks of")
+
+	usage := fmt.Errorf("rollback [-dry-run] -to <time> <playlist> | -run <run id> [playlist]: %w", ErrUsage)
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("rollback: %s, %w", err, ErrUsage)
+	}
+
+	if (*to == "") == (*runID == "") || fs.NArg() > 1 || (*to != "" && fs.NArg() != 1) {
+		return usage
+	}
+
+	var t time.Time
+	if *to != "" {
+		var err error
+		if t, err = parseTime(*to); err != nil {
+			return fmt.Errorf("rollback: %s, %w", err, ErrUsage)
+		}
+	}
+
+	s.authenticate(ctx)
+
+	return s.Rollback(fs.Arg(0), t, *runID, *dryRun)
+}
+
+// Rollback reverts the playlist with key to its state at t, or removes the
+// tracks added in a run if runID is set, and writes the changes to the
+// command output. With dryRun, the changes are only listed.
+func (s *Service) Rollback(key string, t time.Time, runID string, dryRun bool) error {
+	var changes []history.Entry
+	var err error
+
+	if runID != "" {
+		changes, err = s.Spotify.RollbackRun(runID, key, dryRun)
+	} else {
+		changes, err = s.Spotify.RollbackTo(key, t, dryRun)
+	}
+
+	if err := s.writeChanges(changes); err != nil {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("rolling back: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) writeChanges(changes []history.Entry) error {
+	w := tabwriter.NewWriter(s.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGE\tPLAYLIST\tTRACK\tTITLE")
+
+	for _, e := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Status, e.Playlist, e.TrackID, e.Title)
+	}
+
+	return w.Flush()
+}
+
+// timeLayouts are the layouts times are parsed with, in local time unless
+// given.
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time: %s", s)
+}
+
+// exportCommand writes the tracks of a playlist, or the full history, to
+// the command output or a file:
+//
+//	dissic export [-format csv|jsonl|xspf|m3u] [-output file] [playlist]
+func (s *Service) exportCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	format := fs.String("format", export.FormatCSV, "export format")
+	output := fs.String("output", "", "file to write to instead of stdout")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("export: %s, %w", err, ErrUsage)
+	}
+
+	if fs.NArg() > 1 {
+		return fmt.Errorf("export [-format %s] [-output file] [playlist]: %w", strings.Join(export.Formats, "|"), ErrUsage)
+	}
+
+	if !export.ValidFormat(*format) {
+		return fmt.Errorf("export: unknown format: %s, %w", *format, ErrUsage)
+	}
+
+	s.authenticate(ctx)
+
+	if *output == "" {
+		return s.Export(s.Out, fs.Arg(0), *format)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("creating export file: %s, %w", *output, err)
+	}
+
+	if err := s.Export(f, fs.Arg(0), *format); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing export file: %s, %w", *output, err)
+	}
+
+	log.WithFields(log.Fields{"service": "dissic"}).Infof("exported to %s", *output)
+
+	return nil
+}
+
+// Export writes the tracks of the playlist with key, or the full history
+// if key is empty, to w in format.
+func (s *Service) Export(w io.Writer, key string, format string) error {
+	var tracks []export.Track
+	var err error
+
+	title := key
+	if key != "" {
+		tracks, err = s.Spotify.ExportPlaylist(key)
+	} else {
+		title = "dissic history"
+		tracks, err = s.Spotify.ExportHistory()
+	}
+
+	if err != nil {
+		return fmt.Errorf("exporting: %w", err)
+	}
+
+	return export.Write(w, format, title, tracks)
+}
+
+// importCommand seeds a playlist with the tracks in a CSV or JSONL file,
+// and reports the rows that weren't added:
+//
+//	dissic import [-format csv|jsonl] [-report file] <playlist> <file>
+func (s *Service) importCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	format := fs.String("format", "", "import format, by file extension if not set")
+	report := fs.String("report", "", "file to write the report to instead of stdout")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("import: %s, %w", err, ErrUsage)
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("import [-format %s|%s] [-report file] <playlist> <file>: %w", importer.FormatCSV, importer.FormatJSONL, ErrUsage)
+	}
+
+	if *format == "" {
+		*format = importer.FormatFromPath(fs.Arg(1))
+	}
+
+	if *format != importer.FormatCSV && *format != importer.FormatJSONL {
+		return fmt.Errorf("import: unknown format: %s, %w", *format, ErrUsage)
+	}
+
+	f, err := os.Open(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("opening import file: %s, %w", fs.Arg(1), err)
+	}
+	defer f.Close()
+
+	rows, err := importer.Read(f, *format)
+	if err != nil {
+		return fmt.Errorf("reading import file: %s, %w", fs.Arg(1), err)
+	}
+
+	s.authenticate(ctx)
+
+	if *report == "" {
+		return s.Import(s.Out, fs.Arg(0), rows)
+	}
+
+	out, err := os.Create(*report)
+	if err != nil {
+		return fmt.Errorf("creating report file: %s, %w", *report, err)
+	}
+
+	if err := s.Import(out, fs.Arg(0), rows); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("writing report file: %s, %w", *report, err)
+	}
+
+	log.WithFields(log.Fields{"service": "dissic"}).Infof("import report written to %s", *report)
+
+	return nil
+}
+
+// Import adds the tracks of rows to the playlist with key, and writes a
+// report of the rows that weren't added to w.
+func (s *Service) Import(w io.Writer, key string, rows []importer.Row) error {
+	results, err := s.Spotify.Import(key, rows)
+	if err != nil {
+		return fmt.Errorf("importing: %w", err)
+	}
+
+	var added int
+	var report []importer.Result
+
+	for _, r := range results {
+		if r.Status == activity.StatusAdded {
+			added++
+			continue
+		}
+
+		report = append(report, r)
+	}
+
+	log.WithFields(log.Fields{"service": "dissic"}).Infof("imported %d of %d tracks to %s", added, len(rows), key)
+
+	return importer.WriteReport(w, report)
+}
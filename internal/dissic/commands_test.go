@@ -0,0 +1,239 @@
+package dissic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/export"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/importer"
+	"github.com/engvik/dissic/internal/state"
+)
+
+func TestCommandUsage(t *testing.T) {
+	d, _, _ := newTestService(t)
+
+	tests := []struct {
+		n    string
+		args []string
+	}{
+		{"no command", nil},
+		{"unknown command", []string{"unblock"}},
+		{"missing track", []string{"block"}},
+		{"unknown flag", []string{"block", "-purge", "track1"}},
+		{"too many playlists", []string{"suppressed", "one", "two"}},
+		{"missing suppressed track", []string{"unsuppress"}},
+		{"missing rollback target", []string{"rollback", "one"}},
+		{"both rollback targets", []string{"rollback", "-to", "2026-10-19", "-run", "run1", "one"}},
+		{"missing rollback playlist", []string{"rollback", "-to", "2026-10-19"}},
+		{"invalid rollback time", []string{"rollback", "-to", "yesterday", "one"}},
+		{"unknown export format", []string{"export", "-format", "pls", "one"}},
+		{"too many export playlists", []string{"export", "one", "two"}},
+		{"missing import file", []string{"import", "one"}},
+		{"unknown import format", []string{"import", "-format", "xml", "one", "tracks.xml"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			err := d.Command(context.Background(), tt.args)
+			if !errors.Is(err, ErrUsage) {
+				t.Errorf("unexpected error: got %v, exp %v", err, ErrUsage)
+			}
+		})
+	}
+}
+
+func TestBlockArtist(t *testing.T) {
+	d, s, _ := newTestService(t)
+
+	t.Run("should block artist and persist state", func(t *testing.T) {
+		if err := d.BlockArtist("track1", false); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(s.block.Artists) != 1 || s.block.Artists[0] != "artist1" {
+			t.Errorf("unexpected block list: %+v", s.block)
+		}
+
+		if len(s.removed) != 0 {
+			t.Errorf("unexpected removed artists: %v", s.removed)
+		}
+
+		st, err := state.Load(d.Config.StateFile)
+		if err != nil {
+			t.Fatalf("unexpected error loading state: %s", err)
+		}
+
+		if len(st.BlockedArtists) != 1 || st.BlockedArtists[0] != "artist1" {
+			t.Errorf("unexpected state: %+v", st)
+		}
+	})
+
+	t.Run("should remove tracks", func(t *testing.T) {
+		if err := d.BlockArtist("track1", true); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(d.Config.Block.Artists) != 1 {
+			t.Errorf("unexpected block list: %+v", d.Config.Block)
+		}
+
+		if len(s.removed) != 1 || s.removed[0] != "artist1" {
+			t.Errorf("unexpected removed artists: %v", s.removed)
+		}
+	})
+
+	t.Run("should fail for track not in a playlist", func(t *testing.T) {
+		if err := d.BlockArtist("track2", false); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+
+	t.Run("should keep artists blocked by other processes", func(t *testing.T) {
+		err := state.Update(d.Config.StateFile, func(st *state.State) {
+			st.AddBlockedArtist("artist2")
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		d.saveSeen()
+
+		st, err := state.Load(d.Config.StateFile)
+		if err != nil {
+			t.Fatalf("unexpected error loading state: %s", err)
+		}
+
+		if len(st.BlockedArtists) != 2 || st.BlockedArtists[1] != "artist2" {
+			t.Errorf("unexpected state: %+v", st)
+		}
+	})
+}
+
+func TestBlockArtistConfigured(t *testing.T) {
+	d, _, _ := newTestService(t)
+	d.Config.Block.Artists = []string{"artist1"}
+
+	if err := d.BlockArtist("track1", false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	st, err := state.Load(d.Config.StateFile)
+	if err != nil {
+		t.Fatalf("unexpected error loading state: %s", err)
+	}
+
+	if len(st.BlockedArtists) != 0 {
+		t.Errorf("unexpected blocked artists in state: %v", st.BlockedArtists)
+	}
+}
+
+func TestListSuppressed(t *testing.T) {
+	d, s, _ := newTestService(t)
+	s.suppressed = []history.Entry{
+		{Time: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), Playlist: "one", TrackID: "track1", Title: "Daft Punk - Around the World"},
+	}
+
+	var out bytes.Buffer
+	d.Out = &out
+
+	if err := d.ListSuppressed(""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "2026-10-19 12:00  one") || !strings.HasSuffix(lines[1], "Daft Punk - Around the World") {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+
+	if err := d.ListSuppressed("unknown"); err == nil {
+		t.Errorf("expected error for unknown playlist")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	d, s, _ := newTestService(t)
+
+	var out bytes.Buffer
+	d.Out = &out
+
+	to, err := parseTime("2026-10-19T12:00:00Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := d.Rollback("one", to, "", true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !strings.Contains(out.String(), "removed  one       track1") {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+
+	if err := d.Rollback("", time.Time{}, "run1", false); err == nil {
+		t.Errorf("expected error for unknown run")
+	}
+
+	exp := []string{"one to 2026-10-19T12:00:00Z, dry run true", " run run1, dry run false"}
+	if len(s.rollbacks) != 2 || s.rollbacks[0] != exp[0] || s.rollbacks[1] != exp[1] {
+		t.Errorf("unexpected rollbacks: got %v, exp %v", s.rollbacks, exp)
+	}
+}
+
+func TestExport(t *testing.T) {
+	d, _, _ := newTestService(t)
+
+	tests := []struct {
+		n   string
+		key string
+		exp string
+	}{
+		{"playlist", "one", "#EXTM3U\n#EXTINF:-1,Daft Punk - Around the World\nspotify:track:track1\n"},
+		{"history", "", "#EXTM3U\n#EXTINF:-1,Daft Punk - Around the World\nspotify:track:track1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := d.Export(&out, tt.key, export.FormatM3U); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := out.String(); got != tt.exp {
+				t.Errorf("unexpected value: got %s, exp %s", got, tt.exp)
+			}
+		})
+	}
+
+	if err := d.Export(&bytes.Buffer{}, "unknown", export.FormatCSV); err == nil {
+		t.Errorf("expected error for unknown playlist")
+	}
+}
+
+func TestImport(t *testing.T) {
+	d, _, _ := newTestService(t)
+
+	rows := []importer.Row{
+		{Artist: "Daft Punk", Title: "Around the World", Line: 2},
+		{Artist: "Nobody", Title: "Nothing", Line: 3},
+	}
+
+	var out bytes.Buffer
+	if err := d.Import(&out, "one", rows); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := "line,artist,title,isrc,url,status,message\n3,Nobody,Nothing,,,unmatched,no track found\n"
+	if got := out.String(); got != exp {
+		t.Errorf("unexpected value: got %s, exp %s", got, exp)
+	}
+
+	if err := d.Import(&bytes.Buffer{}, "unknown", rows); err == nil {
+		t.Errorf("expected error for unknown playlist")
+	}
+}
@@ -0,0 +1,44 @@
+package dissic
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduleDescriptions refreshes the descriptions with placeholders right
+// away, and schedules refreshing them every description interval.
+func (s *Service) scheduleDescriptions() {
+	for _, p := range s.Config.Playlists {
+		if !p.RefreshesDescription() {
+			continue
+		}
+
+		key := p.Key()
+		refresh := func(t time.Time) {
+			if err := s.refreshDescription(key, t); err != nil {
+				log.WithFields(log.Fields{"service": "dissic"}).Errorf("error refreshing description: %s", err)
+			}
+		}
+
+		s.Scheduler.Run("description "+key, refresh)
+		s.Scheduler.Add("description "+key, p.DescriptionInterval, refresh)
+	}
+}
+
+// refreshDescription renders the description of a playlist with its
+// current subreddits.
+func (s *Service) refreshDescription(key string, now time.Time) error {
+	s.mu.Lock()
+	p := s.Config.Playlist(key)
+	if p == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("playlist %s not found", key)
+	}
+	playlist := *p
+	playlist.Subreddits = append([]string(nil), p.Subreddits...)
+	s.mu.Unlock()
+
+	return s.Spotify.RefreshDescription(playlist, now)
+}
@@ -0,0 +1,27 @@
+package dissic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshDescription(t *testing.T) {
+	d, s, _ := newTestService(t)
+	d.Config.Playlists[0].Description = "Tracks from {subreddits}"
+
+	if err := d.AddSubreddit("one", "jazz"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := d.refreshDescription("one", time.Now()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if exp := "Tracks from r/music, r/jazz"; len(s.described) != 1 || s.described[0] != exp {
+		t.Errorf("unexpected descriptions: got %v, exp %s", s.described, exp)
+	}
+
+	if err := d.refreshDescription("unknown", time.Now()); err == nil {
+		t.Errorf("expected error for unknown playlist")
+	}
+}
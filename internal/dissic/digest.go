@@ -0,0 +1,115 @@
+package dissic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/engvik/dissic/internal/history"
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduleDigests builds the digest playlists right away, and schedules
+// rebuilding them at the start of every period.
+func (s *Service) scheduleDigests() {
+	for _, p := range s.Config.Playlists {
+		if !p.IsDigest() {
+			continue
+		}
+
+		key := p.Key()
+		build := func(t time.Time) {
+			if err := s.buildDigest(key, t); err != nil {
+				log.WithFields(log.Fields{"service": "dissic"}).Errorf("error building digest: %s", err)
+			}
+		}
+
+		s.Scheduler.Run("digest "+key, build)
+		s.Scheduler.Add("digest "+key, p.Period, build)
+	}
+}
+
+// buildDigest replaces the tracks of a digest playlist with the tracks of
+// the top scoring posts from the period before the one now is in. Scores
+// are refreshed from reddit, falling back to the score recorded when the
+// post was matched. Tracks kept out of the playlist are passed over for
+// the next best.
+func (s *Service) buildDigest(key string, now time.Time) error {
+	s.mu.Lock()
+	p := s.Config.Playlist(key)
+	if p == nil || !p.IsDigest() {
+		s.mu.Unlock()
+		return fmt.Errorf("digest playlist %s not found", key)
+	}
+	period, size := p.Period, p.Size
+	subreddits := p.Sources()
+	s.mu.Unlock()
+
+	to := period.Start(now)
+	from := period.Start(to.Add(-time.Nanosecond))
+
+	posts := s.refreshScores(s.Spotify.MatchedPosts(subreddits, from, to))
+
+	n, err := s.Spotify.ReplacePlaylist(key, rankPosts(posts), size)
+	if err != nil {
+		return fmt.Errorf("replacing digest %s: %w", key, err)
+	}
+
+	log.WithFields(log.Fields{"service": "dissic"}).Infof("built digest %s for %s with %d tracks", key, period.Name(from), n)
+
+	return nil
+}
+
+// refreshScores updates the scores of posts from reddit, keeping the
+// recorded score of posts that can't be fetched.
+func (s *Service) refreshScores(posts []history.Entry) []history.Entry {
+	for i, post := range posts {
+		// only reddit posts have a score, and a relative permalink
+		if !strings.HasPrefix(post.Permalink, "/") {
+			continue
+		}
+
+		score, err := s.Reddit.Score(post.Permalink)
+		if err != nil {
+			log.WithFields(log.Fields{"service": "dissic"}).Infof("refreshing score: %s", err)
+			continue
+		}
+
+		posts[i].Score = score
+	}
+
+	return posts
+}
+
+// rankPosts returns the highest scoring post of each track, highest score
+// first. Ties go to the track posted first.
+func rankPosts(posts []history.Entry) []history.Entry {
+	best := make(map[string]history.Entry)
+
+	for _, p := range posts {
+		b, ok := best[p.TrackID]
+		if !ok || p.Score > b.Score || (p.Score == b.Score && p.Time.Before(b.Time)) {
+			best[p.TrackID] = p
+		}
+	}
+
+	ranked := make([]history.Entry, 0, len(best))
+	for _, p := range best {
+		ranked = append(ranked, p)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+
+		if !ranked[i].Time.Equal(ranked[j].Time) {
+			return ranked[i].Time.Before(ranked[j].Time)
+		}
+
+		return ranked[i].TrackID < ranked[j].TrackID
+	})
+
+	return ranked
+}
@@ -0,0 +1,68 @@
+package dissic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/scheduler"
+)
+
+func TestRankPosts(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	posts := []history.Entry{
+		{TrackID: "track1", Score: 10, Time: now},
+		{TrackID: "track2", Score: 50, Time: now},
+		{TrackID: "track1", Score: 80, Time: now.Add(time.Hour)},
+		{TrackID: "track3", Score: 50, Time: now.Add(-time.Hour)},
+		{TrackID: "track4", Score: 1, Time: now},
+	}
+
+	exp := []string{"track1", "track3", "track2", "track4"}
+	got := rankPosts(posts)
+
+	if len(got) != len(exp) {
+		t.Fatalf("unexpected slice length: got %d, exp %d", len(got), len(exp))
+	}
+
+	for i := range exp {
+		if got[i].TrackID != exp[i] {
+			t.Errorf("unexpected value: got %s, exp %s, pos %d", got[i].TrackID, exp[i], i)
+		}
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	d, s, r := newTestService(t)
+	d.Config.Playlists = append(d.Config.Playlists, config.Playlist{
+		Name:       "top",
+		Subreddits: []string{"music"},
+		Type:       config.PlaylistDigest,
+		Period:     scheduler.Weekly,
+		Size:       2,
+	})
+
+	lastWeek := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	s.matched = []history.Entry{
+		{TrackID: "track1", Score: 1, Permalink: "/r/music/comments/1/", Time: lastWeek},
+		{TrackID: "track2", Score: 5, Permalink: "/r/music/comments/2/", Time: lastWeek},
+		{TrackID: "track3", Score: 3, Time: lastWeek},
+		{TrackID: "track4", Score: 900, Time: lastWeek.AddDate(0, 0, 7)},
+	}
+	r.scores = map[string]int{"/r/music/comments/1/": 100}
+
+	if err := d.buildDigest("top", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := s.replaced["top"]
+	if len(got) != 2 || got[0] != "track1" || got[1] != "track2" {
+		t.Errorf("unexpected digest: %v", got)
+	}
+
+	if err := d.buildDigest("one", time.Now()); err == nil {
+		t.Errorf("expected error for playlist not a digest")
+	}
+}
@@ -5,30 +5,71 @@ package dissic
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/cache"
 	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/export"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/importer"
+	"github.com/engvik/dissic/internal/scheduler"
+	"github.com/engvik/dissic/internal/sink"
+	"github.com/engvik/dissic/internal/source"
+	"github.com/engvik/dissic/internal/spotify"
 	log "github.com/sirupsen/logrus"
 	"github.com/turnage/graw/reddit"
 )
 
 type spotifyService interface {
 	Authenticate(openBrowser bool) error
-	Listen()
+	Handle(m spotify.Music)
 	Close()
 	PreparePlaylists(cfg *config.Config) error
 	AuthHandler() http.HandlerFunc
 	SetUser() error
+	MapSubreddits(playlists []config.Playlist)
+	RecentActivity(limit int) []activity.Entry
+	CacheStats() cache.Stats
+	SetLists(block config.Lists, allow config.Lists)
+	TrackArtist(track string) (string, string, error)
+	RemoveArtistTracks(artistID string) (int, error)
+	RotatePlaylist(p config.Playlist, archiveName string) (string, error)
+	MatchedPosts(subreddits []string, from time.Time, to time.Time) []history.Entry
+	ReplacePlaylist(key string, posts []history.Entry, n int) (int, error)
+	OrderPlaylist(key string, order string, scores map[string]int) error
+	RefreshDescription(p config.Playlist, now time.Time) error
+	ReconcilePlaylists() (int, error)
+	Suppressed(key string) ([]history.Entry, error)
+	Unsuppress(track string, key string) (int, error)
+	RollbackTo(key string, t time.Time, dryRun bool) ([]history.Entry, error)
+	RollbackRun(runID string, key string, dryRun bool) ([]history.Entry, error)
+	ExportPlaylist(key string) ([]export.Track, error)
+	ExportHistory() ([]export.Track, error)
+	Import(key string, rows []importer.Row) ([]importer.Result, error)
 }
 
 type redditService interface {
-	PrepareScanner() error
-	Listen(shutdown chan<- os.Signal)
-	Close()
+	source.Source
 	Post(post *reddit.Post) error
+	SetSubreddits(subs []string)
+	Pause(subreddit string)
+	Resume(subreddit string)
+	Paused() []string
+	IsPaused(subreddit string) bool
+	Backfill(subreddit string, limit int) (int, error)
+	Score(permalink string) (int, error)
+}
+
+type sinkService interface {
+	Prepare(playlists []config.Playlist) error
+	Handle(subreddit string, q sink.Query)
 }
 
 // Service is the dissic service. It holds the config and all other services.
@@ -36,7 +77,28 @@ type Service struct {
 	Config  *config.Config
 	Spotify spotifyService
 	Reddit  redditService
-	HTTP    *http.Server
+	// Sources are where posts are found, reddit and the feeds.
+	Sources []source.Source
+	// Sinks keep the playlists on services other than Spotify.
+	Sinks sinkService
+	// Music is where the posts found are sent, to be handed to Spotify
+	// and the sinks.
+	Music chan spotify.Music
+	// HTTP serves the Spotify authentication callback, and API the
+	// management API if enabled. They're apart, so commands can
+	// authenticate while dissic is running.
+	HTTP      *http.Server
+	API       *http.Server
+	Scheduler *scheduler.Scheduler
+	// Rotations are the start of the period each rotating playlist was
+	// last rotated in, by playlist key.
+	Rotations map[string]time.Time
+	// Seen are the posts last seen by the sources polling for them.
+	Seen *source.Seen
+	// Out is where commands write their output.
+	Out io.Writer
+
+	mu sync.Mutex
 }
 
 // New returns a new dissic service.
@@ -45,10 +107,17 @@ func New(cfg *config.Config, s spotifyService, r redditService, mux *http.ServeM
 		Config:  cfg,
 		Spotify: s,
 		Reddit:  r,
+		Sources: []source.Source{r},
+		Sinks:   sink.NewRouter(),
+		Music:   make(chan spotify.Music),
 		HTTP: &http.Server{
 			Addr:    fmt.Sprintf(":%d", cfg.HTTPPort),
 			Handler: mux,
 		},
+		Scheduler: scheduler.New(),
+		Rotations: make(map[string]time.Time),
+		Seen:      source.NewSeen(nil),
+		Out:       os.Stdout,
 	}
 
 	return d
@@ -57,54 +126,117 @@ func New(cfg *config.Config, s spotifyService, r redditService, mux *http.ServeM
 // Start starts the dissic service. It takes care of authentication, sets up
 // listeners and are responsible for properly tearing everything down.
 func (s *Service) Start(ctx context.Context) {
-	go func(s *http.Server) {
-		if err := s.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("error starting http server: %s", err)
-		}
-	}(s.HTTP)
-
-	// Authenticate spotify
-	log.WithFields(log.Fields{"service": "spotify"}).Infoln("awaiting authentication...")
-	if err := s.Spotify.Authenticate(s.Config.AuthOpenBrowser); err != nil {
-		log.Fatalf("error authenticating: %s", err)
-	}
-	log.WithFields(log.Fields{"service": "spotify"}).Infoln("authenticated!")
-
-	// HTTP server no longer needed
-	if err := s.HTTP.Shutdown(ctx); err != nil {
-		fmt.Printf("error shutting down http server: %s", err)
-	}
-
-	// Get and set Spotify user
-	if err := s.Spotify.SetUser(); err != nil {
-		log.Fatalf("error setting user ID: %s", err)
-	}
-
-	// Get Spotify playlists
-	if err := s.Spotify.PreparePlaylists(s.Config); err != nil {
-		log.Fatalf("error preparing playlists: %s", err)
+	s.authenticate(ctx)
+
+	if s.API != nil {
+		go func(s *http.Server) {
+			if err := s.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("error starting api server: %s", err)
+			}
+		}(s.API)
 	}
 
-	// Prepare the reddit scanner
-	if err := s.Reddit.PrepareScanner(); err != nil {
-		log.Fatalf("error preparing reddit/graw scanner: %s", err)
-	}
+	// Catch up on and schedule playlist rotations, build digests and
+	// schedule ordering, description refreshes and reconciliation
+	s.scheduleRotations(time.Now())
+	s.scheduleDigests()
+	s.scheduleOrdering()
+	s.scheduleDescriptions()
+	s.scheduleReconciliation()
 
 	// Start listening and block until shutdown signal receieved
 	func(ctx context.Context, s *Service) {
 		shutdown := make(chan os.Signal, 1)
 		signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
-		go s.Spotify.Listen()
+		go s.listen()
 		log.WithFields(log.Fields{"service": "spotify"}).Infoln("helper ready")
-		go s.Reddit.Listen(shutdown)
-		log.WithFields(log.Fields{"service": "reddit"}).Infoln("helper ready")
+
+		s.Seen.OnChange = s.saveSeen
+
+		for _, src := range s.Sources {
+			if err := src.Start(shutdown); err != nil {
+				log.Fatalf("error starting %s: %s", src.Name(), err)
+			}
+
+			log.WithFields(log.Fields{"service": src.Name()}).Infoln("helper ready")
+		}
 
 		<-shutdown
 
+		s.Scheduler.Stop()
+
+		for _, src := range s.Sources {
+			src.Close()
+		}
+
+		s.saveSeen()
+
+		close(s.Music)
 		s.Spotify.Close()
-		s.Reddit.Close()
+
+		if s.API != nil {
+			if err := s.API.Shutdown(ctx); err != nil {
+				log.WithFields(log.Fields{"service": "dissic"}).Errorf("error shutting down api server: %s", err)
+			}
+		}
 
 		log.WithFields(log.Fields{"service": "dissic"}).Infoln("bye, bye!")
 	}(ctx, s)
 }
+
+// listen hands the posts found to Spotify, unless no playlists are kept
+// there, and the sinks.
+func (s *Service) listen() {
+	useSpotify := s.Config.UsesService(config.ServiceSpotify)
+
+	for m := range s.Music {
+		if useSpotify {
+			s.Spotify.Handle(m)
+		}
+
+		s.Sinks.Handle(m.Subreddit, m.Query())
+	}
+}
+
+// authenticate authenticates against Spotify and prepares the playlists.
+// Spotify is left alone if no playlists are kept there, so dissic can run
+// with only local playlists. The HTTP server is only run while waiting for
+// the authentication callback.
+func (s *Service) authenticate(ctx context.Context) {
+	useSpotify := s.Config.UsesService(config.ServiceSpotify)
+
+	// Authenticate spotify
+	if useSpotify {
+		go func(s *http.Server) {
+			if err := s.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("error starting http server: %s", err)
+			}
+		}(s.HTTP)
+
+		log.WithFields(log.Fields{"service": "spotify"}).Infoln("awaiting authentication...")
+		if err := s.Spotify.Authenticate(s.Config.AuthOpenBrowser); err != nil {
+			log.Fatalf("error authenticating: %s", err)
+		}
+		log.WithFields(log.Fields{"service": "spotify"}).Infoln("authenticated!")
+
+		if err := s.HTTP.Shutdown(ctx); err != nil {
+			fmt.Printf("error shutting down http server: %s", err)
+		}
+
+		// Get and set Spotify user
+		if err := s.Spotify.SetUser(); err != nil {
+			log.Fatalf("error setting user ID: %s", err)
+		}
+
+		// Get Spotify playlists
+		if err := s.Spotify.PreparePlaylists(s.Config); err != nil {
+			log.Fatalf("error preparing playlists: %s", err)
+		}
+	}
+
+	// Get playlists on the other services
+	if err := s.Sinks.Prepare(s.Config.Playlists); err != nil {
+		log.Fatalf("error preparing sink playlists: %s", err)
+	}
+}
@@ -5,29 +5,171 @@ import (
 	"net/http"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/cache"
 	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/export"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/importer"
+	"github.com/engvik/dissic/internal/sink"
+	"github.com/engvik/dissic/internal/spotify"
 	"github.com/turnage/graw/reddit"
 )
 
-type spotifyTestService struct{}
+type spotifyTestService struct {
+	playlists  []config.Playlist
+	block      config.Lists
+	removed    []string
+	rotated    []string
+	matched    []history.Entry
+	replaced   map[string][]string
+	ordered    map[string]int
+	described  []string
+	suppressed []history.Entry
+	rollbacks  []string
+	handled    []spotify.Music
+}
 
 func (s *spotifyTestService) Authenticate(openBrowser bool) error       { return nil }
-func (s *spotifyTestService) Listen()                                   {}
+func (s *spotifyTestService) Handle(m spotify.Music)                    { s.handled = append(s.handled, m) }
 func (s *spotifyTestService) Close()                                    {}
 func (s *spotifyTestService) PreparePlaylists(cfg *config.Config) error { return nil }
 func (s *spotifyTestService) AuthHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {}
 }
-func (s *spotifyTestService) SetUser() error { return nil }
+func (s *spotifyTestService) SetUser() error                            { return nil }
+func (s *spotifyTestService) MapSubreddits(playlists []config.Playlist) { s.playlists = playlists }
+func (s *spotifyTestService) RecentActivity(limit int) []activity.Entry { return nil }
+func (s *spotifyTestService) CacheStats() cache.Stats                   { return cache.Stats{} }
+func (s *spotifyTestService) SetLists(block config.Lists, allow config.Lists) {
+	s.block = block
+}
+func (s *spotifyTestService) TrackArtist(track string) (string, string, error) {
+	if track != "track1" {
+		return "", "", fmt.Errorf("track %s not found in any playlist", track)
+	}
+	return "artist1", "Artist One", nil
+}
+func (s *spotifyTestService) RemoveArtistTracks(artistID string) (int, error) {
+	s.removed = append(s.removed, artistID)
+	return 2, nil
+}
+func (s *spotifyTestService) MatchedPosts(subreddits []string, from time.Time, to time.Time) []history.Entry {
+	var res []history.Entry
+	for _, e := range s.matched {
+		if !e.Time.Before(from) && e.Time.Before(to) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+func (s *spotifyTestService) ReplacePlaylist(key string, posts []history.Entry, n int) (int, error) {
+	if s.replaced == nil {
+		s.replaced = make(map[string][]string)
+	}
+	var trackIDs []string
+	for _, p := range posts {
+		if len(trackIDs) < n {
+			trackIDs = append(trackIDs, p.TrackID)
+		}
+	}
+	s.replaced[key] = trackIDs
+	return len(trackIDs), nil
+}
+func (s *spotifyTestService) OrderPlaylist(key string, order string, scores map[string]int) error {
+	s.ordered = scores
+	return nil
+}
+func (s *spotifyTestService) RefreshDescription(p config.Playlist, now time.Time) error {
+	s.described = append(s.described, p.RenderDescription(now, 0))
+	return nil
+}
+func (s *spotifyTestService) ReconcilePlaylists() (int, error) { return 0, nil }
+func (s *spotifyTestService) Suppressed(key string) ([]history.Entry, error) {
+	if key != "" && key != "one" {
+		return nil, fmt.Errorf("playlist %s not found", key)
+	}
+	return s.suppressed, nil
+}
+func (s *spotifyTestService) Unsuppress(track string, key string) (int, error) {
+	return 1, nil
+}
+func (s *spotifyTestService) RollbackTo(key string, t time.Time, dryRun bool) ([]history.Entry, error) {
+	s.rollbacks = append(s.rollbacks, fmt.Sprintf("%s to %s, dry run %t", key, t.Format(time.RFC3339), dryRun))
+	return []history.Entry{{Status: history.StatusRemoved, Playlist: key, TrackID: "track1", Title: "Daft Punk - Around the World"}}, nil
+}
+func (s *spotifyTestService) RollbackRun(runID string, key string, dryRun bool) ([]history.Entry, error) {
+	s.rollbacks = append(s.rollbacks, fmt.Sprintf("%s run %s, dry run %t", key, runID, dryRun))
+	return nil, fmt.Errorf("run %s not found", runID)
+}
+func (s *spotifyTestService) ExportPlaylist(key string) ([]export.Track, error) {
+	if key != "one" {
+		return nil, fmt.Errorf("playlist %s not found", key)
+	}
+	return []export.Track{{Artist: "Daft Punk", Title: "Around the World", URI: "spotify:track:track1"}}, nil
+}
+func (s *spotifyTestService) ExportHistory() ([]export.Track, error) {
+	return []export.Track{{Title: "Daft Punk - Around the World", URI: "spotify:track:track1", Status: history.StatusAdded}}, nil
+}
+func (s *spotifyTestService) Import(key string, rows []importer.Row) ([]importer.Result, error) {
+	if key != "one" {
+		return nil, fmt.Errorf("playlist %s not found", key)
+	}
+	results := make([]importer.Result, 0, len(rows))
+	for _, row := range rows {
+		if row.Artist == "Nobody" {
+			results = append(results, importer.Result{Row: row, Status: activity.StatusUnmatched, Message: "no track found"})
+			continue
+		}
+		results = append(results, importer.Result{Row: row, Status: activity.StatusAdded, TrackID: "track1"})
+	}
+	return results, nil
+}
+func (s *spotifyTestService) RotatePlaylist(p config.Playlist, archiveName string) (string, error) {
+	s.rotated = append(s.rotated, archiveName)
+	return "archive", nil
+}
+
+type sinkTestService struct {
+	handled []sink.Query
+}
+
+func (s *sinkTestService) Prepare(playlists []config.Playlist) error { return nil }
+func (s *sinkTestService) Handle(subreddit string, q sink.Query) {
+	s.handled = append(s.handled, q)
+}
 
 type redditTestService struct {
+	subreddits []string
+	paused     map[string]bool
+	scores     map[string]int
 }
 
-func (r *redditTestService) PrepareScanner() error            { return nil }
-func (r *redditTestService) Listen(shutdown chan<- os.Signal) {}
-func (r *redditTestService) Close()                           {}
-func (r *redditTestService) Post(post *reddit.Post) error     { return nil }
+func (r *redditTestService) Name() string                          { return "reddit" }
+func (r *redditTestService) Start(shutdown chan<- os.Signal) error { return nil }
+func (r *redditTestService) Close()                                {}
+func (r *redditTestService) Post(post *reddit.Post) error          { return nil }
+func (r *redditTestService) SetSubreddits(subs []string)           { r.subreddits = subs }
+func (r *redditTestService) Pause(subreddit string)                { r.paused[subreddit] = true }
+func (r *redditTestService) Resume(subreddit string)               { delete(r.paused, subreddit) }
+func (r *redditTestService) IsPaused(subreddit string) bool        { return r.paused[subreddit] }
+func (r *redditTestService) Paused() []string {
+	var subs []string
+	for sub := range r.paused {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+func (r *redditTestService) Backfill(subreddit string, limit int) (int, error) { return limit, nil }
+func (r *redditTestService) Score(permalink string) (int, error) {
+	score, ok := r.scores[permalink]
+	if !ok {
+		return 0, fmt.Errorf("fetching post %s: not found", permalink)
+	}
+	return score, nil
+}
 
 func TestNew(t *testing.T) {
 	cfg := &config.Config{}
@@ -41,19 +183,43 @@ func TestNew(t *testing.T) {
 		d := New(cfg, s, r, mux)
 
 		if d.Config == nil {
-			fmt.Errorf("dissic service missing config")
+			t.Errorf("dissic service missing config")
 		}
 
 		if d.Spotify == nil {
-			fmt.Errorf("dissic service missing spotify service")
+			t.Errorf("dissic service missing spotify service")
 		}
 
 		if d.Reddit == nil {
-			fmt.Errorf("dissic service missing reddit service")
+			t.Errorf("dissic service missing reddit service")
 		}
 
 		if d.HTTP == nil {
-			fmt.Errorf("dissic service missing http server")
+			t.Errorf("dissic service missing http server")
 		}
 	})
 }
+
+func TestListen(t *testing.T) {
+	d, s, _ := newTestService(t)
+	sinks := &sinkTestService{}
+	d.Sinks = sinks
+
+	done := make(chan struct{})
+	go func() {
+		d.listen()
+		close(done)
+	}()
+
+	d.Music <- spotify.Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World"}
+	close(d.Music)
+	<-done
+
+	if len(s.handled) != 1 || s.handled[0].PostTitle != "Daft Punk - Around the World" {
+		t.Errorf("unexpected spotify posts: %+v", s.handled)
+	}
+
+	if len(sinks.handled) != 1 || sinks.handled[0].Titles[0] != "Daft Punk - Around the World" {
+		t.Errorf("unexpected sink queries: %+v", sinks.handled)
+	}
+}
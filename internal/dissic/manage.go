@@ -0,0 +1,208 @@
+package dissic
+
+import (
+	"fmt"
+
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/api"
+	"github.com/engvik/dissic/internal/cache"
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// Playlists returns the configured playlists and the sources feeding them.
+func (s *Service) Playlists() []api.Playlist {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	playlists := make([]api.Playlist, 0, len(s.Config.Playlists))
+
+	for _, p := range s.Config.Playlists {
+		ap := api.Playlist{
+			Key:     p.Key(),
+			Name:    p.Name,
+			ID:      p.ID,
+			Sources: make([]api.Source, 0, len(p.Sources())),
+		}
+
+		for _, sub := range p.Subreddits {
+			ap.Sources = append(ap.Sources, api.Source{
+				Subreddit: sub,
+				Paused:    s.Reddit.IsPaused(sub),
+			})
+		}
+
+		for _, feed := range p.Feeds {
+			ap.Sources = append(ap.Sources, api.Source{Feed: feed})
+		}
+
+		for _, community := range p.Communities {
+			ap.Sources = append(ap.Sources, api.Source{Community: community})
+		}
+
+		for _, timeline := range p.Timelines {
+			ap.Sources = append(ap.Sources, api.Source{Timeline: timeline})
+		}
+
+		playlists = append(playlists, ap)
+	}
+
+	return playlists
+}
+
+// AddSubreddit adds a subreddit to the playlist identified by key.
+func (s *Service) AddSubreddit(key string, subreddit string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.Config.Playlist(key)
+	if p == nil {
+		return fmt.Errorf("playlist %s: %w", key, api.ErrNotFound)
+	}
+
+	subreddit = config.CleanSubreddit(subreddit)
+
+	for _, sub := range p.Subreddits {
+		if sub == subreddit {
+			return nil
+		}
+	}
+
+	p.Subreddits = append(p.Subreddits, subreddit)
+
+	return s.subredditsChanged()
+}
+
+// RemoveSubreddit removes a subreddit from the playlist identified by key.
+func (s *Service) RemoveSubreddit(key string, subreddit string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.Config.Playlist(key)
+	if p == nil {
+		return fmt.Errorf("playlist %s: %w", key, api.ErrNotFound)
+	}
+
+	subreddit = config.CleanSubreddit(subreddit)
+
+	for i, sub := range p.Subreddits {
+		if sub == subreddit {
+			p.Subreddits = append(p.Subreddits[:i], p.Subreddits[i+1:]...)
+			return s.subredditsChanged()
+		}
+	}
+
+	return fmt.Errorf("subreddit r/%s in playlist %s: %w", subreddit, key, api.ErrNotFound)
+}
+
+// PauseSource pauses processing posts from a subreddit.
+func (s *Service) PauseSource(subreddit string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subreddit = config.CleanSubreddit(subreddit)
+	if !s.watching(subreddit) {
+		return fmt.Errorf("subreddit r/%s: %w", subreddit, api.ErrNotFound)
+	}
+
+	s.Reddit.Pause(subreddit)
+
+	return s.savePaused()
+}
+
+// ResumeSource resumes processing posts from a paused subreddit.
+func (s *Service) ResumeSource(subreddit string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subreddit = config.CleanSubreddit(subreddit)
+	if !s.watching(subreddit) {
+		return fmt.Errorf("subreddit r/%s: %w", subreddit, api.ErrNotFound)
+	}
+
+	s.Reddit.Resume(subreddit)
+
+	return s.savePaused()
+}
+
+// Backfill queues up to limit of the newest posts from a subreddit for
+// processing.
+func (s *Service) Backfill(subreddit string, limit int) (int, error) {
+	s.mu.Lock()
+	subreddit = config.CleanSubreddit(subreddit)
+	watching := s.watching(subreddit)
+	s.mu.Unlock()
+
+	if !watching {
+		return 0, fmt.Errorf("subreddit r/%s: %w", subreddit, api.ErrNotFound)
+	}
+
+	return s.Reddit.Backfill(subreddit, limit)
+}
+
+// Activity returns up to limit of the most recent activity entries.
+func (s *Service) Activity(limit int) []activity.Entry {
+	return s.Spotify.RecentActivity(limit)
+}
+
+// CacheStats returns the search cache hit and miss counts.
+func (s *Service) CacheStats() cache.Stats {
+	return s.Spotify.CacheStats()
+}
+
+func (s *Service) watching(subreddit string) bool {
+	for _, sub := range s.Config.Reddit.Subreddits {
+		if sub == subreddit {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Service) subredditsChanged() error {
+	s.Config.UpdateSubreddits()
+	s.Spotify.MapSubreddits(s.Config.Playlists)
+	s.Reddit.SetSubreddits(s.Config.Reddit.Subreddits)
+
+	return s.updateState(func(st *state.State) {
+		st.Playlists = nil
+
+		for _, p := range s.Config.Playlists {
+			st.Playlists = append(st.Playlists, state.Playlist{
+				Key:        p.Key(),
+				Subreddits: p.Subreddits,
+			})
+		}
+	})
+}
+
+func (s *Service) savePaused() error {
+	return s.updateState(func(st *state.State) {
+		st.Paused = s.Reddit.Paused()
+	})
+}
+
+// updateState changes the state file with update, keeping the changes
+// made to it by other dissic processes.
+func (s *Service) updateState(update func(st *state.State)) error {
+	if err := state.Update(s.Config.StateFile, update); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+
+	return nil
+}
+
+// saveSeen saves the posts last seen by the sources in the state file.
+func (s *Service) saveSeen() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.updateState(func(st *state.State) {
+		st.Seen = s.Seen.All()
+	})
+	if err != nil {
+		log.WithFields(log.Fields{"service": "dissic"}).Errorf("error saving seen posts: %s", err)
+	}
+}
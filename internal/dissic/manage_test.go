@@ -0,0 +1,122 @@
+package dissic
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/engvik/dissic/internal/api"
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/state"
+)
+
+func newTestService(t *testing.T) (*Service, *spotifyTestService, *redditTestService) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "dissic")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cfg := &config.Config{
+		StateFile: filepath.Join(dir, "state.json"),
+		Playlists: []config.Playlist{
+			{Name: "one", Subreddits: []string{"music"}},
+		},
+	}
+	cfg.UpdateSubreddits()
+
+	s := &spotifyTestService{}
+	r := &redditTestService{paused: make(map[string]bool)}
+
+	return New(cfg, s, r, http.NewServeMux()), s, r
+}
+
+func TestAddSubreddit(t *testing.T) {
+	d, s, r := newTestService(t)
+
+	t.Run("should add subreddit and persist state", func(t *testing.T) {
+		if err := d.AddSubreddit("one", "r/Jazz"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(r.subreddits) != 2 || r.subreddits[1] != "jazz" {
+			t.Errorf("unexpected watched subreddits: %v", r.subreddits)
+		}
+
+		if len(s.playlists) != 1 || len(s.playlists[0].Subreddits) != 2 {
+			t.Errorf("unexpected mapped playlists: %+v", s.playlists)
+		}
+
+		st, err := state.Load(d.Config.StateFile)
+		if err != nil {
+			t.Fatalf("unexpected error loading state: %s", err)
+		}
+
+		if len(st.Playlists) != 1 || len(st.Playlists[0].Subreddits) != 2 {
+			t.Errorf("unexpected state: %+v", st)
+		}
+	})
+
+	t.Run("should return not found for unknown playlist", func(t *testing.T) {
+		err := d.AddSubreddit("unknown", "jazz")
+		if !errors.Is(err, api.ErrNotFound) {
+			t.Errorf("unexpected error: got %v, exp %v", err, api.ErrNotFound)
+		}
+	})
+}
+
+func TestRemoveSubreddit(t *testing.T) {
+	d, _, r := newTestService(t)
+
+	t.Run("should remove subreddit", func(t *testing.T) {
+		if err := d.RemoveSubreddit("one", "music"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(r.subreddits) != 0 {
+			t.Errorf("unexpected watched subreddits: %v", r.subreddits)
+		}
+	})
+
+	t.Run("should return not found for unknown subreddit", func(t *testing.T) {
+		err := d.RemoveSubreddit("one", "music")
+		if !errors.Is(err, api.ErrNotFound) {
+			t.Errorf("unexpected error: got %v, exp %v", err, api.ErrNotFound)
+		}
+	})
+}
+
+func TestPauseSource(t *testing.T) {
+	d, _, r := newTestService(t)
+
+	t.Run("should pause and resume source", func(t *testing.T) {
+		if err := d.PauseSource("Music"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		playlists := d.Playlists()
+		if !r.paused["music"] || !playlists[0].Sources[0].Paused {
+			t.Errorf("source not paused: %+v", playlists)
+		}
+
+		if err := d.ResumeSource("music"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if r.paused["music"] {
+			t.Errorf("source not resumed")
+		}
+	})
+
+	t.Run("should return not found for unknown source", func(t *testing.T) {
+		err := d.PauseSource("jazz")
+		if !errors.Is(err, api.ErrNotFound) {
+			t.Errorf("unexpected error: got %v, exp %v", err, api.ErrNotFound)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package dissic
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduleOrdering schedules reordering the playlists ordered by score,
+// release date or shuffled.
+func (s *Service) scheduleOrdering() {
+	for _, p := range s.Config.Playlists {
+		if !p.Reorders() {
+			continue
+		}
+
+		key := p.Key()
+
+		s.Scheduler.Add("order "+key, p.OrderInterval, func(t time.Time) {
+			if err := s.orderPlaylist(key, t); err != nil {
+				log.WithFields(log.Fields{"service": "dissic"}).Errorf("error ordering playlist: %s", err)
+			}
+		})
+	}
+}
+
+// orderPlaylist reorders a playlist. For the score order, the scores of
+// the posts matched in the playlist's subreddits until now are refreshed,
+// and each track gets the score of its highest scoring post.
+func (s *Service) orderPlaylist(key string, now time.Time) error {
+	s.mu.Lock()
+	p := s.Config.Playlist(key)
+	if p == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("playlist %s not found", key)
+	}
+	order := p.Order
+	subreddits := p.Sources()
+	s.mu.Unlock()
+
+	var scores map[string]int
+
+	if order == config.OrderScore {
+		scores = make(map[string]int)
+
+		for _, post := range s.refreshScores(s.Spotify.MatchedPosts(subreddits, time.Time{}, now)) {
+			if score, ok := scores[post.TrackID]; !ok || post.Score > score {
+				scores[post.TrackID] = post.Score
+			}
+		}
+	}
+
+	if err := s.Spotify.OrderPlaylist(key, order, scores); err != nil {
+		return fmt.Errorf("ordering playlist %s: %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package dissic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+)
+
+func TestOrderPlaylist(t *testing.T) {
+	d, s, r := newTestService(t)
+	d.Config.Playlists[0].Order = config.OrderScore
+
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	s.matched = []history.Entry{
+		{TrackID: "track1", Score: 1, Permalink: "/r/music/comments/1/", Time: now.AddDate(0, -1, 0)},
+		{TrackID: "track1", Score: 20, Time: now.AddDate(0, 0, -1)},
+		{TrackID: "track2", Score: 5, Time: now.AddDate(0, 0, -1)},
+	}
+	r.scores = map[string]int{"/r/music/comments/1/": 100}
+
+	if err := d.orderPlaylist("one", now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if s.ordered["track1"] != 100 || s.ordered["track2"] != 5 {
+		t.Errorf("unexpected scores: %v", s.ordered)
+	}
+
+	if err := d.orderPlaylist("unknown", now); err == nil {
+		t.Errorf("expected error for unknown playlist")
+	}
+}
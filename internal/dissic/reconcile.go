@@ -0,0 +1,25 @@
+package dissic
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduleReconciliation reconciles the playlists with the history right
+// away, and schedules reconciling them every reconcile interval.
+func (s *Service) scheduleReconciliation() {
+	reconcile := func(t time.Time) {
+		suppressed, err := s.Spotify.ReconcilePlaylists()
+		if err != nil {
+			log.WithFields(log.Fields{"service": "dissic"}).Errorf("error reconciling playlists: %s", err)
+		}
+
+		if suppressed > 0 {
+			log.WithFields(log.Fields{"service": "dissic"}).Infof("suppressed %d tracks removed by users", suppressed)
+		}
+	}
+
+	s.Scheduler.Run("reconcile", reconcile)
+	s.Scheduler.Add("reconcile", s.Config.ReconcileInterval, reconcile)
+}
@@ -0,0 +1,74 @@
+package dissic
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/engvik/dissic/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduleRotations rotates the playlists that should have been rotated
+// while dissic wasn't running, and schedules the next rotations.
+func (s *Service) scheduleRotations(now time.Time) {
+	for _, p := range s.Config.Playlists {
+		if p.Rotate == "" {
+			continue
+		}
+
+		key := p.Key()
+
+		if err := s.rotate(key, now); err != nil {
+			log.WithFields(log.Fields{"service": "dissic"}).Errorf("error rotating playlist: %s", err)
+		}
+
+		s.Scheduler.Add("rotate "+key, p.Rotate, func(t time.Time) {
+			if err := s.rotate(key, t); err != nil {
+				log.WithFields(log.Fields{"service": "dissic"}).Errorf("error rotating playlist: %s", err)
+			}
+		})
+	}
+}
+
+// rotate archives a playlist unless it has been rotated since the start of
+// the period now is in. The archive is named after the period the playlist
+// was started in. The first time a playlist is seen, only the start of the
+// period is recorded.
+func (s *Service) rotate(key string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.Config.Playlist(key)
+	if p == nil || p.Rotate == "" {
+		return nil
+	}
+
+	start := p.Rotate.Start(now)
+
+	last, ok := s.Rotations[key]
+	if ok && !last.Before(start) {
+		return nil
+	}
+
+	if ok {
+		archive := p.Archive(p.Rotate.Name(last))
+
+		if _, err := s.Spotify.RotatePlaylist(*p, archive); err != nil {
+			return fmt.Errorf("rotating playlist %s: %w", key, err)
+		}
+
+		s.Spotify.MapSubreddits(s.Config.Playlists)
+
+		log.WithFields(log.Fields{"service": "dissic"}).Infof("rotated playlist %s to %s", key, archive)
+	}
+
+	s.Rotations[key] = start
+
+	return s.updateState(func(st *state.State) {
+		if st.Rotations == nil {
+			st.Rotations = make(map[string]time.Time)
+		}
+
+		st.Rotations[key] = start
+	})
+}
@@ -0,0 +1,79 @@
+package dissic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/scheduler"
+	"github.com/engvik/dissic/internal/state"
+)
+
+func TestRotate(t *testing.T) {
+	d, s, _ := newTestService(t)
+	d.Config.Playlists[0].Rotate = scheduler.Weekly
+	d.Config.Playlists[0].ArchiveName = "{name} — {period}"
+
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should only record the period the first time", func(t *testing.T) {
+		if err := d.rotate("one", monday.Add(36*time.Hour)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(s.rotated) != 0 {
+			t.Errorf("unexpected rotations: %v", s.rotated)
+		}
+
+		if got := d.Rotations["one"]; !got.Equal(monday) {
+			t.Errorf("unexpected value: got %s, exp %s", got, monday)
+		}
+	})
+
+	t.Run("should not rotate twice in a period", func(t *testing.T) {
+		if err := d.rotate("one", monday.Add(72*time.Hour)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(s.rotated) != 0 {
+			t.Errorf("unexpected rotations: %v", s.rotated)
+		}
+	})
+
+	t.Run("should rotate in the next period", func(t *testing.T) {
+		next := monday.AddDate(0, 0, 7)
+
+		if err := d.rotate("one", next); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(s.rotated) != 1 || s.rotated[0] != "one — 2026-W42" {
+			t.Errorf("unexpected rotations: %v", s.rotated)
+		}
+
+		if len(s.playlists) != 1 {
+			t.Errorf("expected playlists to be mapped again: %+v", s.playlists)
+		}
+
+		st, err := state.Load(d.Config.StateFile)
+		if err != nil {
+			t.Fatalf("unexpected error loading state: %s", err)
+		}
+
+		if got := st.Rotations["one"]; !got.Equal(next) {
+			t.Errorf("unexpected state: got %s, exp %s", got, next)
+		}
+	})
+
+	t.Run("should ignore playlists not rotating", func(t *testing.T) {
+		d.Config.Playlists = append(d.Config.Playlists, config.Playlist{Name: "two", Subreddits: []string{"jazz"}})
+
+		if err := d.rotate("two", monday); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if _, ok := d.Rotations["two"]; ok {
+			t.Errorf("unexpected rotation recorded")
+		}
+	})
+}
@@ -0,0 +1,199 @@
+// Package export writes tracks found by dissic to files that can be used
+// outside Spotify.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/engvik/dissic/internal/source"
+)
+
+// Export formats.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXSPF  = "xspf"
+	FormatM3U   = "m3u"
+)
+
+// Formats are the supported export formats.
+var Formats = []string{FormatCSV, FormatJSONL, FormatXSPF, FormatM3U}
+
+// Track is an exported track. Playlist and Status are only set for
+// tracks exported from the history.
+type Track struct {
+	Artist     string    `json:"artist"`
+	Title      string    `json:"title"`
+	Album      string    `json:"album"`
+	ISRC       string    `json:"isrc,omitempty"`
+	URI        string    `json:"uri"`
+	DurationMS int       `json:"duration_ms,omitempty"`
+	Permalink  string    `json:"permalink,omitempty"`
+	Subreddit  string    `json:"subreddit,omitempty"`
+	Score      int       `json:"score,omitempty"`
+	AddedAt    time.Time `json:"added_at"`
+	Playlist   string    `json:"playlist,omitempty"`
+	Status     string    `json:"status,omitempty"`
+}
+
+// ValidFormat reports whether format is a supported export format.
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write writes tracks to w in format. The title is used by the formats
+// with a playlist title.
+func Write(w io.Writer, format string, title string, tracks []Track) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, tracks)
+	case FormatJSONL:
+		return writeJSONL(w, tracks)
+	case FormatXSPF:
+		return writeXSPF(w, title, tracks)
+	case FormatM3U:
+		return writeM3U(w, tracks)
+	default:
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+func writeCSV(w io.Writer, tracks []Track) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"artist", "title", "album", "isrc", "uri", "permalink", "subreddit", "score", "added_at", "playlist", "status"}); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+
+	for _, t := range tracks {
+		var addedAt string
+		if !t.AddedAt.IsZero() {
+			addedAt = t.AddedAt.Format(time.RFC3339)
+		}
+
+		record := []string{t.Artist, t.Title, t.Album, t.ISRC, t.URI, t.Permalink, t.Subreddit, strconv.Itoa(t.Score), addedAt, t.Playlist, t.Status}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("writing csv: %w", err)
+		}
+	}
+
+	cw.Flush()
+
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+
+	return nil
+}
+
+func writeJSONL(w io.Writer, tracks []Track) error {
+	enc := json.NewEncoder(w)
+
+	for _, t := range tracks {
+		if err := enc.Encode(t); err != nil {
+			return fmt.Errorf("writing json: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// xspf is an XML Shareable Playlist, see https://xspf.org/spec.
+type xspf struct {
+	XMLName xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version string      `xml:"version,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   string `xml:"location"`
+	Identifier string `xml:"identifier,omitempty"`
+	Title      string `xml:"title"`
+	Creator    string `xml:"creator"`
+	Album      string `xml:"album,omitempty"`
+	Duration   int    `xml:"duration,omitempty"`
+	Info       string `xml:"info,omitempty"`
+	Annotation string `xml:"annotation,omitempty"`
+}
+
+func writeXSPF(w io.Writer, title string, tracks []Track) error {
+	playlist := xspf{Version: "1", Title: title}
+
+	for _, t := range tracks {
+		var identifier string
+		if t.ISRC != "" {
+			identifier = "isrc:" + t.ISRC
+		}
+
+		var annotation string
+		if t.Subreddit != "" {
+			annotation = fmt.Sprintf("%s, score %d", source.Label(t.Subreddit), t.Score)
+		}
+
+		playlist.Tracks = append(playlist.Tracks, xspfTrack{
+			Location:   t.URI,
+			Identifier: identifier,
+			Title:      t.Title,
+			Creator:    t.Artist,
+			Album:      t.Album,
+			Duration:   t.DurationMS,
+			Info:       t.Permalink,
+			Annotation: annotation,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("writing xspf: %w", err)
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(playlist); err != nil {
+		return fmt.Errorf("writing xspf: %w", err)
+	}
+
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("writing xspf: %w", err)
+	}
+
+	return nil
+}
+
+func writeM3U(w io.Writer, tracks []Track) error {
+	if _, err := io.WriteString(w, "#EXTM3U\n"); err != nil {
+		return fmt.Errorf("writing m3u: %w", err)
+	}
+
+	for _, t := range tracks {
+		// -1 is an unknown duration
+		seconds := -1
+		if t.DurationMS > 0 {
+			seconds = t.DurationMS / 1000
+		}
+
+		name := t.Title
+		if t.Artist != "" {
+			name = t.Artist + " - " + t.Title
+		}
+
+		if _, err := fmt.Fprintf(w, "#EXTINF:%d,%s\n%s\n", seconds, name, t.URI); err != nil {
+			return fmt.Errorf("writing m3u: %w", err)
+		}
+	}
+
+	return nil
+}
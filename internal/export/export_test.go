@@ -0,0 +1,91 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var testTracks = []Track{
+	{
+		Artist:     "Daft Punk",
+		Title:      "Around the World",
+		Album:      "Homework",
+		ISRC:       "GBDUW9700011",
+		URI:        "spotify:track:track1",
+		DurationMS: 429000,
+		Permalink:  "https://www.reddit.com/r/Music/comments/abc/",
+		Subreddit:  "music",
+		Score:      42,
+		AddedAt:    time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	},
+	{Artist: "Aphex Twin", Title: "Windowlicker, Pt. 1", URI: "spotify:track:track2"},
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		n   string
+		exp string
+	}{
+		{
+			FormatCSV,
+			"artist,title,album,isrc,uri,permalink,subreddit,score,added_at,playlist,status\n" +
+				"Daft Punk,Around the World,Homework,GBDUW9700011,spotify:track:track1,https://www.reddit.com/r/Music/comments/abc/,music,42,2026-10-19T12:00:00Z,,\n" +
+				"Aphex Twin,\"Windowlicker, Pt. 1\",,,spotify:track:track2,,,0,,,\n",
+		},
+		{
+			FormatJSONL,
+			`{"artist":"Daft Punk","title":"Around the World","album":"Homework","isrc":"GBDUW9700011","uri":"spotify:track:track1","duration_ms":429000,"permalink":"https://www.reddit.com/r/Music/comments/abc/","subreddit":"music","score":42,"added_at":"2026-10-19T12:00:00Z"}` + "\n" +
+				`{"artist":"Aphex Twin","title":"Windowlicker, Pt. 1","album":"","uri":"spotify:track:track2","added_at":"0001-01-01T00:00:00Z"}` + "\n",
+		},
+		{
+			FormatXSPF,
+			`<?xml version="1.0" encoding="UTF-8"?>
+<playlist xmlns="http://xspf.org/ns/0/" version="1">
+  <title>music</title>
+  <trackList>
+    <track>
+      <location>spotify:track:track1</location>
+      <identifier>isrc:GBDUW9700011</identifier>
+      <title>Around the World</title>
+      <creator>Daft Punk</creator>
+      <album>Homework</album>
+      <duration>429000</duration>
+      <info>https://www.reddit.com/r/Music/comments/abc/</info>
+      <annotation>r/music, score 42</annotation>
+    </track>
+    <track>
+      <location>spotify:track:track2</location>
+      <title>Windowlicker, Pt. 1</title>
+      <creator>Aphex Twin</creator>
+    </track>
+  </trackList>
+</playlist>
+`,
+		},
+		{
+			FormatM3U,
+			"#EXTM3U\n#EXTINF:429,Daft Punk - Around the World\nspotify:track:track1\n#EXTINF:-1,Aphex Twin - Windowlicker, Pt. 1\nspotify:track:track2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			if err := Write(&buf, tt.n, "music", testTracks); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := buf.String(); got != tt.exp {
+				t.Errorf("unexpected value: got %s, exp %s", got, tt.exp)
+			}
+		})
+	}
+
+	t.Run("unknown format", func(t *testing.T) {
+		if err := Write(&bytes.Buffer{}, "pls", "music", testTracks); err == nil {
+			t.Errorf("expected error for unknown format")
+		}
+	})
+}
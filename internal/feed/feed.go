@@ -0,0 +1,356 @@
+// Package feed finds new music in RSS and Atom feeds, like music blogs
+// and Bandcamp feeds.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/source"
+	"github.com/engvik/dissic/internal/spotify"
+	"github.com/engvik/dissic/internal/title"
+)
+
+// Client checks the feeds for new items on an interval, and passes them
+// on for processing.
+type Client struct {
+	*source.Poller
+
+	Feeds     []config.Feed
+	MusicChan chan<- spotify.Music
+
+	http *http.Client
+}
+
+// Item is an item in a feed. Artist and Duration are set for items with
+// iTunes tags, like podcast and audio feeds.
+type Item struct {
+	ID        string
+	Title     string
+	Link      string
+	Published time.Time
+	Artist    string
+	Duration  time.Duration
+}
+
+// New sets up a new feed client. It takes the configuration, the channel
+// to publish new items to for processing and the HTTP client the feeds
+// are fetched with.
+func New(cfg *config.Config, m chan<- spotify.Music, httpClient *http.Client) *Client {
+	c := Client{
+		Feeds:     cfg.RSS.Feeds,
+		MusicChan: m,
+		http:      httpClient,
+	}
+
+	c.Poller = source.NewPoller(source.RSS, time.Duration(cfg.RSS.Interval)*time.Minute, cfg.Version, c.poll)
+	c.Kind = "feeds"
+
+	for _, f := range c.Feeds {
+		c.Channels = append(c.Channels, fmt.Sprintf("%s (%s)", f.Name, f.URL))
+	}
+
+	c.Logger.Infoln("client setup ok")
+
+	return &c
+}
+
+func (c *Client) poll(stop <-chan struct{}) {
+	for _, f := range c.Feeds {
+		if !c.pollFeed(f, stop) {
+			return
+		}
+	}
+}
+
+// pollFeed checks a feed for new items. It returns false if stopped.
+func (c *Client) pollFeed(f config.Feed, stop <-chan struct{}) bool {
+	items, err := c.fetch(f.URL)
+	if err != nil {
+		c.Logger.Errorf("%s: %s", f.Name, err)
+		return true
+	}
+
+	key := source.Key(source.RSS, f.Name)
+
+	last, read := c.Seen.Get(key)
+
+	seen := make(map[string]bool, len(last))
+	for _, id := range last {
+		seen[id] = true
+	}
+
+	ids := make(map[string]bool, len(items))
+	var fresh []Item
+	var newIDs []string
+
+	for _, it := range items {
+		if ids[it.ID] {
+			continue
+		}
+
+		if read && !seen[it.ID] {
+			fresh = append(fresh, it)
+		}
+
+		ids[it.ID] = true
+		newIDs = append(newIDs, it.ID)
+	}
+
+	c.Seen.Set(key, newIDs)
+
+	// feeds list the newest items first
+	for i := len(fresh) - 1; i >= 0; i-- {
+		it := fresh[i]
+		c.Logger.Infof("%s: %s (%s)", f.Name, it.Title, it.Link)
+
+		select {
+		case c.MusicChan <- toMusic(key, it):
+		case <-stop:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *Client) fetch(url string) ([]Item, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.UserAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status: %s", url, resp.Status)
+	}
+
+	items, err := Parse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", url, err)
+	}
+
+	return items, nil
+}
+
+func toMusic(key string, it Item) spotify.Music {
+	posted := it.Published
+	if posted.IsZero() {
+		posted = time.Now().UTC()
+	}
+
+	m := spotify.Music{
+		Source:    source.RSS,
+		Subreddit: key,
+		PostTitle: it.Title,
+		URL:       it.Link,
+		PostID:    it.ID,
+		Permalink: it.Link,
+		Posted:    posted,
+		Duration:  it.Duration,
+	}
+
+	// the title is only the track when the artist is tagged apart
+	if _, err := title.Parse(it.Title); err != nil && it.Artist != "" && it.Title != "" {
+		m.MediaTitle = it.Artist + " - " + it.Title
+		m.Artist = it.Artist
+		m.Title = it.Title
+	}
+
+	return m
+}
+
+// document is an RSS 2.0, RSS 1.0 or Atom feed. Elements match in any
+// namespace, so the same fields read all three.
+type document struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0 has the items next to the channel
+	Items   []rssItem   `xml:"item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title   string `xml:"title"`
+	Links   []link `xml:"link"`
+	GUID    string `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	// Date is the Dublin Core date of RSS 1.0 items
+	Date string `xml:"date"`
+	// Author and Duration are iTunes tags, in their namespace since RSS
+	// has an author element of its own.
+	Author   string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+	Duration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+}
+
+type atomEntry struct {
+	ID        string `xml:"id"`
+	Title     string `xml:"title"`
+	Links     []link `xml:"link"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// link is an RSS link with the URL as text, or an Atom link with the URL
+// in href.
+type link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// Parse reads the items of an RSS or Atom feed.
+func Parse(r io.Reader) ([]Item, error) {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charsetReader
+
+	var doc document
+	if err := d.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding feed: %w", err)
+	}
+
+	var items []Item
+
+	for _, ri := range append(doc.Channel.Items, doc.Items...) {
+		it := Item{
+			ID:        strings.TrimSpace(ri.GUID),
+			Title:     strings.TrimSpace(ri.Title),
+			Published: parseTime(ri.PubDate),
+			Artist:    strings.TrimSpace(ri.Author),
+			Duration:  parseDuration(ri.Duration),
+		}
+
+		if it.Published.IsZero() {
+			it.Published = parseTime(ri.Date)
+		}
+
+		for _, l := range ri.Links {
+			if text := strings.TrimSpace(l.Text); text != "" {
+				it.Link = text
+				break
+			}
+		}
+
+		items = append(items, withID(it))
+	}
+
+	for _, e := range doc.Entries {
+		it := Item{
+			ID:        strings.TrimSpace(e.ID),
+			Title:     strings.TrimSpace(e.Title),
+			Published: parseTime(e.Published),
+		}
+
+		if it.Published.IsZero() {
+			it.Published = parseTime(e.Updated)
+		}
+
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				it.Link = strings.TrimSpace(l.Href)
+				break
+			}
+		}
+
+		items = append(items, withID(it))
+	}
+
+	return items, nil
+}
+
+// withID falls back to the link or title as the ID of items without one.
+func withID(it Item) Item {
+	if it.ID == "" {
+		it.ID = it.Link
+	}
+
+	if it.ID == "" {
+		it.ID = it.Title
+	}
+
+	return it
+}
+
+// timeLayouts are the date formats found in feeds. RSS uses RFC 822 with
+// many variations, Atom and Dublin Core use RFC 3339.
+var timeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02",
+}
+
+// parseTime parses a date in a feed, returning the zero time if it can't.
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+
+	return time.Time{}
+}
+
+// parseDuration parses an iTunes duration, given in seconds or as
+// H:MM:SS or MM:SS, returning zero if it can't.
+func parseDuration(s string) time.Duration {
+	var d time.Duration
+
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0
+		}
+
+		d = d*60 + time.Duration(n)
+	}
+
+	return d * time.Second
+}
+
+// charsetReader reads the Latin-1 feeds besides UTF-8, which is all the
+// XML decoder reads by itself.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "us-ascii":
+	default:
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+
+	b, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, c := range b {
+		buf.WriteRune(rune(c))
+	}
+
+	return &buf, nil
+}
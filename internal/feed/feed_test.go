@@ -0,0 +1,294 @@
+package feed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/source"
+	"github.com/engvik/dissic/internal/spotify"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		n    string
+		file string
+		exp  []Item
+	}{
+		{
+			"should parse rss 2.0",
+			"testdata/rss.xml",
+			[]Item{
+				{
+					ID:        "https://www.gorillavsbear.net/?p=112",
+					Title:     "Khruangbin – May Ninth",
+					Link:      "https://www.gorillavsbear.net/khruangbin-may-ninth/",
+					Published: time.Date(2020, 9, 29, 14, 2, 11, 0, time.UTC),
+				},
+				{
+					ID:        "https://www.gorillavsbear.net/portishead-roads/",
+					Title:     "Portishead - Roads",
+					Link:      "https://www.gorillavsbear.net/portishead-roads/",
+					Published: time.Date(2020, 9, 28, 9, 30, 0, 0, time.UTC),
+				},
+				{
+					ID:        "https://www.gorillavsbear.net/teardrop/",
+					Title:     "Teardrop",
+					Link:      "https://www.gorillavsbear.net/teardrop/",
+					Published: time.Date(2020, 9, 27, 9, 30, 0, 0, time.UTC),
+					Artist:    "Massive Attack",
+					Duration:  330 * time.Second,
+				},
+			},
+		},
+		{
+			"should parse atom",
+			"testdata/atom.xml",
+			[]Item{
+				{
+					ID:        "tag:example.bandcamp.com,2020:track/maria-tambien",
+					Title:     "Maria También by Khruangbin",
+					Link:      "https://khruangbin.bandcamp.com/track/maria-tambien",
+					Published: time.Date(2020, 9, 29, 8, 0, 0, 0, time.UTC),
+				},
+				{
+					ID:        "tag:example.bandcamp.com,2020:track/windowlicker",
+					Title:     "Aphex Twin - Windowlicker",
+					Link:      "https://aphextwin.bandcamp.com/track/windowlicker",
+					Published: time.Date(2020, 9, 28, 8, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			"should parse latin-1 rss 1.0",
+			"testdata/rdf.xml",
+			[]Item{
+				{
+					ID:        "https://example.com/sigur-ros",
+					Title:     "Sigur Rós - Hoppipolla",
+					Link:      "https://example.com/sigur-ros",
+					Published: time.Date(2020, 9, 27, 12, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			f, err := os.Open(tc.file)
+			if err != nil {
+				t.Fatalf("error setting up test: %s", err)
+			}
+			defer f.Close()
+
+			items, err := Parse(f)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if len(items) != len(tc.exp) {
+				t.Fatalf("unexpected number of items: got %d, exp %d", len(items), len(tc.exp))
+			}
+
+			for i, it := range items {
+				if it != tc.exp[i] {
+					t.Errorf("unexpected item, pos %d:\ngot %+v\nexp %+v", i, it, tc.exp[i])
+				}
+			}
+		})
+	}
+}
+
+func TestToMusic(t *testing.T) {
+	tests := []struct {
+		n   string
+		it  Item
+		exp spotify.Music
+	}{
+		{
+			"should keep the title",
+			Item{ID: "1", Title: "Portishead - Roads", Artist: "Portishead"},
+			spotify.Music{PostTitle: "Portishead - Roads"},
+		},
+		{
+			"should set the tagged artist",
+			Item{ID: "2", Title: "Teardrop", Artist: "Massive Attack", Duration: 330 * time.Second},
+			spotify.Music{PostTitle: "Teardrop", MediaTitle: "Massive Attack - Teardrop", Artist: "Massive Attack", Title: "Teardrop", Duration: 330 * time.Second},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			got := toMusic("rss:blog", tc.it)
+
+			if got.PostTitle != tc.exp.PostTitle || got.MediaTitle != tc.exp.MediaTitle || got.Artist != tc.exp.Artist || got.Title != tc.exp.Title || got.Duration != tc.exp.Duration {
+				t.Errorf("unexpected value: got %+v, exp %+v", got, tc.exp)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp time.Duration
+	}{
+		{"330", 330 * time.Second},
+		{"05:30", 330 * time.Second},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second},
+		{"", 0},
+		{"5 minutes", 0},
+	}
+
+	for _, tc := range tests {
+		if got := parseDuration(tc.in); got != tc.exp {
+			t.Errorf("unexpected value: got %s, exp %s", got, tc.exp)
+		}
+	}
+}
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>%s</channel></rss>`
+
+const testItem = `<item><title>%s</title><link>https://blog.example.com/%d</link><guid>%d</guid></item>`
+
+// feedServer serves a feed with the items set, newest first.
+type feedServer struct {
+	mu    sync.Mutex
+	items []string
+}
+
+func (s *feedServer) set(items ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = items
+}
+
+func (s *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Path == "/broken.xml" {
+		http.Error(w, "down", http.StatusInternalServerError)
+		return
+	}
+
+	var items string
+	for i, title := range s.items {
+		id := len(s.items) - i
+		items += fmt.Sprintf(testItem, title, id, id)
+	}
+
+	fmt.Fprintf(w, testFeed, items)
+}
+
+func TestPoll(t *testing.T) {
+	feed := &feedServer{}
+	feed.set("Daft Punk - Around the World")
+
+	srv := httptest.NewServer(feed)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{RSS: config.RSS{
+		Interval: 15,
+		Feeds: []config.Feed{
+			{Name: "Broken", URL: srv.URL + "/broken.xml"},
+			{Name: "Blog", URL: srv.URL + "/feed.xml"},
+		},
+	}}
+
+	music := make(chan spotify.Music, 10)
+	c := New(cfg, music, srv.Client())
+
+	t.Run("should only remember the items when first read", func(t *testing.T) {
+		c.Poll()
+
+		if len(music) != 0 {
+			t.Errorf("unexpected number of items: got %d, exp %d", len(music), 0)
+		}
+	})
+
+	t.Run("should pass on new items oldest first", func(t *testing.T) {
+		feed.set("Portishead - Roads", "Aphex Twin - Windowlicker", "Daft Punk - Around the World")
+		c.Poll()
+
+		exp := []spotify.Music{
+			{Source: "rss", Subreddit: "rss:blog", PostTitle: "Aphex Twin - Windowlicker", URL: "https://blog.example.com/2", PostID: "2", Permalink: "https://blog.example.com/2"},
+			{Source: "rss", Subreddit: "rss:blog", PostTitle: "Portishead - Roads", URL: "https://blog.example.com/3", PostID: "3", Permalink: "https://blog.example.com/3"},
+		}
+
+		if len(music) != len(exp) {
+			t.Fatalf("unexpected number of items: got %d, exp %d", len(music), len(exp))
+		}
+
+		for i, e := range exp {
+			m := <-music
+			if m.Posted.IsZero() {
+				t.Errorf("missing posted time, pos %d", i)
+			}
+
+			m.Posted = time.Time{}
+			if m != e {
+				t.Errorf("unexpected music, pos %d:\ngot %+v\nexp %+v", i, m, e)
+			}
+		}
+	})
+
+	t.Run("should not pass on items again", func(t *testing.T) {
+		c.Poll()
+
+		if len(music) != 0 {
+			t.Errorf("unexpected number of items: got %d, exp %d", len(music), 0)
+		}
+	})
+
+	t.Run("should pass on items posted while stopped when restarted", func(t *testing.T) {
+		feed.set("Massive Attack - Teardrop", "Portishead - Roads", "Aphex Twin - Windowlicker", "Daft Punk - Around the World")
+
+		restarted := New(cfg, music, srv.Client())
+		restarted.Seen = source.NewSeen(c.Seen.All())
+		restarted.Poll()
+
+		if len(music) != 1 {
+			t.Fatalf("unexpected number of items: got %d, exp %d", len(music), 1)
+		}
+
+		if m := <-music; m.PostTitle != "Massive Attack - Teardrop" {
+			t.Errorf("unexpected value: got %s, exp %s", m.PostTitle, "Massive Attack - Teardrop")
+		}
+	})
+}
+
+func TestClose(t *testing.T) {
+	srv := httptest.NewServer(&feedServer{})
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{RSS: config.RSS{
+		Interval: 15,
+		Feeds:    []config.Feed{{Name: "Blog", URL: srv.URL + "/feed.xml"}},
+	}}
+
+	c := New(cfg, make(chan spotify.Music), srv.Client())
+
+	if err := c.Start(make(chan os.Signal, 1)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("close didn't return")
+	}
+}
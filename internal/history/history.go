@@ -0,0 +1,347 @@
+// Package history keeps a persistent record of what dissic did with the
+// tracks it matched, e.g. which playlist they were added to, or why a
+// playlist rejected them.
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses used for history entries. Matched entries record the posts a
+// track was found for, with the time of the post. Suppressed entries
+// record tracks removed from a playlist by a user, which are kept out of
+// it until unsuppressed.
+const (
+	StatusAdded        = "added"
+	StatusRejected     = "rejected"
+	StatusSkipped      = "skipped"
+	StatusRemoved      = "removed"
+	StatusMatched      = "matched"
+	StatusSuppressed   = "suppressed"
+	StatusUnsuppressed = "unsuppressed"
+)
+
+// Entry is a single history entry.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Subreddit string    `json:"subreddit"`
+	Title     string    `json:"title"`
+	Playlist  string    `json:"playlist"`
+	TrackID   string    `json:"track_id"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	PostID    string    `json:"post_id,omitempty"`
+	Permalink string    `json:"permalink,omitempty"`
+	Score     int       `json:"score,omitempty"`
+	// SnapshotID is the playlist snapshot returned by Spotify for the
+	// change recorded.
+	SnapshotID string `json:"snapshot_id,omitempty"`
+	// RunID identifies the dissic run the entry was recorded in.
+	RunID string `json:"run_id,omitempty"`
+}
+
+// Store is a history stored as JSON lines, one entry per line, so adding
+// an entry is a single append. The entries added to the file by other
+// processes, like dissic commands run meanwhile, are read before the
+// history is used. It's safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	runID   string
+	entries []Entry
+	// offset is the size of the file read so far, and lines the number
+	// of lines in it.
+	offset int64
+	lines  int
+}
+
+// Open reads the history file at path. A missing file results in an empty
+// history, and an empty path in a history that's only kept in memory.
+func Open(path string) (*Store, error) {
+	s := Store{path: path}
+
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// read reads the entries added to the history file since it was last
+// read. A line still being written is left for the next read, and a file
+// that was replaced is read from the start.
+func (s *Store) read() error {
+	if s.path == "" {
+		return nil
+	}
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("opening history file: %s, %w", s.path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("reading history file: %s, %w", s.path, err)
+	}
+
+	offset, lines, entries := s.offset, s.lines, s.entries
+	if info.Size() < offset {
+		offset, lines, entries = 0, 0, nil
+	}
+
+	if info.Size() == offset {
+		return nil
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("reading history file: %s, %w", s.path, err)
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("reading history file: %s, %w", s.path, err)
+	}
+
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	if len(data) == 0 {
+		return nil
+	}
+
+	for _, line := range bytes.Split(data[:len(data)-1], []byte("\n")) {
+		lines++
+
+		if len(line) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("unmarshal history file: %s, line %d, %w", s.path, lines, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	s.offset = offset + int64(len(data))
+	s.lines = lines
+	s.entries = entries
+
+	return nil
+}
+
+// refresh reads the entries added to the history file by other processes.
+// A file that can't be read keeps the entries read before, and is tried
+// again the next time.
+func (s *Store) refresh() {
+	_ = s.read()
+}
+
+// SetRunID sets the run ID of the entries added from now on.
+func (s *Store) SetRunID(runID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runID = runID
+}
+
+// Add adds an entry to the history, setting its time and run ID if
+// missing.
+func (s *Store) Add(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.RunID == "" {
+		e.RunID = s.runID
+	}
+
+	if s.path == "" {
+		s.entries = append(s.entries, e)
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal history entry: %w", err)
+	}
+
+	data = append(data, '\n')
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("opening history file: %s, %w", s.path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("writing history file: %s, %w", s.path, err)
+	}
+
+	// Unless the file changed since it was last read, only the entry was
+	// added. Otherwise the entry is read with the others.
+	if end, err := f.Seek(0, io.SeekCurrent); err == nil && end == s.offset+int64(len(data)) {
+		s.offset = end
+		s.lines++
+		s.entries = append(s.entries, e)
+
+		return nil
+	}
+
+	return s.read()
+}
+
+// Playlists returns the playlists a track is in, according to the
+// history: those it was added to and not removed from since.
+func (s *Store) Playlists(trackID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+
+	var playlists []string
+	in := make(map[string]bool)
+
+	for _, e := range s.entries {
+		if e.TrackID != trackID {
+			continue
+		}
+
+		switch e.Status {
+		case StatusAdded:
+			if !in[e.Playlist] {
+				playlists = append(playlists, e.Playlist)
+			}
+			in[e.Playlist] = true
+		case StatusRemoved, StatusSuppressed:
+			in[e.Playlist] = false
+		}
+	}
+
+	res := playlists[:0]
+	for _, p := range playlists {
+		if in[p] {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
+
+// Tracks returns the entries adding the tracks in a playlist, according
+// to the history: those added and not removed since, oldest first.
+func (s *Store) Tracks(playlist string) []Entry {
+	return s.latest(func(e Entry) bool {
+		return e.Playlist == playlist
+	}, StatusAdded, StatusRemoved, StatusSuppressed)
+}
+
+// TracksAt returns the entries adding the tracks in a playlist at a time,
+// according to the history.
+func (s *Store) TracksAt(playlist string, t time.Time) []Entry {
+	return s.latest(func(e Entry) bool {
+		return e.Playlist == playlist && !e.Time.After(t)
+	}, StatusAdded, StatusRemoved, StatusSuppressed)
+}
+
+// Suppressed returns the entries suppressing the tracks suppressed in a
+// playlist, or in all playlists if playlist is empty, oldest first.
+func (s *Store) Suppressed(playlist string) []Entry {
+	return s.latest(func(e Entry) bool {
+		return playlist == "" || e.Playlist == playlist
+	}, StatusSuppressed, StatusAdded, StatusUnsuppressed)
+}
+
+// IsSuppressed reports whether a track is suppressed in a playlist.
+func (s *Store) IsSuppressed(playlist string, trackID string) bool {
+	for _, e := range s.Suppressed(playlist) {
+		if e.TrackID == trackID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// latest returns the matching entries with the status want that are the
+// latest entry with any of the statuses for their playlist and track.
+func (s *Store) latest(match func(Entry) bool, want string, statuses ...string) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+
+	type key struct{ playlist, trackID string }
+
+	last := make(map[key]int)
+	var keys []key
+
+	for i, e := range s.entries {
+		if !match(e) || (e.Status != want && !contains(statuses, e.Status)) {
+			continue
+		}
+
+		k := key{e.Playlist, e.TrackID}
+		if _, ok := last[k]; !ok {
+			keys = append(keys, k)
+		}
+		last[k] = i
+	}
+
+	var res []Entry
+
+	for _, k := range keys {
+		if e := s.entries[last[k]]; e.Status == want {
+			res = append(res, e)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Time.Before(res[j].Time) })
+
+	return res
+}
+
+func contains(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Entries returns the entries for which match returns true, oldest first.
+// A nil match returns all entries.
+func (s *Store) Entries(match func(Entry) bool) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+
+	var res []Entry
+
+	for _, e := range s.entries {
+		if match == nil || match(e) {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
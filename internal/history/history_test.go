@@ -0,0 +1,211 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dissic-history")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "history.jsonl")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening: %s", err)
+	}
+
+	entries := []Entry{
+		{Subreddit: "music", Playlist: "playlist1", TrackID: "track1", Status: StatusAdded},
+		{Subreddit: "music", Playlist: "playlist2", TrackID: "track1", Status: StatusRejected, Reason: "explicit"},
+		{Subreddit: "jazz", Playlist: "playlist2", TrackID: "track2", Status: StatusAdded},
+	}
+
+	for _, e := range entries {
+		if err := s.Add(e); err != nil {
+			t.Fatalf("unexpected error adding: %s", err)
+		}
+	}
+
+	t.Run("should persist entries", func(t *testing.T) {
+		loaded, err := Open(path)
+		if err != nil {
+			t.Fatalf("unexpected error opening: %s", err)
+		}
+
+		got := loaded.Entries(nil)
+		if len(got) != len(entries) {
+			t.Fatalf("unexpected number of entries: got %d, exp %d", len(got), len(entries))
+		}
+
+		for i, e := range got {
+			if e.TrackID != entries[i].TrackID || e.Status != entries[i].Status || e.Time.IsZero() {
+				t.Errorf("unexpected entry, pos %d: %+v", i, e)
+			}
+		}
+	})
+
+	t.Run("should filter entries", func(t *testing.T) {
+		got := s.Entries(func(e Entry) bool { return e.Status == StatusRejected })
+
+		if len(got) != 1 || got[0].Reason != "explicit" {
+			t.Errorf("unexpected entries: %+v", got)
+		}
+	})
+}
+
+func TestPlaylists(t *testing.T) {
+	s, err := Open("")
+	if err != nil {
+		t.Fatalf("unexpected error opening: %s", err)
+	}
+
+	entries := []Entry{
+		{Playlist: "playlist1", TrackID: "track1", Status: StatusAdded},
+		{Playlist: "playlist2", TrackID: "track1", Status: StatusRejected},
+		{Playlist: "playlist3", TrackID: "track1", Status: StatusAdded},
+		{Playlist: "playlist1", TrackID: "track1", Status: StatusRemoved},
+		{Playlist: "playlist2", TrackID: "track2", Status: StatusAdded},
+	}
+
+	for _, e := range entries {
+		if err := s.Add(e); err != nil {
+			t.Fatalf("unexpected error adding: %s", err)
+		}
+	}
+
+	got := s.Playlists("track1")
+	if len(got) != 1 || got[0] != "playlist3" {
+		t.Errorf("unexpected playlists: %v", got)
+	}
+
+	if got := s.Playlists("track3"); len(got) != 0 {
+		t.Errorf("unexpected playlists: %v", got)
+	}
+}
+
+func TestSuppressed(t *testing.T) {
+	s, err := Open("")
+	if err != nil {
+		t.Fatalf("unexpected error opening: %s", err)
+	}
+
+	entries := []Entry{
+		{Playlist: "playlist1", TrackID: "track1", Status: StatusAdded},
+		{Playlist: "playlist1", TrackID: "track2", Status: StatusAdded},
+		{Playlist: "playlist2", TrackID: "track1", Status: StatusAdded},
+		{Playlist: "playlist1", TrackID: "track1", Status: StatusSuppressed},
+		{Playlist: "playlist2", TrackID: "track1", Status: StatusSuppressed},
+		{Playlist: "playlist2", TrackID: "track1", Status: StatusUnsuppressed},
+	}
+
+	for _, e := range entries {
+		if err := s.Add(e); err != nil {
+			t.Fatalf("unexpected error adding: %s", err)
+		}
+	}
+
+	if got := s.Tracks("playlist1"); len(got) != 1 || got[0].TrackID != "track2" {
+		t.Errorf("unexpected tracks: %v", got)
+	}
+
+	if got := s.Playlists("track1"); len(got) != 0 {
+		t.Errorf("unexpected playlists: %v", got)
+	}
+
+	if got := s.Suppressed(""); len(got) != 1 || got[0].Playlist != "playlist1" || got[0].TrackID != "track1" {
+		t.Errorf("unexpected suppressed tracks: %v", got)
+	}
+
+	if !s.IsSuppressed("playlist1", "track1") {
+		t.Errorf("expected track1 to be suppressed in playlist1")
+	}
+
+	if s.IsSuppressed("playlist2", "track1") {
+		t.Errorf("expected track1 to be unsuppressed in playlist2")
+	}
+}
+
+func TestStoreReadsOtherWriters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dissic-history")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "history.jsonl")
+
+	daemon, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening: %s", err)
+	}
+
+	command, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening: %s", err)
+	}
+
+	if err := daemon.Add(Entry{Playlist: "playlist1", TrackID: "track1", Status: StatusSuppressed}); err != nil {
+		t.Fatalf("unexpected error adding: %s", err)
+	}
+
+	t.Run("should read entries added by another store", func(t *testing.T) {
+		if !command.IsSuppressed("playlist1", "track1") {
+			t.Fatalf("expected track1 to be suppressed")
+		}
+
+		if err := command.Add(Entry{Playlist: "playlist1", TrackID: "track1", Status: StatusUnsuppressed}); err != nil {
+			t.Fatalf("unexpected error adding: %s", err)
+		}
+
+		if daemon.IsSuppressed("playlist1", "track1") {
+			t.Errorf("expected track1 to be unsuppressed")
+		}
+	})
+
+	t.Run("should read entries once when both stores add", func(t *testing.T) {
+		if err := command.Add(Entry{Playlist: "playlist1", TrackID: "track2", Status: StatusAdded}); err != nil {
+			t.Fatalf("unexpected error adding: %s", err)
+		}
+
+		if err := daemon.Add(Entry{Playlist: "playlist1", TrackID: "track3", Status: StatusAdded}); err != nil {
+			t.Fatalf("unexpected error adding: %s", err)
+		}
+
+		for _, s := range []*Store{daemon, command} {
+			if got := s.Entries(nil); len(got) != 4 || got[2].TrackID != "track2" || got[3].TrackID != "track3" {
+				t.Errorf("unexpected entries: %+v", got)
+			}
+		}
+	})
+
+	t.Run("should leave a line being written", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatalf("error setting up test: %s", err)
+		}
+		defer f.Close()
+
+		if _, err := f.WriteString(`{"time":"2100-01-01T00:00:00Z","playlist":"playlist1","track_id":"track4",`); err != nil {
+			t.Fatalf("error setting up test: %s", err)
+		}
+
+		if got := daemon.Entries(nil); len(got) != 4 {
+			t.Errorf("unexpected number of entries: got %d, exp %d", len(got), 4)
+		}
+
+		if _, err := f.WriteString(`"status":"added"}` + "\n"); err != nil {
+			t.Fatalf("error setting up test: %s", err)
+		}
+
+		if got := daemon.Tracks("playlist1"); len(got) != 3 || got[2].TrackID != "track4" {
+			t.Errorf("unexpected tracks: %+v", got)
+		}
+	})
+}
@@ -0,0 +1,224 @@
+// Package importer reads lists of tracks to seed playlists with, and
+// writes reports of the rows that weren't added.
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Import formats.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Row is a track to import. Album, ISRC, UPC and URL are optional, URL is
+// a Spotify track URL or URI, and UPC identifies the release of the
+// track. Line is the number of the row in the file, counting the CSV
+// header.
+type Row struct {
+	Artist string `json:"artist"`
+	Title  string `json:"title"`
+	Album  string `json:"album,omitempty"`
+	ISRC   string `json:"isrc,omitempty"`
+	UPC    string `json:"upc,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Line   int    `json:"-"`
+}
+
+// Result is what importing a row resulted in, with the status and message
+// of the activity entry for it.
+type Result struct {
+	Row     Row
+	Status  string
+	Message string
+	TrackID string
+}
+
+// columns are the accepted CSV header names for each row field.
+var columns = map[string][]string{
+	"artist": {"artist", "artists", "creator"},
+	"title":  {"title", "track", "name"},
+	"album":  {"album"},
+	"isrc":   {"isrc"},
+	"upc":    {"upc", "barcode"},
+	"url":    {"url", "uri", "spotify_url", "spotify_uri"},
+}
+
+// FormatFromPath returns the format of a file by its extension: jsonl for
+// .jsonl and .json, csv otherwise.
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json":
+		return FormatJSONL
+	default:
+		return FormatCSV
+	}
+}
+
+// Read reads the rows from r in format. Rows without an artist and title,
+// or a URL, are an error.
+func Read(r io.Reader, format string) ([]Row, error) {
+	var rows []Row
+	var err error
+
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(r)
+	case FormatJSONL:
+		rows, err = readJSONL(r)
+	default:
+		return nil, fmt.Errorf("unknown import format: %s", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if row.URL == "" && row.ISRC == "" && (row.Artist == "" || row.Title == "") {
+			return nil, fmt.Errorf("line %d: missing artist and title, isrc or url", row.Line)
+		}
+	}
+
+	return rows, nil
+}
+
+func readCSV(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+
+	index := make(map[string]int)
+
+	for i, name := range header {
+		name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+
+		for field, names := range columns {
+			for _, n := range names {
+				if _, ok := index[field]; !ok && n == name {
+					index[field] = i
+				}
+			}
+		}
+	}
+
+	if _, ok := index["url"]; !ok {
+		_, artist := index["artist"]
+		_, title := index["title"]
+
+		if _, isrc := index["isrc"]; !isrc && (!artist || !title) {
+			return nil, errors.New("csv header needs artist and title, isrc or url columns")
+		}
+	}
+
+	var rows []Row
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("reading csv: %w", err)
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+
+			return ""
+		}
+
+		rows = append(rows, Row{
+			Artist: field("artist"),
+			Title:  field("title"),
+			Album:  field("album"),
+			ISRC:   field("isrc"),
+			UPC:    field("upc"),
+			URL:    field("url"),
+			Line:   line,
+		})
+	}
+
+	return rows, nil
+}
+
+func readJSONL(r io.Reader) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []Row
+
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var row struct {
+			Row
+			URI string `json:"uri"`
+		}
+
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return nil, fmt.Errorf("unmarshal line %d: %w", n, err)
+		}
+
+		if row.URL == "" {
+			row.URL = row.URI
+		}
+
+		row.Line = n
+		rows = append(rows, row.Row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading jsonl: %w", err)
+	}
+
+	return rows, nil
+}
+
+// WriteReport writes the results as CSV, with the line, fields, status
+// and message of each row.
+func WriteReport(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"line", "artist", "title", "isrc", "url", "status", "message"}); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+
+	for _, res := range results {
+		record := []string{strconv.Itoa(res.Row.Line), res.Row.Artist, res.Row.Title, res.Row.ISRC, res.Row.URL, res.Status, res.Message}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+	}
+
+	cw.Flush()
+
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+
+	return nil
+}
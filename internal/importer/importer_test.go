@@ -0,0 +1,110 @@
+package importer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		n      string
+		format string
+		input  string
+		exp    []Row
+		err    bool
+	}{
+		{
+			"csv",
+			FormatCSV,
+			"Artist,Track,ISRC,Spotify URL\nDaft Punk,Around the World,GBDUW9700011,\n,,,https://open.spotify.com/track/track2\n",
+			[]Row{
+				{Artist: "Daft Punk", Title: "Around the World", ISRC: "GBDUW9700011", Line: 2},
+				{URL: "https://open.spotify.com/track/track2", Line: 3},
+			},
+			false,
+		},
+		{
+			"exported csv",
+			FormatCSV,
+			"artist,title,album,isrc,uri,permalink\nDaft Punk,Around the World,Homework,,spotify:track:track1,\n",
+			[]Row{{Artist: "Daft Punk", Title: "Around the World", Album: "Homework", URL: "spotify:track:track1", Line: 2}},
+			false,
+		},
+		{
+			"csv with release",
+			FormatCSV,
+			"artist,title,album,barcode\nDaft Punk,Around the World,Homework,724384260958\n",
+			[]Row{{Artist: "Daft Punk", Title: "Around the World", Album: "Homework", UPC: "724384260958", Line: 2}},
+			false,
+		},
+		{
+			"jsonl",
+			FormatJSONL,
+			"{\"artist\":\"Daft Punk\",\"title\":\"Around the World\",\"upc\":\"724384260958\"}\n\n{\"uri\":\"spotify:track:track2\"}\n",
+			[]Row{
+				{Artist: "Daft Punk", Title: "Around the World", UPC: "724384260958", Line: 1},
+				{URL: "spotify:track:track2", Line: 3},
+			},
+			false,
+		},
+		{"missing columns", FormatCSV, "artist,album\nDaft Punk,Homework\n", nil, true},
+		{"missing title", FormatJSONL, "{\"artist\":\"Daft Punk\"}\n", nil, true},
+		{"invalid json", FormatJSONL, "{\"artist\":\n", nil, true},
+		{"unknown format", "xlsx", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			rows, err := Read(strings.NewReader(tt.input), tt.format)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(rows) != len(tt.exp) {
+				t.Fatalf("unexpected rows: got %+v, exp %+v", rows, tt.exp)
+			}
+
+			for i := range rows {
+				if rows[i] != tt.exp[i] {
+					t.Errorf("unexpected value: got %+v, exp %+v", rows[i], tt.exp[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var buf bytes.Buffer
+
+	results := []Result{
+		{Row: Row{Artist: "Daft Punk", Title: "Around the World, Live", Line: 2}, Status: "unmatched", Message: "no track found"},
+	}
+
+	if err := WriteReport(&buf, results); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	exp := "line,artist,title,isrc,url,status,message\n2,Daft Punk,\"Around the World, Live\",,,unmatched,no track found\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("unexpected value: got %s, exp %s", got, exp)
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := []struct {
+		n   string
+		exp string
+	}{
+		{"list.csv", FormatCSV},
+		{"list.JSONL", FormatJSONL},
+		{"list.json", FormatJSONL},
+		{"list", FormatCSV},
+	}
+
+	for _, tt := range tests {
+		if got := FormatFromPath(tt.n); got != tt.exp {
+			t.Errorf("unexpected value: got %s, exp %s", got, tt.exp)
+		}
+	}
+}
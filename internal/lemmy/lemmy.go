@@ -0,0 +1,277 @@
+// Package lemmy finds new music in Lemmy communities, by polling the post
+// listing of an instance.
+package lemmy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/source"
+	"github.com/engvik/dissic/internal/spotify"
+)
+
+// pageLimit is the number of posts asked for per page, the most Lemmy
+// lists.
+const pageLimit = 50
+
+// maxPages is the most pages read per check, when more posts than fit on
+// a page were posted since the last one.
+const maxPages = 5
+
+// postLink matches links to Lemmy posts, which crossposts link to.
+var postLink = regexp.MustCompile(`^https?://[^/]+/post/\d+/?$`)
+
+// Client checks the communities for new posts on an interval, and passes
+// them on for processing.
+type Client struct {
+	*source.Poller
+
+	Communities []config.Community
+	MusicChan   chan<- spotify.Music
+
+	http *http.Client
+}
+
+type listResponse struct {
+	Posts []postView `json:"posts"`
+	Error string     `json:"error"`
+}
+
+type postView struct {
+	Post   post   `json:"post"`
+	Counts counts `json:"counts"`
+}
+
+type post struct {
+	ID                int64  `json:"id"`
+	Name              string `json:"name"`
+	URL               string `json:"url"`
+	EmbedTitle        string `json:"embed_title"`
+	APID              string `json:"ap_id"`
+	Published         string `json:"published"`
+	Deleted           bool   `json:"deleted"`
+	Removed           bool   `json:"removed"`
+	FeaturedCommunity bool   `json:"featured_community"`
+	FeaturedLocal     bool   `json:"featured_local"`
+}
+
+type counts struct {
+	Score int `json:"score"`
+}
+
+// New sets up a new Lemmy client. It takes the configuration, the channel
+// to publish new posts to for processing and the HTTP client the
+// instances are requested with.
+func New(cfg *config.Config, m chan<- spotify.Music, httpClient *http.Client) *Client {
+	c := Client{
+		Communities: cfg.Lemmy.Communities,
+		MusicChan:   m,
+		http:        httpClient,
+	}
+
+	c.Poller = source.NewPoller(source.Lemmy, time.Duration(cfg.Lemmy.Interval)*time.Minute, cfg.Version, c.poll)
+	c.Kind = "communities"
+
+	for _, community := range c.Communities {
+		c.Channels = append(c.Channels, fmt.Sprintf("%s (%s, sorted by %s)", community.Community, community.Instance, community.Sort))
+	}
+
+	c.Logger.Infoln("client setup ok")
+
+	return &c
+}
+
+func (c *Client) poll(stop <-chan struct{}) {
+	for _, community := range c.Communities {
+		if !c.pollCommunity(community, stop) {
+			return
+		}
+	}
+}
+
+// pollCommunity checks a community for posts newer than the last one
+// seen. It returns false if stopped.
+func (c *Client) pollCommunity(community config.Community, stop <-chan struct{}) bool {
+	key := source.Key(source.Lemmy, community.Name)
+	last, read := c.last(key)
+
+	posts, err := c.newPosts(community, last, read)
+	if err != nil {
+		c.Logger.Errorf("%s: %s", community.Name, err)
+		return true
+	}
+
+	newest := last
+	for _, pv := range posts {
+		if pv.Post.ID > newest {
+			newest = pv.Post.ID
+		}
+	}
+
+	c.Seen.Set(key, []string{strconv.FormatInt(newest, 10)})
+
+	if !read {
+		return true
+	}
+
+	sort.Slice(posts, func(i, j int) bool { return posts[i].Post.ID < posts[j].Post.ID })
+
+	for _, pv := range posts {
+		if reason := skipReason(pv.Post); reason != "" {
+			c.Logger.Infof("%s: skipping %s post: %s", community.Name, reason, pv.Post.Name)
+			continue
+		}
+
+		c.Logger.Infof("%s: %s (%s)", community.Name, pv.Post.Name, pv.Post.APID)
+
+		select {
+		case c.MusicChan <- toMusic(key, pv):
+		case <-stop:
+			return false
+		}
+	}
+
+	return true
+}
+
+// last returns the ID of the newest post seen in a community, and whether
+// it has been read.
+func (c *Client) last(key string) (int64, bool) {
+	ids, read := c.Seen.Get(key)
+	if len(ids) == 0 {
+		return 0, read
+	}
+
+	id, err := strconv.ParseInt(ids[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return id, read
+}
+
+// newPosts returns the posts listed with a higher ID than last. Posts
+// sorted by New are read page by page until reaching the last post seen,
+// the other orders only have the first page read.
+func (c *Client) newPosts(community config.Community, last int64, read bool) ([]postView, error) {
+	var posts []postView
+	seen := make(map[int64]bool)
+
+	for page := 1; page <= maxPages; page++ {
+		list, err := c.list(community, page)
+		if err != nil {
+			return nil, err
+		}
+
+		reachedLast := false
+
+		for _, pv := range list {
+			if pv.Post.ID <= last {
+				reachedLast = true
+				continue
+			}
+
+			if !seen[pv.Post.ID] {
+				seen[pv.Post.ID] = true
+				posts = append(posts, pv)
+			}
+		}
+
+		if !read || reachedLast || len(list) < pageLimit || community.Sort != "New" {
+			break
+		}
+	}
+
+	return posts, nil
+}
+
+func (c *Client) list(community config.Community, page int) ([]postView, error) {
+	q := url.Values{}
+	q.Set("community_name", community.Community)
+	q.Set("sort", community.Sort)
+	q.Set("limit", strconv.Itoa(pageLimit))
+	q.Set("page", strconv.Itoa(page))
+
+	u := strings.TrimSuffix(community.Instance, "/") + "/api/v3/post/list?" + q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.UserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", community.Community, err)
+	}
+	defer resp.Body.Close()
+
+	var lr listResponse
+	if err := json.NewDecoder(resp.Body).Decode(&lr); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("decoding %s: %w", community.Community, err)
+	}
+
+	if resp.StatusCode != http.StatusOK || lr.Error != "" {
+		return nil, fmt.Errorf("listing %s: %s %s", community.Community, resp.Status, lr.Error)
+	}
+
+	return lr.Posts, nil
+}
+
+// skipReason returns why a post shouldn't be processed, or an empty
+// string if it should.
+func skipReason(p post) string {
+	switch {
+	case p.Deleted:
+		return "deleted"
+	case p.Removed:
+		return "removed"
+	case p.FeaturedCommunity, p.FeaturedLocal:
+		return "featured"
+	default:
+		return ""
+	}
+}
+
+func toMusic(key string, pv postView) spotify.Music {
+	m := spotify.Music{
+		Source:     source.Lemmy,
+		Subreddit:  key,
+		PostTitle:  pv.Post.Name,
+		MediaTitle: pv.Post.EmbedTitle,
+		URL:        pv.Post.URL,
+		PostID:     strconv.FormatInt(pv.Post.ID, 10),
+		Permalink:  pv.Post.APID,
+		Score:      pv.Counts.Score,
+		Posted:     parseTime(pv.Post.Published),
+	}
+
+	// crossposts link to the original lemmy post
+	if postLink.MatchString(m.URL) {
+		m.URL = ""
+	}
+
+	return m
+}
+
+// parseTime parses the publish time of a post. Older Lemmy versions leave
+// out the time zone, which is UTC.
+func parseTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+
+	return time.Now().UTC()
+}
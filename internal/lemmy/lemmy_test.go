@@ -0,0 +1,164 @@
+package lemmy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/lemmy/lemmytest"
+	"github.com/engvik/dissic/internal/source"
+	"github.com/engvik/dissic/internal/spotify"
+)
+
+func newTestClient(t *testing.T, fake *lemmytest.Fake, communities ...config.Community) (*Client, chan spotify.Music) {
+	t.Helper()
+
+	srv := lemmytest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	for i := range communities {
+		communities[i].Instance = srv.URL
+	}
+
+	cfg := &config.Config{Lemmy: config.Lemmy{Interval: 5, Communities: communities}}
+	music := make(chan spotify.Music, 200)
+
+	return New(cfg, music, srv.Client()), music
+}
+
+func TestPoll(t *testing.T) {
+	fake := lemmytest.New("music")
+	fake.AddPost("music", lemmytest.Post{Name: "Daft Punk - Around the World"})
+
+	c, music := newTestClient(t, fake,
+		config.Community{Name: "gone", Community: "gone", Sort: "New"},
+		config.Community{Name: "music", Community: "music", Sort: "New"},
+	)
+
+	t.Run("should only remember the posts when first read", func(t *testing.T) {
+		c.Poll()
+
+		if len(music) != 0 {
+			t.Errorf("unexpected number of posts: got %d, exp %d", len(music), 0)
+		}
+	})
+
+	t.Run("should convert new posts and skip deleted, removed and featured posts", func(t *testing.T) {
+		fake.AddPost("music",
+			lemmytest.Post{Name: "Aphex Twin -- Windowlicker", URL: "https://open.spotify.com/track/4Kk1g8s8ZfK4gS1p5M9WJb", Score: 12},
+			lemmytest.Post{Name: "Rick Astley - Never Gonna Give You Up", Deleted: true},
+			lemmytest.Post{Name: "Weekly Discussion Thread", FeaturedCommunity: true},
+			lemmytest.Post{Name: "Spam", Removed: true},
+			lemmytest.Post{Name: "Portishead - Roads", URL: "https://lemmy.ml/post/1234", EmbedTitle: "Portishead - Roads (Live)", Score: 3},
+		)
+
+		c.Poll()
+
+		exp := []spotify.Music{
+			{
+				Source:    "lemmy",
+				Subreddit: "lemmy:music",
+				PostTitle: "Aphex Twin -- Windowlicker",
+				URL:       "https://open.spotify.com/track/4Kk1g8s8ZfK4gS1p5M9WJb",
+				PostID:    "2",
+				Permalink: "https://lemmy.test/post/2",
+				Score:     12,
+				Posted:    time.Unix(1700000120, 0).UTC(),
+			},
+			{
+				Source:     "lemmy",
+				Subreddit:  "lemmy:music",
+				PostTitle:  "Portishead - Roads",
+				MediaTitle: "Portishead - Roads (Live)",
+				PostID:     "6",
+				Permalink:  "https://lemmy.test/post/6",
+				Score:      3,
+				Posted:     time.Unix(1700000360, 0).UTC(),
+			},
+		}
+
+		if len(music) != len(exp) {
+			t.Fatalf("unexpected number of posts: got %d, exp %d", len(music), len(exp))
+		}
+
+		for i, e := range exp {
+			if m := <-music; m != e {
+				t.Errorf("unexpected music, pos %d:\ngot %+v\nexp %+v", i, m, e)
+			}
+		}
+	})
+
+	t.Run("should not pass on posts again", func(t *testing.T) {
+		c.Poll()
+
+		if len(music) != 0 {
+			t.Errorf("unexpected number of posts: got %d, exp %d", len(music), 0)
+		}
+	})
+
+	t.Run("should pass on posts made while stopped when restarted", func(t *testing.T) {
+		fake.AddPost("music", lemmytest.Post{Name: "Massive Attack - Teardrop"})
+
+		restarted := New(&config.Config{Lemmy: config.Lemmy{Interval: 5, Communities: c.Communities}}, music, c.http)
+		restarted.Seen = source.NewSeen(c.Seen.All())
+		restarted.Poll()
+
+		if len(music) != 1 {
+			t.Fatalf("unexpected number of posts: got %d, exp %d", len(music), 1)
+		}
+
+		if m := <-music; m.PostTitle != "Massive Attack - Teardrop" {
+			t.Errorf("unexpected value: got %s, exp %s", m.PostTitle, "Massive Attack - Teardrop")
+		}
+	})
+}
+
+func TestPollPages(t *testing.T) {
+	fake := lemmytest.New("music")
+	fake.AddPost("music", lemmytest.Post{Name: "Daft Punk - Around the World"})
+
+	c, music := newTestClient(t, fake, config.Community{Name: "music", Community: "music", Sort: "New"})
+	c.Poll()
+
+	for i := 0; i < 120; i++ {
+		fake.AddPost("music", lemmytest.Post{Name: fmt.Sprintf("Artist - Track %d", i)})
+	}
+
+	requests := fake.Requests()
+	c.Poll()
+
+	if len(music) != 120 {
+		t.Fatalf("unexpected number of posts: got %d, exp %d", len(music), 120)
+	}
+
+	if got := fake.Requests() - requests; got != 3 {
+		t.Errorf("unexpected number of pages: got %d, exp %d", got, 3)
+	}
+
+	for i := 0; i < 120; i++ {
+		exp := fmt.Sprintf("Artist - Track %d", i)
+		if m := <-music; m.PostTitle != exp {
+			t.Errorf("unexpected value: got %s, exp %s", m.PostTitle, exp)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		n   string
+		s   string
+		exp time.Time
+	}{
+		{"should parse with time zone", "2023-06-01T12:00:00.123456Z", time.Date(2023, 6, 1, 12, 0, 0, 123456000, time.UTC)},
+		{"should parse without time zone", "2023-06-01T12:00:00.123456", time.Date(2023, 6, 1, 12, 0, 0, 123456000, time.UTC)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			if got := parseTime(tc.s); !got.Equal(tc.exp) {
+				t.Errorf("unexpected value: got %s, exp %s", got, tc.exp)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Package lemmytest provides an in-memory fake of the Lemmy post listing
+// API used by dissic, served by an httptest server, so the lemmy package
+// can be tested without network access.
+package lemmytest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Post is a post in a community. The ID, AP ID and publish time are set
+// when it's added, unless already set.
+type Post struct {
+	ID                int64     `json:"id"`
+	Name              string    `json:"name"`
+	URL               string    `json:"url,omitempty"`
+	EmbedTitle        string    `json:"embed_title,omitempty"`
+	APID              string    `json:"ap_id"`
+	Published         time.Time `json:"published"`
+	Deleted           bool      `json:"deleted"`
+	Removed           bool      `json:"removed"`
+	FeaturedCommunity bool      `json:"featured_community"`
+	FeaturedLocal     bool      `json:"featured_local"`
+	Score             int64     `json:"-"`
+}
+
+// Fake is an in-memory Lemmy instance with communities of posts. It's
+// safe for concurrent use.
+type Fake struct {
+	mu          sync.Mutex
+	communities map[string][]Post
+	nextID      int64
+	requests    int
+}
+
+// New returns a fake with the communities, without posts.
+func New(communities ...string) *Fake {
+	f := Fake{
+		communities: make(map[string][]Post),
+		nextID:      1,
+	}
+
+	for _, c := range communities {
+		f.communities[strings.ToLower(c)] = nil
+	}
+
+	return &f
+}
+
+// AddPost adds posts to a community, and returns them with their IDs.
+func (f *Fake) AddPost(community string, posts ...Post) []Post {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	community = strings.ToLower(community)
+
+	for i := range posts {
+		if posts[i].ID == 0 {
+			posts[i].ID = f.nextID
+		}
+
+		if posts[i].ID >= f.nextID {
+			f.nextID = posts[i].ID + 1
+		}
+
+		if posts[i].APID == "" {
+			posts[i].APID = fmt.Sprintf("https://lemmy.test/post/%d", posts[i].ID)
+		}
+
+		if posts[i].Published.IsZero() {
+			posts[i].Published = time.Unix(1700000000+posts[i].ID*60, 0).UTC()
+		}
+
+		f.communities[community] = append(f.communities[community], posts[i])
+	}
+
+	return posts
+}
+
+// Requests returns the number of listings requested.
+func (f *Fake) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests
+}
+
+// List returns a page of the posts in a community, newest first, and
+// whether the community exists. Pages start at 1.
+func (f *Fake) List(community string, page int, limit int) ([]Post, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+
+	posts, ok := f.communities[strings.ToLower(community)]
+	if !ok {
+		return nil, false
+	}
+
+	sorted := append([]Post(nil), posts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID > sorted[j].ID })
+
+	start := (page - 1) * limit
+	if start >= len(sorted) {
+		return []Post{}, true
+	}
+
+	end := start + limit
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+
+	return sorted[start:end], true
+}
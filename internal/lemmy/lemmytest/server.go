@@ -0,0 +1,84 @@
+package lemmytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+)
+
+// defaultLimit is the number of posts Lemmy lists when no limit is given.
+const defaultLimit = 10
+
+// Server is an httptest server serving a fake as a Lemmy instance.
+type Server struct {
+	*httptest.Server
+	Fake *Fake
+}
+
+// NewServer starts a server serving f. Close it when done.
+func NewServer(f *Fake) *Server {
+	s := Server{Fake: f}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return &s
+}
+
+type postView struct {
+	Post      Post      `json:"post"`
+	Community community `json:"community"`
+	Counts    counts    `json:"counts"`
+}
+
+type community struct {
+	Name string `json:"name"`
+}
+
+type counts struct {
+	PostID int64 `json:"post_id"`
+	Score  int64 `json:"score"`
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.URL.Path != "/api/v3/post/list" {
+		writeError(w, http.StatusNotFound, "not_found")
+		return
+	}
+
+	q := r.URL.Query()
+	name := q.Get("community_name")
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit < 1 {
+		limit = defaultLimit
+	}
+
+	posts, ok := s.Fake.List(name, page, limit)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "couldnt_find_community")
+		return
+	}
+
+	views := make([]postView, 0, len(posts))
+	for _, p := range posts {
+		views = append(views, postView{
+			Post:      p,
+			Community: community{Name: name},
+			Counts:    counts{PostID: p.ID, Score: p.Score},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]postView{"posts": views})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
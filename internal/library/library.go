@@ -0,0 +1,341 @@
+// Package library keeps M3U8 playlists of the tracks in a local music
+// folder, as a sink that runs without network access.
+package library
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/export"
+	"github.com/engvik/dissic/internal/sink"
+	"github.com/engvik/dissic/internal/title"
+	log "github.com/sirupsen/logrus"
+)
+
+// extensions are the audio files indexed.
+var extensions = map[string]bool{".mp3": true, ".flac": true, ".ogg": true, ".oga": true, ".opus": true}
+
+// trackNumber matches a leading track number in a file name, e.g. "01 - ".
+var trackNumber = regexp.MustCompile(`^\d{1,3}[\s.\-_]+`)
+
+// Library is a sink for a music folder. Track IDs are the paths of the
+// files relative to the folder, playlist IDs the paths of M3U8 files.
+type Library struct {
+	Logger *log.Entry
+
+	dir         string
+	playlistDir string
+
+	mu     sync.RWMutex
+	tracks []sink.Track
+}
+
+// New indexes the music folder dir. Playlists are created in playlistDir.
+func New(dir string, playlistDir string) (*Library, error) {
+	l := Library{
+		Logger:      log.WithFields(log.Fields{"service": "library"}),
+		dir:         dir,
+		playlistDir: playlistDir,
+	}
+
+	if err := l.Index(); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// Index reads the tags of the audio files in the music folder. Files
+// without tags are indexed by their name, e.g. "01 - Artist - Title.mp3".
+func (l *Library) Index() error {
+	var tracks []sink.Track
+
+	err := filepath.Walk(l.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !extensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		tags, err := ReadTags(path)
+		if err != nil {
+			l.Logger.Infof("%s", err)
+		}
+
+		rel, err := filepath.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+
+		tracks = append(tracks, toTrack(filepath.ToSlash(rel), tags))
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("indexing music folder: %s, %w", l.dir, err)
+	}
+
+	l.mu.Lock()
+	l.tracks = tracks
+	l.mu.Unlock()
+
+	l.Logger.Infof("indexed %d tracks in %s", len(tracks), l.dir)
+
+	return nil
+}
+
+// toTrack returns the track of a file, taking what the tags don't have
+// from the file name.
+func toTrack(id string, tags Tags) sink.Track {
+	t := sink.Track{
+		ID:       id,
+		Artists:  tags.Artists,
+		Title:    tags.Title,
+		Album:    tags.Album,
+		ISRC:     tags.ISRC,
+		Duration: tags.Duration,
+	}
+
+	if len(t.Artists) > 0 && t.Title != "" {
+		return t
+	}
+
+	name := trackNumber.ReplaceAllString(strings.TrimSuffix(filepath.Base(id), filepath.Ext(id)), "")
+
+	parsed, err := title.Parse(name)
+	if err != nil {
+		if t.Title == "" {
+			t.Title = name
+		}
+
+		return t
+	}
+
+	if len(t.Artists) == 0 {
+		t.Artists = parsed.Artists
+	}
+
+	if t.Title == "" {
+		t.Title = parsed.Track
+		if parsed.Version != "" {
+			t.Title = fmt.Sprintf("%s (%s)", parsed.Track, parsed.Version)
+		}
+	}
+
+	return t
+}
+
+// Service returns the name of the local library service.
+func (l *Library) Service() string {
+	return config.ServiceLocal
+}
+
+// ResolveTrack finds the file a post is about by ISRC and then title,
+// with the same parser and matcher as Spotify.
+func (l *Library) ResolveTrack(q sink.Query) (sink.Track, error) {
+	l.mu.RLock()
+	tracks := l.tracks
+	l.mu.RUnlock()
+
+	if q.ISRC != "" {
+		for _, t := range tracks {
+			if strings.EqualFold(t.ISRC, q.ISRC) {
+				return t, nil
+			}
+		}
+	}
+
+	for _, s := range q.SearchTitles() {
+		t, err := title.Parse(s)
+		if err != nil {
+			l.Logger.Infof("\tparse title: %s, title: %s", err, s)
+			continue
+		}
+
+		if found, ok := sink.Match(t, tracks); ok {
+			l.Logger.Infof("\ttrack found: %s (%s)", s, found.ID)
+			return found, nil
+		}
+	}
+
+	return sink.Track{}, errors.New("no track found")
+}
+
+// EnsurePlaylist returns the path of the M3U8 playlist with a name in the
+// playlist folder, creating it if there's none. M3U8 playlists have no
+// description.
+func (l *Library) EnsurePlaylist(name string, description string) (string, error) {
+	name = strings.NewReplacer("/", "-", `\`, "-").Replace(name)
+	path := filepath.Join(l.playlistDir, name+".m3u8")
+
+	if _, err := os.Stat(path); err == nil {
+		l.Logger.Infof("found playlist: %s", path)
+		return path, nil
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("reading playlist: %s, %w", path, err)
+	}
+
+	if err := os.MkdirAll(l.playlistDir, 0755); err != nil {
+		return "", fmt.Errorf("creating playlist folder: %s, %w", l.playlistDir, err)
+	}
+
+	if err := l.write(path, nil); err != nil {
+		return "", err
+	}
+
+	l.Logger.Infof("created playlist: %s", path)
+
+	return path, nil
+}
+
+// AddTracks appends tracks to a playlist.
+func (l *Library) AddTracks(playlistID string, trackIDs ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ids, err := l.read(playlistID)
+	if err != nil {
+		return err
+	}
+
+	return l.write(playlistID, append(ids, trackIDs...))
+}
+
+// RemoveTracks removes tracks from a playlist.
+func (l *Library) RemoveTracks(playlistID string, trackIDs ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ids, err := l.read(playlistID)
+	if err != nil {
+		return err
+	}
+
+	remove := make(map[string]bool, len(trackIDs))
+	for _, id := range trackIDs {
+		remove[id] = true
+	}
+
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !remove[id] {
+			kept = append(kept, id)
+		}
+	}
+
+	return l.write(playlistID, kept)
+}
+
+// Tracks returns the tracks in a playlist. Files missing from the index
+// only have an ID.
+func (l *Library) Tracks(playlistID string) ([]sink.Track, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	ids, err := l.read(playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := make([]sink.Track, 0, len(ids))
+	for _, id := range ids {
+		t, ok := l.track(id)
+		if !ok {
+			t = sink.Track{ID: id}
+		}
+
+		tracks = append(tracks, t)
+	}
+
+	return tracks, nil
+}
+
+func (l *Library) track(id string) (sink.Track, bool) {
+	for _, t := range l.tracks {
+		if t.ID == id {
+			return t, true
+		}
+	}
+
+	return sink.Track{}, false
+}
+
+// read returns the IDs of the tracks in a playlist. Paths are relative to
+// the playlist.
+func (l *Library) read(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading playlist: %s, %w", path, err)
+	}
+
+	var ids []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		file := filepath.FromSlash(line)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+
+		rel, err := filepath.Rel(l.dir, file)
+		if err != nil {
+			return nil, fmt.Errorf("reading playlist: %s, %w", path, err)
+		}
+
+		ids = append(ids, filepath.ToSlash(rel))
+	}
+
+	return ids, nil
+}
+
+// write replaces a playlist with the tracks, with paths relative to it.
+func (l *Library) write(path string, ids []string) error {
+	tracks := make([]export.Track, 0, len(ids))
+
+	for _, id := range ids {
+		file := filepath.Join(l.dir, filepath.FromSlash(id))
+		if rel, err := filepath.Rel(filepath.Dir(path), file); err == nil {
+			file = rel
+		}
+
+		t, _ := l.track(id)
+		tracks = append(tracks, export.Track{
+			Artist:     strings.Join(t.Artists, ", "),
+			Title:      t.Title,
+			URI:        filepath.ToSlash(file),
+			DurationMS: int(t.Duration.Milliseconds()),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, export.FormatM3U, filepath.Base(path), tracks); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing playlist: %s, %w", tmp, err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replacing playlist: %s, %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package library
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/sink"
+)
+
+func newTestLibrary(t *testing.T) (*Library, string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "library")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	files := map[string][]byte{
+		"Daft Punk/Homework/07 Around the World.mp3":           id3v2(4, [2]string{"TIT2", "Around the World"}, [2]string{"TPE1", "Daft Punk"}, [2]string{"TSRC", "GBDUW9600012"}, [2]string{"TLEN", "429000"}),
+		"Daft Punk/Singles/Around the World (Radio Edit).flac": flac(240, "TITLE=Around the World (Radio Edit)", "ARTIST=Daft Punk"),
+		"misc/01 - Aphex Twin - Windowlicker.mp3":              make([]byte, 200),
+		"misc/cover.jpg": make([]byte, 10),
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error setting up test: %s", err)
+		}
+
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("error setting up test: %s", err)
+		}
+	}
+
+	l, err := New(dir, filepath.Join(dir, "playlists"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return l, dir
+}
+
+func TestResolveTrack(t *testing.T) {
+	l, _ := newTestLibrary(t)
+
+	if len(l.tracks) != 3 {
+		t.Fatalf("unexpected index: %+v", l.tracks)
+	}
+
+	tests := []struct {
+		n   string
+		q   sink.Query
+		exp string
+	}{
+		{"should resolve by isrc", sink.Query{ISRC: "gbduw9600012"}, "Daft Punk/Homework/07 Around the World.mp3"},
+		{"should resolve by title", sink.Query{Titles: []string{"Daft Punk - Around the World [House] (1997)"}}, "Daft Punk/Homework/07 Around the World.mp3"},
+		{"should prefer version asked for", sink.Query{Titles: []string{"Daft Punk - Around the World (Radio Edit)"}}, "Daft Punk/Singles/Around the World (Radio Edit).flac"},
+		{"should resolve untagged file by name", sink.Query{Artist: "Aphex Twin", Title: "Windowlicker"}, "misc/01 - Aphex Twin - Windowlicker.mp3"},
+		{"should not resolve unknown track", sink.Query{Titles: []string{"Bon Iver - Holocene"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			track, err := l.ResolveTrack(tt.q)
+			if tt.exp == "" {
+				if err == nil {
+					t.Errorf("expected error, got %s", track.ID)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if track.ID != tt.exp {
+				t.Errorf("unexpected value: got %s, exp %s", track.ID, tt.exp)
+			}
+		})
+	}
+}
+
+func TestPlaylists(t *testing.T) {
+	l, dir := newTestLibrary(t)
+
+	r := sink.NewRouter(l)
+	err := r.Prepare([]config.Playlist{{Name: "dissic/house", Subreddits: []string{"house"}, Service: config.ServiceLocal}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	path := filepath.Join(dir, "playlists", "dissic-house.m3u8")
+
+	t.Run("should write posted tracks to playlist", func(t *testing.T) {
+		r.Handle("house", sink.Query{Titles: []string{"Daft Punk - Around the World"}})
+		r.Handle("house", sink.Query{Titles: []string{"Daft Punk - Around the World (again)"}})
+		r.Handle("house", sink.Query{Titles: []string{"Aphex Twin - Windowlicker"}})
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		exp := "#EXTM3U\n" +
+			"#EXTINF:429,Daft Punk - Around the World\n../Daft Punk/Homework/07 Around the World.mp3\n" +
+			"#EXTINF:-1,Aphex Twin - Windowlicker\n../misc/01 - Aphex Twin - Windowlicker.mp3\n"
+		if string(data) != exp {
+			t.Errorf("unexpected value: got %s, exp %s", data, exp)
+		}
+	})
+
+	t.Run("should find existing playlist", func(t *testing.T) {
+		id, err := l.EnsurePlaylist("dissic/house", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		tracks, err := l.Tracks(id)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if id != path || len(tracks) != 2 || tracks[1].Title != "Windowlicker" {
+			t.Errorf("unexpected playlist %s: %+v", id, tracks)
+		}
+	})
+
+	t.Run("should remove tracks", func(t *testing.T) {
+		if err := l.RemoveTracks(path, "Daft Punk/Homework/07 Around the World.mp3"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		tracks, err := l.Tracks(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(tracks) != 1 || tracks[0].ID != "misc/01 - Aphex Twin - Windowlicker.mp3" {
+			t.Errorf("unexpected tracks: %+v", tracks)
+		}
+	})
+}
@@ -0,0 +1,402 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Tags are the tags of an audio file dissic matches by.
+type Tags struct {
+	Artists  []string
+	Title    string
+	Album    string
+	ISRC     string
+	Duration time.Duration
+}
+
+// errNoTags is returned for files without tags.
+var errNoTags = errors.New("no tags")
+
+// ReadTags reads the tags of an MP3 (ID3v2 or ID3v1), FLAC or Ogg Vorbis
+// or Opus file.
+func ReadTags(path string) (Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Tags{}, fmt.Errorf("opening audio file: %s, %w", path, err)
+	}
+	defer f.Close()
+
+	var tags Tags
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		tags, err = readID3(f)
+	case ".flac":
+		tags, err = readFLAC(f)
+	case ".ogg", ".oga", ".opus":
+		tags, err = readOgg(f)
+	default:
+		return Tags{}, fmt.Errorf("unsupported audio file: %s", path)
+	}
+
+	if err != nil {
+		return Tags{}, fmt.Errorf("reading tags: %s, %w", path, err)
+	}
+
+	return tags, nil
+}
+
+// readID3 reads an ID3v2 tag, falling back to an ID3v1 tag at the end of
+// the file.
+func readID3(r io.ReadSeeker) (Tags, error) {
+	tags, err := readID3v2(r)
+	if err != errNoTags {
+		return tags, err
+	}
+
+	return readID3v1(r)
+}
+
+func readID3v2(r io.ReadSeeker) (Tags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:3]) != "ID3" {
+		return Tags{}, errNoTags
+	}
+
+	version, flags := header[3], header[5]
+	data := make([]byte, synchsafe(header[6:10]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return Tags{}, fmt.Errorf("reading id3v2 tag: %w", err)
+	}
+
+	// unsynchronisation inserts a zero after every 0xff
+	if flags&0x80 != 0 && version < 4 {
+		data = bytes.ReplaceAll(data, []byte{0xff, 0x00}, []byte{0xff})
+	}
+
+	// skip the extended header
+	if flags&0x40 != 0 && len(data) >= 4 {
+		size := int(binary.BigEndian.Uint32(data[:4])) + 4
+		if version == 4 {
+			size = synchsafe(data[:4])
+		}
+
+		if size > len(data) {
+			return Tags{}, errors.New("invalid id3v2 extended header")
+		}
+
+		data = data[size:]
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	var tags Tags
+
+	for len(data) >= headerLen && data[0] != 0 {
+		id := string(data[:idLen])
+
+		var size int
+		switch version {
+		case 2:
+			size = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(data[4:8]))
+		default:
+			size = synchsafe(data[4:8])
+		}
+
+		if size > len(data)-headerLen {
+			break
+		}
+
+		values := decodeText(data[headerLen : headerLen+size])
+		data = data[headerLen+size:]
+
+		if len(values) == 0 {
+			continue
+		}
+
+		switch id {
+		case "TIT2", "TT2":
+			tags.Title = values[0]
+		case "TPE1", "TP1":
+			tags.Artists = values
+		case "TALB", "TAL":
+			tags.Album = values[0]
+		case "TSRC", "TRC":
+			tags.ISRC = values[0]
+		case "TLEN", "TLE":
+			if ms, err := strconv.Atoi(values[0]); err == nil {
+				tags.Duration = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+// synchsafe decodes a 28 bit integer stored in the low 7 bits of 4 bytes.
+func synchsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// decodeText decodes an ID3v2 text frame into its null separated values.
+func decodeText(frame []byte) []string {
+	if len(frame) < 2 {
+		return nil
+	}
+
+	var text string
+
+	switch frame[0] {
+	case 0:
+		text = latin1(frame[1:])
+	case 1, 2:
+		text = decodeUTF16(frame[1:], frame[0] == 2)
+	default:
+		text = string(frame[1:])
+	}
+
+	var values []string
+	for _, v := range strings.Split(text, "\x00") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// decodeUTF16 decodes UTF-16 text, big endian unless a byte order mark
+// says otherwise. Every value may start with a byte order mark.
+func decodeUTF16(b []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(b)/2)
+
+	for i := 0; i+1 < len(b); i += 2 {
+		switch {
+		case b[i] == 0xff && b[i+1] == 0xfe:
+			bigEndian = false
+			continue
+		case b[i] == 0xfe && b[i+1] == 0xff:
+			bigEndian = true
+			continue
+		}
+
+		if bigEndian {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		} else {
+			units = append(units, uint16(b[i+1])<<8|uint16(b[i]))
+		}
+	}
+
+	return string(utf16.Decode(units))
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, 0, len(b))
+	for _, c := range b {
+		runes = append(runes, rune(c))
+	}
+
+	return string(runes)
+}
+
+func readID3v1(r io.ReadSeeker) (Tags, error) {
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return Tags{}, errNoTags
+	}
+
+	tag := make([]byte, 128)
+	if _, err := io.ReadFull(r, tag); err != nil || string(tag[:3]) != "TAG" {
+		return Tags{}, errNoTags
+	}
+
+	field := func(b []byte) string {
+		return strings.TrimSpace(strings.TrimRight(latin1(b), "\x00"))
+	}
+
+	tags := Tags{Title: field(tag[3:33]), Album: field(tag[63:93])}
+	if artist := field(tag[33:63]); artist != "" {
+		tags.Artists = []string{artist}
+	}
+
+	return tags, nil
+}
+
+// readFLAC reads the Vorbis comment and stream info blocks of a FLAC
+// file, which may start with an ID3v2 tag.
+func readFLAC(r io.ReadSeeker) (Tags, error) {
+	marker := make([]byte, 4)
+	if _, err := io.ReadFull(r, marker); err != nil {
+		return Tags{}, fmt.Errorf("reading flac marker: %w", err)
+	}
+
+	if string(marker[:3]) == "ID3" {
+		header := make([]byte, 6)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return Tags{}, fmt.Errorf("reading id3v2 header: %w", err)
+		}
+
+		if _, err := r.Seek(int64(synchsafe(header[2:6])), io.SeekCurrent); err != nil {
+			return Tags{}, fmt.Errorf("skipping id3v2 tag: %w", err)
+		}
+
+		if _, err := io.ReadFull(r, marker); err != nil {
+			return Tags{}, fmt.Errorf("reading flac marker: %w", err)
+		}
+	}
+
+	if string(marker) != "fLaC" {
+		return Tags{}, errors.New("not a flac file")
+	}
+
+	var tags Tags
+
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return Tags{}, fmt.Errorf("reading flac block header: %w", err)
+		}
+
+		last, blockType := header[0]&0x80 != 0, header[0]&0x7f
+		block := make([]byte, int(header[1])<<16|int(header[2])<<8|int(header[3]))
+		if _, err := io.ReadFull(r, block); err != nil {
+			return Tags{}, fmt.Errorf("reading flac block: %w", err)
+		}
+
+		switch {
+		case blockType == 0 && len(block) >= 18:
+			rate := int64(block[10])<<12 | int64(block[11])<<4 | int64(block[12])>>4
+			samples := int64(block[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(block[14:18]))
+			if rate > 0 {
+				tags.Duration = time.Duration(samples * int64(time.Second) / rate)
+			}
+		case blockType == 4:
+			duration := tags.Duration
+			tags = readVorbisComment(block)
+			tags.Duration = duration
+		}
+
+		if last {
+			return tags, nil
+		}
+	}
+}
+
+// readVorbisComment reads the fields of a Vorbis comment, as used in FLAC
+// and Ogg files.
+func readVorbisComment(b []byte) Tags {
+	var tags Tags
+
+	next := func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+
+		n := int(binary.LittleEndian.Uint32(b[:4]))
+		if n > len(b)-4 {
+			return nil, false
+		}
+
+		v := b[4 : 4+n]
+		b = b[4+n:]
+
+		return v, true
+	}
+
+	// vendor string
+	if _, ok := next(); !ok || len(b) < 4 {
+		return tags
+	}
+
+	count := int(binary.LittleEndian.Uint32(b[:4]))
+	b = b[4:]
+
+	for i := 0; i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			break
+		}
+
+		parts := strings.SplitN(string(comment), "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			continue
+		}
+
+		value := strings.TrimSpace(parts[1])
+
+		switch strings.ToUpper(parts[0]) {
+		case "TITLE":
+			tags.Title = value
+		case "ARTIST":
+			tags.Artists = append(tags.Artists, value)
+		case "ALBUM":
+			tags.Album = value
+		case "ISRC":
+			tags.ISRC = value
+		}
+	}
+
+	return tags
+}
+
+// readOgg reads the comment header of an Ogg Vorbis or Opus file, the
+// second packet of the stream.
+func readOgg(r io.Reader) (Tags, error) {
+	var packets [][]byte
+	var packet []byte
+
+	for len(packets) < 2 {
+		header := make([]byte, 27)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return Tags{}, fmt.Errorf("reading ogg page: %w", err)
+		}
+
+		if string(header[:4]) != "OggS" {
+			return Tags{}, errors.New("not an ogg file")
+		}
+
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return Tags{}, fmt.Errorf("reading ogg segment table: %w", err)
+		}
+
+		for _, size := range segments {
+			segment := make([]byte, size)
+			if _, err := io.ReadFull(r, segment); err != nil {
+				return Tags{}, fmt.Errorf("reading ogg segment: %w", err)
+			}
+
+			packet = append(packet, segment...)
+
+			// a segment shorter than 255 bytes ends the packet
+			if size < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+	}
+
+	comment := packets[1]
+
+	switch {
+	case bytes.HasPrefix(comment, []byte("\x03vorbis")):
+		return readVorbisComment(comment[7:]), nil
+	case bytes.HasPrefix(comment, []byte("OpusTags")):
+		return readVorbisComment(comment[8:]), nil
+	default:
+		return Tags{}, errNoTags
+	}
+}
@@ -0,0 +1,195 @@
+package library
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// id3v2 returns an ID3v2 tag with text frames, UTF-8 encoded for version 4
+// and UTF-16 for version 3.
+func id3v2(version byte, frames ...[2]string) []byte {
+	var body bytes.Buffer
+
+	for _, f := range frames {
+		var text []byte
+		if version == 4 {
+			text = append([]byte{3}, f[1]...)
+		} else {
+			text = []byte{1, 0xff, 0xfe}
+			for _, u := range utf16.Encode([]rune(f[1])) {
+				text = append(text, byte(u), byte(u>>8))
+			}
+		}
+
+		size := make([]byte, 4)
+		if version == 4 {
+			copy(size, synchsafeBytes(len(text)))
+		} else {
+			binary.BigEndian.PutUint32(size, uint32(len(text)))
+		}
+
+		body.WriteString(f[0])
+		body.Write(size)
+		body.Write([]byte{0, 0})
+		body.Write(text)
+	}
+
+	// padding
+	body.Write(make([]byte, 16))
+
+	header := append([]byte{'I', 'D', '3', version, 0, 0}, synchsafeBytes(body.Len())...)
+
+	return append(header, body.Bytes()...)
+}
+
+func synchsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+func id3v1(title, artist, album string) []byte {
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:33], title)
+	copy(tag[33:63], artist)
+	copy(tag[63:93], album)
+
+	return tag
+}
+
+func vorbisComment(comments ...string) []byte {
+	var b bytes.Buffer
+
+	write := func(s string) {
+		_ = binary.Write(&b, binary.LittleEndian, uint32(len(s)))
+		b.WriteString(s)
+	}
+
+	write("dissic")
+	_ = binary.Write(&b, binary.LittleEndian, uint32(len(comments)))
+
+	for _, c := range comments {
+		write(c)
+	}
+
+	return b.Bytes()
+}
+
+func flac(seconds int, comments ...string) []byte {
+	info := make([]byte, 34)
+	rate, samples := 44100, int64(44100*seconds)
+	info[10], info[11], info[12] = byte(rate>>12), byte(rate>>4), byte(rate<<4)
+	info[13] = byte(samples >> 32 & 0x0f)
+	binary.BigEndian.PutUint32(info[14:18], uint32(samples))
+
+	comment := vorbisComment(comments...)
+
+	var b bytes.Buffer
+	b.WriteString("fLaC")
+	b.Write([]byte{0, 0, 0, byte(len(info))})
+	b.Write(info)
+	b.Write([]byte{0x84, byte(len(comment) >> 16), byte(len(comment) >> 8), byte(len(comment))})
+	b.Write(comment)
+
+	return b.Bytes()
+}
+
+// ogg returns an Ogg page with packets.
+func ogg(packets ...[]byte) []byte {
+	var segments, body []byte
+
+	for _, p := range packets {
+		n := len(p)
+		for ; n >= 255; n -= 255 {
+			segments = append(segments, 255)
+		}
+
+		segments = append(segments, byte(n))
+		body = append(body, p...)
+	}
+
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	header[26] = byte(len(segments))
+
+	return append(append(header, segments...), body...)
+}
+
+func TestReadTags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "library")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	audio := make([]byte, 64)
+
+	tests := []struct {
+		n    string
+		file string
+		data []byte
+		exp  Tags
+	}{
+		{
+			"should read id3v2.4",
+			"a.mp3",
+			append(id3v2(4, [2]string{"TIT2", "Around the World"}, [2]string{"TPE1", "Daft Punk"}, [2]string{"TALB", "Homework"}, [2]string{"TSRC", "GBDUW9600012"}, [2]string{"TLEN", "429000"}), audio...),
+			Tags{Artists: []string{"Daft Punk"}, Title: "Around the World", Album: "Homework", ISRC: "GBDUW9600012", Duration: 429 * time.Second},
+		},
+		{
+			"should read utf-16 id3v2.3",
+			"b.mp3",
+			append(id3v2(3, [2]string{"TIT2", "Jóga"}, [2]string{"TPE1", "Björk"}), audio...),
+			Tags{Artists: []string{"Björk"}, Title: "Jóga"},
+		},
+		{
+			"should fall back to id3v1",
+			"c.mp3",
+			append(audio, id3v1("Teardrop", "Massive Attack", "Mezzanine")...),
+			Tags{Artists: []string{"Massive Attack"}, Title: "Teardrop", Album: "Mezzanine"},
+		},
+		{
+			"should read flac",
+			"d.flac",
+			flac(200, "TITLE=Holocene", "ARTIST=Bon Iver", "ALBUM=Bon Iver, Bon Iver"),
+			Tags{Artists: []string{"Bon Iver"}, Title: "Holocene", Album: "Bon Iver, Bon Iver", Duration: 200 * time.Second},
+		},
+		{
+			"should read ogg vorbis across segments",
+			"e.ogg",
+			ogg([]byte("\x01vorbis-identification"), append([]byte("\x03vorbis"), vorbisComment("title=Windowlicker", "artist=Aphex Twin", "comment="+strings.Repeat("x", 300))...)),
+			Tags{Artists: []string{"Aphex Twin"}, Title: "Windowlicker"},
+		},
+		{
+			"should read opus",
+			"f.opus",
+			ogg([]byte("OpusHead"), append([]byte("OpusTags"), vorbisComment("TITLE=Glass", "ARTIST=Hania Rani", "ARTIST=Dobrawa Czocher")...)),
+			Tags{Artists: []string{"Hania Rani", "Dobrawa Czocher"}, Title: "Glass"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := ioutil.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatalf("error setting up test: %s", err)
+			}
+
+			tags, err := ReadTags(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if strings.Join(tags.Artists, ";") != strings.Join(tt.exp.Artists, ";") || tags.Title != tt.exp.Title ||
+				tags.Album != tt.exp.Album || tags.ISRC != tt.exp.ISRC || tags.Duration != tt.exp.Duration {
+				t.Errorf("unexpected value: got %+v, exp %+v", tags, tt.exp)
+			}
+		})
+	}
+}
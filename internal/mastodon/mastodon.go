@@ -0,0 +1,498 @@
+// Package mastodon finds new music in hashtag timelines and the statuses
+// of accounts on Mastodon compatible instances.
+package mastodon
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/source"
+	"github.com/engvik/dissic/internal/spotify"
+	"github.com/engvik/dissic/internal/title"
+)
+
+// pageLimit is the number of statuses asked for per page, the most
+// Mastodon returns.
+const pageLimit = 40
+
+// maxPages is the most pages read per check, when more statuses than fit
+// on a page were posted since the last one.
+const maxPages = 5
+
+// musicHosts are the hosts of the music services links are taken from.
+// Bandcamp is matched on every subdomain.
+var musicHosts = map[string]bool{
+	"open.spotify.com":  true,
+	"spotify.link":      true,
+	"music.apple.com":   true,
+	"deezer.com":        true,
+	"deezer.page.link":  true,
+	"link.deezer.com":   true,
+	"tidal.com":         true,
+	"listen.tidal.com":  true,
+	"youtube.com":       true,
+	"m.youtube.com":     true,
+	"music.youtube.com": true,
+	"youtu.be":          true,
+	"soundcloud.com":    true,
+	"on.soundcloud.com": true,
+	"song.link":         true,
+	"album.link":        true,
+	"odesli.co":         true,
+	"bandcamp.com":      true,
+}
+
+var (
+	anchor    = regexp.MustCompile(`<a\s[^>]*href="([^"]*)"`)
+	lineBreak = regexp.MustCompile(`(?i)<br\s*/?>|</p>`)
+	tag       = regexp.MustCompile(`<[^>]*>`)
+	link      = regexp.MustCompile(`https?://\S+`)
+	hashtag   = regexp.MustCompile(`#[\p{L}\p{N}_]+`)
+	mention   = regexp.MustCompile(`@[\w.-]+(@[\w.-]+)?`)
+	space     = regexp.MustCompile(`\s+`)
+)
+
+// Client checks the timelines for new statuses on an interval, and passes
+// them on for processing.
+type Client struct {
+	*source.Poller
+
+	Timelines []config.Timeline
+	MusicChan chan<- spotify.Music
+
+	http *http.Client
+
+	mu sync.Mutex
+	// accountIDs are the IDs of the accounts followed, by timeline key.
+	accountIDs map[string]string
+}
+
+type status struct {
+	ID              string    `json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	URL             string    `json:"url"`
+	URI             string    `json:"uri"`
+	Content         string    `json:"content"`
+	Language        string    `json:"language"`
+	InReplyToID     *string   `json:"in_reply_to_id"`
+	Reblog          *status   `json:"reblog"`
+	FavouritesCount int       `json:"favourites_count"`
+	Card            *card     `json:"card"`
+}
+
+type card struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	AuthorName string `json:"author_name"`
+}
+
+type account struct {
+	ID string `json:"id"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// New sets up a new Mastodon client. It takes the configuration, the
+// channel to publish new statuses to for processing and the HTTP client
+// the instances are requested with.
+func New(cfg *config.Config, m chan<- spotify.Music, httpClient *http.Client) *Client {
+	c := Client{
+		Timelines:  cfg.Mastodon.Timelines,
+		MusicChan:  m,
+		http:       httpClient,
+		accountIDs: make(map[string]string),
+	}
+
+	c.Poller = source.NewPoller(source.Mastodon, time.Duration(cfg.Mastodon.Interval)*time.Minute, cfg.Version, c.poll)
+	c.Kind = "timelines"
+
+	for _, t := range c.Timelines {
+		if t.Hashtag != "" {
+			c.Channels = append(c.Channels, fmt.Sprintf("#%s (%s)", t.Hashtag, t.Instance))
+		} else {
+			c.Channels = append(c.Channels, fmt.Sprintf("@%s (%s)", t.Account, t.Instance))
+		}
+	}
+
+	c.Logger.Infoln("client setup ok")
+
+	return &c
+}
+
+func (c *Client) poll(stop <-chan struct{}) {
+	for _, t := range c.Timelines {
+		if !c.pollTimeline(t, stop) {
+			return
+		}
+	}
+}
+
+// pollTimeline checks a timeline for statuses newer than the last one
+// seen. It returns false if stopped.
+func (c *Client) pollTimeline(t config.Timeline, stop <-chan struct{}) bool {
+	key := source.Key(source.Mastodon, t.Name)
+
+	var last string
+	ids, read := c.Seen.Get(key)
+	if len(ids) > 0 {
+		last = ids[0]
+	}
+
+	statuses, err := c.newStatuses(key, t, last, read)
+	if err != nil {
+		c.Logger.Errorf("%s: %s", t.Name, err)
+		return true
+	}
+
+	newest := last
+	for _, st := range statuses {
+		if idLess(newest, st.ID) {
+			newest = st.ID
+		}
+	}
+
+	var seen []string
+	if newest != "" {
+		seen = []string{newest}
+	}
+
+	c.Seen.Set(key, seen)
+
+	if !read {
+		return true
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return idLess(statuses[i].ID, statuses[j].ID) })
+
+	for _, st := range statuses {
+		m := toMusic(key, st)
+
+		reason := skipReason(t.Filters, st)
+		if reason == "" && t.Filters.RequireLink && m.URL == "" {
+			reason = "linkless"
+		}
+
+		if reason != "" {
+			c.Logger.Infof("%s: skipping %s status: %s", t.Name, reason, m.Permalink)
+			continue
+		}
+
+		c.Logger.Infof("%s: %s (%s)", t.Name, m.PostTitle, m.Permalink)
+
+		select {
+		case c.MusicChan <- m:
+		case <-stop:
+			return false
+		}
+	}
+
+	return true
+}
+
+// newStatuses returns the statuses posted after last, paging forward from
+// it. The first time a timeline is read, only the newest page is read.
+func (c *Client) newStatuses(key string, t config.Timeline, last string, read bool) ([]status, error) {
+	path, err := c.timelinePath(key, t)
+	if err != nil {
+		return nil, err
+	}
+
+	if !read {
+		return c.list(t, path, "")
+	}
+
+	var statuses []status
+
+	// a timeline that was empty is read from the start
+	minID := last
+	if minID == "" {
+		minID = "0"
+	}
+
+	for page := 1; page <= maxPages; page++ {
+		list, err := c.list(t, path, minID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, st := range list {
+			if idLess(minID, st.ID) {
+				minID = st.ID
+			}
+		}
+
+		statuses = append(statuses, list...)
+
+		if len(list) < pageLimit {
+			break
+		}
+	}
+
+	return statuses, nil
+}
+
+// timelinePath returns the API path of the timeline, looking up the ID of
+// an account the first time it's read.
+func (c *Client) timelinePath(key string, t config.Timeline) (string, error) {
+	if t.Hashtag != "" {
+		return "/api/v1/timelines/tag/" + url.PathEscape(t.Hashtag), nil
+	}
+
+	c.mu.Lock()
+	id, ok := c.accountIDs[key]
+	c.mu.Unlock()
+
+	if !ok {
+		var a account
+		if err := c.get(t, "/api/v1/accounts/lookup?acct="+url.QueryEscape(t.Account), &a); err != nil {
+			return "", fmt.Errorf("looking up @%s: %w", t.Account, err)
+		}
+
+		id = a.ID
+
+		c.mu.Lock()
+		c.accountIDs[key] = id
+		c.mu.Unlock()
+	}
+
+	return "/api/v1/accounts/" + url.PathEscape(id) + "/statuses", nil
+}
+
+// list returns a page of the statuses in a timeline, right after minID if
+// given, or the newest ones.
+func (c *Client) list(t config.Timeline, path string, minID string) ([]status, error) {
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(pageLimit))
+
+	if minID != "" {
+		q.Set("min_id", minID)
+	}
+
+	var statuses []status
+	if err := c.get(t, path+"?"+q.Encode(), &statuses); err != nil {
+		return nil, fmt.Errorf("listing %s: %w", t.Name, err)
+	}
+
+	return statuses, nil
+}
+
+func (c *Client) get(t config.Timeline, path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(t.Instance, "/")+path, nil)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.UserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	if t.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+t.AccessToken)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e apiError
+		json.NewDecoder(resp.Body).Decode(&e)
+
+		return fmt.Errorf("unexpected status: %s %s", resp.Status, e.Error)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
+
+// skipReason returns why a status is left out by the filters, or an
+// empty string if it isn't.
+func skipReason(f config.TimelineFilters, st status) string {
+	if st.Reblog != nil {
+		if f.ExcludeReblogs {
+			return "boosted"
+		}
+
+		st = *st.Reblog
+	}
+
+	switch {
+	case f.ExcludeReplies && st.InReplyToID != nil:
+		return "reply"
+	case len(f.Languages) > 0 && st.Language != "" && !hasLanguage(f.Languages, st.Language):
+		return st.Language
+	default:
+		return ""
+	}
+}
+
+func hasLanguage(languages []string, language string) bool {
+	for _, l := range languages {
+		if strings.EqualFold(l, language) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toMusic(key string, st status) spotify.Music {
+	// a boost carries the boosted status
+	content := st
+	if st.Reblog != nil {
+		content = *st.Reblog
+	}
+
+	links := musicLinks(content.Content)
+	if content.Card != nil && isMusicLink(content.Card.URL) {
+		links = append(links, content.Card.URL)
+	}
+
+	m := spotify.Music{
+		Source:    source.Mastodon,
+		Subreddit: key,
+		PostTitle: statusTitle(content.Content),
+		URL:       pickLink(links),
+		PostID:    st.ID,
+		Permalink: content.URL,
+		Score:     content.FavouritesCount,
+		Posted:    content.CreatedAt.UTC(),
+	}
+
+	if m.Permalink == "" {
+		m.Permalink = content.URI
+	}
+
+	if content.Card != nil {
+		m.MediaTitle = cardTitle(*content.Card)
+		m.Artist, m.Title = cardFields(*content.Card)
+	}
+
+	return m
+}
+
+// musicLinks returns the links to music services in the HTML content of a
+// status.
+func musicLinks(content string) []string {
+	var links []string
+
+	for _, match := range anchor.FindAllStringSubmatch(content, -1) {
+		if href := html.UnescapeString(match[1]); isMusicLink(href) {
+			links = append(links, href)
+		}
+	}
+
+	return links
+}
+
+func isMusicLink(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	return musicHosts[host] || strings.HasSuffix(host, ".bandcamp.com")
+}
+
+// pickLink returns the Spotify link if there is one, as Spotify finds
+// tracks by its own links, or the first link.
+func pickLink(links []string) string {
+	for _, l := range links {
+		if strings.Contains(l, "open.spotify.com/") {
+			return l
+		}
+	}
+
+	if len(links) > 0 {
+		return links[0]
+	}
+
+	return ""
+}
+
+// statusTitle returns the line of the status text that reads as
+// "artist - track", or the first line, without links, hashtags and
+// mentions.
+func statusTitle(content string) string {
+	text := html.UnescapeString(tag.ReplaceAllString(lineBreak.ReplaceAllString(content, "\n"), ""))
+
+	var first string
+
+	for _, line := range strings.Split(text, "\n") {
+		line = link.ReplaceAllString(line, "")
+		line = hashtag.ReplaceAllString(line, "")
+		line = mention.ReplaceAllString(line, "")
+		line = strings.TrimSpace(space.ReplaceAllString(line, " "))
+
+		if line == "" {
+			continue
+		}
+
+		if _, err := title.Parse(line); err == nil {
+			return line
+		}
+
+		if first == "" {
+			first = line
+		}
+	}
+
+	return first
+}
+
+// cardTitle returns the title of a link preview, with the author first
+// when the title is only the track, like on YouTube.
+func cardTitle(c card) string {
+	if c.AuthorName == "" || c.Title == "" {
+		return c.Title
+	}
+
+	if _, err := title.Parse(c.Title); err == nil {
+		return c.Title
+	}
+
+	return c.AuthorName + " - " + c.Title
+}
+
+// cardFields returns the artist and title of the track a link preview is
+// about, taking the author as the artist when the title is only the
+// track.
+func cardFields(c card) (string, string) {
+	if c.AuthorName == "" || c.Title == "" {
+		return "", ""
+	}
+
+	if _, err := title.Parse(c.Title); err == nil {
+		return "", ""
+	}
+
+	return c.AuthorName, c.Title
+}
+
+// idLess reports whether status ID a is older than b. IDs are numbers in
+// strings, so longer ones are newer.
+func idLess(a string, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	return a < b
+}
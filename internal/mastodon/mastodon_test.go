@@ -0,0 +1,226 @@
+package mastodon
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/mastodon/mastodontest"
+	"github.com/engvik/dissic/internal/spotify"
+)
+
+func newTestClient(t *testing.T, fake *mastodontest.Fake, timelines ...config.Timeline) (*Client, chan spotify.Music) {
+	t.Helper()
+
+	srv := mastodontest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	for i := range timelines {
+		timelines[i].Instance = srv.URL
+		timelines[i].AccessToken = fake.Token
+	}
+
+	cfg := &config.Config{Mastodon: config.Mastodon{Interval: 5, Timelines: timelines}}
+	music := make(chan spotify.Music, 200)
+
+	return New(cfg, music, srv.Client()), music
+}
+
+func nowPlaying(content string) mastodontest.Status {
+	return mastodontest.Status{
+		Content:  content,
+		Language: "en",
+		Tags:     []mastodontest.Tag{{Name: "nowplaying"}},
+	}
+}
+
+func TestPollHashtag(t *testing.T) {
+	fake := mastodontest.New("")
+	fake.AddAccount("dj")
+	fake.Post("dj", nowPlaying("<p>Daft Punk - Around the World</p>"))
+
+	c, music := newTestClient(t, fake, config.Timeline{
+		Name:    "nowplaying",
+		Hashtag: "NowPlaying",
+		Filters: config.TimelineFilters{ExcludeReplies: true, Languages: []string{"en"}, RequireLink: true},
+	})
+
+	t.Run("should only remember the statuses when first read", func(t *testing.T) {
+		c.Poll()
+
+		if len(music) != 0 {
+			t.Errorf("unexpected number of statuses: got %d, exp %d", len(music), 0)
+		}
+	})
+
+	t.Run("should convert new statuses and skip filtered statuses", func(t *testing.T) {
+		reply := "100000"
+
+		spotifyStatus := nowPlaying(`<p><a href="https://mastodon.test/tags/nowplaying" class="mention hashtag" rel="tag">#<span>NowPlaying</span></a> Aphex Twin -- Windowlicker<br><a href="https://open.spotify.com/track/4Kk1g8s8ZfK4gS1p5M9WJb?si=abc&amp;nd=1" rel="nofollow noopener"><span class="invisible">https://</span><span class="ellipsis">open.spotify.com/track/4Kk1g8s</span><span class="invisible">8ZfK4gS1p5M9WJb</span></a></p>`)
+		spotifyStatus.FavouritesCount = 4
+
+		cardStatus := nowPlaying(`<p>Listening to this all day</p><p><a href="https://www.youtube.com/watch?v=cAcsYkQUpVQ">https://www.youtube.com/watch?v=cAcsYkQUpVQ</a></p>`)
+		cardStatus.Card = &mastodontest.Card{URL: "https://www.youtube.com/watch?v=cAcsYkQUpVQ", Title: "Roads", AuthorName: "Portishead", Type: "video"}
+
+		replyStatus := nowPlaying(`<p>Khruangbin - Maria También <a href="https://open.spotify.com/track/1">link</a></p>`)
+		replyStatus.InReplyToID = &reply
+
+		germanStatus := nowPlaying(`<p>Kraftwerk - Das Model <a href="https://open.spotify.com/track/2">link</a></p>`)
+		germanStatus.Language = "de"
+
+		fake.Post("dj",
+			spotifyStatus,
+			nowPlaying("<p>Sigur Rós - Hoppipolla</p>"),
+			cardStatus,
+			replyStatus,
+			germanStatus,
+		)
+
+		c.Poll()
+
+		exp := []spotify.Music{
+			{
+				Source:    "mastodon",
+				Subreddit: "mastodon:nowplaying",
+				PostTitle: "Aphex Twin -- Windowlicker",
+				URL:       "https://open.spotify.com/track/4Kk1g8s8ZfK4gS1p5M9WJb?si=abc&nd=1",
+				PostID:    "100001",
+				Permalink: "https://mastodon.test/@dj/100001",
+				Score:     4,
+				Posted:    time.Unix(1700100001, 0).UTC(),
+			},
+			{
+				Source:     "mastodon",
+				Subreddit:  "mastodon:nowplaying",
+				PostTitle:  "Listening to this all day",
+				MediaTitle: "Portishead - Roads",
+				Artist:     "Portishead",
+				Title:      "Roads",
+				URL:        "https://www.youtube.com/watch?v=cAcsYkQUpVQ",
+				PostID:     "100003",
+				Permalink:  "https://mastodon.test/@dj/100003",
+				Posted:     time.Unix(1700100003, 0).UTC(),
+			},
+		}
+
+		if len(music) != len(exp) {
+			t.Fatalf("unexpected number of statuses: got %d, exp %d", len(music), len(exp))
+		}
+
+		for i, e := range exp {
+			if m := <-music; m != e {
+				t.Errorf("unexpected music, pos %d:\ngot %+v\nexp %+v", i, m, e)
+			}
+		}
+	})
+
+	t.Run("should not pass on statuses again", func(t *testing.T) {
+		c.Poll()
+
+		if len(music) != 0 {
+			t.Errorf("unexpected number of statuses: got %d, exp %d", len(music), 0)
+		}
+	})
+}
+
+func TestPollAccount(t *testing.T) {
+	fake := mastodontest.New("token")
+	fake.AddAccount("curator")
+	fake.AddAccount("band@bandcamp.example")
+	fake.Post("curator", nowPlaying("<p>Daft Punk - Around the World</p>"))
+
+	c, music := newTestClient(t, fake,
+		config.Timeline{Name: "curator", Account: "curator"},
+		config.Timeline{Name: "curator-originals", Account: "curator", Filters: config.TimelineFilters{ExcludeReblogs: true}},
+		config.Timeline{Name: "missing", Account: "missing"},
+	)
+
+	c.Poll()
+
+	boosted := fake.Post("band@bandcamp.example", nowPlaying(`<p>New single! <a href="https://band.bandcamp.com/track/roads"><span class="invisible">https://</span>band.bandcamp.com/track/roads</a></p>`))
+	fake.Post("curator",
+		mastodontest.Status{Reblog: &boosted[0]},
+		nowPlaying("<p>Massive Attack - Teardrop</p>"),
+	)
+
+	c.Poll()
+
+	exp := []struct {
+		subreddit string
+		title     string
+		url       string
+	}{
+		{"mastodon:curator", "New single!", "https://band.bandcamp.com/track/roads"},
+		{"mastodon:curator", "Massive Attack - Teardrop", ""},
+		{"mastodon:curator-originals", "Massive Attack - Teardrop", ""},
+	}
+
+	if len(music) != len(exp) {
+		t.Fatalf("unexpected number of statuses: got %d, exp %d", len(music), len(exp))
+	}
+
+	for i, e := range exp {
+		m := <-music
+		if m.Subreddit != e.subreddit || m.PostTitle != e.title || m.URL != e.url {
+			t.Errorf("unexpected music, pos %d: got %s %s %s, exp %s %s %s", i, m.Subreddit, m.PostTitle, m.URL, e.subreddit, e.title, e.url)
+		}
+	}
+
+	// the missing account is looked up again on every check
+	if got := fake.Lookups(); got != 4 {
+		t.Errorf("unexpected number of lookups: got %d, exp %d", got, 4)
+	}
+}
+
+func TestPollPages(t *testing.T) {
+	fake := mastodontest.New("")
+	fake.AddAccount("dj")
+
+	c, music := newTestClient(t, fake, config.Timeline{Name: "nowplaying", Hashtag: "nowplaying"})
+	c.Poll()
+
+	for i := 0; i < 90; i++ {
+		fake.Post("dj", nowPlaying(fmt.Sprintf("<p>Artist - Track %d</p>", i)))
+	}
+
+	requests := fake.Requests()
+	c.Poll()
+
+	if len(music) != 90 {
+		t.Fatalf("unexpected number of statuses: got %d, exp %d", len(music), 90)
+	}
+
+	if got := fake.Requests() - requests; got != 3 {
+		t.Errorf("unexpected number of pages: got %d, exp %d", got, 3)
+	}
+
+	for i := 0; i < 90; i++ {
+		exp := fmt.Sprintf("Artist - Track %d", i)
+		if m := <-music; m.PostTitle != exp {
+			t.Errorf("unexpected value: got %s, exp %s", m.PostTitle, exp)
+		}
+	}
+}
+
+func TestStatusTitle(t *testing.T) {
+	tests := []struct {
+		n       string
+		content string
+		exp     string
+	}{
+		{"should keep plain text", "<p>Daft Punk - Around the World</p>", "Daft Punk - Around the World"},
+		{"should remove hashtags and links", `<p><a href="https://x.test/tags/np" class="mention hashtag">#<span>np</span></a> Portishead – Roads <a href="https://youtu.be/x">https://youtu.be/x</a></p>`, "Portishead – Roads"},
+		{"should remove mentions", `<p><span class="h-card"><a href="https://x.test/@dj" class="u-url mention">@<span>dj</span></a></span> Massive Attack - Teardrop</p>`, "Massive Attack - Teardrop"},
+		{"should pick the line reading as a title", "<p>Today&#39;s pick:<br>Sigur Rós - Hoppipolla</p><p>#music</p>", "Sigur Rós - Hoppipolla"},
+		{"should fall back to the first line", "<p>Listen to this</p><p>so good</p>", "Listen to this"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			if got := statusTitle(tc.content); got != tc.exp {
+				t.Errorf("unexpected value: got %s, exp %s", got, tc.exp)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Package mastodontest provides an in-memory fake of the parts of the
+// Mastodon API used by dissic, served by an httptest server, so the
+// mastodon package can be tested without network access.
+package mastodontest
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Account is an account on the instance.
+type Account struct {
+	ID   string `json:"id"`
+	Acct string `json:"acct"`
+}
+
+// Card is the preview card of the first link in a status.
+type Card struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	AuthorName string `json:"author_name"`
+	Type       string `json:"type"`
+}
+
+// Tag is a hashtag of a status.
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// Status is a status posted by an account. The ID, URL and creation time
+// are set when it's posted, unless already set.
+type Status struct {
+	ID              string    `json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	URL             string    `json:"url"`
+	Content         string    `json:"content"`
+	Language        string    `json:"language,omitempty"`
+	InReplyToID     *string   `json:"in_reply_to_id"`
+	Reblog          *Status   `json:"reblog"`
+	FavouritesCount int       `json:"favourites_count"`
+	Card            *Card     `json:"card"`
+	Tags            []Tag     `json:"tags"`
+	Account         Account   `json:"account"`
+}
+
+// Fake is an in-memory Mastodon instance. Its API needs Token to be sent
+// as a bearer token, unless it's empty. It's safe for concurrent use.
+type Fake struct {
+	Token string
+
+	mu       sync.Mutex
+	accounts map[string]Account
+	statuses []Status
+	nextID   int64
+	lookups  int
+	requests int
+}
+
+// New returns an empty fake accepting token.
+func New(token string) *Fake {
+	return &Fake{
+		Token:    token,
+		accounts: make(map[string]Account),
+		nextID:   100000,
+	}
+}
+
+// AddAccount adds an account and returns it.
+func (f *Fake) AddAccount(acct string) Account {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	a := Account{ID: strconv.Itoa(len(f.accounts) + 1), Acct: acct}
+	f.accounts[strings.ToLower(acct)] = a
+
+	return a
+}
+
+// Post posts statuses by an account added before, and returns them with
+// their IDs.
+func (f *Fake) Post(acct string, statuses ...Status) []Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range statuses {
+		s := &statuses[i]
+		s.Account = f.accounts[strings.ToLower(acct)]
+
+		if s.ID == "" {
+			s.ID = strconv.FormatInt(f.nextID, 10)
+			f.nextID++
+		}
+
+		if s.URL == "" {
+			s.URL = "https://mastodon.test/@" + acct + "/" + s.ID
+		}
+
+		if s.CreatedAt.IsZero() {
+			id, _ := strconv.ParseInt(s.ID, 10, 64)
+			s.CreatedAt = time.Unix(1700000000+id, 0).UTC()
+		}
+
+		f.statuses = append(f.statuses, *s)
+	}
+
+	return statuses
+}
+
+// Lookup returns the account with acct.
+func (f *Fake) Lookup(acct string) (Account, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lookups++
+	a, ok := f.accounts[strings.ToLower(acct)]
+
+	return a, ok
+}
+
+// Lookups returns the number of accounts looked up.
+func (f *Fake) Lookups() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.lookups
+}
+
+// Requests returns the number of timelines requested.
+func (f *Fake) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests
+}
+
+// Hashtag returns a page of the statuses with a hashtag, see timeline.
+func (f *Fake) Hashtag(tag string, minID string, limit int) []Status {
+	return f.timeline(func(s Status) bool {
+		for _, t := range s.Tags {
+			if strings.EqualFold(t.Name, tag) {
+				return true
+			}
+		}
+
+		return false
+	}, minID, limit)
+}
+
+// AccountStatuses returns a page of the statuses of an account, see timeline.
+func (f *Fake) AccountStatuses(id string, minID string, limit int) []Status {
+	return f.timeline(func(s Status) bool { return s.Account.ID == id }, minID, limit)
+}
+
+// timeline returns up to limit of the statuses matching, newest first.
+// With minID, it's the statuses right after minID, like Mastodon pages
+// forward.
+func (f *Fake) timeline(match func(Status) bool, minID string, limit int) []Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+
+	min, _ := strconv.ParseInt(minID, 10, 64)
+
+	var statuses []Status
+	for _, s := range f.statuses {
+		id, _ := strconv.ParseInt(s.ID, 10, 64)
+		if match(s) && id > min {
+			statuses = append(statuses, s)
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return idOf(statuses[i]) > idOf(statuses[j]) })
+
+	if len(statuses) > limit {
+		if minID != "" {
+			statuses = statuses[len(statuses)-limit:]
+		} else {
+			statuses = statuses[:limit]
+		}
+	}
+
+	return statuses
+}
+
+func idOf(s Status) int64 {
+	id, _ := strconv.ParseInt(s.ID, 10, 64)
+	return id
+}
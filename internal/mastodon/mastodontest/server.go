@@ -0,0 +1,72 @@
+package mastodontest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+)
+
+// defaultLimit is the number of statuses Mastodon returns when no limit
+// is given.
+const defaultLimit = 20
+
+// Server is an httptest server serving a fake as a Mastodon instance.
+type Server struct {
+	*httptest.Server
+	Fake *Fake
+}
+
+// NewServer starts a server serving f. Close it when done.
+func NewServer(f *Fake) *Server {
+	s := Server{Fake: f}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return &s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if s.Fake.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Fake.Token {
+		writeError(w, http.StatusUnauthorized, "This method requires an authenticated user")
+		return
+	}
+
+	q := r.URL.Query()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit < 1 {
+		limit = defaultLimit
+	}
+
+	switch {
+	case r.Method != http.MethodGet:
+		writeError(w, http.StatusNotFound, "Record not found")
+	case r.URL.Path == "/api/v1/accounts/lookup":
+		a, ok := s.Fake.Lookup(q.Get("acct"))
+		if !ok {
+			writeError(w, http.StatusNotFound, "Record not found")
+			return
+		}
+
+		writeJSON(w, a)
+	case len(parts) == 5 && parts[2] == "timelines" && parts[3] == "tag":
+		writeJSON(w, s.Fake.Hashtag(parts[4], q.Get("min_id"), limit))
+	case len(parts) == 5 && parts[2] == "accounts" && parts[4] == "statuses":
+		writeJSON(w, s.Fake.AccountStatuses(parts[3], q.Get("min_id"), limit))
+	default:
+		writeError(w, http.StatusNotFound, "Record not found")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -6,19 +6,30 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/source"
 	"github.com/engvik/dissic/internal/spotify"
 	log "github.com/sirupsen/logrus"
 	"github.com/turnage/graw"
 	"github.com/turnage/graw/reddit"
 )
 
+// deletedAuthor is the author of deleted posts.
+const deletedAuthor = "[deleted]"
+
+// Scanner starts scanning reddit, passing new posts to the handler. It
+// returns a function stopping the scan, and a function blocking until the
+// scan fails. graw.Scan is the scanner used against reddit.
+type Scanner func(handler interface{}, script reddit.Script, cfg graw.Config) (func(), func() error, error)
+
 // Client is the reddit client
 type Client struct {
 	Config               graw.Config
 	Script               reddit.Script
+	Scan                 Scanner
 	MusicChan            chan<- spotify.Music
 	RetryAttemptWaitTime time.Duration
 	MaxRetryAttempts     int
@@ -26,13 +37,20 @@ type Client struct {
 	Stop                 func()
 	Wait                 func() error
 	Logger               *log.Entry
+
+	mu     sync.Mutex
+	paused map[string]bool
+	reload bool
+	// done is closed when the client is closed, stopping backfills.
+	done      chan struct{}
+	backfills sync.WaitGroup
 }
 
 // New sets up a new reddit client. It takes the configuration and the channel
 // to publish new posts to for processing. Returns a client or an error.
 func New(cfg *config.Config, m chan<- spotify.Music) (*Client, error) {
 	ua := getRedditUserAgent(cfg)
-	s, err := reddit.NewScript(ua, time.Duration(cfg.Reddit.RequestRate))
+	s, err := reddit.NewScript(ua, time.Duration(cfg.Reddit.RequestRate)*time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("new script: %w", err)
 	}
@@ -43,9 +61,12 @@ func New(cfg *config.Config, m chan<- spotify.Music) (*Client, error) {
 		Config:               gCfg,
 		Script:               s,
 		MusicChan:            m,
-		RetryAttemptWaitTime: time.Duration(cfg.Reddit.MaxRetryAttempts),
+		Scan:                 graw.Scan,
+		RetryAttemptWaitTime: time.Duration(cfg.Reddit.RetryAttemptWaitTime) * time.Second,
 		MaxRetryAttempts:     cfg.Reddit.MaxRetryAttempts,
 		ShouldRetry:          true,
+		paused:               make(map[string]bool),
+		done:                 make(chan struct{}),
 		Logger:               log.WithFields(log.Fields{"service": "reddit"}),
 	}
 
@@ -54,11 +75,36 @@ func New(cfg *config.Config, m chan<- spotify.Music) (*Client, error) {
 	return &c, nil
 }
 
+// Name returns the name of the source.
+func (c *Client) Name() string {
+	return source.Reddit
+}
+
+// Start prepares the scanner and starts listening for posts.
+func (c *Client) Start(shutdown chan<- os.Signal) error {
+	if err := c.PrepareScanner(); err != nil {
+		return err
+	}
+
+	go c.Listen(shutdown)
+
+	return nil
+}
+
 // PrepareScanner calls graw to set up the reddit post scanner.
 // It also makes the stop and wait function returned by graw to
-// the client struct.
+// the client struct. With no subreddits to watch, graw isn't called and
+// the client idles until the subreddits change or it's closed.
 func (c *Client) PrepareScanner() error {
-	stop, wait, err := graw.Scan(c, c.Script, c.Config)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.Config.Subreddits) == 0 {
+		c.Stop, c.Wait = idle()
+		return nil
+	}
+
+	stop, wait, err := c.Scan(c, c.Script, c.Config)
 	if err != nil {
 		return fmt.Errorf("graw preparation failed: %w", err)
 	}
@@ -69,68 +115,296 @@ func (c *Client) PrepareScanner() error {
 	return nil
 }
 
-// Listen starts listening for reddit posts. It also contains logic for
-// reconnecting if an error occurs.
-func (c *Client) Listen(shutdown chan<- os.Signal) {
-	c.Logger.Infof("watching %d subreddits:", len(c.Config.Subreddits))
+// SetSubreddits changes the subreddits being watched. If the scanner is
+// running, it's restarted to pick up the change.
+func (c *Client) SetSubreddits(subs []string) {
+	c.mu.Lock()
+	c.Config.Subreddits = cleanSubNames(append([]string(nil), subs...))
+	stop := c.Stop
+	c.reload = stop != nil
+	c.mu.Unlock()
 
-	for _, sub := range c.Config.Subreddits {
-		c.Logger.Infoln("\tr/" + sub)
+	if stop != nil {
+		stop()
 	}
+}
+
+// Pause stops posts from the subreddit from being processed.
+func (c *Client) Pause(subreddit string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	var retryAttempt int
+	c.paused[strings.ToLower(subreddit)] = true
+}
+
+// Resume resumes processing posts from a paused subreddit.
+func (c *Client) Resume(subreddit string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.paused, strings.ToLower(subreddit))
+}
+
+// Paused returns the paused subreddits.
+func (c *Client) Paused() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var subs []string
+	for sub := range c.paused {
+		subs = append(subs, sub)
+	}
+
+	return subs
+}
+
+// IsPaused reports if processing posts from the subreddit is paused.
+func (c *Client) IsPaused(subreddit string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.paused[strings.ToLower(subreddit)]
+}
+
+// Backfill fetches up to limit of the newest posts from the subreddit and
+// queues them for processing, oldest first. It returns the number of posts
+// queued.
+func (c *Client) Backfill(subreddit string, limit int) (int, error) {
+	harvest, err := c.Script.ListingWithParams("/r/"+subreddit+"/new", map[string]string{
+		"limit": fmt.Sprintf("%d", limit),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("fetching r/%s: %w", subreddit, err)
+	}
+
+	posts := harvest.Posts
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.ShouldRetry {
+		return 0, fmt.Errorf("backfilling r/%s: client closed", subreddit)
+	}
+
+	c.Logger.Infof("backfilling %d posts from r/%s", len(posts), subreddit)
+
+	c.backfills.Add(1)
+	go func() {
+		defer c.backfills.Done()
+
+		for i := len(posts) - 1; i >= 0; i-- {
+			if !c.send(posts[i]) {
+				return
+			}
+		}
+	}()
+
+	return len(posts), nil
+}
+
+// Listen starts listening for reddit posts. It also contains logic for
+// reconnecting if an error occurs. If reconnecting fails more than the
+// maximum retry attempts in a row, a shutdown is requested.
+func (c *Client) Listen(shutdown chan<- os.Signal) {
+	c.logSubreddits()
 
 	for {
-		if retryAttempt == c.MaxRetryAttempts {
-			c.Logger.Errorf("hit maximum retry attempts %d - quitting", retryAttempt)
-			shutdown <- os.Interrupt
+		c.mu.Lock()
+		wait := c.Wait
+		c.mu.Unlock()
+
+		err := wait()
+
+		c.mu.Lock()
+		shouldRetry, reload := c.ShouldRetry, c.reload
+		c.reload = false
+		c.mu.Unlock()
+
+		if !shouldRetry {
+			return
+		}
+
+		if reload {
+			c.logSubreddits()
+
+			if err := c.PrepareScanner(); err == nil {
+				continue
+			}
 		}
 
-		if err := c.Wait(); err != nil {
-			retryAttempt = 0
+		if err != nil {
 			c.Logger.Errorf("reddit/graw error: %s", err)
 		}
 
-		if !c.ShouldRetry {
+		if !c.reconnect() {
+			c.Logger.Errorf("hit maximum retry attempts %d - quitting", c.MaxRetryAttempts)
+			shutdown <- os.Interrupt
 			return
 		}
+	}
+}
 
-		c.Logger.Infof("restarting reddit helper in %s seconds", c.RetryAttemptWaitTime)
-		time.Sleep(c.RetryAttemptWaitTime * time.Second)
+// reconnect attempts to restart the scanner, waiting between each attempt.
+// It returns false if all attempts failed or the client was closed.
+func (c *Client) reconnect() bool {
+	for attempt := 1; attempt <= c.MaxRetryAttempts; attempt++ {
+		c.Logger.Infof("restarting reddit helper in %s (attempt %d/%d)", c.RetryAttemptWaitTime, attempt, c.MaxRetryAttempts)
+		time.Sleep(c.RetryAttemptWaitTime)
+
+		c.mu.Lock()
+		shouldRetry := c.ShouldRetry
+		c.mu.Unlock()
+
+		if !shouldRetry {
+			return false
+		}
 
 		if err := c.PrepareScanner(); err != nil {
 			c.Logger.Errorf("error restarting reddit helper: %s", err)
+			continue
 		}
 
-		retryAttempt++
+		return true
+	}
+
+	return false
+}
+
+func (c *Client) logSubreddits() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.Config.Subreddits) == 0 {
+		c.Logger.Infoln("no subreddits to watch")
+		return
+	}
+
+	c.Logger.Infof("watching %d subreddits:", len(c.Config.Subreddits))
+
+	for _, sub := range c.Config.Subreddits {
+		c.Logger.Infoln("\tr/" + sub)
 	}
 }
 
-// Close shuts down the reddit client.
+// Score returns the current score of a post.
+func (c *Client) Score(permalink string) (int, error) {
+	post, err := c.Script.Thread(permalink)
+	if err != nil {
+		return 0, fmt.Errorf("fetching post %s: %w", permalink, err)
+	}
+
+	return int(post.Score), nil
+}
+
+// Close shuts down the reddit client. It waits for backfills in progress
+// to stop, so nothing is sent for processing after it returns.
 func (c *Client) Close() {
+	c.mu.Lock()
+	closed := !c.ShouldRetry
 	c.ShouldRetry = false
+	stop := c.Stop
+	c.mu.Unlock()
+
 	c.Logger.Println("shutting down")
-	c.Stop()
+
+	if stop != nil {
+		stop()
+	}
+
+	if !closed {
+		close(c.done)
+	}
+
+	c.backfills.Wait()
 }
 
 // Post receives incoming posts from reddit and passes them
 // on to the spotify processor.
 func (c *Client) Post(post *reddit.Post) error {
+	c.send(post)
+
+	return nil
+}
+
+// send passes a post on for processing unless it's skipped. It returns
+// false if the client was closed before the post was passed on.
+func (c *Client) send(post *reddit.Post) bool {
+	if c.IsPaused(post.Subreddit) {
+		c.Logger.Infof("r/%s is paused, skipping: %s", post.Subreddit, post.Title)
+		return true
+	}
+
+	if reason := skipReason(post); reason != "" {
+		c.Logger.Infof("r/%s: skipping %s post: %s", post.Subreddit, reason, post.Title)
+		return true
+	}
+
 	c.Logger.Infof("r/%s: %s (https://reddit.com%s)", post.Subreddit, post.Title, post.Permalink)
-	c.MusicChan <- spotify.Music{
+
+	select {
+	case c.MusicChan <- toMusic(post):
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// skipReason returns why a post shouldn't be processed, or an empty
+// string if it should.
+func skipReason(post *reddit.Post) string {
+	switch {
+	case post.Deleted, post.Author == deletedAuthor:
+		return "deleted"
+	case post.Stickied:
+		return "stickied"
+	default:
+		return ""
+	}
+}
+
+func toMusic(post *reddit.Post) spotify.Music {
+	m := spotify.Music{
+		Source:           source.Reddit,
 		Subreddit:        strings.ToLower(post.Subreddit),
 		PostTitle:        post.Title,
 		MediaTitle:       post.Media.OEmbed.Title,
 		SecureMediaTitle: post.SecureMedia.OEmbed.Title,
 		URL:              post.URL,
+		PostID:           post.ID,
+		Permalink:        post.Permalink,
+		Score:            int(post.Score),
+		Posted:           time.Unix(int64(post.CreatedUTC), 0).UTC(),
 	}
 
-	return nil
+	// crossposts link to the original reddit post
+	if strings.HasPrefix(m.URL, "/r/") || strings.Contains(m.URL, "reddit.com/r/") {
+		m.URL = ""
+	}
+
+	return m
+}
+
+// idle returns the stop and wait functions of a scanner watching nothing.
+// Waiting blocks until stopped.
+func idle() (func(), func() error) {
+	stopped := make(chan struct{})
+	var once sync.Once
+
+	stop := func() { once.Do(func() { close(stopped) }) }
+	wait := func() error {
+		<-stopped
+		return nil
+	}
+
+	return stop, wait
 }
 
 func cleanSubNames(subs []string) []string {
 	for i, sub := range subs {
-		if sub[:2] == "r/" {
+		if strings.HasPrefix(sub, "r/") {
 			subs[i] = sub[2:]
 		}
 	}
@@ -0,0 +1,429 @@
+package reddit
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/reddit/reddittest"
+	"github.com/engvik/dissic/internal/spotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/turnage/graw"
+	"github.com/turnage/graw/reddit"
+)
+
+type testScanner struct {
+	mu      sync.Mutex
+	calls   []graw.Config
+	results []error
+	waits   chan error
+}
+
+func newTestScanner(results ...error) *testScanner {
+	return &testScanner{results: results, waits: make(chan error)}
+}
+
+func (s *testScanner) scan(handler interface{}, script reddit.Script, cfg graw.Config) (func(), func() error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.calls)
+	s.calls = append(s.calls, graw.Config{Subreddits: append([]string(nil), cfg.Subreddits...)})
+
+	if n < len(s.results) && s.results[n] != nil {
+		return nil, nil, s.results[n]
+	}
+
+	stopped := make(chan struct{})
+	var once sync.Once
+
+	stop := func() { once.Do(func() { close(stopped) }) }
+	wait := func() error {
+		select {
+		case <-stopped:
+			return nil
+		case err := <-s.waits:
+			return err
+		}
+	}
+
+	return stop, wait, nil
+}
+
+func (s *testScanner) scans() []graw.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]graw.Config(nil), s.calls...)
+}
+
+func newTestClient(t *testing.T, scanner *testScanner, script reddit.Script) (*Client, chan spotify.Music) {
+	t.Helper()
+
+	m := make(chan spotify.Music, 10)
+
+	c := Client{
+		Config:               graw.Config{Subreddits: []string{"music"}},
+		Script:               script,
+		MusicChan:            m,
+		RetryAttemptWaitTime: time.Millisecond,
+		MaxRetryAttempts:     3,
+		ShouldRetry:          true,
+		paused:               make(map[string]bool),
+		done:                 make(chan struct{}),
+		Logger:               log.WithFields(log.Fields{"service": "reddit"}),
+	}
+
+	if scanner != nil {
+		c.Scan = scanner.scan
+	}
+
+	return &c, m
+}
+
+func newTestServer(t *testing.T) (*reddittest.Server, reddit.Script) {
+	t.Helper()
+
+	srv := reddittest.NewServer()
+	t.Cleanup(srv.Close)
+
+	if err := srv.AddListingFile("/r/music/new", "testdata/music_new.json"); err != nil {
+		t.Fatalf("error setting up test server: %s", err)
+	}
+
+	return srv, srv.Script()
+}
+
+func receive(t *testing.T, m chan spotify.Music, n int) []spotify.Music {
+	t.Helper()
+
+	var res []spotify.Music
+
+	for len(res) < n {
+		select {
+		case music := <-m:
+			res = append(res, music)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for music: got %d, exp %d", len(res), n)
+		}
+	}
+
+	return res
+}
+
+func TestPost(t *testing.T) {
+	_, script := newTestServer(t)
+	c, m := newTestClient(t, nil, script)
+
+	harvest, err := script.Listing("/r/music/new", "")
+	if err != nil {
+		t.Fatalf("unexpected error fetching listing: %s", err)
+	}
+
+	for _, post := range harvest.Posts {
+		if err := c.Post(post); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	exp := []spotify.Music{
+		{
+			Source:           "reddit",
+			Subreddit:        "music",
+			PostTitle:        "Daft Punk - Around the World [House] (1997)",
+			MediaTitle:       "Daft Punk - Around The World (Official Music Video)",
+			SecureMediaTitle: "Daft Punk - Around The World (Official Music Video)",
+			URL:              "https://www.youtube.com/watch?v=K0HSD_i2DvA",
+			PostID:           "j1a0f1",
+			Permalink:        "/r/Music/comments/j1a0f1/daft_punk_around_the_world_house_1997/",
+			Score:            412,
+			Posted:           time.Unix(1601300000, 0).UTC(),
+		},
+		{
+			Source:    "reddit",
+			Subreddit: "music",
+			PostTitle: "Aphex Twin -- Windowlicker [Electronic]",
+			URL:       "https://open.spotify.com/track/4Kk1g8s8ZfK4gS1p5M9WJb?si=abc123",
+			PostID:    "j1a0f2",
+			Permalink: "/r/Music/comments/j1a0f2/aphex_twin_windowlicker_electronic/",
+			Score:     88,
+			Posted:    time.Unix(1601300100, 0).UTC(),
+		},
+		{
+			Source:    "reddit",
+			Subreddit: "music",
+			PostTitle: "Khruangbin - Maria También [Psychedelic Funk]",
+			PostID:    "j1a0f3",
+			Permalink: "/r/Music/comments/j1a0f3/khruangbin_maria_tambien_psychedelic_funk/",
+			Score:     23,
+			Posted:    time.Unix(1601300200, 0).UTC(),
+		},
+		{
+			Source:    "reddit",
+			Subreddit: "music",
+			PostTitle: "Portishead - Roads (Live at Roseland NYC)",
+			PostID:    "j1a0f6",
+			Permalink: "/r/Music/comments/j1a0f6/portishead_roads_live_at_roseland_nyc/",
+			Score:     9,
+			Posted:    time.Unix(1601300500, 0).UTC(),
+		},
+	}
+
+	t.Run("should convert posts and skip deleted and stickied posts", func(t *testing.T) {
+		if len(m) != len(exp) {
+			t.Fatalf("unexpected number of music: got %d, exp %d", len(m), len(exp))
+		}
+
+		for i, music := range receive(t, m, len(exp)) {
+			if music != exp[i] {
+				t.Errorf("unexpected music, pos %d:\ngot %+v\nexp %+v", i, music, exp[i])
+			}
+		}
+	})
+
+	t.Run("should skip posts from paused subreddits", func(t *testing.T) {
+		c.Pause("Music")
+		defer c.Resume("music")
+
+		if err := c.Post(harvest.Posts[0]); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(m) != 0 {
+			t.Errorf("unexpected music from paused subreddit: got %d", len(m))
+		}
+	})
+}
+
+func TestBackfill(t *testing.T) {
+	srv, script := newTestServer(t)
+	c, m := newTestClient(t, nil, script)
+
+	t.Run("should queue posts oldest first", func(t *testing.T) {
+		n, err := c.Backfill("music", 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if n != 2 {
+			t.Errorf("unexpected number of posts queued: got %d, exp %d", n, 2)
+		}
+
+		music := receive(t, m, 2)
+		if music[0].PostTitle != "Aphex Twin -- Windowlicker [Electronic]" {
+			t.Errorf("unexpected first post: %s", music[0].PostTitle)
+		}
+
+		if music[1].PostTitle != "Daft Punk - Around the World [House] (1997)" {
+			t.Errorf("unexpected second post: %s", music[1].PostTitle)
+		}
+
+		if reqs := srv.Requests(); len(reqs) != 1 {
+			t.Errorf("unexpected requests: %v", reqs)
+		}
+	})
+
+	t.Run("should fail when reddit is unavailable", func(t *testing.T) {
+		srv.Fail(1)
+
+		if _, err := c.Backfill("music", 2); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+
+	t.Run("should stop sending when closed", func(t *testing.T) {
+		c, _ := newTestClient(t, nil, script)
+		c.MusicChan = make(chan spotify.Music)
+
+		if _, err := c.Backfill("music", 4); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		c.Close()
+
+		if _, err := c.Backfill("music", 4); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+}
+
+func TestScore(t *testing.T) {
+	srv, script := newTestServer(t)
+	c, _ := newTestClient(t, nil, script)
+
+	permalink := "/r/Music/comments/j1a0f1/daft_punk_around_the_world_house_1997/"
+	srv.AddListing(permalink, []byte(`[
+		{"kind": "Listing", "data": {"children": [{"kind": "t3", "data": {"id": "j1a0f1", "score": 1337, "permalink": "`+permalink+`"}}]}},
+		{"kind": "Listing", "data": {"children": []}}
+	]`))
+
+	t.Run("should return current score", func(t *testing.T) {
+		score, err := c.Score(permalink)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if score != 1337 {
+			t.Errorf("unexpected value: got %d, exp %d", score, 1337)
+		}
+	})
+
+	t.Run("should fail for unknown post", func(t *testing.T) {
+		if _, err := c.Score("/r/Music/comments/unknown/"); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+}
+
+func TestListen(t *testing.T) {
+	t.Run("should reconnect after scanner error", func(t *testing.T) {
+		scanner := newTestScanner(nil, errors.New("reddit down"))
+		c, _ := newTestClient(t, scanner, nil)
+
+		if err := c.PrepareScanner(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		shutdown := make(chan os.Signal, 1)
+		done := make(chan struct{})
+
+		go func() {
+			c.Listen(shutdown)
+			close(done)
+		}()
+
+		scanner.waits <- errors.New("connection lost")
+
+		waitFor(t, func() bool { return len(scanner.scans()) == 3 })
+
+		c.Close()
+		<-done
+
+		if len(shutdown) != 0 {
+			t.Errorf("unexpected shutdown")
+		}
+	})
+
+	t.Run("should request shutdown after maximum retry attempts", func(t *testing.T) {
+		down := errors.New("reddit down")
+		scanner := newTestScanner(nil, down, down, down)
+		c, _ := newTestClient(t, scanner, nil)
+
+		if err := c.PrepareScanner(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		shutdown := make(chan os.Signal, 1)
+		done := make(chan struct{})
+
+		go func() {
+			c.Listen(shutdown)
+			close(done)
+		}()
+
+		scanner.waits <- errors.New("connection lost")
+
+		select {
+		case <-shutdown:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for shutdown")
+		}
+
+		<-done
+
+		if n := len(scanner.scans()); n != 4 {
+			t.Errorf("unexpected number of scans: got %d, exp %d", n, 4)
+		}
+	})
+
+	t.Run("should restart scanner when subreddits change", func(t *testing.T) {
+		scanner := newTestScanner()
+		c, _ := newTestClient(t, scanner, nil)
+
+		if err := c.PrepareScanner(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		shutdown := make(chan os.Signal, 1)
+		done := make(chan struct{})
+
+		go func() {
+			c.Listen(shutdown)
+			close(done)
+		}()
+
+		c.SetSubreddits([]string{"music", "r/jazz"})
+
+		waitFor(t, func() bool { return len(scanner.scans()) == 2 })
+
+		c.Close()
+		<-done
+
+		scans := scanner.scans()
+		if subs := scans[1].Subreddits; len(subs) != 2 || subs[1] != "jazz" {
+			t.Errorf("unexpected subreddits after restart: %v", subs)
+		}
+	})
+
+	t.Run("should idle without subreddits", func(t *testing.T) {
+		scanner := newTestScanner()
+		c, _ := newTestClient(t, scanner, nil)
+
+		if err := c.PrepareScanner(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		shutdown := make(chan os.Signal, 1)
+		done := make(chan struct{})
+
+		go func() {
+			c.Listen(shutdown)
+			close(done)
+		}()
+
+		c.SetSubreddits(nil)
+		time.Sleep(50 * time.Millisecond)
+
+		if n := len(scanner.scans()); n != 1 {
+			t.Errorf("unexpected number of scans: got %d, exp %d", n, 1)
+		}
+
+		c.SetSubreddits([]string{"jazz"})
+
+		waitFor(t, func() bool { return len(scanner.scans()) == 2 })
+
+		c.Close()
+		<-done
+
+		if len(shutdown) != 0 {
+			t.Errorf("unexpected shutdown")
+		}
+	})
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("timed out waiting for condition")
+}
+
+func TestCleanSubNames(t *testing.T) {
+	subs := cleanSubNames([]string{"r/Music", "jazz", "r"})
+	exp := []string{"Music", "jazz", "r"}
+
+	for i, sub := range subs {
+		if sub != exp[i] {
+			t.Errorf("unexpected value: got %s, exp %s, pos %d", sub, exp[i], i)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package reddittest provides a local stand-in for Reddit replaying recorded
+// listing JSON, so the reddit package can be tested without network access.
+package reddittest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/turnage/graw/reddit"
+)
+
+// Server is an httptest server replaying recorded listings. Each path has
+// a queue of responses; the last response is repeated once the queue is
+// exhausted.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	listings map[string][][]byte
+	requests []string
+	failures int
+}
+
+// NewServer starts a server without any listings. Close it when done.
+func NewServer() *Server {
+	s := Server{listings: make(map[string][][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return &s
+}
+
+// AddListing queues responses for a listing path, e.g. /r/music/new.
+func (s *Server) AddListing(path string, responses ...[]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listings[path] = append(s.listings[path], responses...)
+}
+
+// AddListingFile queues responses read from recorded files for a listing path.
+func (s *Server) AddListingFile(path string, files ...string) error {
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("reading listing file: %s, %w", f, err)
+		}
+
+		s.AddListing(path, data)
+	}
+
+	return nil
+}
+
+// Fail makes the next n requests fail with 503 Service Unavailable.
+func (s *Server) Fail(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = n
+}
+
+// Requests returns the request URIs received, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// Script returns a graw script reading listings from the server instead
+// of reddit.com.
+func (s *Server) Script() reddit.Script {
+	return &script{url: s.URL, client: s.Client()}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.URL.RequestURI())
+
+	if s.failures > 0 {
+		s.failures--
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, ".json")
+
+	responses, ok := s.listings[path]
+	if !ok || len(responses) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(responses) > 1 {
+		s.listings[path] = responses[1:]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responses[0])
+}
@@ -0,0 +1,124 @@
+package reddittest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/turnage/graw/reddit"
+)
+
+// deletedKey is the self text of deleted posts.
+const deletedKey = "[deleted]"
+
+// script is a graw script reading from the server. Unlike the scripts
+// created by graw, it doesn't rate limit requests.
+type script struct {
+	url    string
+	client *http.Client
+}
+
+// thing is the envelope Reddit wraps all objects in.
+type thing struct {
+	Kind string                 `json:"kind"`
+	Data map[string]interface{} `json:"data"`
+}
+
+func (s *script) Listing(path, after string) (reddit.Harvest, error) {
+	return s.ListingWithParams(path, map[string]string{"before": after})
+}
+
+func (s *script) ListingWithParams(path string, params map[string]string) (reddit.Harvest, error) {
+	v := url.Values{"raw_json": {"1"}, "limit": {"100"}}
+	for key, value := range params {
+		v.Set(key, value)
+	}
+
+	var listing thing
+	if err := s.get(path, v, &listing); err != nil {
+		return reddit.Harvest{}, err
+	}
+
+	posts, err := parseListing(listing)
+	if err != nil {
+		return reddit.Harvest{}, err
+	}
+
+	return reddit.Harvest{Posts: posts}, nil
+}
+
+func (s *script) Thread(permalink string) (*reddit.Post, error) {
+	var listings []thing
+	if err := s.get(permalink, url.Values{"raw_json": {"1"}}, &listings); err != nil {
+		return nil, err
+	}
+
+	if len(listings) == 0 {
+		return nil, reddit.ThreadDoesNotExistErr
+	}
+
+	posts, err := parseListing(listings[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(posts) != 1 {
+		return nil, reddit.ThreadDoesNotExistErr
+	}
+
+	return posts[0], nil
+}
+
+func (s *script) get(path string, v url.Values, result interface{}) error {
+	resp, err := s.client.Get(s.url + path + ".json?" + v.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusServiceUnavailable:
+		return reddit.BusyErr
+	default:
+		return fmt.Errorf("bad response code: %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// parseListing parses the posts in a listing the same way graw does.
+func parseListing(t thing) ([]*reddit.Post, error) {
+	if t.Kind != "Listing" {
+		return nil, errors.New("thing is not listing")
+	}
+
+	var l struct {
+		Children []thing `mapstructure:"children"`
+	}
+
+	if err := mapstructure.Decode(t.Data, &l); err != nil {
+		return nil, fmt.Errorf("decoding listing: %w", err)
+	}
+
+	var posts []*reddit.Post
+
+	for _, c := range l.Children {
+		if c.Kind != "t3" {
+			continue
+		}
+
+		var p reddit.Post
+		if err := mapstructure.Decode(c.Data, &p); err != nil {
+			return nil, fmt.Errorf("decoding post: %w", err)
+		}
+
+		p.Deleted = p.SelfText == deletedKey
+		posts = append(posts, &p)
+	}
+
+	return posts, nil
+}
@@ -0,0 +1,137 @@
+// Package scheduler runs jobs at the start of every day, week or month,
+// like rotating playlists.
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Interval is how often a job runs.
+type Interval string
+
+// Supported intervals. Weeks start on monday.
+const (
+	Daily   Interval = "daily"
+	Weekly  Interval = "weekly"
+	Monthly Interval = "monthly"
+)
+
+// Valid reports whether the interval is supported.
+func (i Interval) Valid() bool {
+	switch i {
+	case Daily, Weekly, Monthly:
+		return true
+	}
+
+	return false
+}
+
+// Start returns the start of the period t is in.
+func (i Interval) Start(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	switch i {
+	case Weekly:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case Monthly:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+// Next returns the start of the period after the one t is in.
+func (i Interval) Next(t time.Time) time.Time {
+	start := i.Start(t)
+
+	switch i {
+	case Weekly:
+		return start.AddDate(0, 0, 7)
+	case Monthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Name returns a name for the period t is in, like 2026-10-19, 2026-W43
+// or 2026-10.
+func (i Interval) Name(t time.Time) string {
+	switch i {
+	case Weekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case Monthly:
+		return t.Format("2006-01")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// Scheduler runs jobs at the start of their intervals until stopped.
+type Scheduler struct {
+	Logger *log.Entry
+
+	wg   sync.WaitGroup
+	stop chan struct{}
+	once sync.Once
+	now  func() time.Time
+	// after is time.After, replaced in tests.
+	after func(d time.Duration) <-chan time.Time
+}
+
+// New returns a scheduler with no jobs.
+func New() *Scheduler {
+	return &Scheduler{
+		Logger: log.WithFields(log.Fields{"service": "scheduler"}),
+		stop:   make(chan struct{}),
+		now:    time.Now,
+		after:  time.After,
+	}
+}
+
+// Add starts running a job at the start of every interval. The job is
+// passed the time it was scheduled for.
+func (s *Scheduler) Add(name string, interval Interval, job func(t time.Time)) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		for {
+			next := interval.Next(s.now())
+			s.Logger.Infof("next run of %s: %s", name, next.Format(time.RFC3339))
+
+			select {
+			case <-s.after(next.Sub(s.now())):
+				s.Logger.Infof("running %s", name)
+				job(next)
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Run runs a job once right away, passing it the current time. Stop waits
+// for it to finish.
+func (s *Scheduler) Run(name string, job func(t time.Time)) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		s.Logger.Infof("running %s", name)
+		job(s.now())
+	}()
+}
+
+// Stop stops the scheduler and waits for running jobs to finish.
+func (s *Scheduler) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	s.wg.Wait()
+}
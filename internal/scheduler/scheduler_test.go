@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInterval(t *testing.T) {
+	// a sunday
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		n     Interval
+		start string
+		next  string
+		name  string
+	}{
+		{Daily, "2026-10-18", "2026-10-19", "2026-10-18"},
+		{Weekly, "2026-10-12", "2026-10-19", "2026-W42"},
+		{Monthly, "2026-10-01", "2026-11-01", "2026-10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.n), func(t *testing.T) {
+			if got := tt.n.Start(now).Format("2006-01-02"); got != tt.start {
+				t.Errorf("unexpected start: got %s, exp %s", got, tt.start)
+			}
+
+			if got := tt.n.Next(now).Format("2006-01-02"); got != tt.next {
+				t.Errorf("unexpected next: got %s, exp %s", got, tt.next)
+			}
+
+			if got := tt.n.Name(now); got != tt.name {
+				t.Errorf("unexpected name: got %s, exp %s", got, tt.name)
+			}
+		})
+	}
+
+	t.Run("should start week on monday", func(t *testing.T) {
+		monday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+		if got := Weekly.Start(monday); !got.Equal(monday) {
+			t.Errorf("unexpected start: got %s, exp %s", got, monday)
+		}
+	})
+
+	t.Run("should validate", func(t *testing.T) {
+		if !Weekly.Valid() || Interval("yearly").Valid() {
+			t.Errorf("unexpected validation")
+		}
+	})
+}
+
+func TestScheduler(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC)
+	fire := make(chan time.Time)
+
+	s := New()
+	s.now = func() time.Time { return now }
+	s.after = func(d time.Duration) <-chan time.Time {
+		exp := 8*time.Hour + 30*time.Minute
+		if d != exp {
+			t.Errorf("unexpected wait: got %s, exp %s", d, exp)
+		}
+		return fire
+	}
+
+	ran := make(chan time.Time, 1)
+	s.Add("test", Daily, func(t time.Time) { ran <- t })
+
+	fire <- now
+
+	select {
+	case got := <-ran:
+		if exp := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC); !got.Equal(exp) {
+			t.Errorf("unexpected run time: got %s, exp %s", got, exp)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("job did not run")
+	}
+
+	s.Stop()
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC)
+
+	s := New()
+	s.now = func() time.Time { return now }
+
+	var got time.Time
+	s.Run("test", func(t time.Time) { got = t })
+	s.Stop()
+
+	if !got.Equal(now) {
+		t.Errorf("unexpected run time: got %s, exp %s", got, now)
+	}
+}
@@ -0,0 +1,43 @@
+package sink
+
+import (
+	"fmt"
+
+	"github.com/engvik/dissic/internal/title"
+)
+
+// SearchTitles returns the titles to match a query by, with "artist -
+// title" from the structured fields first when known.
+func (q Query) SearchTitles() []string {
+	if q.Artist == "" || q.Title == "" {
+		return q.Titles
+	}
+
+	return append([]string{fmt.Sprintf("%s - %s", q.Artist, q.Title)}, q.Titles...)
+}
+
+// Match returns the first track matching a parsed title, preferring one
+// with the version asked for, like the Spotify matcher.
+func Match(t title.Title, tracks []Track) (Track, bool) {
+	var match *Track
+
+	for i, track := range tracks {
+		if !t.Matches(track.Title, track.Artists) {
+			continue
+		}
+
+		if t.VersionMatches(track.Title) {
+			return track, true
+		}
+
+		if match == nil {
+			match = &tracks[i]
+		}
+	}
+
+	if match == nil {
+		return Track{}, false
+	}
+
+	return *match, true
+}
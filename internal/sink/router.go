@@ -0,0 +1,137 @@
+package sink
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Router adds the tracks posted to subreddits to the playlists they feed
+// on the sinks it's given. Every track found is added: block and allow
+// lists, filters, dedupe and suppressions only apply to Spotify
+// playlists.
+type Router struct {
+	Logger *log.Entry
+
+	mu      sync.RWMutex
+	sinks   map[string]Sink
+	targets map[string][]target
+}
+
+// target is a playlist on a sink.
+type target struct {
+	sink       Sink
+	playlistID string
+}
+
+// NewRouter returns a router for sinks. Playlists on other services are
+// left alone.
+func NewRouter(sinks ...Sink) *Router {
+	r := Router{
+		Logger:  log.WithFields(log.Fields{"service": "sink"}),
+		sinks:   make(map[string]Sink, len(sinks)),
+		targets: make(map[string][]target),
+	}
+
+	for _, s := range sinks {
+		r.sinks[s.Service()] = s
+	}
+
+	return &r
+}
+
+// Prepare finds the playlists on the sinks, creating the ones configured
+// by name that don't exist, and maps the subreddits to them.
+func (r *Router) Prepare(playlists []config.Playlist) error {
+	targets := make(map[string][]target)
+
+	for _, p := range playlists {
+		s, ok := r.sinks[p.Service]
+		if !ok {
+			continue
+		}
+
+		playlistID := p.ID
+		if playlistID == "" {
+			id, err := s.EnsurePlaylist(p.Name, p.RenderDescription(time.Now(), 0))
+			if err != nil {
+				return fmt.Errorf("preparing %s playlist %s: %w", p.Service, p.Name, err)
+			}
+
+			playlistID = id
+		}
+
+		r.Logger.Infof("prepared %s playlist: %s (%s)", p.Service, p.Key(), playlistID)
+
+		for _, key := range p.Sources() {
+			targets[key] = append(targets[key], target{sink: s, playlistID: playlistID})
+		}
+	}
+
+	r.mu.Lock()
+	r.targets = targets
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Handle resolves the track a post is about once on every sink the
+// subreddit feeds, and adds it to the playlists it isn't already in.
+func (r *Router) Handle(subreddit string, q Query) {
+	r.mu.RLock()
+	targets := r.targets[strings.ToLower(subreddit)]
+	r.mu.RUnlock()
+
+	resolved := make(map[string]*Track)
+
+	for _, t := range targets {
+		service := t.sink.Service()
+
+		track, ok := resolved[service]
+		if !ok {
+			found, err := t.sink.ResolveTrack(q)
+			if err != nil {
+				r.Logger.Infof("\t%s: no track found: %s", service, err)
+			} else {
+				track = &found
+			}
+
+			resolved[service] = track
+		}
+
+		if track == nil {
+			continue
+		}
+
+		if err := r.add(t, *track); err != nil {
+			r.Logger.Errorf("\t%s: %s", service, err)
+		}
+	}
+}
+
+// add adds a track to the playlist of a target unless it's already there.
+func (r *Router) add(t target, track Track) error {
+	tracks, err := t.sink.Tracks(t.playlistID)
+	if err != nil {
+		return fmt.Errorf("getting playlist tracks: playlist %s: %w", t.playlistID, err)
+	}
+
+	for _, existing := range tracks {
+		if existing.ID == track.ID {
+			r.Logger.Infof("\t%s: track already in playlist %s: %s", t.sink.Service(), t.playlistID, track.ID)
+			return nil
+		}
+	}
+
+	if err := t.sink.AddTracks(t.playlistID, track.ID); err != nil {
+		return fmt.Errorf("adding track: playlist %s, track %s: %w", t.playlistID, track.ID, err)
+	}
+
+	r.Logger.Infof("\t%s: added track to playlist %s: %s - %s (%s)", t.sink.Service(), t.playlistID, strings.Join(track.Artists, ", "), track.Title, track.ID)
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/engvik/dissic/internal/config"
+)
+
+// memorySink is a sink keeping playlists in memory, resolving tracks by
+// the first title.
+type memorySink struct {
+	service   string
+	catalog   map[string]Track
+	playlists map[string][]string
+	resolved  int
+}
+
+func newMemorySink(service string, tracks ...Track) *memorySink {
+	s := memorySink{service: service, catalog: make(map[string]Track), playlists: make(map[string][]string)}
+	for _, t := range tracks {
+		s.catalog[t.Title] = t
+	}
+
+	return &s
+}
+
+func (s *memorySink) Service() string {
+	return s.service
+}
+
+func (s *memorySink) ResolveTrack(q Query) (Track, error) {
+	s.resolved++
+
+	if len(q.Titles) == 0 {
+		return Track{}, errors.New("no titles")
+	}
+
+	t, ok := s.catalog[q.Titles[0]]
+	if !ok {
+		return Track{}, errors.New("no track found")
+	}
+
+	return t, nil
+}
+
+func (s *memorySink) EnsurePlaylist(name string, description string) (string, error) {
+	id := fmt.Sprintf("%s:%s", s.service, name)
+	if _, ok := s.playlists[id]; !ok {
+		s.playlists[id] = nil
+	}
+
+	return id, nil
+}
+
+func (s *memorySink) AddTracks(playlistID string, trackIDs ...string) error {
+	s.playlists[playlistID] = append(s.playlists[playlistID], trackIDs...)
+	return nil
+}
+
+func (s *memorySink) RemoveTracks(playlistID string, trackIDs ...string) error {
+	return errors.New("not implemented")
+}
+
+func (s *memorySink) Tracks(playlistID string) ([]Track, error) {
+	var tracks []Track
+	for _, id := range s.playlists[playlistID] {
+		tracks = append(tracks, Track{ID: id})
+	}
+
+	return tracks, nil
+}
+
+func TestRouter(t *testing.T) {
+	deezer := newMemorySink(config.ServiceDeezer, Track{ID: "1", Title: "Daft Punk - Around the World"})
+
+	r := NewRouter(deezer)
+
+	err := r.Prepare([]config.Playlist{
+		{Name: "house", Subreddits: []string{"house"}, Service: config.ServiceDeezer},
+		{Name: "electronic", Subreddits: []string{"house", "techno"}, Service: config.ServiceDeezer},
+		{Name: "spotify", Subreddits: []string{"house"}, Service: config.ServiceSpotify},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	t.Run("should add track to every playlist the subreddit feeds", func(t *testing.T) {
+		r.Handle("House", Query{Titles: []string{"Daft Punk - Around the World"}})
+
+		for _, id := range []string{"deezer:house", "deezer:electronic"} {
+			if tracks := deezer.playlists[id]; len(tracks) != 1 || tracks[0] != "1" {
+				t.Errorf("unexpected tracks in %s: %v", id, tracks)
+			}
+		}
+
+		if deezer.resolved != 1 {
+			t.Errorf("unexpected value: got %d, exp %d", deezer.resolved, 1)
+		}
+
+		if len(deezer.playlists) != 2 {
+			t.Errorf("unexpected playlists: %v", deezer.playlists)
+		}
+	})
+
+	t.Run("should not add track already in playlist", func(t *testing.T) {
+		r.Handle("techno", Query{Titles: []string{"Daft Punk - Around the World"}})
+
+		if tracks := deezer.playlists["deezer:electronic"]; len(tracks) != 1 {
+			t.Errorf("unexpected tracks: %v", tracks)
+		}
+	})
+
+	t.Run("should skip unresolved track", func(t *testing.T) {
+		r.Handle("house", Query{Titles: []string{"Nobody - Nothing"}})
+
+		if tracks := deezer.playlists["deezer:house"]; len(tracks) != 1 {
+			t.Errorf("unexpected tracks: %v", tracks)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+// Package sink defines the music services playlists are kept on, and
+// routes the tracks posted to the playlists on services other than
+// Spotify. Spotify has a pipeline of its own, with the block and allow
+// lists, filters, dedupe and history the sinks don't have.
+package sink
+
+import "time"
+
+// Query is what's known about the track a post is about. Titles are the
+// post and media titles, URL the link posted. The structured fields are
+// set when the source knows them.
+type Query struct {
+	Titles   []string
+	URL      string
+	ISRC     string
+	Artist   string
+	Title    string
+	Album    string
+	Duration time.Duration
+}
+
+// Track is a track on a music service.
+type Track struct {
+	ID       string
+	Artists  []string
+	Title    string
+	Album    string
+	ISRC     string
+	Duration time.Duration
+}
+
+// Sink is a music service other than Spotify playlists are kept on.
+type Sink interface {
+	// Service is the name playlists refer to the service by.
+	Service() string
+	// ResolveTrack finds the track a query is about.
+	ResolveTrack(q Query) (Track, error)
+	// EnsurePlaylist returns the ID of the user's playlist with a name,
+	// creating it with the description if there's none.
+	EnsurePlaylist(name string, description string) (string, error)
+	AddTracks(playlistID string, trackIDs ...string) error
+	RemoveTracks(playlistID string, trackIDs ...string) error
+	Tracks(playlistID string) ([]Track, error)
+}
@@ -0,0 +1,206 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Poller checks the channels of a source on an interval in the background,
+// for the sources checking for new posts instead of being sent them, like
+// feeds and Lemmy communities. A channel that can't be read is tried again
+// on the next check, so a poller never requests a shutdown.
+//
+// The posts in a channel the first time it's read are only remembered,
+// like the posts already in a subreddit when it's watched. The posts last
+// seen are kept in Seen, which is saved in the state file, so posts made
+// while dissic was down are picked up when it starts again.
+type Poller struct {
+	Interval  time.Duration
+	Logger    *log.Entry
+	UserAgent string
+	// Kind and Channels describe the channels checked, like "feeds" and
+	// their names, for logging on start.
+	Kind     string
+	Channels []string
+	// Seen are the IDs of the posts last seen in each channel.
+	Seen *Seen
+
+	name  string
+	check func(stop <-chan struct{})
+
+	mu   sync.Mutex
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPoller returns a poller for the source called name, checking its
+// channels with check every interval. check is given a channel closed
+// when stopping, to give up sending posts.
+func NewPoller(name string, interval time.Duration, version string, check func(stop <-chan struct{})) *Poller {
+	return &Poller{
+		Interval:  interval,
+		Logger:    log.WithFields(log.Fields{"service": name}),
+		UserAgent: fmt.Sprintf("dissic/%s (+https://github.com/engvik/dissic)", version),
+		Seen:      NewSeen(nil),
+		name:      name,
+		check:     check,
+	}
+}
+
+// Name returns the name of the source.
+func (p *Poller) Name() string {
+	return p.name
+}
+
+// Start checks the channels right away and then every interval, until
+// closed.
+func (p *Poller) Start(shutdown chan<- os.Signal) error {
+	p.Logger.Infof("watching %d %s:", len(p.Channels), p.Kind)
+
+	for _, c := range p.Channels {
+		p.Logger.Infof("\t%s", c)
+	}
+
+	stop := make(chan struct{})
+
+	p.mu.Lock()
+	p.stop = stop
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.check(stop)
+			p.Seen.notify()
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops checking the channels, and waits for a check in progress.
+func (p *Poller) Close() {
+	p.Logger.Println("shutting down")
+
+	p.mu.Lock()
+	stop := p.stop
+	p.stop = nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		p.wg.Wait()
+	}
+}
+
+// Poll checks every channel for new posts once, and passes them on oldest
+// first.
+func (p *Poller) Poll() {
+	p.check(nil)
+	p.Seen.notify()
+}
+
+// Seen holds the IDs of the posts last seen in each channel of the polled
+// sources, by routing key. A channel that hasn't been read has no entry.
+type Seen struct {
+	// OnChange is called after a check that changed the posts seen.
+	OnChange func()
+
+	mu      sync.Mutex
+	ids     map[string][]string
+	changed bool
+}
+
+// NewSeen returns the posts seen, starting from ids.
+func NewSeen(ids map[string][]string) *Seen {
+	s := Seen{ids: make(map[string][]string, len(ids))}
+
+	for key, v := range ids {
+		s.ids[key] = append([]string(nil), v...)
+	}
+
+	return &s
+}
+
+// Get returns the IDs of the posts last seen in a channel, and whether it
+// has been read.
+func (s *Seen) Get(key string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, ok := s.ids[key]
+
+	return ids, ok
+}
+
+// Set sets the IDs of the posts last seen in a channel.
+func (s *Seen) Set(key string, ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.ids[key]; ok && equal(old, ids) {
+		return
+	}
+
+	if ids == nil {
+		ids = []string{}
+	}
+
+	s.ids[key] = ids
+	s.changed = true
+}
+
+// All returns a copy of the IDs of the posts last seen in every channel.
+func (s *Seen) All() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make(map[string][]string, len(s.ids))
+	for key, ids := range s.ids {
+		all[key] = append([]string{}, ids...)
+	}
+
+	return all
+}
+
+// notify calls OnChange if the posts seen changed since the last call.
+func (s *Seen) notify() {
+	s.mu.Lock()
+	changed := s.changed
+	s.changed = false
+	s.mu.Unlock()
+
+	if changed && s.OnChange != nil {
+		s.OnChange()
+	}
+}
+
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,45 @@
+// Package source defines the sources new music is found in, like reddit
+// or RSS and Atom feeds.
+package source
+
+import (
+	"os"
+	"strings"
+)
+
+// Source names.
+const (
+	Reddit   = "reddit"
+	RSS      = "rss"
+	Lemmy    = "lemmy"
+	Mastodon = "mastodon"
+)
+
+// Source is somewhere posts about music are found. The posts found are
+// sent to the channel the source was created with.
+type Source interface {
+	// Name returns the name of the source.
+	Name() string
+	// Start starts looking for new posts in the background. If the source
+	// fails for good, a shutdown is requested.
+	Start(shutdown chan<- os.Signal) error
+	// Close stops looking for new posts.
+	Close()
+}
+
+// Key returns the key posts from a channel of a source other than reddit
+// are routed to playlists by, like "rss:bandcamp". Posts from reddit are
+// routed by the subreddit name, which can't contain a colon.
+func Key(source string, name string) string {
+	return source + ":" + strings.ToLower(name)
+}
+
+// Label returns a readable label of a routing key, like "r/music" or
+// "rss:bandcamp".
+func Label(key string) string {
+	if strings.Contains(key, ":") {
+		return key
+	}
+
+	return "r/" + key
+}
@@ -0,0 +1,187 @@
+package spotify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/engvik/dissic/internal/title"
+	"github.com/zmb3/spotify"
+)
+
+// corpus is a set of real-world post titles with the artist and track
+// they're about, the track they should match and the tracks Spotify has
+// for them. See testdata/corpus.json.
+type corpus struct {
+	MinPrecision float64      `json:"min_precision"`
+	MinRecall    float64      `json:"min_recall"`
+	Cases        []corpusCase `json:"cases"`
+}
+
+type corpusCase struct {
+	Title      string        `json:"title"`
+	MediaTitle string        `json:"media_title"`
+	URL        string        `json:"url"`
+	Artist     string        `json:"artist"`
+	Track      string        `json:"track"`
+	TrackID    string        `json:"track_id"`
+	Note       string        `json:"note"`
+	Results    []corpusTrack `json:"results"`
+}
+
+type corpusTrack struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Artists     []string `json:"artists"`
+	Album       string   `json:"album"`
+	ReleaseDate string   `json:"release_date"`
+	ISRC        string   `json:"isrc"`
+}
+
+func (t corpusTrack) fullTrack() spotify.FullTrack {
+	track := spotifytest.NewTrack(t.ID, t.Name, t.Artists...)
+	track.Album.Name = t.Album
+	track.Album.ReleaseDate = t.ReleaseDate
+
+	if t.ISRC != "" {
+		track.ExternalIDs = map[string]string{"isrc": t.ISRC}
+	}
+
+	return track
+}
+
+func loadCorpus(t *testing.T) corpus {
+	t.Helper()
+
+	data, err := ioutil.ReadFile("testdata/corpus.json")
+	if err != nil {
+		t.Fatalf("error reading corpus: %s", err)
+	}
+
+	var c corpus
+	if err := json.Unmarshal(data, &c); err != nil {
+		t.Fatalf("error parsing corpus: %s", err)
+	}
+
+	return c
+}
+
+// TestCorpus runs the matcher against every corpus case, each with its own
+// fake catalog, and fails if precision or recall drop below the thresholds
+// in the corpus. Run with -v to see the misses.
+func TestCorpus(t *testing.T) {
+	corpus := loadCorpus(t)
+
+	var tp, fp, fn int
+	strategies := make(map[string]int)
+
+	for _, cc := range corpus.Cases {
+		fake := spotifytest.New("tester")
+		for _, r := range cc.Results {
+			fake.AddTrack(r.fullTrack())
+		}
+
+		c := newTestClient(t, fake)
+		m := Music{
+			Subreddit:  "music",
+			PostTitle:  cc.Title,
+			MediaTitle: cc.MediaTitle,
+			URL:        cc.URL,
+		}
+
+		var got string
+		if found, err := c.findTrack(m); err == nil {
+			got = string(found.Track.ID)
+			strategies[found.Strategy]++
+		}
+
+		switch {
+		case got != "" && got == cc.TrackID:
+			tp++
+			continue
+		case got != "" && cc.TrackID == "":
+			fp++
+		case got != "":
+			fp++
+			fn++
+		case cc.TrackID != "":
+			fn++
+		default:
+			continue
+		}
+
+		t.Logf("miss: %q: got %q, exp %q, searches %q", cc.Title, got, cc.TrackID, fake.Searches())
+	}
+
+	precision := ratio(tp, tp+fp)
+	recall := ratio(tp, tp+fn)
+
+	t.Logf("cases: %d, precision: %.2f, recall: %.2f, strategies: %v", len(corpus.Cases), precision, recall, strategies)
+
+	if precision < corpus.MinPrecision {
+		t.Errorf("precision below threshold: got %.2f, exp %.2f", precision, corpus.MinPrecision)
+	}
+
+	if recall < corpus.MinRecall {
+		t.Errorf("recall below threshold: got %.2f, exp %.2f", recall, corpus.MinRecall)
+	}
+}
+
+// TestCorpusParse checks that the titles of every corpus case about a
+// track parse into its artist and track. Cases only matched by their link
+// may have titles that don't parse.
+func TestCorpusParse(t *testing.T) {
+	for _, cc := range loadCorpus(t).Cases {
+		if cc.Artist == "" {
+			continue
+		}
+
+		parsed, ok := parseCase(cc)
+		if !ok {
+			if cc.URL == "" {
+				t.Errorf("unparsed title: %q", cc.Title)
+			}
+
+			continue
+		}
+
+		if !hasArtist(parsed.Artists, cc.Artist) {
+			t.Errorf("unexpected artists for %q: got %q, exp %q", cc.Title, parsed.Artists, cc.Artist)
+		}
+
+		if title.Normalize(parsed.Track) != title.Normalize(cc.Track) {
+			t.Errorf("unexpected track for %q: got %q, exp %q", cc.Title, parsed.Track, cc.Track)
+		}
+	}
+}
+
+// parseCase parses the post title of a case, or the media title if the
+// post title doesn't parse.
+func parseCase(cc corpusCase) (title.Title, bool) {
+	for _, s := range []string{cc.Title, cc.MediaTitle} {
+		if parsed, err := title.Parse(s); s != "" && err == nil {
+			return parsed, true
+		}
+	}
+
+	return title.Title{}, false
+}
+
+func hasArtist(artists []string, artist string) bool {
+	for _, a := range artists {
+		if title.Normalize(a) == title.Normalize(artist) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ratio(n int, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+
+	return float64(n) / float64(total)
+}
@@ -0,0 +1,73 @@
+package spotify
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+
+	"github.com/engvik/dissic/internal/config"
+)
+
+const (
+	// coverSize is the width and height of generated covers.
+	coverSize = 300
+	// maxCoverSize is the largest cover image Spotify accepts, in bytes.
+	maxCoverSize = 256 * 1024
+)
+
+// coverImage returns the cover image of a playlist, read from the
+// configured file or generated.
+func coverImage(p config.Playlist) ([]byte, error) {
+	if p.Cover == config.CoverGenerate {
+		return generateCover(p.Key())
+	}
+
+	img, err := ioutil.ReadFile(p.Cover)
+	if err != nil {
+		return nil, fmt.Errorf("reading cover: %s, %w", p.Cover, err)
+	}
+
+	if len(img) > maxCoverSize {
+		return nil, fmt.Errorf("cover %s is larger than %d KB", p.Cover, maxCoverSize/1024)
+	}
+
+	return img, nil
+}
+
+// generateCover draws a JPEG cover with a diagonal gradient between two
+// colors picked from seed, so a playlist keeps its colors.
+func generateCover(seed string) ([]byte, error) {
+	h := fnv.New32a()
+	h.Write([]byte(seed))
+	sum := h.Sum32()
+
+	from := color.RGBA{R: uint8(sum), G: uint8(sum >> 8), B: uint8(sum >> 16), A: 0xff}
+	to := color.RGBA{R: 0xff - from.G, G: 0xff - from.B, B: 0xff - from.R, A: 0xff}
+
+	img := image.NewRGBA(image.Rect(0, 0, coverSize, coverSize))
+
+	for y := 0; y < coverSize; y++ {
+		for x := 0; x < coverSize; x++ {
+			img.Set(x, y, blend(from, to, float64(x+y)/float64(2*(coverSize-1))))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, fmt.Errorf("encoding cover: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func blend(from color.RGBA, to color.RGBA, t float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
+	}
+
+	return color.RGBA{R: mix(from.R, to.R), G: mix(from.G, to.G), B: mix(from.B, to.B), A: 0xff}
+}
@@ -0,0 +1,38 @@
+package spotify
+
+import (
+	"bytes"
+	"image/jpeg"
+	"testing"
+
+	"github.com/engvik/dissic/internal/config"
+)
+
+func TestGenerateCover(t *testing.T) {
+	img, err := coverImage(config.Playlist{Name: "music", Cover: config.CoverGenerate})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(img) > maxCoverSize {
+		t.Errorf("cover too large: %d bytes", len(img))
+	}
+
+	decoded, err := jpeg.Decode(bytes.NewReader(img))
+	if err != nil {
+		t.Fatalf("unexpected error decoding cover: %s", err)
+	}
+
+	if b := decoded.Bounds(); b.Dx() != coverSize || b.Dy() != coverSize {
+		t.Errorf("unexpected size: %dx%d", b.Dx(), b.Dy())
+	}
+
+	again, _ := generateCover("music")
+	if !bytes.Equal(img, again) {
+		t.Errorf("expected the same cover for the same playlist")
+	}
+
+	if _, err := coverImage(config.Playlist{Cover: "testdata/missing.jpg"}); err == nil {
+		t.Errorf("expected error for missing cover file")
+	}
+}
@@ -0,0 +1,119 @@
+package spotify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/zmb3/spotify"
+)
+
+// dedupe is the dedupe policy of a playlist: the other playlists a track
+// may not already be in, and what to do if it is.
+type dedupe struct {
+	action   string
+	siblings []spotify.ID
+}
+
+// dedupePolicies returns the dedupe policy of each playlist.
+func dedupePolicies(playlists []config.Playlist, playlistIDs map[string]spotify.ID) map[spotify.ID]dedupe {
+	policies := make(map[spotify.ID]dedupe)
+
+	for _, p := range playlists {
+		playlistID, ok := playlistIDs[p.Key()]
+		if !ok {
+			continue
+		}
+
+		d := dedupe{action: p.DedupeAction}
+
+		for _, sibling := range playlists {
+			siblingID, ok := playlistIDs[sibling.Key()]
+			if !ok || siblingID == playlistID {
+				continue
+			}
+
+			if p.DedupeScope == config.DedupeAll ||
+				(p.DedupeScope == config.DedupeGroup && sibling.DedupeGroup == p.DedupeGroup) {
+				d.siblings = append(d.siblings, siblingID)
+			}
+		}
+
+		policies[playlistID] = d
+	}
+
+	return policies
+}
+
+// dedupe checks whether a track is already in another playlist in the
+// dedupe scope of a playlist, according to the history. It returns why
+// the track is skipped, or the playlists to move it from. Tracks added to
+// a sibling for the same post are always skipped.
+func (c *Client) dedupe(playlistID spotify.ID, trackID spotify.ID, added map[spotify.ID]bool) (string, []spotify.ID) {
+	c.mu.RLock()
+	d := c.playlistDedupe[playlistID]
+	c.mu.RUnlock()
+
+	if len(d.siblings) == 0 {
+		return "", nil
+	}
+
+	in := make(map[string]bool)
+	for _, p := range c.History.Playlists(string(trackID)) {
+		in[p] = true
+	}
+
+	var dupes []spotify.ID
+	var skip bool
+
+	for _, sibling := range d.siblings {
+		if in[string(sibling)] {
+			dupes = append(dupes, sibling)
+			skip = skip || added[sibling]
+		}
+	}
+
+	if len(dupes) == 0 {
+		return "", nil
+	}
+
+	if skip || d.action != config.DedupeMove {
+		return fmt.Sprintf("already in playlist %s", joinIDs(dupes)), nil
+	}
+
+	return "", dupes
+}
+
+// move removes a track added to playlistID from the playlists it's moved
+// from, recording the removals in the history.
+func (c *Client) move(e history.Entry, from []spotify.ID, playlistID spotify.ID) error {
+	for _, p := range from {
+		snapshotID, err := c.Spotify.RemoveTracksFromPlaylist(p, spotify.ID(e.TrackID))
+		if err != nil {
+			return fmt.Errorf("removing track: playlist %s, track %s: %w", p, e.TrackID, err)
+		}
+
+		e.Playlist = string(p)
+		e.SnapshotID = snapshotID
+		e.Status = history.StatusRemoved
+		e.Reason = fmt.Sprintf("moved to playlist %s", playlistID)
+
+		if err := c.History.Add(e); err != nil {
+			c.Logger.Errorf("adding history: %s", err)
+		}
+
+		c.Logger.Infof("\tmoved track %s from playlist %s to %s", e.TrackID, p, playlistID)
+	}
+
+	return nil
+}
+
+func joinIDs(ids []spotify.ID) string {
+	s := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s = append(s, string(id))
+	}
+
+	return strings.Join(s, ", ")
+}
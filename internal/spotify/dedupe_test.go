@@ -0,0 +1,136 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func TestDedupePolicies(t *testing.T) {
+	playlists := []config.Playlist{
+		{Name: "one", DedupeScope: config.DedupeGroup, DedupeGroup: "chill"},
+		{Name: "two", DedupeScope: config.DedupeGroup, DedupeGroup: "chill", DedupeAction: config.DedupeMove},
+		{Name: "three", DedupeScope: config.DedupeAll},
+		{Name: "four", DedupeScope: config.DedupePlaylist},
+	}
+	ids := map[string]spotify.ID{"one": "p1", "two": "p2", "three": "p3", "four": "p4"}
+
+	policies := dedupePolicies(playlists, ids)
+
+	tests := []struct {
+		n      string
+		action string
+		exp    []spotify.ID
+	}{
+		{"p1", "", []spotify.ID{"p2"}},
+		{"p2", config.DedupeMove, []spotify.ID{"p1"}},
+		{"p3", "", []spotify.ID{"p1", "p2", "p4"}},
+		{"p4", "", nil},
+	}
+
+	for _, tt := range tests {
+		d := policies[spotify.ID(tt.n)]
+
+		if d.action != tt.action {
+			t.Errorf("unexpected action for %s: got %s, exp %s", tt.n, d.action, tt.action)
+		}
+
+		if joinIDs(d.siblings) != joinIDs(tt.exp) {
+			t.Errorf("unexpected value for %s: got %v, exp %v", tt.n, d.siblings, tt.exp)
+		}
+	}
+}
+
+func TestAddToPlaylistsDedupe(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(spotifytest.NewTrack("track1", "Around the World", "Daft Punk"))
+	oneID := fake.AddPlaylist("tester", "one")
+	twoID := fake.AddPlaylist("tester", "two")
+	otherID := fake.AddPlaylist("tester", "other")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"one": oneID, "two": twoID, "other": otherID}
+
+	t.Run("should skip track in sibling playlist", func(t *testing.T) {
+		c.MapSubreddits([]config.Playlist{
+			{Name: "one", Subreddits: []string{"music"}, DedupeScope: config.DedupeGroup, DedupeGroup: "g"},
+			{Name: "two", Subreddits: []string{"music"}, DedupeScope: config.DedupeGroup, DedupeGroup: "g"},
+			{Name: "other", Subreddits: []string{"music"}},
+		})
+
+		c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World"})
+
+		if ids := fake.PlaylistTrackIDs(oneID); len(ids) != 1 {
+			t.Errorf("unexpected tracks in playlist one: %v", ids)
+		}
+
+		if ids := fake.PlaylistTrackIDs(twoID); len(ids) != 0 {
+			t.Errorf("unexpected tracks in playlist two: %v", ids)
+		}
+
+		if ids := fake.PlaylistTrackIDs(otherID); len(ids) != 1 {
+			t.Errorf("unexpected tracks in playlist other: %v", ids)
+		}
+
+		skipped := c.History.Entries(func(e history.Entry) bool { return e.Status == history.StatusSkipped })
+		if len(skipped) != 1 || skipped[0].Playlist != string(twoID) || skipped[0].Reason != "already in playlist "+string(oneID) {
+			t.Errorf("unexpected skipped history: %+v", skipped)
+		}
+	})
+
+	t.Run("should move track from sibling playlist", func(t *testing.T) {
+		c.MapSubreddits([]config.Playlist{
+			{Name: "one", Subreddits: []string{"music"}},
+			{Name: "two", Subreddits: []string{"jazz"}, DedupeScope: config.DedupeAll, DedupeAction: config.DedupeMove},
+			{Name: "other", Subreddits: []string{"music"}},
+		})
+
+		c.handle(Music{Subreddit: "jazz", PostTitle: "Daft Punk - Around the World"})
+
+		if ids := fake.PlaylistTrackIDs(oneID); len(ids) != 0 {
+			t.Errorf("unexpected tracks in playlist one: %v", ids)
+		}
+
+		if ids := fake.PlaylistTrackIDs(otherID); len(ids) != 0 {
+			t.Errorf("unexpected tracks in playlist other: %v", ids)
+		}
+
+		if ids := fake.PlaylistTrackIDs(twoID); len(ids) != 1 {
+			t.Errorf("unexpected tracks in playlist two: %v", ids)
+		}
+
+		if got := c.History.Playlists("track1"); len(got) != 1 || got[0] != string(twoID) {
+			t.Errorf("unexpected playlists in history: %v", got)
+		}
+
+		entries := c.RecentActivity(1)
+		if len(entries) != 1 || entries[0].Status != activity.StatusAdded || entries[0].Message != "moved from playlist "+string(oneID)+", "+string(otherID) {
+			t.Errorf("unexpected activity: %+v", entries)
+		}
+	})
+	t.Run("should keep track in sibling playlist if adding fails", func(t *testing.T) {
+		fake.AddTrack(spotifytest.NewTrack("track2", "Windowlicker", "Aphex Twin"))
+		fake.AddTracksToPlaylist(twoID, "track2")
+
+		c.handle(Music{Subreddit: "music", PostTitle: "Aphex Twin - Windowlicker"})
+
+		if ids := fake.PlaylistTrackIDs(oneID); len(ids) != 1 || ids[0] != "track2" {
+			t.Fatalf("unexpected tracks in playlist one: %v", ids)
+		}
+
+		c.handle(Music{Subreddit: "jazz", PostTitle: "Aphex Twin - Windowlicker"})
+
+		if ids := fake.PlaylistTrackIDs(oneID); len(ids) != 1 {
+			t.Errorf("unexpected tracks in playlist one: %v", ids)
+		}
+
+		entries := c.RecentActivity(1)
+		if len(entries) != 1 || entries[0].Status != activity.StatusFailed {
+			t.Errorf("unexpected activity: %+v", entries)
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package spotify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/zmb3/spotify"
+)
+
+// digestReason is the reason tracks are added to and removed from digest
+// playlists with.
+const digestReason = "digest rebuilt"
+
+// addMatched records the post a track was found for in the history.
+func (c *Client) addMatched(m Music, found match) {
+	err := c.History.Add(history.Entry{
+		Time:      m.Posted,
+		Subreddit: m.Subreddit,
+		Title:     m.PostTitle,
+		TrackID:   string(found.Track.ID),
+		Status:    history.StatusMatched,
+		PostID:    m.PostID,
+		Permalink: m.Permalink,
+		Score:     m.Score,
+	})
+	if err != nil {
+		c.Logger.Errorf("adding history: %s", err)
+	}
+}
+
+// MatchedPosts returns the posts from the subreddits a track was found
+// for, posted from from until to. A post matched more than once is only
+// returned once.
+func (c *Client) MatchedPosts(subreddits []string, from time.Time, to time.Time) []history.Entry {
+	subs := make(map[string]bool, len(subreddits))
+	for _, sub := range subreddits {
+		subs[strings.ToLower(sub)] = true
+	}
+
+	seen := make(map[string]bool)
+
+	return c.History.Entries(func(e history.Entry) bool {
+		if e.Status != history.StatusMatched || !subs[e.Subreddit] || e.Time.Before(from) || !e.Time.Before(to) {
+			return false
+		}
+
+		key := e.PostID
+		if key == "" {
+			key = e.Subreddit + "/" + e.Title
+		}
+
+		if seen[key] {
+			return false
+		}
+
+		seen[key] = true
+
+		return true
+	})
+}
+
+// ReplacePlaylist replaces the tracks of the playlist identified by key
+// with the tracks of up to n of the posts, in order. Tracks suppressed in
+// the playlist, or kept out of it by its lists and filters, are left out.
+// The tracks removed and added are recorded in the history. It returns
+// the number of tracks in the playlist.
+func (c *Client) ReplacePlaylist(key string, posts []history.Entry, n int) (int, error) {
+	c.mu.RLock()
+	playlistID, ok := c.PlaylistIDs[key]
+	filters := c.PlaylistFilters[playlistID]
+	c.mu.RUnlock()
+
+	if !ok {
+		return 0, fmt.Errorf("unknown playlist: %s", key)
+	}
+
+	current, err := c.playlistTracks(playlistID)
+	if err != nil {
+		return 0, err
+	}
+
+	picked, err := c.digestPosts(playlistID, filters, posts, n)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]spotify.ID, 0, len(picked))
+	for _, p := range picked {
+		ids = append(ids, spotify.ID(p.TrackID))
+	}
+
+	first := ids
+	if len(first) > maxTracksPerRequest {
+		first = first[:maxTracksPerRequest]
+	}
+
+	if err := c.Spotify.ReplacePlaylistTracks(playlistID, first...); err != nil {
+		return 0, fmt.Errorf("replacing tracks: playlist %s: %w", playlistID, err)
+	}
+
+	for start := len(first); start < len(ids); start += maxTracksPerRequest {
+		end := start + maxTracksPerRequest
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		if _, err := c.Spotify.AddTracksToPlaylist(playlistID, ids[start:end]...); err != nil {
+			return 0, fmt.Errorf("adding tracks: playlist %s: %w", playlistID, err)
+		}
+	}
+
+	c.addDigestHistory(playlistID, current, picked)
+
+	c.Logger.Infof("replaced tracks of playlist %s with %d tracks", playlistID, len(ids))
+
+	return len(ids), nil
+}
+
+// digestPosts returns up to n of the posts whose tracks may be added to a
+// digest playlist.
+func (c *Client) digestPosts(playlistID spotify.ID, filters config.Filters, posts []history.Entry, n int) ([]history.Entry, error) {
+	tracks, err := c.fullTracks(posts)
+	if err != nil {
+		return nil, err
+	}
+
+	var picked []history.Entry
+
+	for _, p := range posts {
+		if len(picked) >= n {
+			break
+		}
+
+		t, ok := tracks[p.TrackID]
+		if !ok {
+			c.Logger.Infof("\tskipped track %s for playlist %s: track not found", p.TrackID, playlistID)
+			continue
+		}
+
+		reason := c.suppressedReason(playlistID, t.ID)
+		if reason == "" {
+			reason = c.listsReason(playlistID, Music{Subreddit: p.Subreddit, PostTitle: p.Title}, *t)
+		}
+
+		if reason == "" {
+			reason, err = rejectReason(filters, *t, c.audioFeatures(t.ID))
+			if err != nil {
+				return nil, fmt.Errorf("checking playlist filters: %w", err)
+			}
+		}
+
+		if reason != "" {
+			c.Logger.Infof("\tskipped track %s for playlist %s: %s", p.TrackID, playlistID, reason)
+			continue
+		}
+
+		picked = append(picked, p)
+	}
+
+	return picked, nil
+}
+
+// fullTracks looks up the tracks of the posts by ID.
+func (c *Client) fullTracks(posts []history.Entry) (map[string]*spotify.FullTrack, error) {
+	var ids []spotify.ID
+	seen := make(map[string]bool)
+
+	for _, p := range posts {
+		if !seen[p.TrackID] {
+			seen[p.TrackID] = true
+			ids = append(ids, spotify.ID(p.TrackID))
+		}
+	}
+
+	tracks := make(map[string]*spotify.FullTrack, len(ids))
+
+	for start := 0; start < len(ids); start += maxTracksPerLookup {
+		end := start + maxTracksPerLookup
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		found, err := c.Spotify.GetTracks(ids[start:end]...)
+		if err != nil {
+			return nil, fmt.Errorf("getting tracks: %w", err)
+		}
+
+		for _, t := range found {
+			if t != nil {
+				tracks[string(t.ID)] = t
+			}
+		}
+	}
+
+	return tracks, nil
+}
+
+// addDigestHistory records the tracks removed from and added to a digest
+// playlist when it was rebuilt.
+func (c *Client) addDigestHistory(playlistID spotify.ID, current []spotify.PlaylistTrack, picked []history.Entry) {
+	var snapshotID string
+	if playlist, err := c.Spotify.GetPlaylist(playlistID); err != nil {
+		c.Logger.Errorf("error getting playlist snapshot: %s", err)
+	} else {
+		snapshotID = playlist.SnapshotID
+	}
+
+	kept := make(map[string]bool, len(picked))
+	for _, p := range picked {
+		kept[p.TrackID] = true
+	}
+
+	was := make(map[string]bool, len(current))
+	var entries []history.Entry
+
+	for _, t := range current {
+		id := string(t.Track.ID)
+		if was[id] {
+			continue
+		}
+		was[id] = true
+
+		if !kept[id] {
+			entries = append(entries, history.Entry{
+				Playlist:   string(playlistID),
+				TrackID:    id,
+				Status:     history.StatusRemoved,
+				Reason:     digestReason,
+				SnapshotID: snapshotID,
+			})
+		}
+	}
+
+	for _, p := range picked {
+		if was[p.TrackID] {
+			continue
+		}
+		was[p.TrackID] = true
+
+		entries = append(entries, history.Entry{
+			Subreddit:  p.Subreddit,
+			Title:      p.Title,
+			Playlist:   string(playlistID),
+			TrackID:    p.TrackID,
+			Status:     history.StatusAdded,
+			Reason:     digestReason,
+			PostID:     p.PostID,
+			Permalink:  p.Permalink,
+			Score:      p.Score,
+			SnapshotID: snapshotID,
+		})
+	}
+
+	for _, e := range entries {
+		if err := c.History.Add(e); err != nil {
+			c.Logger.Errorf("adding history: %s", err)
+		}
+	}
+}
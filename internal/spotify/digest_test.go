@@ -0,0 +1,132 @@
+package spotify
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func TestMatchedPosts(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(
+		spotifytest.NewTrack("track1", "Around the World", "Daft Punk"),
+		spotifytest.NewTrack("track2", "Windowlicker", "Aphex Twin"),
+	)
+	playlistID := fake.AddPlaylist("tester", "one")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"one": playlistID}
+
+	posted := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	music := []Music{
+		{Subreddit: "music", PostTitle: "Daft Punk - Around the World", PostID: "p1", Score: 3, Posted: posted},
+		{Subreddit: "music", PostTitle: "Daft Punk - Around the World", PostID: "p1", Score: 4, Posted: posted},
+		{Subreddit: "jazz", PostTitle: "Aphex Twin - Windowlicker", PostID: "p2", Posted: posted},
+		{Subreddit: "music", PostTitle: "Aphex Twin - Windowlicker", PostID: "p3", Posted: posted.AddDate(0, 0, 7)},
+	}
+
+	for _, m := range music {
+		c.handle(m)
+	}
+
+	got := c.MatchedPosts([]string{"Music"}, posted.AddDate(0, 0, -2), posted.AddDate(0, 0, 5))
+	if len(got) != 1 || got[0].PostID != "p1" || got[0].TrackID != "track1" || got[0].Score != 3 {
+		t.Errorf("unexpected posts: %+v", got)
+	}
+}
+
+func TestReplacePlaylist(t *testing.T) {
+	fake := spotifytest.New("tester")
+
+	var posts []history.Entry
+	for i := 119; i >= 0; i-- {
+		id := fmt.Sprintf("track%d", i)
+		fake.AddTrack(spotifytest.NewTrack(id, id, "Artist"))
+		posts = append(posts, history.Entry{TrackID: id})
+	}
+
+	playlistID := fake.AddPlaylist("tester", "top", "track0", "track1")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"top": playlistID}
+
+	n, err := c.ReplacePlaylist("top", posts, 110)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ids := fake.PlaylistTrackIDs(playlistID)
+	if n != 110 || len(ids) != 110 || ids[0] != "track119" || ids[109] != "track10" {
+		t.Errorf("unexpected tracks: %d", len(ids))
+	}
+
+	if _, err := c.ReplacePlaylist("unknown", posts, 110); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestReplacePlaylistGated(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(
+		spotifytest.NewTrack("track1", "Around the World", "Daft Punk"),
+		spotifytest.NewTrack("track2", "Windowlicker", "Aphex Twin"),
+		spotifytest.NewTrack("track3", "Roads", "Portishead"),
+		spotifytest.NewTrack("track4", "Teardrop", "Massive Attack"),
+	)
+	playlistID := fake.AddPlaylist("tester", "top", "track3", "track4")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"top": playlistID}
+	c.MapSubreddits([]config.Playlist{
+		{Name: "top", Subreddits: []string{"music"}, Block: config.Lists{Artists: []string{"Daft Punk"}}},
+	})
+
+	// track3 was added by the last build, and removed by a user since
+	if err := c.History.Add(history.Entry{Playlist: string(playlistID), TrackID: "track3", Status: history.StatusAdded}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := fake.RemoveTracksFromPlaylist(playlistID, "track3"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	posts := []history.Entry{
+		{Subreddit: "music", Title: "Daft Punk - Around the World", TrackID: "track1"},
+		{Subreddit: "music", Title: "Portishead - Roads", TrackID: "track3"},
+		{Subreddit: "music", Title: "Aphex Twin - Windowlicker", TrackID: "track2", PostID: "p2"},
+		{Subreddit: "music", Title: "Massive Attack - Teardrop", TrackID: "track4"},
+	}
+
+	n, err := c.ReplacePlaylist("top", posts, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if ids := fake.PlaylistTrackIDs(playlistID); n != 1 || len(ids) != 1 || ids[0] != "track2" {
+		t.Errorf("unexpected tracks: %v", ids)
+	}
+
+	t.Run("should record the tracks removed and added", func(t *testing.T) {
+		tracks := c.History.Tracks(string(playlistID))
+		if len(tracks) != 1 || tracks[0].TrackID != "track2" || tracks[0].PostID != "p2" || tracks[0].SnapshotID == "" {
+			t.Errorf("unexpected history: %+v", tracks)
+		}
+
+		removed := c.History.Entries(func(e history.Entry) bool {
+			return e.Status == history.StatusRemoved
+		})
+		if len(removed) != 1 || removed[0].TrackID != "track4" {
+			t.Errorf("unexpected removed tracks: %+v", removed)
+		}
+
+		if !c.History.IsSuppressed(string(playlistID), "track3") {
+			t.Errorf("expected track3 to be suppressed")
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package spotify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/engvik/dissic/internal/export"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/zmb3/spotify"
+)
+
+// maxTracksPerLookup is the most tracks Spotify looks up in a request.
+const maxTracksPerLookup = 50
+
+// ExportPlaylist returns the tracks in the playlist with key for export,
+// with the post each track was last found for according to the history.
+func (c *Client) ExportPlaylist(key string) ([]export.Track, error) {
+	playlistID, err := c.playlistKeyID(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if playlistID == "" {
+		return nil, fmt.Errorf("missing playlist")
+	}
+
+	tracks, err := c.playlistTracks(playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := c.trackPosts(string(playlistID))
+	res := make([]export.Track, 0, len(tracks))
+
+	for _, t := range tracks {
+		et := exportTrack(t.Track)
+		et.AddedAt, _ = time.Parse(spotify.TimestampLayout, t.AddedAt)
+		withPost(&et, posts[string(t.Track.ID)])
+
+		res = append(res, et)
+	}
+
+	return res, nil
+}
+
+// ExportHistory returns every history entry with a track for export, with
+// the track looked up on Spotify and the playlist key as playlist.
+func (c *Client) ExportHistory() ([]export.Track, error) {
+	entries := c.withKeys(c.History.Entries(func(e history.Entry) bool {
+		return e.TrackID != ""
+	}))
+
+	var ids []spotify.ID
+	seen := make(map[string]bool)
+
+	for _, e := range entries {
+		if !seen[e.TrackID] {
+			seen[e.TrackID] = true
+			ids = append(ids, spotify.ID(e.TrackID))
+		}
+	}
+
+	tracks := make(map[string]*spotify.FullTrack, len(ids))
+
+	for start := 0; start < len(ids); start += maxTracksPerLookup {
+		end := start + maxTracksPerLookup
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		found, err := c.Spotify.GetTracks(ids[start:end]...)
+		if err != nil {
+			return nil, fmt.Errorf("getting tracks: %w", err)
+		}
+
+		for _, t := range found {
+			if t != nil {
+				tracks[string(t.ID)] = t
+			}
+		}
+	}
+
+	res := make([]export.Track, 0, len(entries))
+
+	for _, e := range entries {
+		et := export.Track{Title: e.Title, URI: "spotify:track:" + e.TrackID}
+		if t, ok := tracks[e.TrackID]; ok {
+			et = exportTrack(*t)
+		}
+
+		withPost(&et, e)
+		et.AddedAt = e.Time
+		et.Playlist = e.Playlist
+		et.Status = e.Status
+
+		res = append(res, et)
+	}
+
+	return res, nil
+}
+
+// trackPosts returns the latest history entry with a post for each track
+// added to a playlist, preferring the matched entries with the permalink
+// and score.
+func (c *Client) trackPosts(playlist string) map[string]history.Entry {
+	posts := make(map[string]history.Entry)
+
+	for _, e := range c.History.Entries(func(e history.Entry) bool {
+		return e.Status == history.StatusAdded && e.Playlist == playlist
+	}) {
+		posts[e.TrackID] = e
+	}
+
+	for _, e := range c.History.Entries(func(e history.Entry) bool {
+		return e.Status == history.StatusMatched
+	}) {
+		if _, ok := posts[e.TrackID]; ok {
+			posts[e.TrackID] = e
+		}
+	}
+
+	return posts
+}
+
+func exportTrack(t spotify.FullTrack) export.Track {
+	artists := make([]string, 0, len(t.Artists))
+	for _, a := range t.Artists {
+		artists = append(artists, a.Name)
+	}
+
+	return export.Track{
+		Artist:     strings.Join(artists, ", "),
+		Title:      t.Name,
+		Album:      t.Album.Name,
+		ISRC:       t.ExternalIDs["isrc"],
+		URI:        string(t.URI),
+		DurationMS: t.Duration,
+	}
+}
+
+// withPost sets the post fields of an exported track from a history entry.
+func withPost(t *export.Track, e history.Entry) {
+	t.Subreddit = e.Subreddit
+	t.Score = e.Score
+	t.Permalink = e.Permalink
+
+	if strings.HasPrefix(t.Permalink, "/") {
+		t.Permalink = "https://www.reddit.com" + t.Permalink
+	}
+}
@@ -0,0 +1,75 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func TestExport(t *testing.T) {
+	track := spotifytest.NewTrack("track1", "Around the World", "Daft Punk")
+	track.Album.Name = "Homework"
+	track.ExternalIDs = map[string]string{"isrc": "GBDUW9700011"}
+
+	fake := spotifytest.New("tester")
+	fake.AddTrack(track)
+	playlistID := fake.AddPlaylist("tester", "music")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"music": playlistID}
+	c.MapSubreddits([]config.Playlist{{Name: "music", Subreddits: []string{"music"}}})
+
+	c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World", PostID: "abc", Permalink: "/r/Music/comments/abc/", Score: 42})
+
+	t.Run("should export playlist", func(t *testing.T) {
+		tracks, err := c.ExportPlaylist("music")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(tracks) != 1 {
+			t.Fatalf("unexpected tracks: %+v", tracks)
+		}
+
+		got := tracks[0]
+		if got.Artist != "Daft Punk" || got.Album != "Homework" || got.ISRC != "GBDUW9700011" || got.URI != "spotify:track:track1" {
+			t.Errorf("unexpected track: %+v", got)
+		}
+
+		if got.Permalink != "https://www.reddit.com/r/Music/comments/abc/" || got.Subreddit != "music" || got.Score != 42 || got.AddedAt.IsZero() {
+			t.Errorf("unexpected post: %+v", got)
+		}
+	})
+
+	t.Run("should export history", func(t *testing.T) {
+		if err := c.History.Add(history.Entry{Title: "Unknown - Track", TrackID: "unknown", Status: history.StatusRejected}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		tracks, err := c.ExportHistory()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(tracks) != 3 {
+			t.Fatalf("unexpected tracks: %+v", tracks)
+		}
+
+		if got := tracks[1]; got.Status != history.StatusAdded || got.Playlist != "music" || got.ISRC != "GBDUW9700011" {
+			t.Errorf("unexpected track: %+v", got)
+		}
+
+		if got := tracks[2]; got.Title != "Unknown - Track" || got.URI != "spotify:track:unknown" {
+			t.Errorf("unexpected track: %+v", got)
+		}
+	})
+
+	t.Run("should fail on unknown playlist", func(t *testing.T) {
+		if _, err := c.ExportPlaylist("unknown"); err == nil {
+			t.Errorf("expected error for unknown playlist")
+		}
+	})
+}
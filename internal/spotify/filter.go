@@ -0,0 +1,100 @@
+package spotify
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/zmb3/spotify"
+)
+
+// rejectReason returns why a track doesn't pass the filters of a playlist,
+// or an empty string if it does. Audio features are only fetched, through
+// features, if there are audio feature filters.
+func rejectReason(f config.Filters, t spotify.FullTrack, features func() (*spotify.AudioFeatures, error)) (string, error) {
+	if !f.Year.IsZero() {
+		if len(t.Album.ReleaseDate) < 4 {
+			return "release year unknown", nil
+		}
+
+		year, err := strconv.Atoi(t.Album.ReleaseDate[:4])
+		if err != nil {
+			return "release year unknown", nil
+		}
+
+		if !f.Year.Contains(float64(year)) {
+			return fmt.Sprintf("release year %d outside %s", year, f.Year), nil
+		}
+	}
+
+	if f.Explicit != nil && !*f.Explicit && t.Explicit {
+		return "explicit", nil
+	}
+
+	if !f.Popularity.Contains(float64(t.Popularity)) {
+		return fmt.Sprintf("popularity %d outside %s", t.Popularity, f.Popularity), nil
+	}
+
+	if seconds := t.Duration / 1000; !f.Duration.Contains(float64(seconds)) {
+		return fmt.Sprintf("duration %ds outside %s", seconds, f.Duration), nil
+	}
+
+	if !f.NeedsAudioFeatures() {
+		return "", nil
+	}
+
+	af, err := features()
+	if err != nil {
+		return "", err
+	}
+
+	if af == nil {
+		return "no audio features", nil
+	}
+
+	checks := []struct {
+		name  string
+		r     config.Range
+		value float32
+	}{
+		{"tempo", f.Tempo, af.Tempo},
+		{"energy", f.Energy, af.Energy},
+		{"danceability", f.Danceability, af.Danceability},
+		{"valence", f.Valence, af.Valence},
+		{"instrumentalness", f.Instrumentalness, af.Instrumentalness},
+	}
+
+	for _, c := range checks {
+		if !c.r.Contains(float64(c.value)) {
+			return fmt.Sprintf("%s %.2f outside %s", c.name, c.value, c.r), nil
+		}
+	}
+
+	return "", nil
+}
+
+// audioFeatures returns a function fetching the audio features of a track
+// once, for checking it against the filters of several playlists.
+func (c *Client) audioFeatures(trackID spotify.ID) func() (*spotify.AudioFeatures, error) {
+	var fetched bool
+	var af *spotify.AudioFeatures
+
+	return func() (*spotify.AudioFeatures, error) {
+		if fetched {
+			return af, nil
+		}
+
+		res, err := c.Spotify.GetAudioFeatures(trackID)
+		if err != nil {
+			return nil, fmt.Errorf("getting audio features: %s, %w", trackID, err)
+		}
+
+		fetched = true
+
+		if len(res) > 0 {
+			af = res[0]
+		}
+
+		return af, nil
+	}
+}
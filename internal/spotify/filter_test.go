@@ -0,0 +1,96 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func TestRejectReason(t *testing.T) {
+	track := spotifytest.NewTrack("track1", "Around the World", "Daft Punk")
+	track.Album.ReleaseDate = "1997-01-20"
+	track.Explicit = true
+	track.Popularity = 70
+	track.Duration = 429000
+
+	features := &spotify.AudioFeatures{ID: "track1", Tempo: 121, Energy: 0.8, Danceability: 0.9, Valence: 0.7}
+	notExplicit := false
+
+	tests := []struct {
+		n   string
+		f   config.Filters
+		exp string
+	}{
+		{"should pass without filters", config.Filters{}, ""},
+		{"should pass matching filters", config.Filters{Year: config.Range{Min: 1990, Max: 1999}, Tempo: config.Range{Min: 120, Max: 130}}, ""},
+		{"should reject release year", config.Filters{Year: config.Range{Min: 2000}}, "release year 1997 outside 2000-"},
+		{"should reject explicit", config.Filters{Explicit: &notExplicit}, "explicit"},
+		{"should reject popularity", config.Filters{Popularity: config.Range{Max: 50}}, "popularity 70 outside -50"},
+		{"should reject duration", config.Filters{Duration: config.Range{Min: 60, Max: 300}}, "duration 429s outside 60-300"},
+		{"should reject energy", config.Filters{Energy: config.Range{Max: 0.4}}, "energy 0.80 outside -0.4"},
+		{"should reject instrumentalness", config.Filters{Instrumentalness: config.Range{Min: 0.5}}, "instrumentalness 0.00 outside 0.5-"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			reason, err := rejectReason(tc.f, track, func() (*spotify.AudioFeatures, error) { return features, nil })
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if reason != tc.exp {
+				t.Errorf("unexpected reason: got %q, exp %q", reason, tc.exp)
+			}
+		})
+	}
+
+	t.Run("should not fetch audio features without audio feature filters", func(t *testing.T) {
+		fetch := func() (*spotify.AudioFeatures, error) {
+			t.Errorf("unexpected audio features fetch")
+			return nil, nil
+		}
+
+		if _, err := rejectReason(config.Filters{Year: config.Range{Min: 1990}}, track, fetch); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+}
+
+func TestAddToPlaylistsFilters(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(spotifytest.NewTrack("track1", "Around the World", "Daft Punk"))
+	fake.SetAudioFeatures(spotify.AudioFeatures{ID: "track1", Energy: 0.8})
+	chillID := fake.AddPlaylist("tester", "chill")
+	energyID := fake.AddPlaylist("tester", "energy")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"chill": chillID, "energy": energyID}
+	c.MapSubreddits([]config.Playlist{
+		{Name: "chill", Subreddits: []string{"music"}, Filters: config.Filters{Energy: config.Range{Max: 0.5}}},
+		{Name: "energy", Subreddits: []string{"music"}, Filters: config.Filters{Energy: config.Range{Min: 0.7}}},
+	})
+
+	c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World"})
+
+	if ids := fake.PlaylistTrackIDs(chillID); len(ids) != 0 {
+		t.Errorf("unexpected tracks in rejecting playlist: %v", ids)
+	}
+
+	if ids := fake.PlaylistTrackIDs(energyID); len(ids) != 1 {
+		t.Errorf("unexpected tracks in accepting playlist: %v", ids)
+	}
+
+	rejected := c.History.Entries(func(e history.Entry) bool { return e.Status == history.StatusRejected })
+	if len(rejected) != 1 || rejected[0].Playlist != string(chillID) || rejected[0].Reason != "energy 0.80 outside -0.5" {
+		t.Errorf("unexpected rejected history: %+v", rejected)
+	}
+
+	entries := c.RecentActivity(2)
+	if len(entries) != 2 || entries[1].Status != activity.StatusRejected {
+		t.Errorf("unexpected activity: %+v", entries)
+	}
+}
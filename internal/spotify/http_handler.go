@@ -11,7 +11,8 @@ func (c *Client) AuthHandler() http.HandlerFunc {
 			return
 		}
 
-		c.Spotify = c.Auth.NewClient(token)
+		client := c.Auth.NewClient(token)
+		c.Spotify = NewAPI(&client, http.DefaultClient)
 		c.AuthChan <- true
 		w.Write([]byte("All good - you can close this window now"))
 	}
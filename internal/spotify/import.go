@@ -0,0 +1,80 @@
+package spotify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/importer"
+	"github.com/zmb3/spotify"
+)
+
+// Import finds the tracks of rows with the same matcher as posts, and
+// adds them to the playlist with key unless its suppression, lists,
+// filters or dedupe keep them out. It returns the result of each row.
+func (c *Client) Import(key string, rows []importer.Row) ([]importer.Result, error) {
+	playlistID, err := c.playlistKeyID(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if playlistID == "" {
+		return nil, fmt.Errorf("missing playlist")
+	}
+
+	defer c.saveCache()
+
+	results := make([]importer.Result, 0, len(rows))
+
+	for _, row := range rows {
+		m := Music{PostTitle: rowTitle(row), ISRC: row.ISRC, UPC: row.UPC, Artist: row.Artist, Title: row.Title, Album: row.Album}
+
+		found, err := c.findRowTrack(row, m)
+		if err != nil {
+			c.Logger.Infof("\timporting line %d: %s", row.Line, err)
+
+			e := activity.Entry{Title: m.PostTitle, Status: activity.StatusUnmatched, Message: err.Error()}
+			c.Activity.Add(e)
+
+			results = append(results, importer.Result{Row: row, Status: e.Status, Message: e.Message})
+
+			continue
+		}
+
+		e := c.addToPlaylistIDs(m, found, []spotify.ID{playlistID})[0]
+		results = append(results, importer.Result{Row: row, Status: e.Status, Message: e.Message, TrackID: e.TrackID})
+	}
+
+	return results, nil
+}
+
+// findRowTrack finds the track of a row by its Spotify URL or URI, and
+// then like a post, moving on when one isn't found.
+func (c *Client) findRowTrack(row importer.Row, m Music) (match, error) {
+	if row.URL != "" {
+		trackID, err := parseTrackID(row.URL)
+		if err == nil {
+			var track *spotify.FullTrack
+			if track, err = c.Spotify.GetTrack(trackID); err == nil {
+				track, err = c.playable(*track)
+			}
+
+			if err == nil {
+				return match{Track: *track, Strategy: StrategyURL}, nil
+			}
+		}
+
+		c.Logger.Infof("\ttrack by url: %s", err)
+	}
+
+	return c.findTrack(m)
+}
+
+// rowTitle returns the row as a post title, e.g. "Artist - Title".
+func rowTitle(row importer.Row) string {
+	if row.Artist == "" || row.Title == "" {
+		return strings.TrimSpace(row.Artist + row.Title)
+	}
+
+	return fmt.Sprintf("%s - %s", row.Artist, row.Title)
+}
@@ -0,0 +1,82 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/importer"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func TestImport(t *testing.T) {
+	fake := spotifytest.New("tester")
+
+	isrcTrack := spotifytest.NewTrack("track2", "Windowlicker", "Aphex Twin")
+	isrcTrack.ExternalIDs = map[string]string{"isrc": "GBBPW9900011"}
+
+	fake.AddTrack(
+		spotifytest.NewTrack("track1", "Around the World", "Daft Punk"),
+		isrcTrack,
+		spotifytest.NewTrack("track3", "Teardrop", "Massive Attack"),
+	)
+	playlistID := fake.AddPlaylist("tester", "music", "track3")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"music": playlistID}
+	c.MapSubreddits([]config.Playlist{{Name: "music", Subreddits: []string{"music"}}})
+
+	rows := []importer.Row{
+		{Artist: "Daft Punk", Title: "Around the World", Line: 2},
+		{ISRC: "GBBPW9900011", Line: 3},
+		{URL: "https://open.spotify.com/track/track3", Line: 4},
+		{Artist: "Nobody", Title: "Nothing", Line: 5},
+	}
+
+	results, err := c.Import("music", rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		n       string
+		status  string
+		trackID string
+	}{
+		{n: "should add track by artist and title", status: activity.StatusAdded, trackID: "track1"},
+		{n: "should add track by isrc", status: activity.StatusAdded, trackID: "track2"},
+		{n: "should fail track by url already in playlist", status: activity.StatusFailed, trackID: "track3"},
+		{n: "should not match unknown track", status: activity.StatusUnmatched},
+	}
+
+	if len(results) != len(tests) {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	for i, test := range tests {
+		t.Run(test.n, func(t *testing.T) {
+			r := results[i]
+
+			if r.Status != test.status {
+				t.Errorf("unexpected value: got %s, exp %s", r.Status, test.status)
+			}
+
+			if r.TrackID != test.trackID {
+				t.Errorf("unexpected value: got %s, exp %s", r.TrackID, test.trackID)
+			}
+
+			if r.Row.Line != rows[i].Line {
+				t.Errorf("unexpected value: got %d, exp %d", r.Row.Line, rows[i].Line)
+			}
+		})
+	}
+
+	if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 3 {
+		t.Errorf("unexpected playlist tracks: %v", ids)
+	}
+
+	if _, err := c.Import("missing", rows); err == nil {
+		t.Errorf("expected error for missing playlist")
+	}
+}
@@ -0,0 +1,229 @@
+package spotify
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/zmb3/spotify"
+)
+
+// lists are compiled block or allow lists. Artist names are kept
+// lowercased next to the artist IDs.
+type lists struct {
+	artists map[string]bool
+	tracks  map[spotify.ID]bool
+	titles  []*regexp.Regexp
+}
+
+// compileLists compiles config lists. The title patterns are validated
+// when the config is loaded, so invalid ones are skipped here.
+func compileLists(l config.Lists) lists {
+	cl := lists{
+		artists: make(map[string]bool, len(l.Artists)),
+		tracks:  make(map[spotify.ID]bool, len(l.Tracks)),
+	}
+
+	for _, a := range l.Artists {
+		cl.artists[strings.ToLower(a)] = true
+	}
+
+	for _, t := range l.Tracks {
+		cl.tracks[spotify.ID(t)] = true
+	}
+
+	for _, t := range l.Titles {
+		if re, err := regexp.Compile(t); err == nil {
+			cl.titles = append(cl.titles, re)
+		}
+	}
+
+	return cl
+}
+
+func (l lists) isEmpty() bool {
+	return len(l.artists) == 0 && len(l.tracks) == 0 && len(l.titles) == 0
+}
+
+// match returns what in the lists matches a post and the track found for
+// it, or an empty string if nothing does.
+func (l lists) match(m Music, t spotify.FullTrack) string {
+	if l.tracks[t.ID] {
+		return fmt.Sprintf("track %s", t.ID)
+	}
+
+	for _, a := range t.Artists {
+		if l.artists[strings.ToLower(string(a.ID))] || l.artists[strings.ToLower(a.Name)] {
+			return fmt.Sprintf("artist %s", a.Name)
+		}
+	}
+
+	titles := []string{m.PostTitle}
+	if len(t.Artists) > 0 {
+		titles = append(titles, fmt.Sprintf("%s - %s", t.Artists[0].Name, t.Name))
+	}
+
+	for _, re := range l.titles {
+		for _, title := range titles {
+			if re.MatchString(title) {
+				return fmt.Sprintf("title %s", re)
+			}
+		}
+	}
+
+	return ""
+}
+
+// listsReason returns why the global or playlist block and allow lists
+// keep a track out of a playlist, or an empty string if they don't.
+func (c *Client) listsReason(playlistID spotify.ID, m Music, t spotify.FullTrack) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, block := range []lists{c.block, c.playlistBlock[playlistID]} {
+		if what := block.match(m, t); what != "" {
+			return "blocked " + what
+		}
+	}
+
+	for _, allow := range []lists{c.allow, c.playlistAllow[playlistID]} {
+		if !allow.isEmpty() && allow.match(m, t) == "" {
+			return "not in allow list"
+		}
+	}
+
+	return ""
+}
+
+// SetLists sets the global block and allow lists.
+func (c *Client) SetLists(block config.Lists, allow config.Lists) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.block = compileLists(block)
+	c.allow = compileLists(allow)
+}
+
+// TrackArtist returns the ID and name of the first artist of a track in
+// one of the playlists. The track is given by ID, URL or URI.
+func (c *Client) TrackArtist(track string) (string, string, error) {
+	trackID, err := parseTrackID(track)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, playlistID := range c.playlistIDs() {
+		tracks, err := c.playlistTracks(playlistID)
+		if err != nil {
+			return "", "", err
+		}
+
+		for _, t := range tracks {
+			if t.Track.ID == trackID && len(t.Track.Artists) > 0 {
+				a := t.Track.Artists[0]
+				return string(a.ID), a.Name, nil
+			}
+		}
+	}
+
+	return "", "", fmt.Errorf("track %s not found in any playlist", trackID)
+}
+
+// parseTrackID returns the track ID from a track ID, URL or URI.
+func parseTrackID(s string) (spotify.ID, error) {
+	if strings.HasPrefix(s, "spotify:") {
+		parts := strings.Split(s, ":")
+		if len(parts) != 3 || parts[1] != "track" {
+			return "", fmt.Errorf("not a track uri: %s", s)
+		}
+
+		return spotify.ID(parts[2]), nil
+	}
+
+	if !strings.Contains(s, "/") {
+		return spotify.ID(s), nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("parse url: %w", err)
+	}
+
+	parts := strings.Split(u.Path, "/")
+	if u.Host != "open.spotify.com" || len(parts) != 3 || parts[1] != "track" {
+		return "", fmt.Errorf("not a spotify track url: %s", s)
+	}
+
+	return spotify.ID(parts[2]), nil
+}
+
+// RemoveArtistTracks removes the tracks by an artist from all playlists,
+// and returns the number of tracks removed.
+func (c *Client) RemoveArtistTracks(artistID string) (int, error) {
+	var removed int
+
+	for _, playlistID := range c.playlistIDs() {
+		tracks, err := c.playlistTracks(playlistID)
+		if err != nil {
+			return removed, err
+		}
+
+		var trackIDs []spotify.ID
+
+		for _, t := range tracks {
+			for _, a := range t.Track.Artists {
+				if a.ID == spotify.ID(artistID) {
+					trackIDs = append(trackIDs, t.Track.ID)
+					break
+				}
+			}
+		}
+
+		if len(trackIDs) == 0 {
+			continue
+		}
+
+		snapshotID, err := c.Spotify.RemoveTracksFromPlaylist(playlistID, trackIDs...)
+		if err != nil {
+			return removed, fmt.Errorf("removing tracks: playlist %s: %w", playlistID, err)
+		}
+
+		for _, id := range trackIDs {
+			err := c.History.Add(history.Entry{
+				Playlist:   string(playlistID),
+				TrackID:    string(id),
+				Status:     history.StatusRemoved,
+				Reason:     fmt.Sprintf("blocked artist %s", artistID),
+				SnapshotID: snapshotID,
+			})
+			if err != nil {
+				c.Logger.Errorf("adding history: %s", err)
+			}
+		}
+
+		c.Logger.Infof("removed %d tracks by artist %s from playlist %s", len(trackIDs), artistID, playlistID)
+
+		removed += len(trackIDs)
+	}
+
+	return removed, nil
+}
+
+// playlistIDs returns the IDs of the playlists, sorted for a stable order.
+func (c *Client) playlistIDs() []spotify.ID {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]spotify.ID, 0, len(c.PlaylistIDs))
+	for _, id := range c.PlaylistIDs {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
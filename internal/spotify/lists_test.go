@@ -0,0 +1,187 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/cache"
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func TestListsMatch(t *testing.T) {
+	track := spotifytest.NewTrack("track1", "Around the World", "Daft Punk")
+	m := Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World [Official Video]"}
+
+	tests := []struct {
+		name  string
+		lists config.Lists
+		exp   string
+	}{
+		{"empty", config.Lists{}, ""},
+		{"track id", config.Lists{Tracks: []string{"track1"}}, "track track1"},
+		{"artist id", config.Lists{Artists: []string{"daftpunk"}}, "artist Daft Punk"},
+		{"artist name", config.Lists{Artists: []string{"daft PUNK"}}, "artist Daft Punk"},
+		{"post title", config.Lists{Titles: []string{`(?i)official video`}}, "title (?i)official video"},
+		{"track title", config.Lists{Titles: []string{`^Daft Punk - Around`}}, "title ^Daft Punk - Around"},
+		{"no match", config.Lists{Artists: []string{"Aphex Twin"}, Titles: []string{"live"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compileLists(tt.lists).match(m, track); got != tt.exp {
+				t.Errorf("unexpected value: got %s, exp %s", got, tt.exp)
+			}
+		})
+	}
+}
+
+func TestAddToPlaylistsLists(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(spotifytest.NewTrack("track1", "Around the World", "Daft Punk"))
+	openID := fake.AddPlaylist("tester", "open")
+	blockingID := fake.AddPlaylist("tester", "blocking")
+	allowingID := fake.AddPlaylist("tester", "allowing")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"open": openID, "blocking": blockingID, "allowing": allowingID}
+	c.MapSubreddits([]config.Playlist{
+		{Name: "open", Subreddits: []string{"music"}},
+		{Name: "blocking", Subreddits: []string{"music"}, Block: config.Lists{Artists: []string{"Daft Punk"}}},
+		{Name: "allowing", Subreddits: []string{"music"}, Allow: config.Lists{Artists: []string{"Aphex Twin"}}},
+	})
+
+	c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World"})
+
+	if ids := fake.PlaylistTrackIDs(openID); len(ids) != 1 {
+		t.Errorf("unexpected tracks in open playlist: %v", ids)
+	}
+
+	if ids := fake.PlaylistTrackIDs(blockingID); len(ids) != 0 {
+		t.Errorf("unexpected tracks in blocking playlist: %v", ids)
+	}
+
+	if ids := fake.PlaylistTrackIDs(allowingID); len(ids) != 0 {
+		t.Errorf("unexpected tracks in allowing playlist: %v", ids)
+	}
+
+	entries := c.RecentActivity(3)
+	if len(entries) != 3 || entries[1].Message != "blocked artist Daft Punk" || entries[0].Message != "not in allow list" {
+		t.Errorf("unexpected activity: %+v", entries)
+	}
+
+	t.Run("global block list", func(t *testing.T) {
+		c.SetLists(config.Lists{Tracks: []string{"track1"}}, config.Lists{})
+		c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World (again)"})
+
+		if ids := fake.PlaylistTrackIDs(openID); len(ids) != 1 {
+			t.Errorf("unexpected tracks in open playlist: %v", ids)
+		}
+	})
+}
+
+func TestParseTrackID(t *testing.T) {
+	tests := []struct {
+		n   string
+		exp spotify.ID
+		err bool
+	}{
+		{"4uLU6hMCjMI75M1A2tKUQC", "4uLU6hMCjMI75M1A2tKUQC", false},
+		{"spotify:track:4uLU6hMCjMI75M1A2tKUQC", "4uLU6hMCjMI75M1A2tKUQC", false},
+		{"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=abc", "4uLU6hMCjMI75M1A2tKUQC", false},
+		{"spotify:album:4uLU6hMCjMI75M1A2tKUQC", "", true},
+		{"https://open.spotify.com/album/4uLU6hMCjMI75M1A2tKUQC", "", true},
+		{"https://example.com/track/4uLU6hMCjMI75M1A2tKUQC", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTrackID(tt.n)
+		if (err != nil) != tt.err {
+			t.Errorf("unexpected error for %s: %v", tt.n, err)
+		}
+
+		if got != tt.exp {
+			t.Errorf("unexpected value: got %s, exp %s", got, tt.exp)
+		}
+	}
+}
+
+func TestRemoveArtistTracks(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(
+		spotifytest.NewTrack("track1", "Around the World", "Daft Punk"),
+		spotifytest.NewTrack("track2", "Windowlicker", "Aphex Twin"),
+		spotifytest.NewTrack("track3", "One More Time", "Daft Punk"),
+	)
+	oneID := fake.AddPlaylist("tester", "one", "track1", "track2")
+	twoID := fake.AddPlaylist("tester", "two", "track3")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"one": oneID, "two": twoID}
+
+	id, name, err := c.TrackArtist("spotify:track:track3")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if id != "daftpunk" || name != "Daft Punk" {
+		t.Errorf("unexpected artist: got %s (%s)", name, id)
+	}
+
+	if _, _, err := c.TrackArtist("track4"); err == nil {
+		t.Errorf("expected error for track not in any playlist")
+	}
+
+	removed, err := c.RemoveArtistTracks(id)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if removed != 2 {
+		t.Errorf("unexpected value: got %d, exp %d", removed, 2)
+	}
+
+	if ids := fake.PlaylistTrackIDs(oneID); len(ids) != 1 || ids[0] != "track2" {
+		t.Errorf("unexpected tracks in playlist one: %v", ids)
+	}
+
+	if ids := fake.PlaylistTrackIDs(twoID); len(ids) != 0 {
+		t.Errorf("unexpected tracks in playlist two: %v", ids)
+	}
+}
+
+func TestAddToPlaylistsListsCached(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(spotifytest.NewTrack("track1", "Around the World", "Daft Punk"))
+	musicID := fake.AddPlaylist("tester", "music")
+	houseID := fake.AddPlaylist("tester", "house")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"music": musicID, "house": houseID}
+	c.MapSubreddits([]config.Playlist{
+		{Name: "music", Subreddits: []string{"music"}},
+		{Name: "house", Subreddits: []string{"house"}, Block: config.Lists{Artists: []string{"daftpunk"}}},
+	})
+
+	searchCache, err := cache.Load("", time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("error setting up cache: %s", err)
+	}
+	c.Cache = searchCache
+
+	c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World"})
+	c.handle(Music{Subreddit: "house", PostTitle: "Daft Punk - Around the World"})
+
+	if stats := c.CacheStats(); stats.Hits == 0 {
+		t.Fatalf("expected cache hit: %+v", stats)
+	}
+
+	if ids := fake.PlaylistTrackIDs(houseID); len(ids) != 0 {
+		t.Errorf("unexpected tracks in blocking playlist: %v", ids)
+	}
+
+	if e := c.RecentActivity(1); len(e) != 1 || e[0].Message != "blocked artist Daft Punk" {
+		t.Errorf("unexpected activity: %+v", e)
+	}
+}
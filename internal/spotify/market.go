@@ -0,0 +1,53 @@
+package spotify
+
+import (
+	"fmt"
+
+	"github.com/zmb3/spotify"
+)
+
+// available reports whether a track can be played in the market. Tracks
+// without available markets, like search results for a market, are
+// available.
+func available(t spotify.FullTrack, market string) bool {
+	if market == "" || len(t.AvailableMarkets) == 0 {
+		return true
+	}
+
+	for _, m := range t.AvailableMarkets {
+		if m == market {
+			return true
+		}
+	}
+
+	return false
+}
+
+// playable returns the track if it's available in the market, otherwise
+// a relinked version of it that is. The client library can't pass a market
+// to GetTrack, so relinked versions are found by searching for the ISRC in
+// the market instead.
+func (c *Client) playable(t spotify.FullTrack) (*spotify.FullTrack, error) {
+	if available(t, c.market) {
+		return &t, nil
+	}
+
+	isrc := t.ExternalIDs["isrc"]
+	if isrc == "" {
+		return nil, fmt.Errorf("not available in market %s", c.market)
+	}
+
+	tracks, err := c.search("isrc:" + isrc)
+	if err != nil {
+		return nil, fmt.Errorf("searching for relinked track: %w", err)
+	}
+
+	for i, r := range tracks {
+		if r.ID != t.ID && available(r, c.market) {
+			c.Logger.Infof("\trelinked track %s to %s for market %s", t.ID, r.ID, c.market)
+			return &tracks[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("not available in market %s and no relinked track found", c.market)
+}
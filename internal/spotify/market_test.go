@@ -0,0 +1,109 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/engvik/dissic/internal/title"
+	"github.com/zmb3/spotify"
+)
+
+func TestSetUserMarket(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.SetCountry("NO")
+
+	t.Run("should use the user's country", func(t *testing.T) {
+		c := newTestClient(t, fake)
+
+		if c.market != "NO" {
+			t.Errorf("unexpected market: got %s, exp %s", c.market, "NO")
+		}
+	})
+
+	t.Run("should prefer configured market", func(t *testing.T) {
+		c := newTestClient(t, fake)
+		c.market = "SE"
+
+		if err := c.SetUser(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if c.market != "SE" {
+			t.Errorf("unexpected market: got %s, exp %s", c.market, "SE")
+		}
+	})
+}
+
+func TestFindTrackMarket(t *testing.T) {
+	swedish := spotifytest.NewTrack("swedish", "Dancing On My Own", "Robyn")
+	swedish.AvailableMarkets = []string{"SE"}
+	swedish.ExternalIDs = map[string]string{"isrc": "SE1234567890"}
+
+	norwegian := spotifytest.NewTrack("norwegian", "Dancing On My Own", "Robyn")
+	norwegian.AvailableMarkets = []string{"NO"}
+	norwegian.ExternalIDs = map[string]string{"isrc": "SE1234567890"}
+
+	elsewhere := spotifytest.NewTrack("elsewhere", "Hang With Me", "Robyn")
+	elsewhere.AvailableMarkets = []string{"SE"}
+
+	fake := spotifytest.New("tester")
+	fake.SetCountry("NO")
+	fake.AddTrack(swedish, norwegian, elsewhere)
+
+	c := newTestClient(t, fake)
+
+	tests := []struct {
+		n     string
+		m     Music
+		expID string
+	}{
+		{
+			"should relink track linked to in another market",
+			Music{PostTitle: "Robyn", URL: "https://open.spotify.com/track/swedish"},
+			"norwegian",
+		},
+		{
+			"should skip unavailable track without relinked version",
+			Music{PostTitle: "Robyn - Hang With Me", URL: "https://open.spotify.com/track/elsewhere"},
+			"",
+		},
+		{
+			"should search in market",
+			Music{PostTitle: "Robyn - Dancing On My Own"},
+			"norwegian",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			found, err := c.findTrack(tc.m)
+			if tc.expID == "" {
+				if err == nil {
+					t.Errorf("expected error, got %s", found.Track.ID)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if string(found.Track.ID) != tc.expID {
+				t.Errorf("unexpected track: got %s, exp %s", found.Track.ID, tc.expID)
+			}
+		})
+	}
+
+	t.Run("should skip unavailable search results", func(t *testing.T) {
+		parsed, err := title.Parse("Robyn - Dancing On My Own")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		track, found := c.findMatchFromSearchResult(parsed, []spotify.FullTrack{swedish, norwegian})
+		if !found || track.ID != "norwegian" {
+			t.Errorf("unexpected match: got %s, exp %s", track.ID, "norwegian")
+		}
+	})
+}
@@ -1,19 +1,84 @@
 package spotify
 
+import (
+	"time"
+
+	"github.com/engvik/dissic/internal/cache"
+	"github.com/engvik/dissic/internal/sink"
+)
+
 // Music contains data about potential new music to add to
 // a spotify list.
 type Music struct {
+	// Source is the name of the source the post was found in.
+	Source string
+	// Subreddit is the key the post is routed to playlists by: the
+	// subreddit for reddit posts, or a source key like "rss:bandcamp".
 	Subreddit        string
 	PostTitle        string
 	MediaTitle       string
 	SecureMediaTitle string
 	URL              string
+	PostID           string
+	Permalink        string
+	Score            int
+	Posted           time.Time
+
+	// Structured fields, set when the source knows them. A track is found
+	// by ISRC first, then on the release with the UPC, then by artist,
+	// title and album, before parsing the titles.
+	ISRC     string
+	UPC      string
+	Artist   string
+	Title    string
+	Album    string
+	Duration time.Duration
 }
 
 func (m *Music) titleStringSlice() []string {
 	return []string{m.PostTitle, m.MediaTitle, m.SecureMediaTitle}
 }
 
+// cacheKey returns the key an unmatched post is cached by. The link is
+// part of it, so a post with a new link is tried again.
+func (m *Music) cacheKey() string {
+	parts := m.titleStringSlice()
+	if m.ISRC != "" || m.Artist != "" || m.Title != "" {
+		parts = append(parts, m.ISRC, m.Artist, m.Title, m.Album)
+	}
+
+	if m.UPC != "" {
+		parts = append(parts, m.UPC)
+	}
+
+	if m.URL != "" {
+		parts = append(parts, m.URL)
+	}
+
+	return cache.Key(parts...)
+}
+
+// Query returns what's known about the track a post is about, for the
+// sinks to resolve it by.
+func (m *Music) Query() sink.Query {
+	var titles []string
+	for _, t := range m.titleStringSlice() {
+		if t != "" {
+			titles = append(titles, t)
+		}
+	}
+
+	return sink.Query{
+		Titles:   titles,
+		URL:      m.URL,
+		ISRC:     m.ISRC,
+		Artist:   m.Artist,
+		Title:    m.Title,
+		Album:    m.Album,
+		Duration: m.Duration,
+	}
+}
+
 func (m *Music) isEmpty() bool {
 	if m.Subreddit == "" {
 		return true
@@ -0,0 +1,207 @@
+package spotify
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/zmb3/spotify"
+)
+
+// OrderPlaylist reorders the tracks of the playlist identified by key.
+// With the score order, tracks are ordered by scores, highest first.
+// Only the tracks out of order are moved, those next to each other
+// together. Every move is made against the snapshot returned by the
+// previous one, starting from the snapshot the tracks were read at, so
+// the positions moved never refer to a playlist changed meanwhile.
+func (c *Client) OrderPlaylist(key string, order string, scores map[string]int) error {
+	c.mu.RLock()
+	playlistID, ok := c.PlaylistIDs[key]
+	c.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown playlist: %s", key)
+	}
+
+	playlist, err := c.Spotify.GetPlaylist(playlistID)
+	if err != nil {
+		return fmt.Errorf("getting playlist %s: %w", playlistID, err)
+	}
+
+	tracks, err := c.playlistTracks(playlistID)
+	if err != nil {
+		return err
+	}
+
+	read, err := c.Spotify.GetPlaylist(playlistID)
+	if err != nil {
+		return fmt.Errorf("getting playlist %s: %w", playlistID, err)
+	}
+
+	if read.SnapshotID != playlist.SnapshotID {
+		return fmt.Errorf("playlist %s changed while reading tracks", playlistID)
+	}
+
+	snapshotID := playlist.SnapshotID
+
+	// current holds the position each track should be at, in the order
+	// the tracks are in.
+	current := make([]int, len(tracks))
+	for i, pos := range orderedTracks(tracks, order, scores) {
+		current[pos] = i
+	}
+
+	keep := longestIncreasing(current)
+
+	var moves int
+
+	for want := 0; want < len(current); want++ {
+		if keep[want] {
+			continue
+		}
+
+		start := indexOf(current, want)
+
+		length := 1
+		for start+length < len(current) && current[start+length] == want+length && !keep[want+length] {
+			length++
+		}
+
+		insert := 0
+		if want > 0 {
+			insert = indexOf(current, want-1) + 1
+		}
+
+		want += length - 1
+
+		if insert >= start && insert <= start+length {
+			continue
+		}
+
+		snapshotID, err = c.Spotify.ReorderPlaylistTracks(playlistID, spotify.PlaylistReorderOptions{
+			RangeStart:   start,
+			RangeLength:  length,
+			InsertBefore: insert,
+			SnapshotID:   snapshotID,
+		})
+		if err != nil {
+			return fmt.Errorf("moving tracks: playlist %s, position %d to %d: %w", playlistID, start, insert, err)
+		}
+
+		current = move(current, start, length, insert)
+		moves += length
+	}
+
+	c.Logger.Infof("ordered playlist %s by %s, moved %d tracks", playlistID, order, moves)
+
+	return nil
+}
+
+// longestIncreasing returns the values of a longest increasing
+// subsequence of values, which are the distinct numbers 0 to n-1.
+func longestIncreasing(values []int) map[int]bool {
+	// tails holds the index of the last value of the increasing
+	// subsequences found, by length.
+	var tails []int
+	prev := make([]int, len(values))
+
+	for i, v := range values {
+		n := sort.Search(len(tails), func(k int) bool { return values[tails[k]] >= v })
+
+		prev[i] = -1
+		if n > 0 {
+			prev[i] = tails[n-1]
+		}
+
+		if n == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[n] = i
+		}
+	}
+
+	keep := make(map[int]bool, len(tails))
+
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			keep[values[i]] = true
+		}
+	}
+
+	return keep
+}
+
+// move returns values with length values from start moved before insert,
+// like Spotify reorders playlist tracks.
+func move(values []int, start int, length int, insert int) []int {
+	moved := append([]int(nil), values[start:start+length]...)
+	rest := append(append([]int(nil), values[:start]...), values[start+length:]...)
+
+	if insert > start {
+		insert -= length
+	}
+
+	return append(append(append([]int(nil), rest[:insert]...), moved...), rest[insert:]...)
+}
+
+func indexOf(values []int, v int) int {
+	for i := range values {
+		if values[i] == v {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// orderedTracks returns the positions of the tracks in the given order.
+func orderedTracks(tracks []spotify.PlaylistTrack, order string, scores map[string]int) []int {
+	positions := make([]int, len(tracks))
+	for i := range positions {
+		positions[i] = i
+	}
+
+	var less func(a, b spotify.PlaylistTrack) bool
+
+	switch order {
+	case config.OrderNewest:
+		less = func(a, b spotify.PlaylistTrack) bool { return a.AddedAt > b.AddedAt }
+	case config.OrderScore:
+		less = func(a, b spotify.PlaylistTrack) bool { return scores[string(a.Track.ID)] > scores[string(b.Track.ID)] }
+	case config.OrderReleaseDate:
+		less = func(a, b spotify.PlaylistTrack) bool { return a.Track.Album.ReleaseDate > b.Track.Album.ReleaseDate }
+	case config.OrderShuffle:
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		r.Shuffle(len(positions), func(i, j int) { positions[i], positions[j] = positions[j], positions[i] })
+		return positions
+	default:
+		less = func(a, b spotify.PlaylistTrack) bool { return a.AddedAt < b.AddedAt }
+	}
+
+	sort.SliceStable(positions, func(i, j int) bool {
+		return less(tracks[positions[i]], tracks[positions[j]])
+	})
+
+	return positions
+}
+
+// moveFirst moves the last track of a playlist, just added at position,
+// to the top.
+func (c *Client) moveFirst(playlistID spotify.ID, position int, snapshotID string) (string, error) {
+	if position == 0 {
+		return snapshotID, nil
+	}
+
+	snapshotID, err := c.Spotify.ReorderPlaylistTracks(playlistID, spotify.PlaylistReorderOptions{
+		RangeStart:   position,
+		InsertBefore: 0,
+		SnapshotID:   snapshotID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("moving track first: playlist %s: %w", playlistID, err)
+	}
+
+	return snapshotID, nil
+}
@@ -0,0 +1,224 @@
+package spotify
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+// editingFake is a fake where the playlist is edited by someone else right
+// before the first reorder.
+type editingFake struct {
+	*spotifytest.Fake
+	edited bool
+}
+
+func (f *editingFake) ReorderPlaylistTracks(playlistID spotify.ID, opt spotify.PlaylistReorderOptions) (string, error) {
+	if !f.edited {
+		f.edited = true
+		f.AddTracksToPlaylist(playlistID, "track1")
+	}
+
+	return f.Fake.ReorderPlaylistTracks(playlistID, opt)
+}
+
+// countingFake is a fake counting the reorders.
+type countingFake struct {
+	*spotifytest.Fake
+	reorders int
+}
+
+func (f *countingFake) ReorderPlaylistTracks(playlistID spotify.ID, opt spotify.PlaylistReorderOptions) (string, error) {
+	f.reorders++
+
+	return f.Fake.ReorderPlaylistTracks(playlistID, opt)
+}
+
+func newOrderTestFake() *spotifytest.Fake {
+	fake := spotifytest.New("tester")
+
+	for _, d := range []struct{ id, date string }{
+		{"track1", "1997-01-20"},
+		{"track2", "2019-06-01"},
+		{"track3", "2005"},
+		{"track4", "2012-03-05"},
+	} {
+		track := spotifytest.NewTrack(d.id, d.id, "Artist")
+		track.Album.ReleaseDate = d.date
+		fake.AddTrack(track)
+	}
+
+	return fake
+}
+
+func TestOrderPlaylist(t *testing.T) {
+	tests := []struct {
+		n      string
+		scores map[string]int
+		exp    []spotify.ID
+	}{
+		{config.OrderReleaseDate, nil, []spotify.ID{"track2", "track4", "track3", "track1"}},
+		{config.OrderScore, map[string]int{"track3": 40, "track4": 90, "track2": 40}, []spotify.ID{"track4", "track2", "track3", "track1"}},
+		{config.OrderNewest, nil, []spotify.ID{"track4", "track3", "track2", "track1"}},
+		{config.OrderAdded, nil, []spotify.ID{"track1", "track2", "track3", "track4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.n, func(t *testing.T) {
+			fake := newOrderTestFake()
+			playlistID := fake.AddPlaylist("tester", "one", "track1", "track2", "track3", "track4")
+
+			c := newTestClient(t, fake)
+			c.PlaylistIDs = map[string]spotify.ID{"one": playlistID}
+
+			if err := c.OrderPlaylist("one", tt.n, tt.scores); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			got := fake.PlaylistTrackIDs(playlistID)
+			for i := range tt.exp {
+				if got[i] != tt.exp[i] {
+					t.Errorf("unexpected order: got %v, exp %v", got, tt.exp)
+					break
+				}
+			}
+		})
+	}
+
+	t.Run("should shuffle all tracks", func(t *testing.T) {
+		fake := newOrderTestFake()
+		playlistID := fake.AddPlaylist("tester", "one", "track1", "track2", "track3", "track4")
+
+		c := newTestClient(t, fake)
+		c.PlaylistIDs = map[string]spotify.ID{"one": playlistID}
+
+		if err := c.OrderPlaylist("one", config.OrderShuffle, nil); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		got := fake.PlaylistTrackIDs(playlistID)
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+
+		if len(got) != 4 || got[0] != "track1" || got[3] != "track4" {
+			t.Errorf("unexpected tracks: %v", got)
+		}
+	})
+
+	t.Run("should only move the tracks out of order", func(t *testing.T) {
+		fake := &countingFake{Fake: newOrderTestFake()}
+		playlistID := fake.AddPlaylist("tester", "one", "track1", "track2", "track3", "track4")
+
+		c := newTestClient(t, fake)
+		c.PlaylistIDs = map[string]spotify.ID{"one": playlistID}
+
+		scores := map[string]int{"track2": 10, "track3": 10, "track4": 10}
+		if err := c.OrderPlaylist("one", config.OrderScore, scores); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		exp := []spotify.ID{"track2", "track3", "track4", "track1"}
+		if got := fake.PlaylistTrackIDs(playlistID); len(got) != 4 || got[0] != exp[0] || got[3] != exp[3] {
+			t.Errorf("unexpected order: got %v, exp %v", got, exp)
+		}
+
+		if fake.reorders != 1 {
+			t.Errorf("unexpected reorders: got %d, exp %d", fake.reorders, 1)
+		}
+	})
+
+	t.Run("should order many tracks", func(t *testing.T) {
+		fake := spotifytest.New("tester")
+
+		var ids []spotify.ID
+		scores := make(map[string]int)
+
+		for i := 0; i < 250; i++ {
+			track := spotifytest.NewTrack(fmt.Sprintf("track%d", i), fmt.Sprintf("Track %d", i), "Artist")
+			fake.AddTrack(track)
+			ids = append(ids, track.ID)
+			scores[string(track.ID)] = (i * 7919) % 101
+		}
+
+		playlistID := fake.AddPlaylist("tester", "many", ids...)
+
+		c := newTestClient(t, fake)
+		c.PlaylistIDs = map[string]spotify.ID{"many": playlistID}
+
+		if err := c.OrderPlaylist("many", config.OrderScore, scores); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		exp := append([]spotify.ID(nil), ids...)
+		sort.SliceStable(exp, func(i, j int) bool { return scores[string(exp[i])] > scores[string(exp[j])] })
+
+		got := fake.PlaylistTrackIDs(playlistID)
+		for i := range exp {
+			if got[i] != exp[i] {
+				t.Errorf("unexpected value: got %s, exp %s at %d", got[i], exp[i], i)
+				break
+			}
+		}
+	})
+
+	t.Run("should stop when the playlist is changed meanwhile", func(t *testing.T) {
+		fake := newOrderTestFake()
+		playlistID := fake.AddPlaylist("tester", "one", "track1", "track2", "track3", "track4")
+
+		c := newTestClient(t, &editingFake{Fake: fake})
+		c.PlaylistIDs = map[string]spotify.ID{"one": playlistID}
+
+		if err := c.OrderPlaylist("one", config.OrderReleaseDate, nil); err == nil {
+			t.Errorf("expected error")
+		}
+
+		got := fake.PlaylistTrackIDs(playlistID)
+		if len(got) != 5 || got[0] != "track1" || got[4] != "track1" {
+			t.Errorf("unexpected tracks: %v", got)
+		}
+	})
+}
+
+func TestAddToPlaylistNewestFirst(t *testing.T) {
+	fake := newOrderTestFake()
+	playlistID := fake.AddPlaylist("tester", "one", "track1", "track2")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"one": playlistID}
+	c.MapSubreddits([]config.Playlist{{Name: "one", Subreddits: []string{"music"}, Order: config.OrderNewest}})
+
+	if _, err := c.addToPlaylist(playlistID, "track3"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := fake.PlaylistTrackIDs(playlistID)
+	if len(got) != 3 || got[0] != "track3" || got[1] != "track1" {
+		t.Errorf("unexpected tracks: %v", got)
+	}
+}
+
+func TestAddToPlaylistNewestFirstFailing(t *testing.T) {
+	fake := &editingFake{Fake: newOrderTestFake()}
+	playlistID := fake.AddPlaylist("tester", "one", "track1", "track2")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"one": playlistID}
+	c.MapSubreddits([]config.Playlist{{Name: "one", Subreddits: []string{"music"}, Order: config.OrderNewest}})
+
+	snapshotID, err := c.addToPlaylist(playlistID, "track3")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if snapshotID == "" {
+		t.Errorf("expected snapshot id")
+	}
+
+	got := fake.PlaylistTrackIDs(playlistID)
+	if len(got) != 4 || got[2] != "track3" {
+		t.Errorf("unexpected tracks: %v", got)
+	}
+}
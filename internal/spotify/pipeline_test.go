@@ -0,0 +1,83 @@
+package spotify_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/reddit"
+	"github.com/engvik/dissic/internal/spotify"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	log "github.com/sirupsen/logrus"
+	graw "github.com/turnage/graw/reddit"
+	zspotify "github.com/zmb3/spotify"
+)
+
+func TestPipeline(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(spotifytest.NewTrack("track1", "Around the World", "Daft Punk"))
+
+	srv := spotifytest.NewServer(fake)
+	defer srv.Close()
+
+	cfg := config.Config{
+		Playlists: []config.Playlist{{Name: "dissic", Subreddits: []string{"music"}}},
+	}
+
+	s, err := spotify.New(&cfg)
+	if err != nil {
+		t.Fatalf("error setting up spotify client: %s", err)
+	}
+
+	s.Spotify = spotify.NewAPI(srv.Client(), srv.HTTPClient())
+
+	if err := s.SetUser(); err != nil {
+		t.Fatalf("error setting user: %s", err)
+	}
+
+	if err := s.PreparePlaylists(&cfg); err != nil {
+		t.Fatalf("error preparing playlists: %s", err)
+	}
+
+	music := make(chan spotify.Music)
+
+	r := reddit.Client{
+		MusicChan: music,
+		Logger:    log.WithFields(log.Fields{"service": "reddit"}),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for m := range music {
+			s.Handle(m)
+		}
+		close(done)
+	}()
+
+	t.Run("should add track from reddit post to playlist", func(t *testing.T) {
+		if err := r.Post(&graw.Post{Subreddit: "Music", Title: "Daft Punk - Around the World"}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		playlist := fake.PlaylistByName("dissic")
+		if playlist == nil {
+			t.Fatalf("playlist not created")
+		}
+
+		var ids []zspotify.ID
+		for i := 0; i < 50; i++ {
+			if ids = fake.PlaylistTrackIDs(playlist.ID); len(ids) > 0 {
+				break
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if len(ids) != 1 || ids[0] != "track1" {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	close(music)
+	<-done
+}
@@ -1,8 +1,8 @@
 package spotify
 
 import (
+	"bytes"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/engvik/dissic/internal/config"
@@ -14,42 +14,187 @@ const ErrInvalidID = "Invalid playlist Id"
 
 // PreparePlaylists checks the playlists defined in the config and fetches
 // them from Spotify. If a playlist is passed by name, it's created if it
-// doesn't exist. It also connects the subreddits to a corresponding playlist id.
+// doesn't exist. The access and cover of the playlists owned by the user
+// are updated to match the config. It also connects the subreddits to a
+// corresponding playlist id.
 func (c *Client) PreparePlaylists(cfg *config.Config) error {
-	subredditPlaylist := make(map[string]spotify.ID, len(cfg.Reddit.Subreddits))
+	playlistIDs := make(map[string]spotify.ID, len(cfg.Playlists))
 
 	for _, p := range cfg.Playlists {
-		// get playlist
-		playlist, err := c.getPlaylist(p)
+		// playlists on other services are kept by their sinks
+		if !p.IsSpotify() {
+			continue
+		}
+
+		description := p.RenderDescription(time.Now(), 0)
+		if description == "" {
+			description = cfg.PlaylistDescription
+		}
+
+		playlist, err := c.ensurePlaylist(p, description)
 		if err != nil {
-			return fmt.Errorf("unable to get playlist: %w", err)
+			return err
 		}
 
-		// not found, but name provided, create playlist
-		if playlist == nil && p.Name != "" {
-			playlist, err = c.Spotify.CreatePlaylistForUser(c.User.ID, p.Name, cfg.PlaylistDescription, false)
-			if err != nil {
-				return fmt.Errorf("error creating playlist: %w", err)
+		if playlist.Owner.ID == c.User.ID {
+			if err := c.applySettings(playlist, p); err != nil {
+				return err
 			}
+		}
+
+		playlistIDs[p.Key()] = playlist.ID
+
+		// Be nice to the Spotify API
+		time.Sleep(c.playlistDelay)
+	}
+
+	c.mu.Lock()
+	c.PlaylistIDs = playlistIDs
+	c.mu.Unlock()
 
-			c.Logger.Infof("created playlist: %s", p.Name)
+	c.MapSubreddits(cfg.Playlists)
+
+	return nil
+}
+
+// MapSubreddits connects the subreddits to the playlists they feed, and
+// the playlists to their filters, lists, dedupe policies and order. It's
+// called again whenever the subreddits of a playlist change.
+func (c *Client) MapSubreddits(playlists []config.Playlist) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	subredditPlaylist := make(map[string][]spotify.ID)
+	playlistFilters := make(map[spotify.ID]config.Filters)
+	playlistBlock := make(map[spotify.ID]lists)
+	playlistAllow := make(map[spotify.ID]lists)
+	playlistOrder := make(map[spotify.ID]string)
+
+	for _, p := range playlists {
+		playlistID, ok := c.PlaylistIDs[p.Key()]
+		if !ok || !p.IsSpotify() {
+			continue
 		}
 
-		// create subreddit playlist map
-		for _, s := range p.Subreddits {
-			subreddit := strings.ToLower(s)
-			subredditPlaylist[subreddit] = playlist.ID
+		playlistFilters[playlistID] = p.Filters
+		playlistBlock[playlistID] = compileLists(p.Block)
+		playlistAllow[playlistID] = compileLists(p.Allow)
+		playlistOrder[playlistID] = p.Order
+
+		// digests are rebuilt on a schedule, not fed posts
+		if p.IsDigest() {
+			continue
 		}
 
-		// Be nice to the Spotify API
-		time.Sleep(1 * time.Second)
+		for _, key := range p.Sources() {
+			subredditPlaylist[key] = append(subredditPlaylist[key], playlistID)
+		}
 	}
 
 	c.SubredditPlaylist = subredditPlaylist
+	c.PlaylistFilters = playlistFilters
+	c.playlistBlock = playlistBlock
+	c.playlistAllow = playlistAllow
+	c.playlistDedupe = dedupePolicies(playlists, c.PlaylistIDs)
+	c.playlistOrder = playlistOrder
+}
+
+// applySettings makes a playlist public, private or collaborative as
+// configured, leaving the settings not configured as they are, and
+// uploads the cover if the playlist has no image. A failing upload is
+// logged, as it needs a token with the image upload scope.
+func (c *Client) applySettings(playlist *spotify.FullPlaylist, p config.Playlist) error {
+	if p.Collaborative != nil && playlist.Collaborative != *p.Collaborative {
+		if err := c.Spotify.ChangePlaylistCollaborative(playlist.ID, *p.Collaborative); err != nil {
+			return fmt.Errorf("changing collaborative: playlist %s: %w", playlist.ID, err)
+		}
+
+		playlist.IsPublic = playlist.IsPublic && !*p.Collaborative
+		c.Logger.Infof("changed playlist %s to collaborative: %t", playlist.ID, *p.Collaborative)
+	}
+
+	if p.Public != nil && playlist.IsPublic != *p.Public {
+		if err := c.Spotify.ChangePlaylistAccess(playlist.ID, *p.Public); err != nil {
+			return fmt.Errorf("changing access: playlist %s: %w", playlist.ID, err)
+		}
+
+		c.Logger.Infof("changed playlist %s to public: %t", playlist.ID, *p.Public)
+	}
+
+	if p.Cover == "" || len(playlist.Images) > 0 {
+		return nil
+	}
+
+	img, err := coverImage(p)
+	if err != nil {
+		c.Logger.Errorf("error uploading cover: %s", err)
+		return nil
+	}
+
+	if err := c.Spotify.SetPlaylistImage(playlist.ID, bytes.NewReader(img)); err != nil {
+		c.Logger.Errorf("error uploading cover: playlist %s: %s", playlist.ID, err)
+		return nil
+	}
+
+	c.Logger.Infof("uploaded cover for playlist %s", playlist.ID)
+
+	return nil
+}
+
+// RefreshDescription renders the description template of a playlist and
+// updates the description if it changed.
+func (c *Client) RefreshDescription(p config.Playlist, now time.Time) error {
+	c.mu.RLock()
+	playlistID, ok := c.PlaylistIDs[p.Key()]
+	c.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("playlist %s not found", p.Key())
+	}
+
+	playlist, err := c.Spotify.GetPlaylist(playlistID)
+	if err != nil {
+		return fmt.Errorf("getting playlist %s: %w", playlistID, err)
+	}
+
+	description := p.RenderDescription(now, playlist.Tracks.Total)
+	if description == playlist.Description {
+		return nil
+	}
+
+	if err := c.Spotify.ChangePlaylistDescription(playlistID, description); err != nil {
+		return fmt.Errorf("changing description: playlist %s: %w", playlistID, err)
+	}
+
+	c.Logger.Infof("refreshed description of playlist %s", playlistID)
 
 	return nil
 }
 
+// ensurePlaylist gets a playlist, creating it with the description if
+// it's passed by name and doesn't exist.
+func (c *Client) ensurePlaylist(p config.Playlist, description string) (*spotify.FullPlaylist, error) {
+	playlist, err := c.getPlaylist(p)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get playlist: %w", err)
+	}
+
+	if playlist != nil || p.Name == "" {
+		return playlist, nil
+	}
+
+	public := p.Public != nil && *p.Public
+
+	playlist, err = c.Spotify.CreatePlaylistForUser(c.User.ID, p.Name, description, public)
+	if err != nil {
+		return nil, fmt.Errorf("error creating playlist: %w", err)
+	}
+
+	c.Logger.Infof("created playlist: %s", p.Name)
+
+	return playlist, nil
+}
+
 func (c *Client) getPlaylist(p config.Playlist) (*spotify.FullPlaylist, error) {
 	// prefer getting by id
 	if p.ID != "" {
@@ -95,29 +240,66 @@ func (c *Client) getPlaylistByName(name string) (*spotify.FullPlaylist, error) {
 	return playlist, nil
 }
 
-func (c *Client) addToPlaylist(subreddit string, trackID spotify.ID) error {
-	playlistID, ok := c.SubredditPlaylist[subreddit]
-	if !ok {
-		return fmt.Errorf("no playlist found for subreddit: %s", subreddit)
-	}
-
-	playlist, err := c.Spotify.GetPlaylistTracks(playlistID)
+// addToPlaylist adds a track to a playlist, and returns the snapshot ID
+// of the playlist after adding it. A track that can't be moved first in a
+// playlist ordered newest first is logged and left last, as it was still
+// added.
+func (c *Client) addToPlaylist(playlistID spotify.ID, trackID spotify.ID) (string, error) {
+	tracks, err := c.playlistTracks(playlistID)
 	if err != nil {
-		return fmt.Errorf("error getting playlist tracks (%s): %w", playlistID, err)
+		return "", err
 	}
 
-	for _, t := range playlist.Tracks {
+	for _, t := range tracks {
 		if t.Track.ID == trackID {
-			return fmt.Errorf("track already in playlist: %s - %s (%s)", t.Track.Artists, t.Track.Name, trackID)
+			return "", fmt.Errorf("track already in playlist: %s - %s (%s)", t.Track.Artists, t.Track.Name, trackID)
 		}
 	}
 
 	snapshotID, err := c.Spotify.AddTracksToPlaylist(playlistID, trackID)
 	if err != nil {
-		return fmt.Errorf("adding track: playlist %s, track %s: %w", playlistID, trackID, err)
+		return "", fmt.Errorf("adding track: playlist %s, track %s: %w", playlistID, trackID, err)
 	}
 
 	c.Logger.Infof("\tadded track to playlist %s, snapshot id: %s", playlistID, snapshotID)
 
-	return nil
+	c.mu.RLock()
+	order := c.playlistOrder[playlistID]
+	c.mu.RUnlock()
+
+	if order == config.OrderNewest {
+		moved, err := c.moveFirst(playlistID, len(tracks), snapshotID)
+		if err != nil {
+			c.Logger.Infof("\t%s", err)
+			return snapshotID, nil
+		}
+
+		return moved, nil
+	}
+
+	return snapshotID, nil
+}
+
+// maxTracksPerRequest is the most tracks Spotify adds or replaces in one
+// request, and returns in one page of playlist tracks.
+const maxTracksPerRequest = 100
+
+// playlistTracks returns all the tracks of a playlist, fetching every page.
+func (c *Client) playlistTracks(playlistID spotify.ID) ([]spotify.PlaylistTrack, error) {
+	var tracks []spotify.PlaylistTrack
+
+	for {
+		limit, offset := maxTracksPerRequest, len(tracks)
+
+		page, err := c.Spotify.GetPlaylistTracksOpt(playlistID, &spotify.Options{Limit: &limit, Offset: &offset}, "")
+		if err != nil {
+			return nil, fmt.Errorf("error getting playlist tracks (%s): %w", playlistID, err)
+		}
+
+		tracks = append(tracks, page.Tracks...)
+
+		if len(page.Tracks) == 0 || len(tracks) >= page.Total {
+			return tracks, nil
+		}
+	}
 }
@@ -0,0 +1,151 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+)
+
+func TestPreparePlaylists(t *testing.T) {
+	fake := spotifytest.New("tester")
+	existingID := fake.AddPlaylist("tester", "existing")
+	otherID := fake.AddPlaylist("someone-else", "other")
+
+	c := newTestClient(t, fake)
+
+	cfg := config.Config{
+		Playlists: []config.Playlist{
+			{Name: "existing", Subreddits: []string{"music"}},
+			{Name: "created", Subreddits: []string{"jazz", "music"}},
+			{ID: string(otherID), Subreddits: []string{"Blues"}},
+		},
+	}
+
+	t.Run("should find, create and map playlists", func(t *testing.T) {
+		if err := c.PreparePlaylists(&cfg); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		created := fake.PlaylistByName("created")
+		if created == nil {
+			t.Fatalf("expected playlist to be created for user, got %+v", created)
+		}
+
+		if created.Owner.ID != "tester" {
+			t.Errorf("unexpected owner: got %s, exp %s", created.Owner.ID, "tester")
+		}
+
+		if ids := c.SubredditPlaylist["music"]; len(ids) != 2 || ids[0] != existingID || ids[1] != created.ID {
+			t.Errorf("unexpected playlists for r/music: %v", ids)
+		}
+
+		if ids := c.SubredditPlaylist["jazz"]; len(ids) != 1 || ids[0] != created.ID {
+			t.Errorf("unexpected playlists for r/jazz: %v", ids)
+		}
+
+		if ids := c.SubredditPlaylist["blues"]; len(ids) != 1 || ids[0] != otherID {
+			t.Errorf("unexpected playlists for r/blues: %v", ids)
+		}
+	})
+
+	t.Run("should fail on unknown playlist id", func(t *testing.T) {
+		cfg := config.Config{
+			Playlists: []config.Playlist{{ID: "unknown", Subreddits: []string{"music"}}},
+		}
+
+		if err := c.PreparePlaylists(&cfg); err == nil {
+			t.Errorf("expected error for unknown playlist id")
+		}
+	})
+}
+
+func TestAddToPlaylist(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(spotifytest.NewTrack("track1", "Around the World", "Daft Punk"))
+	playlistID := fake.AddPlaylist("tester", "music")
+
+	c := newTestClient(t, fake)
+
+	t.Run("should add track", func(t *testing.T) {
+		if _, err := c.addToPlaylist(playlistID, "track1"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 1 || ids[0] != "track1" {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should not add track already in playlist", func(t *testing.T) {
+		if _, err := c.addToPlaylist(playlistID, "track1"); err == nil {
+			t.Errorf("expected error adding duplicate track")
+		}
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 1 {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should fail on unknown playlist", func(t *testing.T) {
+		if _, err := c.addToPlaylist("unknown", "track1"); err == nil {
+			t.Errorf("expected error adding to unknown playlist")
+		}
+	})
+}
+
+func TestPreparePlaylistsSettings(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(spotifytest.NewTrack("track1", "Around the World", "Daft Punk"))
+	existingID := fake.AddPlaylist("tester", "existing", "track1")
+	sharedID := fake.AddPlaylist("tester", "shared")
+
+	if err := fake.ChangePlaylistAccess(sharedID, true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c := newTestClient(t, fake)
+
+	yes := true
+	cfg := config.Config{
+		Playlists: []config.Playlist{
+			{Name: "existing", Subreddits: []string{"music"}, Collaborative: &yes, Description: "{tracks} tracks from {subreddits}"},
+			{Name: "created", Subreddits: []string{"jazz"}, Public: &yes, Cover: config.CoverGenerate, Description: "Jazz"},
+			{Name: "shared", Subreddits: []string{"electronic"}},
+		},
+	}
+
+	if err := c.PreparePlaylists(&cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	existing, _ := fake.GetPlaylist(existingID)
+	if !existing.Collaborative || existing.IsPublic {
+		t.Errorf("expected existing playlist to be private and collaborative, got public %t, collaborative %t", existing.IsPublic, existing.Collaborative)
+	}
+
+	if shared, _ := fake.GetPlaylist(sharedID); !shared.IsPublic {
+		t.Errorf("expected playlist without settings to stay public")
+	}
+
+	created := fake.PlaylistByName("created")
+	if created == nil || !created.IsPublic || created.Description != "Jazz" {
+		t.Fatalf("unexpected created playlist: %+v", created)
+	}
+
+	if img := fake.PlaylistImage(created.ID); len(img) == 0 {
+		t.Errorf("expected cover to be uploaded")
+	}
+
+	t.Run("should refresh description", func(t *testing.T) {
+		if err := c.RefreshDescription(cfg.Playlists[0], time.Now()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		existing, _ := fake.GetPlaylist(existingID)
+		if exp := "1 tracks from r/music"; existing.Description != exp {
+			t.Errorf("unexpected value: got %s, exp %s", existing.Description, exp)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package spotify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/engvik/dissic/internal/history"
+	"github.com/zmb3/spotify"
+)
+
+// removedByUser is the reason tracks removed from a playlist by a user
+// are suppressed with.
+const removedByUser = "removed by user"
+
+// ReconcilePlaylists compares the playlists with the history, and
+// suppresses the tracks dissic added that have since been removed by a
+// user, so they're never added to the playlist again. It returns the
+// number of tracks suppressed.
+func (c *Client) ReconcilePlaylists() (int, error) {
+	var suppressed int
+
+	for _, playlistID := range c.playlistIDs() {
+		n, err := c.reconcile(playlistID)
+		if err != nil {
+			return suppressed, err
+		}
+
+		suppressed += n
+	}
+
+	return suppressed, nil
+}
+
+// reconcile suppresses the tracks added to a playlist that are no longer
+// in it. The history is read before the playlist, so tracks being added
+// meanwhile are never mistaken for removed. It's read again for the
+// tracks missing, as commands like rollback record the tracks they
+// removed after removing them.
+func (c *Client) reconcile(playlistID spotify.ID) (int, error) {
+	added := c.History.Tracks(string(playlistID))
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	tracks, err := c.playlistTracks(playlistID)
+	if err != nil {
+		return 0, err
+	}
+
+	present := make(map[string]bool, len(tracks))
+	for _, t := range tracks {
+		present[string(t.Track.ID)] = true
+	}
+
+	var missing []history.Entry
+	for _, e := range added {
+		if !present[e.TrackID] {
+			missing = append(missing, e)
+		}
+	}
+
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	still := make(map[string]bool, len(added))
+	for _, e := range c.History.Tracks(string(playlistID)) {
+		still[e.TrackID] = true
+	}
+
+	var suppressed int
+
+	for _, e := range missing {
+		if still[e.TrackID] {
+			c.suppress(e)
+			suppressed++
+		}
+	}
+
+	return suppressed, nil
+}
+
+// suppress records a track added to a playlist as removed by a user.
+func (c *Client) suppress(added history.Entry) {
+	e := added
+	e.Time = time.Time{}
+	e.Status = history.StatusSuppressed
+	e.Reason = removedByUser
+	e.PostID = ""
+	e.Permalink = ""
+	e.Score = 0
+
+	if err := c.History.Add(e); err != nil {
+		c.Logger.Errorf("adding history: %s", err)
+	}
+
+	c.Logger.Infof("suppressed track %s in playlist %s: %s", e.TrackID, e.Playlist, removedByUser)
+}
+
+// suppressedReason returns why a track is kept out of a playlist for
+// having been removed by a user, or an empty string if it isn't. A track
+// the history has in the playlist is looked for in it, so a track
+// removed since the last reconciliation is suppressed when reposted.
+func (c *Client) suppressedReason(playlistID spotify.ID, trackID spotify.ID) string {
+	if c.History.IsSuppressed(string(playlistID), string(trackID)) {
+		return removedByUser
+	}
+
+	for _, e := range c.History.Tracks(string(playlistID)) {
+		if e.TrackID != string(trackID) {
+			continue
+		}
+
+		tracks, err := c.playlistTracks(playlistID)
+		if err != nil {
+			c.Logger.Infof("\tchecking playlist tracks: %s", err)
+			return ""
+		}
+
+		for _, t := range tracks {
+			if t.Track.ID == trackID {
+				return ""
+			}
+		}
+
+		c.suppress(e)
+
+		return removedByUser
+	}
+
+	return ""
+}
+
+// Suppressed returns the entries suppressing tracks in the playlist with
+// key, or in all playlists if key is empty. The playlist of the entries
+// is the playlist key.
+func (c *Client) Suppressed(key string) ([]history.Entry, error) {
+	playlist, err := c.playlistKeyID(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.withKeys(c.History.Suppressed(string(playlist))), nil
+}
+
+// Unsuppress lets a track suppressed in the playlist with key, or in all
+// playlists if key is empty, be added again. The track is given by ID,
+// URL or URI. It returns the number of playlists it was suppressed in.
+func (c *Client) Unsuppress(track string, key string) (int, error) {
+	trackID, err := parseTrackID(track)
+	if err != nil {
+		return 0, err
+	}
+
+	playlist, err := c.playlistKeyID(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var unsuppressed int
+
+	for _, e := range c.History.Suppressed(string(playlist)) {
+		if e.TrackID != string(trackID) {
+			continue
+		}
+
+		e.Time = time.Time{}
+		e.Status = history.StatusUnsuppressed
+		e.Reason = ""
+
+		if err := c.History.Add(e); err != nil {
+			return unsuppressed, fmt.Errorf("adding history: %w", err)
+		}
+
+		c.Logger.Infof("unsuppressed track %s in playlist %s", e.TrackID, e.Playlist)
+
+		unsuppressed++
+	}
+
+	if unsuppressed == 0 {
+		return 0, fmt.Errorf("track %s isn't suppressed", trackID)
+	}
+
+	return unsuppressed, nil
+}
+
+// playlistKeyID returns the ID of the playlist with key, or an empty ID
+// for an empty key.
+func (c *Client) playlistKeyID(key string) (spotify.ID, error) {
+	if key == "" {
+		return "", nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	id, ok := c.PlaylistIDs[key]
+	if !ok {
+		return "", fmt.Errorf("playlist %s not found", key)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,140 @@
+package spotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func TestSuppressRemovedByUser(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(
+		spotifytest.NewTrack("track1", "Around the World", "Daft Punk"),
+		spotifytest.NewTrack("track2", "Windowlicker", "Aphex Twin"),
+	)
+	playlistID := fake.AddPlaylist("tester", "music")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"music": playlistID}
+	c.MapSubreddits([]config.Playlist{{Name: "music", Subreddits: []string{"music"}}})
+
+	c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World"})
+	c.handle(Music{Subreddit: "music", PostTitle: "Aphex Twin - Windowlicker"})
+
+	if _, err := fake.RemoveTracksFromPlaylist(playlistID, "track1", "track2"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	t.Run("should suppress track removed since reconciling when reposted", func(t *testing.T) {
+		c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World (repost)"})
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 0 {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+
+		if e := c.RecentActivity(1); len(e) != 1 || e[0].Message != removedByUser {
+			t.Errorf("unexpected activity: %+v", e)
+		}
+	})
+
+	t.Run("should suppress removed tracks when reconciling", func(t *testing.T) {
+		suppressed, err := c.ReconcilePlaylists()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if suppressed != 1 {
+			t.Errorf("unexpected value: got %d, exp %d", suppressed, 1)
+		}
+
+		entries, err := c.Suppressed("music")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(entries) != 2 || entries[0].TrackID != "track1" || entries[1].TrackID != "track2" || entries[1].Playlist != "music" {
+			t.Errorf("unexpected suppressed tracks: %+v", entries)
+		}
+
+		if entries[1].Status != history.StatusSuppressed || entries[1].Title != "Aphex Twin - Windowlicker" {
+			t.Errorf("unexpected entry: %+v", entries[1])
+		}
+	})
+
+	t.Run("should add unsuppressed track", func(t *testing.T) {
+		if _, err := c.Unsuppress("spotify:track:track1", ""); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if _, err := c.Unsuppress("track1", "music"); err == nil {
+			t.Errorf("expected error for track that isn't suppressed")
+		}
+
+		c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World (again)"})
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 1 || ids[0] != "track1" {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should fail on unknown playlist", func(t *testing.T) {
+		if _, err := c.Suppressed("unknown"); err == nil {
+			t.Errorf("expected error for unknown playlist")
+		}
+	})
+}
+
+func TestUnsuppressByCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dissic-spotify")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "history.jsonl")
+
+	fake := spotifytest.New("tester")
+	fake.AddTrack(spotifytest.NewTrack("track1", "Around the World", "Daft Punk"))
+	playlistID := fake.AddPlaylist("tester", "music")
+
+	// newHistoryClient returns a client with the history file, like
+	// dissic and the commands run meanwhile.
+	newHistoryClient := func() *Client {
+		c := newTestClient(t, fake)
+		c.PlaylistIDs = map[string]spotify.ID{"music": playlistID}
+		c.MapSubreddits([]config.Playlist{{Name: "music", Subreddits: []string{"music"}}})
+
+		if c.History, err = history.Open(path); err != nil {
+			t.Fatalf("error setting up test: %s", err)
+		}
+
+		return c
+	}
+
+	daemon := newHistoryClient()
+	daemon.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World"})
+
+	if _, err := fake.RemoveTracksFromPlaylist(playlistID, "track1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := daemon.ReconcilePlaylists(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := newHistoryClient().Unsuppress("track1", "music"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	daemon.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World (again)"})
+
+	if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 1 || ids[0] != "track1" {
+		t.Errorf("unexpected playlist tracks: %v", ids)
+	}
+}
@@ -0,0 +1,219 @@
+package spotify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/engvik/dissic/internal/history"
+	"github.com/zmb3/spotify"
+)
+
+// RollbackTo reverts the playlist with key to its state at a time,
+// according to the history: the tracks added since are removed, and the
+// tracks removed since are added back, except those removed by users.
+// It returns the changes, with the playlist key as their playlist. With
+// dryRun, the changes are only returned.
+func (c *Client) RollbackTo(key string, t time.Time, dryRun bool) ([]history.Entry, error) {
+	if key == "" {
+		return nil, fmt.Errorf("missing playlist")
+	}
+
+	playlistID, err := c.playlistKeyID(key)
+	if err != nil {
+		return nil, err
+	}
+
+	then := make(map[string]bool)
+	for _, e := range c.History.TracksAt(string(playlistID), t) {
+		then[e.TrackID] = true
+	}
+
+	now := make(map[string]bool)
+	var remove []history.Entry
+
+	for _, e := range c.History.Tracks(string(playlistID)) {
+		now[e.TrackID] = true
+
+		if !then[e.TrackID] {
+			remove = append(remove, e)
+		}
+	}
+
+	var restore []history.Entry
+
+	for _, e := range c.History.TracksAt(string(playlistID), t) {
+		if !now[e.TrackID] && !c.History.IsSuppressed(string(playlistID), e.TrackID) {
+			restore = append(restore, e)
+		}
+	}
+
+	reason := fmt.Sprintf("rolled back to %s", t.Format(time.RFC3339))
+
+	return c.rollback(playlistID, remove, restore, reason, dryRun)
+}
+
+// RollbackRun removes the tracks added in a run from the playlist with
+// key, or from all playlists if key is empty. It returns the changes, with
+// the playlist key as their playlist. With dryRun, the changes are only
+// returned.
+func (c *Client) RollbackRun(runID string, key string, dryRun bool) ([]history.Entry, error) {
+	if runID == "" {
+		return nil, fmt.Errorf("missing run id")
+	}
+
+	playlistIDs := c.playlistIDs()
+
+	if key != "" {
+		playlistID, err := c.playlistKeyID(key)
+		if err != nil {
+			return nil, err
+		}
+
+		playlistIDs = []spotify.ID{playlistID}
+	}
+
+	reason := fmt.Sprintf("rolled back run %s", runID)
+
+	var changes []history.Entry
+
+	for _, playlistID := range playlistIDs {
+		var remove []history.Entry
+
+		for _, e := range c.History.Tracks(string(playlistID)) {
+			if e.RunID == runID {
+				remove = append(remove, e)
+			}
+		}
+
+		if len(remove) == 0 {
+			continue
+		}
+
+		changed, err := c.rollback(playlistID, remove, nil, reason, dryRun)
+		changes = append(changes, changed...)
+
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	return changes, nil
+}
+
+// rollback removes and adds back tracks to a playlist, recording the
+// changes in the history. Tracks already removed or added are skipped.
+func (c *Client) rollback(playlistID spotify.ID, remove []history.Entry, restore []history.Entry, reason string, dryRun bool) ([]history.Entry, error) {
+	tracks, err := c.playlistTracks(playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(tracks))
+	for _, t := range tracks {
+		present[string(t.Track.ID)] = true
+	}
+
+	var removed []history.Entry
+	for _, e := range remove {
+		if present[e.TrackID] {
+			removed = append(removed, c.rollbackEntry(e, history.StatusRemoved, reason))
+		}
+	}
+
+	var restored []history.Entry
+	for _, e := range restore {
+		if !present[e.TrackID] {
+			restored = append(restored, c.rollbackEntry(e, history.StatusAdded, reason))
+		}
+	}
+
+	changes := append(removed, restored...)
+
+	if dryRun {
+		return c.withKeys(changes), nil
+	}
+
+	for start := 0; start < len(removed); start += maxTracksPerRequest {
+		end := start + maxTracksPerRequest
+		if end > len(removed) {
+			end = len(removed)
+		}
+
+		snapshotID, err := c.Spotify.RemoveTracksFromPlaylist(playlistID, entryTrackIDs(removed[start:end])...)
+		if err != nil {
+			return nil, fmt.Errorf("removing tracks: playlist %s: %w", playlistID, err)
+		}
+
+		c.addChanges(removed[start:end], snapshotID)
+	}
+
+	for start := 0; start < len(restored); start += maxTracksPerRequest {
+		end := start + maxTracksPerRequest
+		if end > len(restored) {
+			end = len(restored)
+		}
+
+		snapshotID, err := c.Spotify.AddTracksToPlaylist(playlistID, entryTrackIDs(restored[start:end])...)
+		if err != nil {
+			return c.withKeys(removed), fmt.Errorf("adding tracks: playlist %s: %w", playlistID, err)
+		}
+
+		c.addChanges(restored[start:end], snapshotID)
+	}
+
+	c.Logger.Infof("%s: removed %d and added %d tracks to playlist %s", reason, len(removed), len(restored), playlistID)
+
+	return c.withKeys(changes), nil
+}
+
+// rollbackEntry returns the history entry for undoing an entry.
+func (c *Client) rollbackEntry(e history.Entry, status string, reason string) history.Entry {
+	return history.Entry{
+		Subreddit: e.Subreddit,
+		Title:     e.Title,
+		Playlist:  e.Playlist,
+		TrackID:   e.TrackID,
+		Status:    status,
+		Reason:    reason,
+	}
+}
+
+func (c *Client) addChanges(changes []history.Entry, snapshotID string) {
+	for _, e := range changes {
+		e.SnapshotID = snapshotID
+
+		if err := c.History.Add(e); err != nil {
+			c.Logger.Errorf("adding history: %s", err)
+		}
+	}
+}
+
+// withKeys returns entries with the key of their playlist as playlist.
+func (c *Client) withKeys(entries []history.Entry) []history.Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make(map[string]string, len(c.PlaylistIDs))
+	for k, id := range c.PlaylistIDs {
+		keys[string(id)] = k
+	}
+
+	res := make([]history.Entry, len(entries))
+	for i, e := range entries {
+		res[i] = e
+		if k, ok := keys[e.Playlist]; ok {
+			res[i].Playlist = k
+		}
+	}
+
+	return res
+}
+
+func entryTrackIDs(entries []history.Entry) []spotify.ID {
+	ids := make([]spotify.ID, len(entries))
+	for i, e := range entries {
+		ids[i] = spotify.ID(e.TrackID)
+	}
+
+	return ids
+}
@@ -0,0 +1,193 @@
+package spotify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func TestRollback(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(
+		spotifytest.NewTrack("track1", "Around the World", "Daft Punk"),
+		spotifytest.NewTrack("track2", "Windowlicker", "Aphex Twin"),
+		spotifytest.NewTrack("track3", "One More Time", "Daft Punk"),
+		spotifytest.NewTrack("track4", "Xtal", "Aphex Twin"),
+	)
+	playlistID := fake.AddPlaylist("tester", "music", "track1", "track2", "track4")
+
+	c := newTestClient(t, fake)
+	c.PlaylistIDs = map[string]spotify.ID{"music": playlistID}
+
+	before := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	after := before.Add(2 * time.Hour)
+
+	for _, e := range []history.Entry{
+		{Time: before, TrackID: "track1", Status: history.StatusAdded, RunID: "run1"},
+		{Time: before, TrackID: "track3", Status: history.StatusAdded, RunID: "run1"},
+		{Time: after, TrackID: "track2", Status: history.StatusAdded, RunID: "run2"},
+		{Time: after, TrackID: "track3", Status: history.StatusRemoved, RunID: "run2"},
+		{Time: after, TrackID: "track4", Status: history.StatusAdded, RunID: "run2"},
+	} {
+		e.Playlist = string(playlistID)
+		if err := c.History.Add(e); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	t.Run("should only list changes on dry run", func(t *testing.T) {
+		changes, err := c.RollbackTo("music", before.Add(time.Hour), true)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(changes) != 3 || changes[0].TrackID != "track2" || changes[2].TrackID != "track3" || changes[2].Status != history.StatusAdded || changes[0].Playlist != "music" {
+			t.Errorf("unexpected changes: %+v", changes)
+		}
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 3 {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should roll back run", func(t *testing.T) {
+		changes, err := c.RollbackRun("run2", "", false)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(changes) != 2 {
+			t.Errorf("unexpected changes: %+v", changes)
+		}
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 1 || ids[0] != "track1" {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should roll back to time", func(t *testing.T) {
+		if _, err := c.RollbackTo("music", before.Add(time.Hour), false); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 2 || ids[0] != "track1" || ids[1] != "track3" {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+
+		entries := c.History.Tracks(string(playlistID))
+		if last := entries[len(entries)-1]; last.TrackID != "track3" || last.SnapshotID == "" || last.RunID != c.RunID {
+			t.Errorf("unexpected history entry: %+v", last)
+		}
+	})
+
+	t.Run("should fail on unknown playlist", func(t *testing.T) {
+		if _, err := c.RollbackTo("unknown", before, true); err == nil {
+			t.Errorf("expected error for unknown playlist")
+		}
+	})
+}
+
+// rollingBackFake is a fake where a command rolls back a run right before
+// the playlist tracks are read.
+type rollingBackFake struct {
+	*spotifytest.Fake
+	rollback func()
+}
+
+func (f *rollingBackFake) GetPlaylistTracksOpt(playlistID spotify.ID, opt *spotify.Options, fields string) (*spotify.PlaylistTrackPage, error) {
+	if f.rollback != nil {
+		rollback := f.rollback
+		f.rollback = nil
+		rollback()
+	}
+
+	return f.Fake.GetPlaylistTracksOpt(playlistID, opt, fields)
+}
+
+func TestRollbackByCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dissic-spotify")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "history.jsonl")
+
+	fake := spotifytest.New("tester")
+	fake.AddTrack(
+		spotifytest.NewTrack("track1", "Around the World", "Daft Punk"),
+		spotifytest.NewTrack("track2", "Windowlicker", "Aphex Twin"),
+	)
+	playlistID := fake.AddPlaylist("tester", "music", "track1", "track2")
+
+	// newHistoryClient returns a client with the history file, like
+	// dissic and the commands run meanwhile.
+	newHistoryClient := func() *Client {
+		c := newTestClient(t, fake)
+		c.PlaylistIDs = map[string]spotify.ID{"music": playlistID}
+
+		if c.History, err = history.Open(path); err != nil {
+			t.Fatalf("error setting up test: %s", err)
+		}
+
+		return c
+	}
+
+	daemon := newHistoryClient()
+
+	for _, e := range []history.Entry{
+		{TrackID: "track1", Status: history.StatusAdded, RunID: "run1"},
+		{TrackID: "track2", Status: history.StatusAdded, RunID: "run2"},
+	} {
+		e.Playlist = string(playlistID)
+		if err := daemon.History.Add(e); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	t.Run("should not suppress tracks rolled back", func(t *testing.T) {
+		if _, err := newHistoryClient().RollbackRun("run2", "", false); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		suppressed, err := daemon.ReconcilePlaylists()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if suppressed != 0 {
+			t.Errorf("unexpected value: got %d, exp %d", suppressed, 0)
+		}
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 1 || ids[0] != "track1" {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should not suppress tracks rolled back while reconciling", func(t *testing.T) {
+		command := newHistoryClient()
+
+		racing := &rollingBackFake{Fake: fake}
+		racing.rollback = func() {
+			if _, err := command.RollbackRun("run1", "", false); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}
+		daemon.Spotify = racing
+
+		suppressed, err := daemon.ReconcilePlaylists()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if suppressed != 0 {
+			t.Errorf("unexpected value: got %d, exp %d", suppressed, 0)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package spotify
+
+import (
+	"fmt"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/zmb3/spotify"
+)
+
+// RotatePlaylist archives a playlist and starts a new empty one. The
+// playlist is either renamed to archiveName and replaced by a new playlist
+// with its name and cover, or its tracks are copied to a new playlist
+// named archiveName before it's emptied, as configured. It returns the ID
+// of the archive.
+func (c *Client) RotatePlaylist(p config.Playlist, archiveName string) (string, error) {
+	key := p.Key()
+
+	c.mu.RLock()
+	playlistID, ok := c.PlaylistIDs[key]
+	c.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("unknown playlist: %s", key)
+	}
+
+	var archiveID spotify.ID
+	var err error
+
+	if p.RotateMode == config.RotateCopy {
+		archiveID, err = c.rotateByCopy(playlistID, archiveName)
+	} else {
+		archiveID, err = c.rotateByRename(p, playlistID, archiveName)
+	}
+
+	return string(archiveID), err
+}
+
+func (c *Client) rotateByRename(p config.Playlist, playlistID spotify.ID, archiveName string) (spotify.ID, error) {
+	tracks, err := c.playlistTracks(playlistID)
+	if err != nil {
+		return "", err
+	}
+
+	playlist, err := c.Spotify.GetPlaylist(playlistID)
+	if err != nil {
+		return "", fmt.Errorf("getting playlist %s: %w", playlistID, err)
+	}
+
+	created, err := c.Spotify.CreatePlaylistForUser(c.User.ID, playlist.Name, playlist.Description, playlist.IsPublic)
+	if err != nil {
+		return "", fmt.Errorf("error creating playlist: %w", err)
+	}
+
+	if playlist.Collaborative {
+		if err := c.Spotify.ChangePlaylistCollaborative(created.ID, true); err != nil {
+			return "", fmt.Errorf("changing collaborative: playlist %s: %w", created.ID, err)
+		}
+	}
+
+	if err := c.applySettings(created, p); err != nil {
+		return "", err
+	}
+
+	if err := c.Spotify.ChangePlaylistName(playlistID, archiveName); err != nil {
+		return "", fmt.Errorf("renaming playlist %s: %w", playlistID, err)
+	}
+
+	c.mu.Lock()
+	c.PlaylistIDs[p.Key()] = created.ID
+	c.mu.Unlock()
+
+	c.recordRotated(playlistID, playlistTrackIDs(tracks), fmt.Sprintf("archived as %s", archiveName))
+
+	c.Logger.Infof("archived playlist %s as %s (%s), new playlist: %s", playlist.Name, archiveName, playlistID, created.ID)
+
+	return playlistID, nil
+}
+
+func (c *Client) rotateByCopy(playlistID spotify.ID, archiveName string) (spotify.ID, error) {
+	tracks, err := c.playlistTracks(playlistID)
+	if err != nil {
+		return "", err
+	}
+
+	playlist, err := c.Spotify.GetPlaylist(playlistID)
+	if err != nil {
+		return "", fmt.Errorf("getting playlist %s: %w", playlistID, err)
+	}
+
+	archive, err := c.Spotify.CreatePlaylistForUser(c.User.ID, archiveName, playlist.Description, playlist.IsPublic)
+	if err != nil {
+		return "", fmt.Errorf("error creating playlist: %w", err)
+	}
+
+	ids := playlistTrackIDs(tracks)
+
+	for start := 0; start < len(ids); start += maxTracksPerRequest {
+		end := start + maxTracksPerRequest
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		if _, err := c.Spotify.AddTracksToPlaylist(archive.ID, ids[start:end]...); err != nil {
+			return "", fmt.Errorf("adding tracks: playlist %s: %w", archive.ID, err)
+		}
+	}
+
+	if err := c.Spotify.ReplacePlaylistTracks(playlistID); err != nil {
+		return "", fmt.Errorf("emptying playlist %s: %w", playlistID, err)
+	}
+
+	c.recordRotated(playlistID, ids, fmt.Sprintf("rotated to playlist %s", archive.ID))
+
+	c.Logger.Infof("archived %d tracks of playlist %s to %s (%s)", len(ids), playlistID, archiveName, archive.ID)
+
+	return archive.ID, nil
+}
+
+// recordRotated records the tracks rotated out of a playlist as removed
+// in the history, so they're no longer taken to be in it.
+func (c *Client) recordRotated(playlistID spotify.ID, ids []spotify.ID, reason string) {
+	for _, id := range ids {
+		err := c.History.Add(history.Entry{
+			Playlist: string(playlistID),
+			TrackID:  string(id),
+			Status:   history.StatusRemoved,
+			Reason:   reason,
+		})
+		if err != nil {
+			c.Logger.Errorf("adding history: %s", err)
+		}
+	}
+}
+
+// playlistTrackIDs returns the IDs of playlist tracks.
+func playlistTrackIDs(tracks []spotify.PlaylistTrack) []spotify.ID {
+	ids := make([]spotify.ID, 0, len(tracks))
+	for _, t := range tracks {
+		ids = append(ids, t.Track.ID)
+	}
+
+	return ids
+}
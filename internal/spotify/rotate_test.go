@@ -0,0 +1,95 @@
+package spotify
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func TestRotatePlaylist(t *testing.T) {
+	fake := spotifytest.New("tester")
+
+	var trackIDs []spotify.ID
+	for i := 0; i < 150; i++ {
+		track := spotifytest.NewTrack(fmt.Sprintf("track%d", i), fmt.Sprintf("Track %d", i), "Artist")
+		fake.AddTrack(track)
+		trackIDs = append(trackIDs, track.ID)
+	}
+
+	t.Run("should rename playlist and start a new one", func(t *testing.T) {
+		playlistID := fake.AddPlaylist("tester", "Fresh", trackIDs[:3]...)
+
+		c := newTestClient(t, fake)
+		c.PlaylistIDs = map[string]spotify.ID{"Fresh": playlistID}
+
+		for _, id := range trackIDs[:3] {
+			c.History.Add(history.Entry{Playlist: string(playlistID), TrackID: string(id), Status: history.StatusAdded})
+		}
+
+		archiveID, err := c.RotatePlaylist(config.Playlist{Name: "Fresh", Cover: config.CoverGenerate}, "Fresh — 2026-W42")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if archiveID != string(playlistID) {
+			t.Errorf("unexpected value: got %s, exp %s", archiveID, playlistID)
+		}
+
+		if archive := fake.PlaylistByName("Fresh — 2026-W42"); archive == nil || len(fake.PlaylistTrackIDs(archive.ID)) != 3 {
+			t.Errorf("unexpected archive: %+v", archive)
+		}
+
+		newID := c.PlaylistIDs["Fresh"]
+		if newID == playlistID || len(fake.PlaylistTrackIDs(newID)) != 0 {
+			t.Errorf("unexpected new playlist: %s, tracks %v", newID, fake.PlaylistTrackIDs(newID))
+		}
+
+		if img := fake.PlaylistImage(newID); len(img) == 0 {
+			t.Errorf("expected cover on new playlist")
+		}
+
+		if got := c.History.Playlists(string(trackIDs[0])); len(got) != 0 {
+			t.Errorf("unexpected playlists in history: %v", got)
+		}
+	})
+
+	t.Run("should copy tracks and empty playlist", func(t *testing.T) {
+		playlistID := fake.AddPlaylist("tester", "Monthly", trackIDs...)
+
+		c := newTestClient(t, fake)
+		c.PlaylistIDs = map[string]spotify.ID{"Monthly": playlistID}
+
+		archiveID, err := c.RotatePlaylist(config.Playlist{Name: "Monthly", RotateMode: config.RotateCopy}, "Monthly — 2026-10")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if ids := fake.PlaylistTrackIDs(spotify.ID(archiveID)); len(ids) != 150 || ids[149] != trackIDs[149] {
+			t.Errorf("unexpected archive tracks: %d", len(ids))
+		}
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 0 {
+			t.Errorf("unexpected tracks in rotated playlist: %d", len(ids))
+		}
+
+		if c.PlaylistIDs["Monthly"] != playlistID {
+			t.Errorf("unexpected playlist id: got %s, exp %s", c.PlaylistIDs["Monthly"], playlistID)
+		}
+
+		if got := c.History.Playlists(string(trackIDs[0])); len(got) != 0 {
+			t.Errorf("unexpected playlists in history: %v", got)
+		}
+	})
+
+	t.Run("should fail for unknown playlist", func(t *testing.T) {
+		c := newTestClient(t, fake)
+
+		if _, err := c.RotatePlaylist(config.Playlist{Name: "unknown"}, "archive"); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+}
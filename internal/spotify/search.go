@@ -4,12 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/engvik/dissic/internal/cache"
+	"github.com/engvik/dissic/internal/title"
 	"github.com/zmb3/spotify"
 )
 
+// errRequest is returned when a request to Spotify failed while finding a
+// track, so it may still be found when tried again.
+var errRequest = errors.New("request failed")
+
 func (c *Client) getTrackByURL(URL string) (*spotify.FullTrack, error) {
 	parsedURL, err := url.Parse(URL)
 	if err != nil {
@@ -30,80 +37,388 @@ func (c *Client) getTrackByURL(URL string) (*spotify.FullTrack, error) {
 		return nil, fmt.Errorf("not a track path: %s", parsedURL.Path)
 	}
 
-	return c.Spotify.GetTrack(spotify.ID(splitURL[2]))
+	track, err := c.Spotify.GetTrack(spotify.ID(splitURL[2]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errRequest, err)
+	}
+
+	return track, nil
+}
+
+// Search strategies, from the strictest to the loosest query.
+const (
+	StrategyURL             = "url"
+	StrategyISRC            = "isrc"
+	StrategyUPC             = "upc"
+	StrategyFields          = "fields"
+	StrategyArtistTrackYear = "artist-track-year"
+	StrategyArtistTrack     = "artist-track"
+	StrategyKeywords        = "keywords"
+	StrategyTrack           = "track"
+)
+
+// searchQuery is a search query and the strategy it was made by.
+type searchQuery struct {
+	Strategy string
+	Query    string
+}
+
+// searchQueries returns the queries to try for a parsed title, strictest
+// first. Queries only differing in strategy are left out.
+func searchQueries(t title.Title) []searchQuery {
+	artist := quote(t.Artists[0])
+	track := quote(t.Track)
+
+	var queries []searchQuery
+
+	if t.Year != 0 {
+		queries = append(queries, searchQuery{
+			StrategyArtistTrackYear,
+			fmt.Sprintf("artist:%s track:%s year:%d", artist, track, t.Year),
+		})
+	}
+
+	return append(queries,
+		searchQuery{StrategyArtistTrack, fmt.Sprintf("artist:%s track:%s", artist, track)},
+		searchQuery{StrategyKeywords, strings.Join(append(append([]string(nil), t.Artists...), t.Track), " ")},
+		searchQuery{StrategyTrack, fmt.Sprintf("track:%s", track)},
+	)
+}
+
+// fieldQueries returns the queries to try for the structured fields of a
+// post, with the album first when known.
+func fieldQueries(t title.Title, album string) []searchQuery {
+	query := fmt.Sprintf("artist:%s track:%s", quote(t.Artists[0]), quote(t.Track))
+
+	var queries []searchQuery
+
+	if album != "" {
+		queries = append(queries, searchQuery{StrategyFields, fmt.Sprintf("%s album:%s", query, quote(album))})
+	}
+
+	return append(queries, searchQuery{StrategyFields, query})
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "") + `"`
+}
+
+// searchOptions returns the configured result limit and market, or nil
+// to use the Spotify defaults.
+func (c *Client) searchOptions() *spotify.Options {
+	if c.searchLimit == 0 && c.market == "" {
+		return nil
+	}
+
+	var opt spotify.Options
+
+	if c.searchLimit != 0 {
+		opt.Limit = &c.searchLimit
+	}
+
+	if c.market != "" {
+		opt.Country = &c.market
+	}
+
+	return &opt
+}
+
+// search searches for tracks, using cached results when available.
+func (c *Client) search(query string) ([]spotify.FullTrack, error) {
+	key := cache.Key(query, c.market, strconv.Itoa(c.searchLimit))
+
+	if cached, ok := c.Cache.Results(key); ok {
+		tracks := make([]spotify.FullTrack, 0, len(cached))
+		for _, ct := range cached {
+			tracks = append(tracks, fromCacheTrack(ct))
+		}
+
+		return tracks, nil
+	}
+
+	res, err := c.Spotify.SearchOpt(query, spotify.SearchTypeTrack, c.searchOptions())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errRequest, err)
+	}
+
+	var tracks []spotify.FullTrack
+	if res.Tracks != nil {
+		tracks = res.Tracks.Tracks
+	}
+
+	cached := make([]cache.Track, 0, len(tracks))
+	for _, t := range tracks {
+		cached = append(cached, c.toCacheTrack(t))
+	}
+
+	c.Cache.AddResults(key, cached)
+
+	return tracks, nil
 }
 
-func (c *Client) getTrackByTitles(m Music) (spotify.FullTrack, error) {
-	var track spotify.FullTrack
+// toCacheTrack returns a search result as cached. The available markets
+// are only kept with a market set, as they aren't looked at without one.
+func (c *Client) toCacheTrack(t spotify.FullTrack) cache.Track {
+	ct := cache.Track{
+		ID:          string(t.ID),
+		Name:        t.Name,
+		Album:       t.Album.Name,
+		AlbumID:     string(t.Album.ID),
+		ReleaseDate: t.Album.ReleaseDate,
+		ISRC:        t.ExternalIDs["isrc"],
+		Explicit:    t.Explicit,
+		Popularity:  t.Popularity,
+		DurationMs:  t.Duration,
+	}
+
+	if c.market != "" {
+		ct.AvailableMarkets = t.AvailableMarkets
+	}
+
+	for _, a := range t.Artists {
+		ct.Artists = append(ct.Artists, cache.Artist{ID: string(a.ID), Name: a.Name})
+	}
+
+	return ct
+}
+
+// fromCacheTrack returns a cached search result as a track.
+func fromCacheTrack(ct cache.Track) spotify.FullTrack {
+	var t spotify.FullTrack
+	t.ID = spotify.ID(ct.ID)
+	t.Name = ct.Name
+	t.URI = spotify.URI("spotify:track:" + ct.ID)
+	t.Album.Name = ct.Album
+	t.Album.ID = spotify.ID(ct.AlbumID)
+	t.Album.ReleaseDate = ct.ReleaseDate
+	t.Explicit = ct.Explicit
+	t.Popularity = ct.Popularity
+	t.Duration = ct.DurationMs
+	t.AvailableMarkets = ct.AvailableMarkets
+
+	if ct.ISRC != "" {
+		t.ExternalIDs = map[string]string{"isrc": ct.ISRC}
+	}
+
+	for _, a := range ct.Artists {
+		t.Artists = append(t.Artists, spotify.SimpleArtist{ID: spotify.ID(a.ID), Name: a.Name})
+	}
+
+	return t
+}
+
+// getTrackByISRC finds the track with an ISRC, available in the market.
+func (c *Client) getTrackByISRC(isrc string) (match, error) {
+	tracks, err := c.search(fmt.Sprintf("isrc:%s", isrc))
+	if err != nil {
+		return match{}, fmt.Errorf("search: %w", err)
+	}
+
+	for _, track := range tracks {
+		if available(track, c.market) {
+			c.Logger.Infof("\ttrack found: isrc %s (%s), strategy: %s", isrc, track.ID, StrategyISRC)
+			return match{Track: track, Strategy: StrategyISRC}, nil
+		}
+	}
+
+	return match{}, fmt.Errorf("no track found for isrc %s", isrc)
+}
+
+// getTrackByUPC finds the track with the ISRC or title on the release
+// with the UPC. Spotify only searches albums by UPC, so the tracks of the
+// album found are searched for next.
+func (c *Client) getTrackByUPC(m Music) (match, error) {
+	res, err := c.Spotify.SearchOpt("upc:"+m.UPC, spotify.SearchTypeAlbum, c.searchOptions())
+	if err != nil {
+		return match{}, fmt.Errorf("search: %w: %s", errRequest, err)
+	}
+
+	if res.Albums == nil || len(res.Albums.Albums) == 0 {
+		return match{}, fmt.Errorf("no album found for upc %s", m.UPC)
+	}
+
+	album := res.Albums.Albums[0]
+
+	artist := m.Artist
+	if artist == "" && len(album.Artists) > 0 {
+		artist = album.Artists[0].Name
+	}
+
+	query := "album:" + quote(album.Name)
+	if artist != "" {
+		query += " artist:" + quote(artist)
+	}
+
+	if m.Title != "" {
+		query += " track:" + quote(m.Title)
+	}
+
+	tracks, err := c.search(query)
+	if err != nil {
+		return match{}, fmt.Errorf("search: %w", err)
+	}
+
+	var onAlbum []spotify.FullTrack
+	for _, t := range tracks {
+		if t.Album.ID == album.ID && available(t, c.market) {
+			onAlbum = append(onAlbum, t)
+		}
+	}
+
+	for _, t := range onAlbum {
+		if m.ISRC != "" && strings.EqualFold(t.ExternalIDs["isrc"], m.ISRC) {
+			c.Logger.Infof("\ttrack found: upc %s, isrc %s (%s), strategy: %s", m.UPC, m.ISRC, t.ID, StrategyUPC)
+			return match{Track: t, Strategy: StrategyUPC}, nil
+		}
+	}
+
+	if m.Title != "" && artist != "" {
+		if parsed, err := title.Parse(fmt.Sprintf("%s - %s", artist, m.Title)); err == nil {
+			if t, found := c.findMatchFromSearchResult(parsed, withDuration(onAlbum, m.Duration)); found {
+				c.Logger.Infof("\ttrack found: upc %s, %s - %s (%s), strategy: %s", m.UPC, artist, m.Title, t.ID, StrategyUPC)
+				return match{Track: t, Strategy: StrategyUPC}, nil
+			}
+		}
+	}
+
+	return match{}, fmt.Errorf("no track found on album %s for upc %s", album.ID, m.UPC)
+}
+
+// durationTolerance is how far the duration of a track found by fields
+// may be from the known duration.
+const durationTolerance = 10 * time.Second
+
+// getTrackByFields finds the track by the structured artist, title and
+// album, leaving out tracks too far from the duration when it's known.
+func (c *Client) getTrackByFields(m Music) (match, error) {
+	t, err := title.Parse(fmt.Sprintf("%s - %s", m.Artist, m.Title))
+	if err != nil {
+		return match{}, fmt.Errorf("parse fields: %w", err)
+	}
+
+	var failed bool
+
+	for _, q := range fieldQueries(t, m.Album) {
+		c.Logger.Infof("\tsearch query: \"%s\" (%s) from fields", q.Query, q.Strategy)
+
+		tracks, err := c.search(q.Query)
+		if err != nil {
+			c.Logger.Infof("search: %s", err)
+			failed = true
+			continue
+		}
+
+		track, found := c.findMatchFromSearchResult(t, withDuration(tracks, m.Duration))
+		if found {
+			c.Logger.Infof("\ttrack found: %s - %s (%s), strategy: %s", m.Artist, m.Title, track.ID, q.Strategy)
+			return match{Track: track, Strategy: q.Strategy}, nil
+		}
+	}
+
+	return match{}, noTrackFound(failed)
+}
+
+// withDuration returns the tracks within durationTolerance of d, or all
+// tracks if d is unknown.
+func withDuration(tracks []spotify.FullTrack, d time.Duration) []spotify.FullTrack {
+	if d == 0 {
+		return tracks
+	}
+
+	var within []spotify.FullTrack
+
+	for _, t := range tracks {
+		diff := time.Duration(t.Duration)*time.Millisecond - d
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if diff <= durationTolerance {
+			within = append(within, t)
+		}
+	}
+
+	return within
+}
+
+func (c *Client) getTrackByTitles(m Music) (match, error) {
+	var failed bool
 
 	// loop through possible titles
-	for _, title := range m.titleStringSlice() {
-		if title == "" {
+	for _, s := range m.titleStringSlice() {
+		if s == "" {
 			continue
 		}
 
-		separators := []string{"-", "~", "|", "by", "--", "ー"}
+		t, err := title.Parse(s)
+		if err != nil {
+			c.Logger.Infof("\tparse title: %s, title: %s", err, s)
+			continue
+		}
 
-		// attempt finding search query for different track separators
-		for _, s := range separators {
-			// create search query
-			searchQuery, err := c.createSearchQuery(title, s)
-			if err != nil {
-				c.Logger.Infof("\tsearch query: %s, separator: %s", err, s)
-				continue
-			}
+		for _, q := range searchQueries(t) {
+			c.Logger.Infof("\tsearch query: \"%s\" (%s) from title: %s", q.Query, q.Strategy, s)
 
-			// search by query
-			res, err := c.Spotify.Search(searchQuery, spotify.SearchTypeAlbum|spotify.SearchTypeArtist|spotify.SearchTypeTrack)
+			tracks, err := c.search(q.Query)
 			if err != nil {
 				c.Logger.Infof("search: %s", err)
+				failed = true
 				continue
 			}
 
-			track, found := c.findMatchFromSearchResult(title, res)
+			track, found := c.findMatchFromSearchResult(t, tracks)
 			if found {
-				return track, nil
+				c.Logger.Infof("\ttrack found: %s (%s), strategy: %s", s, track.ID, q.Strategy)
+				return match{Track: track, Strategy: q.Strategy}, nil
 			}
 		}
 	}
 
-	return track, errors.New("no track found")
+	return match{}, noTrackFound(failed)
 }
 
-func (c *Client) findMatchFromSearchResult(title string, res *spotify.SearchResult) (spotify.FullTrack, bool) {
-	cmprTitle := strings.ToLower(title)
-
-	// figure out if the search result is a match
-	for _, t := range res.Tracks.Tracks {
-		if strings.Contains(cmprTitle, strings.ToLower(t.Name)) {
-			for _, artist := range t.Artists {
-				if strings.Contains(cmprTitle, strings.ToLower(artist.Name)) {
-					c.Logger.Infof("\ttrack found: %s (%s)", title, t.ID)
-					return t, true
-				}
-			}
-		}
+// noTrackFound returns the error for a track that wasn't found, wrapping
+// errRequest if a search failed.
+func noTrackFound(failed bool) error {
+	if failed {
+		return fmt.Errorf("no track found: %w", errRequest)
 	}
 
-	return spotify.FullTrack{}, false
+	return errors.New("no track found")
 }
 
-func (c *Client) createSearchQuery(title string, separator string) (string, error) {
-	re := regexp.MustCompile(`\(([^)]+)\)|\[([^)]+)\]`)
-	replacedTitle := re.ReplaceAll([]byte(title), []byte(""))
-	searchQuery := string(replacedTitle)
-	searchQuery = strings.ReplaceAll(searchQuery, "'", "")
-	searchQuery = strings.ReplaceAll(searchQuery, "\"", "")
+// findMatchFromSearchResult returns the first result matching the parsed
+// title, preferring one with the version asked for.
+func (c *Client) findMatchFromSearchResult(t title.Title, tracks []spotify.FullTrack) (spotify.FullTrack, bool) {
+	var match *spotify.FullTrack
 
-	splitTitle := strings.Split(searchQuery, fmt.Sprintf(" %s ", separator))
+	for i, track := range tracks {
+		artists := make([]string, 0, len(track.Artists))
+		for _, a := range track.Artists {
+			artists = append(artists, a.Name)
+		}
 
-	if len(splitTitle) <= 1 {
-		return "", fmt.Errorf("not able to find title and/or artist: %s", searchQuery)
+		if !t.Matches(track.Name, artists) {
+			continue
+		}
+
+		if !available(track, c.market) {
+			c.Logger.Infof("\tskipping track %s: not available in market %s", track.ID, c.market)
+			continue
+		}
+
+		if t.VersionMatches(track.Name) {
+			return track, true
+		}
+
+		if match == nil {
+			match = &tracks[i]
+		}
 	}
 
-	searchQuery = strings.Join(splitTitle, " ")
-	searchQuery = strings.TrimSpace(searchQuery)
+	if match == nil {
+		return spotify.FullTrack{}, false
+	}
 
-	c.Logger.Infof("\tsearch query: \"%s\" from title: %s", searchQuery, title)
-	return searchQuery, nil
+	return *match, true
 }
@@ -2,72 +2,275 @@ package spotify
 
 import (
 	"testing"
+	"time"
 
-	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/engvik/dissic/internal/title"
 )
 
-func TestCreateSearchQuery(t *testing.T) {
-	var cfg config.Config
-
-	c, err := New(&cfg)
-	if err != nil {
-		t.Fatalf("error setting up test client: %s", err)
-	}
-
+func TestSearchQueries(t *testing.T) {
 	tests := []struct {
-		name      string
-		title     string
-		separator string
-		exp       string
+		name  string
+		title string
+		exp   []string
 	}{
 		{
 			"should parse separator '-' correctly",
 			"Something - Something",
-			"-",
-			"Something Something",
+			[]string{`artist:"Something" track:"Something"`, "Something Something", `track:"Something"`},
 		},
 		{
 			"should parse separator '~' correctly",
 			"Something ~ Something",
-			"~",
-			"Something Something",
+			[]string{`artist:"Something" track:"Something"`, "Something Something", `track:"Something"`},
 		},
 		{
 			"should parse separator '|' correctly",
 			"Something | Something",
-			"|",
-			"Something Something",
+			[]string{`artist:"Something" track:"Something"`, "Something Something", `track:"Something"`},
 		},
 		{
 			"should parse separator 'by' correctly",
 			"Something by Something",
-			"by",
-			"Something Something",
+			[]string{`artist:"Something" track:"Something"`, "Something Something", `track:"Something"`},
 		},
 		{
 			"should parse separator '--' correctly",
 			"Something -- Something",
-			"--",
-			"Something Something",
+			[]string{`artist:"Something" track:"Something"`, "Something Something", `track:"Something"`},
 		},
 		{
 			"should parse separator 'ー' correctly",
 			"Something ー Something",
-			"ー",
-			"Something Something",
+			[]string{`artist:"Something" track:"Something"`, "Something Something", `track:"Something"`},
+		},
+		{
+			"should start with year and join artists",
+			`Daft Punk & Pharrell Williams - "Get Lucky" (Official Audio) [Disco] (2013)`,
+			[]string{
+				`artist:"Daft Punk" track:"Get Lucky" year:2013`,
+				`artist:"Daft Punk" track:"Get Lucky"`,
+				"Daft Punk Pharrell Williams Get Lucky",
+				`track:"Get Lucky"`,
+			},
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			sq, err := c.createSearchQuery(tc.title, tc.separator)
+			parsed, err := title.Parse(tc.title)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			queries := searchQueries(parsed)
+			if len(queries) != len(tc.exp) {
+				t.Fatalf("unexpected number of queries: got %d, exp %d", len(queries), len(tc.exp))
+			}
+
+			for i, q := range queries {
+				if q.Query != tc.exp[i] {
+					t.Errorf("unexpected search query: got %s, exp %s, pos %d", q.Query, tc.exp[i], i)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTrackByTitles(t *testing.T) {
+	fake := spotifytest.New("tester")
+	remaster := spotifytest.NewTrack("dreams", "Dreams - 2004 Remaster", "Fleetwood Mac")
+	remaster.Album.ReleaseDate = "2004-03-24"
+	fake.AddTrack(
+		spotifytest.NewTrack("aroundtheworld", "Around the World", "Daft Punk"),
+		remaster,
+		spotifytest.NewTrack("holocene", "Holocene", "Bon Iver"),
+	)
+
+	c := newTestClient(t, fake)
+
+	tests := []struct {
+		n           string
+		m           Music
+		expID       string
+		expStrategy string
+	}{
+		{
+			"should match strictest query",
+			Music{PostTitle: "Daft Punk - Around the World"},
+			"aroundtheworld",
+			StrategyArtistTrack,
+		},
+		{
+			"should fall back when year doesn't match",
+			Music{PostTitle: "Fleetwood Mac - Dreams (1977)"},
+			"dreams",
+			StrategyArtistTrack,
+		},
+		{
+			"should not match misspelled artist",
+			Music{PostTitle: "Bon Ivor - Holocene"},
+			"",
+			"",
+		},
+		{
+			"should find track by media title",
+			Music{PostTitle: "so good", MediaTitle: "Bon Iver - Holocene (Official Video)"},
+			"holocene",
+			StrategyArtistTrack,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			found, err := c.getTrackByTitles(tc.m)
+			if tc.expID == "" {
+				if err == nil {
+					t.Errorf("expected error, got %s", found.Track.ID)
+				}
+
+				return
+			}
+
 			if err != nil {
-				t.Errorf("unpexected error: %s", err)
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if string(found.Track.ID) != tc.expID {
+				t.Errorf("unexpected track: got %s, exp %s", found.Track.ID, tc.expID)
 			}
 
-			if sq != tc.exp {
-				t.Errorf("unexpected search query: got %s, exp %s", sq, tc.exp)
+			if found.Strategy != tc.expStrategy {
+				t.Errorf("unexpected strategy: got %s, exp %s", found.Strategy, tc.expStrategy)
 			}
 		})
 	}
 }
+
+func TestSearchOptions(t *testing.T) {
+	c := newTestClient(t, spotifytest.New("tester"))
+
+	if opt := c.searchOptions(); opt != nil {
+		t.Errorf("unexpected options: %+v", opt)
+	}
+
+	c.searchLimit = 5
+	c.market = "NO"
+
+	opt := c.searchOptions()
+	if opt == nil || *opt.Limit != 5 || *opt.Country != "NO" {
+		t.Errorf("unexpected options: %+v", opt)
+	}
+}
+
+func TestFindTrackByFields(t *testing.T) {
+	fake := spotifytest.New("tester")
+	live := spotifytest.NewTrack("live", "Teardrop", "Massive Attack")
+	live.Album.ID = "glastonbury"
+	live.Album.Name = "Live at Glastonbury"
+	live.Album.Artists = live.Artists
+	live.Duration = 420000
+	studio := spotifytest.NewTrack("studio", "Teardrop", "Massive Attack")
+	studio.Album.ID = "mezzanine"
+	studio.Album.Name = "Mezzanine"
+	studio.Album.Artists = studio.Artists
+	studio.Duration = 330000
+	studio.ExternalIDs = map[string]string{"isrc": "GBAAA9800001"}
+	fake.AddTrack(live, studio)
+	fake.SetAlbumUPC("glastonbury", "0724384561121")
+
+	c := newTestClient(t, fake)
+
+	tests := []struct {
+		n           string
+		m           Music
+		expID       string
+		expStrategy string
+	}{
+		{
+			"should match isrc first",
+			Music{ISRC: "GBAAA9800001", PostTitle: "Massive Attack - Teardrop (live)"},
+			"studio",
+			StrategyISRC,
+		},
+		{
+			"should match title on upc release",
+			Music{UPC: "0724384561121", Title: "Teardrop"},
+			"live",
+			StrategyUPC,
+		},
+		{
+			"should fall back to fields from unknown upc",
+			Music{UPC: "0000000000000", Artist: "Massive Attack", Title: "Teardrop", Album: "Mezzanine"},
+			"studio",
+			StrategyFields,
+		},
+		{
+			"should match fields with album",
+			Music{Artist: "Massive Attack", Title: "Teardrop", Album: "Mezzanine"},
+			"studio",
+			StrategyFields,
+		},
+		{
+			"should match fields within duration",
+			Music{Artist: "Massive Attack", Title: "Teardrop", Duration: 418 * time.Second},
+			"live",
+			StrategyFields,
+		},
+		{
+			"should fall back to fields from unknown isrc",
+			Music{ISRC: "GBAAA0000000", Artist: "Massive Attack", Title: "Teardrop"},
+			"live",
+			StrategyFields,
+		},
+		{
+			"should fall back to titles",
+			Music{Artist: "Massive Attack", Title: "Teardrop", Duration: time.Minute, PostTitle: "Massive Attack - Teardrop"},
+			"live",
+			StrategyArtistTrack,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			found, err := c.findTrack(tc.m)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if string(found.Track.ID) != tc.expID {
+				t.Errorf("unexpected track: got %s, exp %s", found.Track.ID, tc.expID)
+			}
+
+			if found.Strategy != tc.expStrategy {
+				t.Errorf("unexpected strategy: got %s, exp %s", found.Strategy, tc.expStrategy)
+			}
+		})
+	}
+}
+
+func TestCacheTrack(t *testing.T) {
+	track := spotifytest.NewTrack("track1", "Around the World", "Daft Punk")
+	track.Album.ID = "homework"
+	track.Album.Name = "Homework"
+	track.Album.ReleaseDate = "1997-01-20"
+	track.ExternalIDs = map[string]string{"isrc": "GBDUW9700004"}
+	track.AvailableMarkets = []string{"NO", "SE"}
+
+	c := newTestClient(t, spotifytest.New("tester"))
+	c.market = "NO"
+
+	got := fromCacheTrack(c.toCacheTrack(track))
+
+	if got.Artists[0].ID != "daftpunk" || got.Album.ID != "homework" || got.Album.Name != "Homework" {
+		t.Errorf("unexpected artist or album: %+v, %+v", got.Artists, got.Album)
+	}
+
+	if got.ExternalIDs["isrc"] != "GBDUW9700004" {
+		t.Errorf("unexpected value: got %s, exp %s", got.ExternalIDs["isrc"], "GBDUW9700004")
+	}
+
+	if available(got, "DK") {
+		t.Errorf("unexpected availability in market DK")
+	}
+}
@@ -2,47 +2,124 @@
 package spotify
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"sync"
 	"time"
 
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/cache"
 	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/history"
 	"github.com/pkg/browser"
 	log "github.com/sirupsen/logrus"
 	"github.com/zmb3/spotify"
 )
 
+// API is the part of the Spotify Web API used by dissic. It's implemented
+// by the spotify.Client from github.com/zmb3/spotify, extended by webAPI,
+// and by the fake in the spotifytest package.
+type API interface {
+	CurrentUser() (*spotify.PrivateUser, error)
+	GetPlaylist(playlistID spotify.ID) (*spotify.FullPlaylist, error)
+	GetPlaylistsForUser(userID string) (*spotify.SimplePlaylistPage, error)
+	CreatePlaylistForUser(userID, playlistName, description string, public bool) (*spotify.FullPlaylist, error)
+	GetPlaylistTracks(playlistID spotify.ID) (*spotify.PlaylistTrackPage, error)
+	GetPlaylistTracksOpt(playlistID spotify.ID, opt *spotify.Options, fields string) (*spotify.PlaylistTrackPage, error)
+	ChangePlaylistName(playlistID spotify.ID, newName string) error
+	ChangePlaylistDescription(playlistID spotify.ID, newDescription string) error
+	ChangePlaylistAccess(playlistID spotify.ID, public bool) error
+	ChangePlaylistCollaborative(playlistID spotify.ID, collaborative bool) error
+	SetPlaylistImage(playlistID spotify.ID, img io.Reader) error
+	ReplacePlaylistTracks(playlistID spotify.ID, trackIDs ...spotify.ID) error
+	ReorderPlaylistTracks(playlistID spotify.ID, opt spotify.PlaylistReorderOptions) (string, error)
+	AddTracksToPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (string, error)
+	RemoveTracksFromPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (string, error)
+	GetTrack(id spotify.ID) (*spotify.FullTrack, error)
+	GetTracks(ids ...spotify.ID) ([]*spotify.FullTrack, error)
+	SearchOpt(query string, t spotify.SearchType, opt *spotify.Options) (*spotify.SearchResult, error)
+	GetAudioFeatures(ids ...spotify.ID) ([]*spotify.AudioFeatures, error)
+}
+
 // Client is the spotify client
 type Client struct {
-	spotify.Client
 	Auth              spotify.Authenticator
 	AuthURL           string
 	Session           string
 	AuthChan          chan bool
-	MusicChan         chan Music
-	Spotify           spotify.Client
-	SubredditPlaylist map[string]spotify.ID
+	Spotify           API
+	SubredditPlaylist map[string][]spotify.ID
+	PlaylistIDs       map[string]spotify.ID
+	PlaylistFilters   map[spotify.ID]config.Filters
 	User              *spotify.PrivateUser
+	Activity          *activity.Log
+	Cache             *cache.Cache
+	History           *history.Store
+	RunID             string
 	Logger            *log.Entry
+
+	mu             sync.RWMutex
+	playlistDelay  time.Duration
+	searchLimit    int
+	market         string
+	block          lists
+	allow          lists
+	playlistBlock  map[spotify.ID]lists
+	playlistAllow  map[spotify.ID]lists
+	playlistDedupe map[spotify.ID]dedupe
+	playlistOrder  map[spotify.ID]string
+}
+
+// match is a track found for a post and the strategy that found it.
+type match struct {
+	Track    spotify.FullTrack
+	Strategy string
 }
 
 // New sets up a new spotify client. It takes the configuration and returns
 // a client or an error.
 func New(cfg *config.Config) (*Client, error) {
 	callbackURL := fmt.Sprintf("http://localhost:%d/spotifyAuth", cfg.HTTPPort)
-	auth := spotify.NewAuthenticator(callbackURL, spotify.ScopePlaylistReadPrivate, spotify.ScopePlaylistReadPrivate, spotify.ScopePlaylistModifyPrivate, spotify.ScopePlaylistModifyPublic)
+	auth := spotify.NewAuthenticator(callbackURL, spotify.ScopePlaylistReadPrivate, spotify.ScopePlaylistReadPrivate, spotify.ScopePlaylistModifyPrivate, spotify.ScopePlaylistModifyPublic, spotify.ScopeUserReadPrivate, spotify.ScopeImageUpload)
 
 	c := Client{
-		Auth:      auth,
-		Session:   fmt.Sprintf("dissic:%d", time.Now().Unix()),
-		AuthChan:  make(chan bool),
-		MusicChan: make(chan Music),
-		Logger:    log.WithFields(log.Fields{"service": "spotify"}),
+		Auth:     auth,
+		Session:  fmt.Sprintf("dissic:%d", time.Now().Unix()),
+		AuthChan: make(chan bool),
+		Activity: activity.NewLog(100),
+		Logger:   log.WithFields(log.Fields{"service": "spotify"}),
+
+		playlistDelay: 1 * time.Second,
+		searchLimit:   cfg.Spotify.SearchLimit,
+		market:        cfg.Spotify.Market,
 	}
 
+	searchCache, err := cache.Load(
+		cfg.Spotify.CacheFile,
+		time.Duration(cfg.Spotify.CacheTTL)*time.Hour,
+		time.Duration(cfg.Spotify.UnmatchedTTL)*time.Hour,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading cache: %w", err)
+	}
+
+	c.Cache = searchCache
+	c.SetLists(cfg.Block, cfg.Allow)
+
+	h, err := history.Open(cfg.HistoryFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening history: %w", err)
+	}
+
+	c.History = h
+	c.RunID = time.Now().UTC().Format("20060102T150405")
+	c.History.SetRunID(c.RunID)
+
 	c.Auth.SetAuthInfo(cfg.Spotify.ClientID, cfg.Spotify.ClientSecret)
 	c.AuthURL = c.Auth.AuthURL(c.Session)
 
-	c.Logger.Infoln("client setup ok")
+	c.Logger.Infof("client setup ok, run id: %s", c.RunID)
 
 	return &c, nil
 }
@@ -65,11 +142,10 @@ func (c *Client) Authenticate(openBrowser bool) error {
 	return nil
 }
 
-// Listen listens for incoming data on the music channel.
-func (c *Client) Listen() {
-	for m := range c.MusicChan {
-		c.handle(m)
-	}
+// Handle finds the track a post is about and adds it to the playlists
+// the subreddit feeds.
+func (c *Client) Handle(m Music) {
+	c.handle(m)
 }
 
 func (c *Client) handle(m Music) {
@@ -77,35 +153,255 @@ func (c *Client) handle(m Music) {
 		return
 	}
 
+	defer c.saveCache()
+
+	key := m.cacheKey()
+
+	if c.Cache.IsUnmatched(key) {
+		c.Logger.Infof("\tskipping previously unmatched title: %s", m.PostTitle)
+		c.Activity.Add(activity.Entry{
+			Subreddit: m.Subreddit,
+			Title:     m.PostTitle,
+			Status:    activity.StatusUnmatched,
+			Message:   "previously unmatched",
+		})
+
+		return
+	}
+
+	found, err := c.findTrack(m)
+	if err != nil {
+		// a failed request may succeed when the post is seen again
+		if !errors.Is(err, errRequest) {
+			c.Cache.AddUnmatched(key)
+		}
+
+		c.Logger.Infof("\ttrack by title: %s", err)
+		c.Activity.Add(activity.Entry{
+			Subreddit: m.Subreddit,
+			Title:     m.PostTitle,
+			Status:    activity.StatusUnmatched,
+			Message:   err.Error(),
+		})
+
+		return
+	}
+
+	c.addMatched(m, found)
+	c.addToPlaylists(m, found)
+}
+
+// findTrack finds the track a post is about, preferring a Spotify link,
+// then the ISRC, the UPC and the structured fields over searching by the
+// titles.
+// If the track isn't found and a request failed on the way, the error
+// wraps errRequest.
+func (c *Client) findTrack(m Music) (match, error) {
+	var failed bool
+
 	if m.URL != "" {
 		track, err := c.getTrackByURL(m.URL)
 		if err != nil {
 			c.Logger.Infof("\ttrack by url: %s", err)
+			failed = errors.Is(err, errRequest)
 		}
 
 		if track != nil {
-			if err := c.addToPlaylist(m.Subreddit, track.ID); err != nil {
-				c.Logger.Infof("\tadding track to playlist: %s", err)
+			playable, err := c.playable(*track)
+			if err == nil {
+				return match{Track: *playable, Strategy: StrategyURL}, nil
 			}
 
-			return
+			c.Logger.Infof("\tskipping track %s: %s", track.ID, err)
+			failed = errors.Is(err, errRequest)
 		}
 	}
 
-	track, err := c.getTrackByTitles(m)
-	if err != nil {
-		c.Logger.Infof("\ttrack by title: %s", err)
+	if m.ISRC != "" {
+		found, err := c.getTrackByISRC(m.ISRC)
+		if err == nil {
+			return found, nil
+		}
+
+		c.Logger.Infof("\ttrack by isrc: %s", err)
+		failed = failed || errors.Is(err, errRequest)
+	}
+
+	if m.UPC != "" && (m.ISRC != "" || m.Title != "") {
+		found, err := c.getTrackByUPC(m)
+		if err == nil {
+			return found, nil
+		}
+
+		c.Logger.Infof("\ttrack by upc: %s", err)
+		failed = failed || errors.Is(err, errRequest)
+	}
+
+	if m.Artist != "" && m.Title != "" {
+		found, err := c.getTrackByFields(m)
+		if err == nil {
+			return found, nil
+		}
+
+		c.Logger.Infof("\ttrack by fields: %s", err)
+		failed = failed || errors.Is(err, errRequest)
+	}
+
+	found, err := c.getTrackByTitles(m)
+	if err != nil && failed && !errors.Is(err, errRequest) {
+		return match{}, fmt.Errorf("%s: %w", err, errRequest)
+	}
+
+	return found, err
+}
+
+func (c *Client) addToPlaylists(m Music, found match) {
+	c.mu.RLock()
+	playlistIDs := c.SubredditPlaylist[m.Subreddit]
+	c.mu.RUnlock()
+
+	if len(playlistIDs) == 0 {
+		c.Logger.Infof("\tno playlist found for subreddit: %s", m.Subreddit)
 		return
 	}
 
-	if err := c.addToPlaylist(m.Subreddit, track.ID); err != nil {
-		c.Logger.Infof("\tadding track to playlist: %s", err)
+	c.addToPlaylistIDs(m, found, playlistIDs)
+}
+
+// addToPlaylistIDs adds a track found for a post to the playlists it
+// isn't kept out of by suppression, lists, filters or dedupe. It returns
+// the activity entry for each playlist.
+func (c *Client) addToPlaylistIDs(m Music, found match, playlistIDs []spotify.ID) []activity.Entry {
+	trackID := found.Track.ID
+
+	c.mu.RLock()
+	filters := c.PlaylistFilters
+	c.mu.RUnlock()
+
+	features := c.audioFeatures(trackID)
+	entries := make([]activity.Entry, 0, len(playlistIDs))
+	added := make(map[spotify.ID]bool)
+
+	for _, playlistID := range playlistIDs {
+		e := activity.Entry{
+			Subreddit: m.Subreddit,
+			Title:     m.PostTitle,
+			Playlist:  string(playlistID),
+			TrackID:   string(trackID),
+			Strategy:  found.Strategy,
+			Status:    activity.StatusAdded,
+		}
+
+		suppressed := c.suppressedReason(playlistID, trackID)
+
+		var reason string
+		if suppressed == "" {
+			reason = c.listsReason(playlistID, m, found.Track)
+		}
+
+		var err error
+		if suppressed == "" && reason == "" {
+			reason, err = rejectReason(filters[playlistID], found.Track, features)
+		}
+
+		var duplicate string
+		var moveFrom []spotify.ID
+		if suppressed == "" && reason == "" && err == nil {
+			duplicate, moveFrom = c.dedupe(playlistID, trackID, added)
+		}
+
+		switch {
+		case suppressed != "":
+			c.Logger.Infof("\tskipped track %s for playlist %s: %s", trackID, playlistID, suppressed)
+			e.Status = activity.StatusSkipped
+			e.Message = suppressed
+			c.addHistory(e, history.StatusSkipped)
+		case err != nil:
+			c.Logger.Infof("\tchecking playlist filters: %s", err)
+			e.Status = activity.StatusFailed
+			e.Message = err.Error()
+		case reason != "":
+			c.Logger.Infof("\trejected track %s for playlist %s: %s", trackID, playlistID, reason)
+			e.Status = activity.StatusRejected
+			e.Message = reason
+			c.addHistory(e, history.StatusRejected)
+		case duplicate != "":
+			c.Logger.Infof("\tskipped track %s for playlist %s: %s", trackID, playlistID, duplicate)
+			e.Status = activity.StatusSkipped
+			e.Message = duplicate
+			c.addHistory(e, history.StatusSkipped)
+		default:
+			snapshotID, err := c.addToPlaylist(playlistID, trackID)
+			if err != nil {
+				c.Logger.Infof("\tadding track to playlist: %s", err)
+				e.Status = activity.StatusFailed
+				e.Message = err.Error()
+				break
+			}
+
+			added[playlistID] = true
+
+			// the track is only moved once it's in the playlist, so a
+			// failed add never loses it
+			if len(moveFrom) > 0 {
+				e.Message = fmt.Sprintf("moved from playlist %s", joinIDs(moveFrom))
+
+				if err := c.move(c.historyEntry(e, ""), moveFrom, playlistID); err != nil {
+					c.Logger.Infof("\tmoving track to playlist: %s", err)
+					e.Message = fmt.Sprintf("not moved from playlist %s: %s", joinIDs(moveFrom), err)
+				}
+			}
+
+			he := c.historyEntry(e, history.StatusAdded)
+			he.SnapshotID = snapshotID
+			if err := c.History.Add(he); err != nil {
+				c.Logger.Errorf("adding history: %s", err)
+			}
+		}
+
+		c.Activity.Add(e)
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+func (c *Client) addHistory(e activity.Entry, status string) {
+	if err := c.History.Add(c.historyEntry(e, status)); err != nil {
+		c.Logger.Errorf("adding history: %s", err)
+	}
+}
+
+func (c *Client) historyEntry(e activity.Entry, status string) history.Entry {
+	return history.Entry{
+		Subreddit: e.Subreddit,
+		Title:     e.Title,
+		Playlist:  e.Playlist,
+		TrackID:   e.TrackID,
+		Status:    status,
+		Reason:    e.Message,
+	}
+}
+
+// RecentActivity returns up to limit of the most recent activity entries.
+func (c *Client) RecentActivity(limit int) []activity.Entry {
+	return c.Activity.Recent(limit)
+}
+
+// CacheStats returns the search cache hit and miss counts.
+func (c *Client) CacheStats() cache.Stats {
+	return c.Cache.Stats()
+}
+
+func (c *Client) saveCache() {
+	if err := c.Cache.Save(); err != nil {
+		c.Logger.Errorf("saving cache: %s", err)
 	}
 }
 
 // Close properly closes the Spotify client
 func (c *Client) Close() {
 	c.Logger.Infoln("shutting down")
+	c.saveCache()
 	close(c.AuthChan)
-	close(c.MusicChan)
 }
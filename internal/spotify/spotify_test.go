@@ -0,0 +1,183 @@
+package spotify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/engvik/dissic/internal/activity"
+	"github.com/engvik/dissic/internal/cache"
+	"github.com/engvik/dissic/internal/config"
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+	"github.com/zmb3/spotify"
+)
+
+func newTestClient(t *testing.T, api API) *Client {
+	t.Helper()
+
+	var cfg config.Config
+
+	c, err := New(&cfg)
+	if err != nil {
+		t.Fatalf("error setting up test client: %s", err)
+	}
+
+	c.Spotify = api
+	c.playlistDelay = 0
+
+	if err := c.SetUser(); err != nil {
+		t.Fatalf("error setting up test user: %s", err)
+	}
+
+	return c
+}
+
+func TestHandle(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(
+		spotifytest.NewTrack("track1", "Around the World", "Daft Punk"),
+		spotifytest.NewTrack("track2", "Windowlicker", "Aphex Twin"),
+	)
+	musicID := fake.AddPlaylist("tester", "music")
+	electronicID := fake.AddPlaylist("tester", "electronic")
+
+	c := newTestClient(t, fake)
+	c.SubredditPlaylist = map[string][]spotify.ID{
+		"music":      {musicID},
+		"electronic": {musicID, electronicID},
+	}
+
+	tests := []struct {
+		n         string
+		m         Music
+		expStatus string
+		expTracks map[spotify.ID][]spotify.ID
+	}{
+		{
+			"should add track found by url",
+			Music{Subreddit: "music", PostTitle: "Check this out", URL: "https://open.spotify.com/track/track2"},
+			activity.StatusAdded,
+			map[spotify.ID][]spotify.ID{musicID: {"track2"}},
+		},
+		{
+			"should add track found by title",
+			Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World [House] (1997)"},
+			activity.StatusAdded,
+			map[spotify.ID][]spotify.ID{musicID: {"track2", "track1"}},
+		},
+		{
+			"should not add duplicate track",
+			Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World"},
+			activity.StatusFailed,
+			map[spotify.ID][]spotify.ID{musicID: {"track2", "track1"}},
+		},
+		{
+			"should add track to every playlist of the subreddit",
+			Music{Subreddit: "electronic", PostTitle: "Aphex Twin - Windowlicker"},
+			activity.StatusAdded,
+			map[spotify.ID][]spotify.ID{musicID: {"track2", "track1"}, electronicID: {"track2"}},
+		},
+		{
+			"should not add unmatched track",
+			Music{Subreddit: "music", PostTitle: "Unknown Artist - Unknown Song"},
+			activity.StatusUnmatched,
+			map[spotify.ID][]spotify.ID{musicID: {"track2", "track1"}, electronicID: {"track2"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			c.handle(tc.m)
+
+			entries := c.RecentActivity(1)
+			if len(entries) != 1 || entries[0].Status != tc.expStatus {
+				t.Errorf("unexpected activity: got %+v, exp status %s", entries, tc.expStatus)
+			}
+
+			for playlistID, exp := range tc.expTracks {
+				ids := fake.PlaylistTrackIDs(playlistID)
+
+				if len(ids) != len(exp) {
+					t.Fatalf("unexpected playlist length: got %v, exp %v", ids, exp)
+				}
+
+				for i, id := range ids {
+					if id != exp[i] {
+						t.Errorf("unexpected value: got %s, exp %s, pos %d", id, exp[i], i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCache(t *testing.T) {
+	fake := spotifytest.New("tester")
+	fake.AddTrack(spotifytest.NewTrack("track1", "Around the World", "Daft Punk"))
+	musicID := fake.AddPlaylist("tester", "music")
+	jazzID := fake.AddPlaylist("tester", "jazz")
+
+	c := newTestClient(t, fake)
+	c.SubredditPlaylist = map[string][]spotify.ID{"music": {musicID}, "jazz": {jazzID}}
+
+	searchCache, err := cache.Load("", time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("error setting up cache: %s", err)
+	}
+	c.Cache = searchCache
+
+	t.Run("should reuse search results across subreddits", func(t *testing.T) {
+		c.handle(Music{Subreddit: "music", PostTitle: "Daft Punk - Around the World"})
+		searches := len(fake.Searches())
+
+		c.handle(Music{Subreddit: "jazz", PostTitle: "Daft Punk - Around the World (Official Video)"})
+
+		if n := len(fake.Searches()); n != searches {
+			t.Errorf("unexpected searches: got %d, exp %d", n, searches)
+		}
+
+		if ids := fake.PlaylistTrackIDs(jazzID); len(ids) != 1 || ids[0] != "track1" {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should skip previously unmatched titles", func(t *testing.T) {
+		c.handle(Music{Subreddit: "music", PostTitle: "Unknown Artist - Unknown Song"})
+		searches := len(fake.Searches())
+
+		c.handle(Music{Subreddit: "jazz", PostTitle: "Unknown Artist - Unknown Song"})
+
+		if n := len(fake.Searches()); n != searches {
+			t.Errorf("unexpected searches: got %d, exp %d", n, searches)
+		}
+
+		if stats := c.CacheStats(); stats.UnmatchedHits != 1 || stats.Hits == 0 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("should try the link of a post with a previously unmatched title", func(t *testing.T) {
+		fake.AddTrack(spotifytest.NewTrack("track2", "Unknown Song", "Someone Else"))
+
+		c.handle(Music{Subreddit: "music", PostTitle: "Unknown Artist - Unknown Song", URL: "https://open.spotify.com/track/track2"})
+
+		if ids := fake.PlaylistTrackIDs(musicID); len(ids) != 2 || ids[1] != "track2" {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should not cache titles unmatched because a search failed", func(t *testing.T) {
+		fake.FailSearches(1)
+		c.handle(Music{Subreddit: "music", PostTitle: "Missing Artist - Missing Song"})
+
+		searches := len(fake.Searches())
+		c.handle(Music{Subreddit: "music", PostTitle: "Missing Artist - Missing Song"})
+
+		if n := len(fake.Searches()); n == searches {
+			t.Errorf("unexpected searches: got %d, exp more than %d", n, searches)
+		}
+
+		if stats := c.CacheStats(); stats.UnmatchedHits != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+	})
+}
@@ -0,0 +1,705 @@
+// Package spotifytest provides an in-memory fake of the parts of the Spotify
+// Web API used by dissic, and an httptest server serving the fake, so the
+// spotify package can be tested without network access.
+package spotifytest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+// defaultSearchLimit is the number of search results returned by Spotify
+// when no limit is given.
+const defaultSearchLimit = 20
+
+// addedAtBase is the time the fake clock starts at. Every change to a
+// playlist advances it by a minute, so tracks are added in order.
+var addedAtBase = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// defaultPlaylistTracksLimit is the number of playlist tracks returned by
+// Spotify when no limit is given.
+const defaultPlaylistTracksLimit = 100
+
+// Fake is an in-memory Spotify with a catalog of tracks, users and playlists.
+// It's safe for concurrent use.
+type Fake struct {
+	mu            sync.Mutex
+	user          spotify.PrivateUser
+	tracks        map[spotify.ID]spotify.FullTrack
+	features      map[spotify.ID]spotify.AudioFeatures
+	catalog       []spotify.ID
+	playlists     map[spotify.ID]*spotify.FullPlaylist
+	playlistOrder []spotify.ID
+	searches      []string
+	failSearches  int
+	snapshots     int
+	images        map[spotify.ID][]byte
+	upcs          map[spotify.ID]string
+}
+
+// New returns an empty fake where userID is the authenticated user.
+func New(userID string) *Fake {
+	f := Fake{
+		tracks:    make(map[spotify.ID]spotify.FullTrack),
+		features:  make(map[spotify.ID]spotify.AudioFeatures),
+		playlists: make(map[spotify.ID]*spotify.FullPlaylist),
+		images:    make(map[spotify.ID][]byte),
+		upcs:      make(map[spotify.ID]string),
+	}
+
+	f.user.ID = userID
+	f.user.DisplayName = userID
+
+	return &f
+}
+
+// NewTrack is a helper for creating a catalog track.
+func NewTrack(id string, name string, artists ...string) spotify.FullTrack {
+	var t spotify.FullTrack
+
+	t.ID = spotify.ID(id)
+	t.Name = name
+	t.URI = spotify.URI("spotify:track:" + id)
+
+	for _, a := range artists {
+		t.Artists = append(t.Artists, spotify.SimpleArtist{
+			Name: a,
+			ID:   spotify.ID(strings.ToLower(strings.ReplaceAll(a, " ", ""))),
+		})
+	}
+
+	return t
+}
+
+// SetCountry sets the country of the authenticated user.
+func (f *Fake) SetCountry(country string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.user.Country = country
+}
+
+// AddTrack adds tracks to the catalog.
+func (f *Fake) AddTrack(tracks ...spotify.FullTrack) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range tracks {
+		if _, ok := f.tracks[t.ID]; !ok {
+			f.catalog = append(f.catalog, t.ID)
+		}
+
+		f.tracks[t.ID] = t
+	}
+}
+
+// SetAlbumUPC sets the UPC of the album of catalog tracks, for searching
+// albums by it.
+func (f *Fake) SetAlbumUPC(albumID spotify.ID, upc string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.upcs[albumID] = upc
+}
+
+// SetAudioFeatures sets the audio features of a catalog track.
+func (f *Fake) SetAudioFeatures(features spotify.AudioFeatures) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.features[features.ID] = features
+}
+
+// AddPlaylist creates a playlist owned by owner containing the given tracks,
+// and returns its ID.
+func (f *Fake) AddPlaylist(owner string, name string, trackIDs ...spotify.ID) spotify.ID {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := f.createPlaylist(owner, name, "", false)
+
+	for _, id := range trackIDs {
+		f.appendTrack(p, id)
+	}
+
+	return p.ID
+}
+
+// PlaylistTrackIDs returns the IDs of the tracks in a playlist, in order.
+func (f *Fake) PlaylistTrackIDs(playlistID spotify.ID) []spotify.ID {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return nil
+	}
+
+	ids := make([]spotify.ID, 0, len(p.Tracks.Tracks))
+	for _, t := range p.Tracks.Tracks {
+		ids = append(ids, t.Track.ID)
+	}
+
+	return ids
+}
+
+// PlaylistByName returns the first playlist with the given name, or nil.
+func (f *Fake) PlaylistByName(name string) *spotify.FullPlaylist {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range f.playlistOrder {
+		if f.playlists[id].Name == name {
+			p := *f.playlists[id]
+			return &p
+		}
+	}
+
+	return nil
+}
+
+// Searches returns the search queries received, in order.
+func (f *Fake) Searches() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.searches...)
+}
+
+// CurrentUser returns the authenticated user.
+func (f *Fake) CurrentUser() (*spotify.PrivateUser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	u := f.user
+
+	return &u, nil
+}
+
+// GetPlaylist returns a playlist with its tracks.
+func (f *Fake) GetPlaylist(playlistID spotify.ID) (*spotify.FullPlaylist, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return nil, notFound("Invalid playlist Id")
+	}
+
+	cp := *p
+	cp.Tracks.Tracks = append([]spotify.PlaylistTrack(nil), p.Tracks.Tracks...)
+
+	return &cp, nil
+}
+
+// GetPlaylistsForUser returns the playlists owned by a user.
+func (f *Fake) GetPlaylistsForUser(userID string) (*spotify.SimplePlaylistPage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var page spotify.SimplePlaylistPage
+
+	for _, id := range f.playlistOrder {
+		p := f.playlists[id]
+		if p.Owner.ID == userID {
+			page.Playlists = append(page.Playlists, p.SimplePlaylist)
+		}
+	}
+
+	page.Total = len(page.Playlists)
+	page.Limit = len(page.Playlists)
+
+	return &page, nil
+}
+
+// CreatePlaylistForUser creates an empty playlist.
+func (f *Fake) CreatePlaylistForUser(userID, playlistName, description string, public bool) (*spotify.FullPlaylist, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := *f.createPlaylist(userID, playlistName, description, public)
+
+	return &p, nil
+}
+
+// GetPlaylistTracks returns the first page of tracks of a playlist.
+func (f *Fake) GetPlaylistTracks(playlistID spotify.ID) (*spotify.PlaylistTrackPage, error) {
+	return f.GetPlaylistTracksOpt(playlistID, nil, "")
+}
+
+// GetPlaylistTracksOpt returns a page of tracks of a playlist. The limit
+// and offset options are supported, fields is ignored.
+func (f *Fake) GetPlaylistTracksOpt(playlistID spotify.ID, opt *spotify.Options, fields string) (*spotify.PlaylistTrackPage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return nil, notFound("Invalid playlist Id")
+	}
+
+	limit, offset := defaultPlaylistTracksLimit, 0
+
+	if opt != nil && opt.Limit != nil {
+		limit = *opt.Limit
+	}
+
+	if opt != nil && opt.Offset != nil {
+		offset = *opt.Offset
+	}
+
+	tracks := p.Tracks.Tracks
+	if offset > len(tracks) {
+		offset = len(tracks)
+	}
+
+	tracks = tracks[offset:]
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
+	var page spotify.PlaylistTrackPage
+	page.Tracks = append(page.Tracks, tracks...)
+	page.Total = len(p.Tracks.Tracks)
+	page.Limit = limit
+	page.Offset = offset
+
+	return &page, nil
+}
+
+// ReorderPlaylistTracks moves a range of tracks in a playlist. When a
+// snapshot ID is given, it must be the current one, so changes based on
+// an outdated playlist fail.
+func (f *Fake) ReorderPlaylistTracks(playlistID spotify.ID, opt spotify.PlaylistReorderOptions) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return "", notFound("Invalid playlist Id")
+	}
+
+	if opt.SnapshotID != "" && opt.SnapshotID != p.SnapshotID {
+		return "", spotify.Error{Status: http.StatusBadRequest, Message: "Invalid snapshot id"}
+	}
+
+	length := opt.RangeLength
+	if length == 0 {
+		length = 1
+	}
+
+	tracks := p.Tracks.Tracks
+	if opt.RangeStart < 0 || opt.RangeStart+length > len(tracks) || opt.InsertBefore < 0 || opt.InsertBefore > len(tracks) {
+		return "", spotify.Error{Status: http.StatusBadRequest, Message: "Index out of bounds"}
+	}
+
+	moved := append([]spotify.PlaylistTrack(nil), tracks[opt.RangeStart:opt.RangeStart+length]...)
+	rest := append(append([]spotify.PlaylistTrack(nil), tracks[:opt.RangeStart]...), tracks[opt.RangeStart+length:]...)
+
+	insert := opt.InsertBefore
+	if insert > opt.RangeStart {
+		insert -= length
+	}
+
+	if insert < 0 {
+		insert = 0
+	}
+
+	reordered := append(append(append([]spotify.PlaylistTrack(nil), rest[:insert]...), moved...), rest[insert:]...)
+
+	p.Tracks.Tracks = reordered
+	p.SnapshotID = f.nextSnapshot()
+
+	return p.SnapshotID, nil
+}
+
+// ChangePlaylistName renames a playlist.
+func (f *Fake) ChangePlaylistName(playlistID spotify.ID, newName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return notFound("Invalid playlist Id")
+	}
+
+	p.Name = newName
+
+	return nil
+}
+
+// ChangePlaylistDescription changes the description of a playlist.
+func (f *Fake) ChangePlaylistDescription(playlistID spotify.ID, newDescription string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return notFound("Invalid playlist Id")
+	}
+
+	p.Description = newDescription
+
+	return nil
+}
+
+// ChangePlaylistAccess makes a playlist public or private.
+func (f *Fake) ChangePlaylistAccess(playlistID spotify.ID, public bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return notFound("Invalid playlist Id")
+	}
+
+	if public && p.Collaborative {
+		return spotify.Error{Status: http.StatusBadRequest, Message: "Collaborative playlists can't be public"}
+	}
+
+	p.IsPublic = public
+
+	return nil
+}
+
+// ChangePlaylistCollaborative makes a playlist collaborative or not.
+// Collaborative playlists are made private.
+func (f *Fake) ChangePlaylistCollaborative(playlistID spotify.ID, collaborative bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return notFound("Invalid playlist Id")
+	}
+
+	p.Collaborative = collaborative
+	if collaborative {
+		p.IsPublic = false
+	}
+
+	return nil
+}
+
+// SetPlaylistImage sets the cover image of a playlist.
+func (f *Fake) SetPlaylistImage(playlistID spotify.ID, img io.Reader) error {
+	data, err := ioutil.ReadAll(img)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return notFound("Invalid playlist Id")
+	}
+
+	f.images[playlistID] = data
+	p.Images = []spotify.Image{{URL: fmt.Sprintf("https://i.scdn.co/image/%s", playlistID)}}
+
+	return nil
+}
+
+// PlaylistImage returns the cover image uploaded for a playlist.
+func (f *Fake) PlaylistImage(playlistID spotify.ID) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.images[playlistID]
+}
+
+// ReplacePlaylistTracks replaces the tracks of a playlist with catalog
+// tracks. No tracks clears the playlist.
+func (f *Fake) ReplacePlaylistTracks(playlistID spotify.ID, trackIDs ...spotify.ID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return notFound("Invalid playlist Id")
+	}
+
+	if len(trackIDs) > 100 {
+		return spotify.Error{Status: http.StatusBadRequest, Message: "Too many ids requested"}
+	}
+
+	for _, id := range trackIDs {
+		if _, ok := f.tracks[id]; !ok {
+			return spotify.Error{Status: http.StatusBadRequest, Message: "Invalid track uri: spotify:track:" + string(id)}
+		}
+	}
+
+	p.Tracks.Tracks = nil
+	for _, id := range trackIDs {
+		f.appendTrack(p, id)
+	}
+
+	p.Tracks.Total = len(p.Tracks.Tracks)
+	p.SimplePlaylist.Tracks.Total = uint(len(p.Tracks.Tracks))
+	p.SnapshotID = f.nextSnapshot()
+
+	return nil
+}
+
+// AddTracksToPlaylist appends catalog tracks to a playlist.
+func (f *Fake) AddTracksToPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return "", notFound("Invalid playlist Id")
+	}
+
+	if len(trackIDs) > 100 {
+		return "", spotify.Error{Status: http.StatusBadRequest, Message: "Too many ids requested"}
+	}
+
+	for _, id := range trackIDs {
+		if _, ok := f.tracks[id]; !ok {
+			return "", spotify.Error{Status: http.StatusBadRequest, Message: "Invalid track uri: spotify:track:" + string(id)}
+		}
+	}
+
+	for _, id := range trackIDs {
+		f.appendTrack(p, id)
+	}
+
+	return p.SnapshotID, nil
+}
+
+// RemoveTracksFromPlaylist removes every occurrence of the tracks from a
+// playlist.
+func (f *Fake) RemoveTracksFromPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.playlists[playlistID]
+	if !ok {
+		return "", notFound("Invalid playlist Id")
+	}
+
+	remove := make(map[spotify.ID]bool, len(trackIDs))
+	for _, id := range trackIDs {
+		remove[id] = true
+	}
+
+	tracks := p.Tracks.Tracks[:0]
+	for _, t := range p.Tracks.Tracks {
+		if !remove[t.Track.ID] {
+			tracks = append(tracks, t)
+		}
+	}
+
+	p.Tracks.Tracks = tracks
+	p.Tracks.Total = len(p.Tracks.Tracks)
+	p.SimplePlaylist.Tracks.Total = uint(len(p.Tracks.Tracks))
+	p.SnapshotID = f.nextSnapshot()
+
+	return p.SnapshotID, nil
+}
+
+// GetTrack returns a catalog track.
+func (f *Fake) GetTrack(id spotify.ID) (*spotify.FullTrack, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tracks[id]
+	if !ok {
+		return nil, spotify.Error{Status: http.StatusBadRequest, Message: "invalid id"}
+	}
+
+	return &t, nil
+}
+
+// GetTracks returns catalog tracks, with nil for unknown IDs.
+func (f *Fake) GetTracks(ids ...spotify.ID) ([]*spotify.FullTrack, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(ids) > 50 {
+		return nil, spotify.Error{Status: http.StatusBadRequest, Message: "Too many ids requested"}
+	}
+
+	res := make([]*spotify.FullTrack, len(ids))
+	for i, id := range ids {
+		if t, ok := f.tracks[id]; ok {
+			res[i] = &t
+		}
+	}
+
+	return res, nil
+}
+
+// GetAudioFeatures returns the audio features of tracks, with nil for
+// tracks without any.
+func (f *Fake) GetAudioFeatures(ids ...spotify.ID) ([]*spotify.AudioFeatures, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	res := make([]*spotify.AudioFeatures, len(ids))
+
+	for i, id := range ids {
+		if af, ok := f.features[id]; ok {
+			res[i] = &af
+		}
+	}
+
+	return res, nil
+}
+
+// FailSearches makes the next n searches fail as rate limited.
+func (f *Fake) FailSearches(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failSearches = n
+}
+
+// Search searches the catalog for tracks with the default options.
+func (f *Fake) Search(query string, t spotify.SearchType) (*spotify.SearchResult, error) {
+	return f.SearchOpt(query, t, nil)
+}
+
+// SearchOpt searches the catalog for tracks, and the albums of the catalog
+// tracks. Plain words must all be found in the track name, artists or
+// album. The artist, track, album, isrc and year field filters are
+// supported for tracks, and the artist, album and upc filters for albums,
+// as are the limit and offset options. With a country given, tracks not
+// available there are left out.
+func (f *Fake) SearchOpt(query string, t spotify.SearchType, opt *spotify.Options) (*spotify.SearchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.searches = append(f.searches, query)
+
+	if f.failSearches > 0 {
+		f.failSearches--
+		return nil, spotify.Error{Message: "API rate limit exceeded", Status: http.StatusTooManyRequests}
+	}
+
+	q := parseQuery(query)
+
+	var res spotify.SearchResult
+	res.Tracks = &spotify.FullTrackPage{}
+
+	if t&spotify.SearchTypeAlbum != 0 {
+		res.Albums = &spotify.SimpleAlbumPage{}
+		seen := make(map[spotify.ID]bool)
+
+		for _, id := range f.catalog {
+			album := f.tracks[id].Album
+			if album.ID != "" && !seen[album.ID] && q.matchesAlbum(album, f.upcs[album.ID]) {
+				seen[album.ID] = true
+				res.Albums.Albums = append(res.Albums.Albums, album)
+			}
+		}
+
+		res.Albums.Total = len(res.Albums.Albums)
+	}
+
+	if t&spotify.SearchTypeTrack == 0 {
+		return &res, nil
+	}
+
+	var market string
+	if opt != nil && opt.Country != nil {
+		market = *opt.Country
+	}
+
+	for _, id := range f.catalog {
+		track := f.tracks[id]
+		if q.matches(track) && availableIn(track, market) {
+			res.Tracks.Tracks = append(res.Tracks.Tracks, track)
+		}
+	}
+
+	limit, offset := defaultSearchLimit, 0
+
+	if opt != nil && opt.Limit != nil {
+		limit = *opt.Limit
+	}
+
+	if opt != nil && opt.Offset != nil {
+		offset = *opt.Offset
+	}
+
+	res.Tracks.Total = len(res.Tracks.Tracks)
+	res.Tracks.Limit = limit
+	res.Tracks.Offset = offset
+
+	if offset > len(res.Tracks.Tracks) {
+		offset = len(res.Tracks.Tracks)
+	}
+
+	res.Tracks.Tracks = res.Tracks.Tracks[offset:]
+
+	if len(res.Tracks.Tracks) > limit {
+		res.Tracks.Tracks = res.Tracks.Tracks[:limit]
+	}
+
+	return &res, nil
+}
+
+func (f *Fake) createPlaylist(owner string, name string, description string, public bool) *spotify.FullPlaylist {
+	var p spotify.FullPlaylist
+
+	p.ID = spotify.ID(fmt.Sprintf("playlist%d", len(f.playlists)+1))
+	p.Name = name
+	p.Description = description
+	p.IsPublic = public
+	p.Owner.ID = owner
+	p.URI = spotify.URI("spotify:playlist:" + string(p.ID))
+	p.SnapshotID = f.nextSnapshot()
+
+	f.playlists[p.ID] = &p
+	f.playlistOrder = append(f.playlistOrder, p.ID)
+
+	return &p
+}
+
+func (f *Fake) appendTrack(p *spotify.FullPlaylist, id spotify.ID) {
+	var pt spotify.PlaylistTrack
+
+	pt.Track = f.tracks[id]
+	pt.Track.ID = id
+	pt.AddedBy.ID = f.user.ID
+	pt.AddedAt = addedAtBase.Add(time.Duration(f.snapshots) * time.Minute).Format(spotify.TimestampLayout)
+
+	p.Tracks.Tracks = append(p.Tracks.Tracks, pt)
+	p.Tracks.Total = len(p.Tracks.Tracks)
+	p.SimplePlaylist.Tracks.Total = uint(len(p.Tracks.Tracks))
+	p.SnapshotID = f.nextSnapshot()
+}
+
+func (f *Fake) nextSnapshot() string {
+	f.snapshots++
+	return fmt.Sprintf("snapshot%d", f.snapshots)
+}
+
+func availableIn(t spotify.FullTrack, market string) bool {
+	if market == "" || len(t.AvailableMarkets) == 0 {
+		return true
+	}
+
+	for _, m := range t.AvailableMarkets {
+		if m == market {
+			return true
+		}
+	}
+
+	return false
+}
+
+func notFound(msg string) error {
+	return spotify.Error{Status: http.StatusNotFound, Message: msg}
+}
@@ -0,0 +1,183 @@
+package spotifytest
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/zmb3/spotify"
+)
+
+// query is a parsed search query.
+type query struct {
+	words   []string
+	filters map[string][]string
+}
+
+// parseQuery splits a search query into plain words and field filters,
+// e.g. `artist:"Daft Punk" track:aerodynamic live`.
+func parseQuery(q string) query {
+	res := query{filters: make(map[string][]string)}
+
+	for _, term := range splitTerms(q) {
+		if i := strings.Index(term, ":"); i > 0 {
+			field := strings.ToLower(term[:i])
+			switch field {
+			case "artist", "track", "album", "isrc", "upc", "year":
+				value := strings.Trim(term[i+1:], `"`)
+				res.filters[field] = append(res.filters[field], normalize(value))
+				continue
+			}
+		}
+
+		for _, w := range strings.Fields(normalize(strings.Trim(term, `"`))) {
+			// like Spotify, ignore terms without any letters or digits
+			if strings.IndexFunc(w, isAlphanumeric) >= 0 {
+				res.words = append(res.words, w)
+			}
+		}
+	}
+
+	return res
+}
+
+// splitTerms splits on spaces, keeping quoted phrases together.
+func splitTerms(q string) []string {
+	var terms []string
+	var cur strings.Builder
+	var quoted bool
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case r == ' ' && !quoted:
+			if cur.Len() > 0 {
+				terms = append(terms, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+
+	if cur.Len() > 0 {
+		terms = append(terms, cur.String())
+	}
+
+	return terms
+}
+
+func (q query) matches(t spotify.FullTrack) bool {
+	var artists []string
+	for _, a := range t.Artists {
+		artists = append(artists, normalize(a.Name))
+	}
+
+	name := normalize(t.Name)
+	album := normalize(t.Album.Name)
+	all := strings.Join(append([]string{name, album}, artists...), " ")
+
+	for _, w := range q.words {
+		if !strings.Contains(all, w) {
+			return false
+		}
+	}
+
+	for field, values := range q.filters {
+		for _, v := range values {
+			var ok bool
+
+			switch field {
+			case "artist":
+				ok = strings.Contains(strings.Join(artists, " "), v)
+			case "track":
+				ok = strings.Contains(name, v)
+			case "album":
+				ok = strings.Contains(album, v)
+			case "isrc":
+				ok = strings.EqualFold(t.ExternalIDs["isrc"], v)
+			case "year":
+				ok = matchesYear(t.Album.ReleaseDate, v)
+			}
+
+			if !ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (q query) matchesAlbum(a spotify.SimpleAlbum, upc string) bool {
+	var artists []string
+	for _, ar := range a.Artists {
+		artists = append(artists, normalize(ar.Name))
+	}
+
+	name := normalize(a.Name)
+	all := strings.Join(append([]string{name}, artists...), " ")
+
+	for _, w := range q.words {
+		if !strings.Contains(all, w) {
+			return false
+		}
+	}
+
+	for field, values := range q.filters {
+		for _, v := range values {
+			var ok bool
+
+			switch field {
+			case "artist":
+				ok = strings.Contains(strings.Join(artists, " "), v)
+			case "album":
+				ok = strings.Contains(name, v)
+			case "upc":
+				ok = upc != "" && upc == v
+			}
+
+			if !ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// matchesYear matches a release date against a year or a year range,
+// e.g. 2019 or 2010-2019.
+func matchesYear(releaseDate string, v string) bool {
+	if len(releaseDate) < 4 {
+		return false
+	}
+
+	year := releaseDate[:4]
+
+	if i := strings.Index(v, "-"); i > 0 {
+		return year >= v[:i] && year <= v[i+1:]
+	}
+
+	return year == v
+}
+
+// folder removes apostrophes and diacritics, since Spotify search ignores them.
+var folder = strings.NewReplacer(
+	"'", "", "’", "",
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c",
+)
+
+func normalize(s string) string {
+	return folder.Replace(strings.ToLower(strings.TrimSpace(s)))
+}
+
+func isAlphanumeric(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
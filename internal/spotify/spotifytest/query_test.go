@@ -0,0 +1,78 @@
+package spotifytest
+
+import (
+	"testing"
+
+	"github.com/zmb3/spotify"
+)
+
+func TestQueryMatches(t *testing.T) {
+	track := NewTrack("track1", "Around the World", "Daft Punk")
+	track.Album.Name = "Homework"
+	track.Album.ReleaseDate = "1997-01-20"
+	track.ExternalIDs = map[string]string{"isrc": "GBDUW0000059"}
+
+	tests := []struct {
+		n     string
+		query string
+		exp   bool
+	}{
+		{"should match plain words", "daft punk around the world", true},
+		{"should match words in any field", "homework world", true},
+		{"should not match missing word", "daft punk one more time", false},
+		{"should match quoted artist filter", `artist:"Daft Punk" track:world`, true},
+		{"should not match wrong artist filter", `artist:"Aphex Twin" track:world`, false},
+		{"should match album filter", "album:homework", true},
+		{"should match isrc filter", "isrc:gbduw0000059", true},
+		{"should match year", "world year:1997", true},
+		{"should match year range", "world year:1990-1999", true},
+		{"should not match year outside range", "world year:2000-2009", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			if got := parseQuery(tc.query).matches(track); got != tc.exp {
+				t.Errorf("unexpected result: got %t, exp %t", got, tc.exp)
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	fake := New("tester")
+	fake.AddTrack(NewTrack("track1", "Around the World", "Daft Punk"))
+	playlistID := fake.AddPlaylist("tester", "music")
+
+	srv := NewServer(fake)
+	defer srv.Close()
+
+	c := srv.Client()
+
+	t.Run("should search catalog", func(t *testing.T) {
+		res, err := c.Search("daft punk", spotify.SearchTypeTrack)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(res.Tracks.Tracks) != 1 || res.Tracks.Tracks[0].ID != "track1" {
+			t.Errorf("unexpected search result: %+v", res.Tracks.Tracks)
+		}
+	})
+
+	t.Run("should add tracks to playlist", func(t *testing.T) {
+		if _, err := c.AddTracksToPlaylist(playlistID, "track1"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if ids := fake.PlaylistTrackIDs(playlistID); len(ids) != 1 {
+			t.Errorf("unexpected playlist tracks: %v", ids)
+		}
+	})
+
+	t.Run("should return spotify errors", func(t *testing.T) {
+		_, err := c.GetPlaylist("unknown")
+		if e, ok := err.(spotify.Error); !ok || e.Status != 404 {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,330 @@
+package spotifytest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/zmb3/spotify"
+)
+
+// Server is an httptest server serving a fake as the Spotify Web API.
+type Server struct {
+	*httptest.Server
+	Fake *Fake
+}
+
+// NewServer starts a server serving f. Close it when done.
+func NewServer(f *Fake) *Server {
+	s := Server{Fake: f}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return &s
+}
+
+// Client returns a spotify client sending its requests to the server
+// instead of api.spotify.com.
+func (s *Server) Client() *spotify.Client {
+	c := spotify.NewClient(s.HTTPClient())
+
+	return &c
+}
+
+// HTTPClient returns an http client sending its requests to the server.
+func (s *Server) HTTPClient() *http.Client {
+	target, _ := url.Parse(s.URL)
+
+	return &http.Client{Transport: rewriteTransport{target: target}}
+}
+
+// rewriteTransport sends all requests to target.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	r.Host = t.target.Host
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1"), "/"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "me":
+		respond(w, http.StatusOK)(s.Fake.CurrentUser())
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "search":
+		s.search(w, r)
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "audio-features":
+		s.audioFeatures(w, r)
+	case r.Method == http.MethodGet && len(parts) == 1 && parts[0] == "tracks":
+		s.tracks(w, r)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "tracks":
+		respond(w, http.StatusOK)(s.Fake.GetTrack(spotify.ID(parts[1])))
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "playlists":
+		respond(w, http.StatusOK)(s.Fake.GetPlaylist(spotify.ID(parts[1])))
+	case r.Method == http.MethodPut && len(parts) == 2 && parts[0] == "playlists":
+		s.changePlaylist(w, r, spotify.ID(parts[1]))
+	case r.Method == http.MethodPut && len(parts) == 3 && parts[0] == "playlists" && parts[2] == "images":
+		s.setImage(w, r, spotify.ID(parts[1]))
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "playlists" && parts[2] == "tracks":
+		s.playlistTracks(w, r, spotify.ID(parts[1]))
+	case r.Method == http.MethodPut && len(parts) == 3 && parts[0] == "playlists" && parts[2] == "tracks":
+		s.replaceTracks(w, r, spotify.ID(parts[1]))
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "playlists" && parts[2] == "tracks":
+		s.addTracks(w, r, spotify.ID(parts[1]))
+	case r.Method == http.MethodDelete && len(parts) == 3 && parts[0] == "playlists" && parts[2] == "tracks":
+		s.removeTracks(w, r, spotify.ID(parts[1]))
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "users" && parts[2] == "playlists":
+		respond(w, http.StatusOK)(s.Fake.GetPlaylistsForUser(parts[1]))
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "users" && parts[2] == "playlists":
+		s.createPlaylist(w, r, parts[1])
+	default:
+		writeError(w, spotify.Error{Status: http.StatusNotFound, Message: "Service not found"})
+	}
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	var t spotify.SearchType
+
+	for _, typ := range strings.Split(r.URL.Query().Get("type"), ",") {
+		switch typ {
+		case "album":
+			t |= spotify.SearchTypeAlbum
+		case "artist":
+			t |= spotify.SearchTypeArtist
+		case "playlist":
+			t |= spotify.SearchTypePlaylist
+		case "track":
+			t |= spotify.SearchTypeTrack
+		}
+	}
+
+	var opt spotify.Options
+
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+		opt.Limit = &limit
+	}
+
+	if offset, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil {
+		opt.Offset = &offset
+	}
+
+	if market := r.URL.Query().Get("market"); market != "" {
+		opt.Country = &market
+	}
+
+	respond(w, http.StatusOK)(s.Fake.SearchOpt(r.URL.Query().Get("q"), t, &opt))
+}
+
+func (s *Server) tracks(w http.ResponseWriter, r *http.Request) {
+	var ids []spotify.ID
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		ids = append(ids, spotify.ID(id))
+	}
+
+	tracks, err := s.Fake.GetTracks(ids...)
+	respond(w, http.StatusOK)(map[string][]*spotify.FullTrack{"tracks": tracks}, err)
+}
+
+func (s *Server) audioFeatures(w http.ResponseWriter, r *http.Request) {
+	var ids []spotify.ID
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		ids = append(ids, spotify.ID(id))
+	}
+
+	features, err := s.Fake.GetAudioFeatures(ids...)
+	respond(w, http.StatusOK)(map[string][]*spotify.AudioFeatures{"audio_features": features}, err)
+}
+
+func (s *Server) playlistTracks(w http.ResponseWriter, r *http.Request, playlistID spotify.ID) {
+	var opt spotify.Options
+
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+		opt.Limit = &limit
+	}
+
+	if offset, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil {
+		opt.Offset = &offset
+	}
+
+	respond(w, http.StatusOK)(s.Fake.GetPlaylistTracksOpt(playlistID, &opt, r.URL.Query().Get("fields")))
+}
+
+func (s *Server) changePlaylist(w http.ResponseWriter, r *http.Request, playlistID spotify.ID) {
+	var req struct {
+		Name          string `json:"name"`
+		Description   string `json:"description"`
+		Public        *bool  `json:"public"`
+		Collaborative *bool  `json:"collaborative"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, spotify.Error{Status: http.StatusBadRequest, Message: "Error parsing JSON."})
+		return
+	}
+
+	var changes []func() error
+
+	if req.Name != "" {
+		changes = append(changes, func() error { return s.Fake.ChangePlaylistName(playlistID, req.Name) })
+	}
+
+	if req.Description != "" {
+		changes = append(changes, func() error { return s.Fake.ChangePlaylistDescription(playlistID, req.Description) })
+	}
+
+	if req.Collaborative != nil {
+		changes = append(changes, func() error { return s.Fake.ChangePlaylistCollaborative(playlistID, *req.Collaborative) })
+	}
+
+	if req.Public != nil {
+		changes = append(changes, func() error { return s.Fake.ChangePlaylistAccess(playlistID, *req.Public) })
+	}
+
+	for _, change := range changes {
+		if err := change(); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// setImage sets the cover image of a playlist from the base64 encoded
+// JPEG body.
+func (s *Server) setImage(w http.ResponseWriter, r *http.Request, playlistID spotify.ID) {
+	if err := s.Fake.SetPlaylistImage(playlistID, base64.NewDecoder(base64.StdEncoding, r.Body)); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// replaceTracks replaces the tracks of a playlist when uris are given,
+// otherwise it reorders them.
+func (s *Server) replaceTracks(w http.ResponseWriter, r *http.Request, playlistID spotify.ID) {
+	if _, ok := r.URL.Query()["uris"]; !ok {
+		s.reorderTracks(w, r, playlistID)
+		return
+	}
+
+	var ids []spotify.ID
+
+	if uris := r.URL.Query().Get("uris"); uris != "" {
+		for _, uri := range strings.Split(uris, ",") {
+			ids = append(ids, spotify.ID(strings.TrimPrefix(uri, "spotify:track:")))
+		}
+	}
+
+	if err := s.Fake.ReplacePlaylistTracks(playlistID, ids...); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) reorderTracks(w http.ResponseWriter, r *http.Request, playlistID spotify.ID) {
+	var opt spotify.PlaylistReorderOptions
+
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		writeError(w, spotify.Error{Status: http.StatusBadRequest, Message: "Error parsing JSON."})
+		return
+	}
+
+	snapshotID, err := s.Fake.ReorderPlaylistTracks(playlistID, opt)
+	respond(w, http.StatusOK)(map[string]string{"snapshot_id": snapshotID}, err)
+}
+
+func (s *Server) addTracks(w http.ResponseWriter, r *http.Request, playlistID spotify.ID) {
+	var req struct {
+		URIs []string `json:"uris"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, spotify.Error{Status: http.StatusBadRequest, Message: "Error parsing JSON."})
+		return
+	}
+
+	ids := make([]spotify.ID, 0, len(req.URIs))
+	for _, uri := range req.URIs {
+		ids = append(ids, spotify.ID(strings.TrimPrefix(uri, "spotify:track:")))
+	}
+
+	snapshotID, err := s.Fake.AddTracksToPlaylist(playlistID, ids...)
+	respond(w, http.StatusCreated)(map[string]string{"snapshot_id": snapshotID}, err)
+}
+
+func (s *Server) removeTracks(w http.ResponseWriter, r *http.Request, playlistID spotify.ID) {
+	var req struct {
+		Tracks []struct {
+			URI string `json:"uri"`
+		} `json:"tracks"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, spotify.Error{Status: http.StatusBadRequest, Message: "Error parsing JSON."})
+		return
+	}
+
+	ids := make([]spotify.ID, 0, len(req.Tracks))
+	for _, t := range req.Tracks {
+		ids = append(ids, spotify.ID(strings.TrimPrefix(t.URI, "spotify:track:")))
+	}
+
+	snapshotID, err := s.Fake.RemoveTracksFromPlaylist(playlistID, ids...)
+	respond(w, http.StatusOK)(map[string]string{"snapshot_id": snapshotID}, err)
+}
+
+func (s *Server) createPlaylist(w http.ResponseWriter, r *http.Request, userID string) {
+	var req struct {
+		Name        string `json:"name"`
+		Public      bool   `json:"public"`
+		Description string `json:"description"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, spotify.Error{Status: http.StatusBadRequest, Message: "Error parsing JSON."})
+		return
+	}
+
+	respond(w, http.StatusCreated)(s.Fake.CreatePlaylistForUser(userID, req.Name, req.Description, req.Public))
+}
+
+// respond returns a function writing either the result or the error,
+// allowing fake calls to be passed directly.
+func respond(w http.ResponseWriter, status int) func(v interface{}, err error) {
+	return func(v interface{}, err error) {
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var e spotify.Error
+	if !errors.As(err, &e) {
+		e = spotify.Error{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(map[string]spotify.Error{"error": e})
+}
@@ -5,7 +5,8 @@ import (
 )
 
 // SetUser fetches the authenticated users and
-// sets it on the client.
+// sets it on the client. The user's country is used as market unless one
+// is configured.
 func (c *Client) SetUser() error {
 	user, err := c.Spotify.CurrentUser()
 	if err != nil {
@@ -16,5 +17,10 @@ func (c *Client) SetUser() error {
 
 	c.User = user
 
+	if c.market == "" && user.Country != "" {
+		c.market = user.Country
+		c.Logger.Infof("using market: %s", c.market)
+	}
+
 	return nil
 }
@@ -0,0 +1,72 @@
+package spotify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/zmb3/spotify"
+)
+
+// apiURL is the base URL of the Spotify Web API.
+const apiURL = "https://api.spotify.com/v1/"
+
+// webAPI is the spotify.Client with the endpoints it's missing.
+type webAPI struct {
+	*spotify.Client
+
+	http *http.Client
+}
+
+// NewAPI returns the API backed by a spotify client. The requests the
+// client doesn't cover are sent with httpClient, authorized with the
+// client's oauth2 token when it has one.
+func NewAPI(client *spotify.Client, httpClient *http.Client) API {
+	return &webAPI{Client: client, http: httpClient}
+}
+
+// ChangePlaylistCollaborative makes a playlist collaborative or not.
+// Collaborative playlists are made private, as Spotify requires.
+func (w *webAPI) ChangePlaylistCollaborative(playlistID spotify.ID, collaborative bool) error {
+	body := map[string]bool{"collaborative": collaborative}
+	if collaborative {
+		body["public"] = false
+	}
+
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%splaylists/%s", apiURL, playlistID), bytes.NewReader(bodyJSON))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if token, err := w.Client.Token(); err == nil {
+		token.SetAuthHeader(req)
+	}
+
+	res, err := w.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		var e struct {
+			Error spotify.Error `json:"error"`
+		}
+
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error.Message == "" {
+			return spotify.Error{Status: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+		}
+
+		return e.Error
+	}
+
+	return nil
+}
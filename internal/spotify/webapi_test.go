@@ -0,0 +1,34 @@
+package spotify
+
+import (
+	"testing"
+
+	"github.com/engvik/dissic/internal/spotify/spotifytest"
+)
+
+func TestChangePlaylistCollaborative(t *testing.T) {
+	fake := spotifytest.New("tester")
+	playlistID := fake.AddPlaylist("tester", "music")
+
+	if err := fake.ChangePlaylistAccess(playlistID, true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	srv := spotifytest.NewServer(fake)
+	defer srv.Close()
+
+	api := NewAPI(srv.Client(), srv.HTTPClient())
+
+	if err := api.ChangePlaylistCollaborative(playlistID, true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	p, _ := fake.GetPlaylist(playlistID)
+	if !p.Collaborative || p.IsPublic {
+		t.Errorf("expected private collaborative playlist, got public %t, collaborative %t", p.IsPublic, p.Collaborative)
+	}
+
+	if err := api.ChangePlaylistCollaborative("unknown", true); err == nil {
+		t.Errorf("expected error for unknown playlist")
+	}
+}
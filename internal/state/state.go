@@ -0,0 +1,123 @@
+// Package state persists changes made to dissic at runtime, like
+// subreddits added through the management API, so they survive a restart.
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/engvik/dissic/internal/config"
+)
+
+// State is the runtime state written to the state file.
+type State struct {
+	Playlists []Playlist `json:"playlists"`
+	Paused    []string   `json:"paused"`
+	// BlockedArtists are the artists blocked at runtime, on top of the
+	// block list in the config.
+	BlockedArtists []string `json:"blocked_artists,omitempty"`
+	// Rotations are the start of the period each rotating playlist was
+	// last rotated in, by playlist key.
+	Rotations map[string]time.Time `json:"rotations,omitempty"`
+	// Seen are the IDs of the posts last seen in each feed, community and
+	// timeline, by routing key.
+	Seen map[string][]string `json:"seen,omitempty"`
+}
+
+// Playlist holds the subreddits of a playlist, identified by its config key.
+type Playlist struct {
+	Key        string   `json:"key"`
+	Subreddits []string `json:"subreddits"`
+}
+
+// Load reads the state file at path. A missing file results in an empty state.
+func Load(path string) (*State, error) {
+	var s State
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &s, nil
+		}
+
+		return nil, fmt.Errorf("reading state file: %s, %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("unmarshal state file: %s, %w", path, err)
+	}
+
+	return &s, nil
+}
+
+// Save writes the state to path. The file is replaced atomically so a
+// crash never leaves a half written state behind.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing state file: %s, %w", tmp, err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replacing state file: %s, %w", path, err)
+	}
+
+	return nil
+}
+
+// Update reads the state file at path, changes the state with update and
+// writes it back. Reading it first keeps the changes made by other dissic
+// processes, like a command run while dissic is running.
+func Update(path string, update func(s *State)) error {
+	s, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	update(s)
+
+	return s.Save(path)
+}
+
+// AddBlockedArtist adds an artist to the artists blocked at runtime. It
+// returns false if the artist was already blocked.
+func (s *State) AddBlockedArtist(artist string) bool {
+	for _, a := range s.BlockedArtists {
+		if strings.EqualFold(a, artist) {
+			return false
+		}
+	}
+
+	s.BlockedArtists = append(s.BlockedArtists, artist)
+
+	return true
+}
+
+// Apply overrides the subreddits of the configured playlists with the ones
+// found in the state, and adds the blocked artists to the block list.
+// Playlists no longer in the config are ignored.
+func (s *State) Apply(cfg *config.Config) {
+	for _, a := range s.BlockedArtists {
+		cfg.Block.AddArtist(a)
+	}
+
+	for _, sp := range s.Playlists {
+		p := cfg.Playlist(sp.Key)
+		if p == nil {
+			continue
+		}
+
+		p.Subreddits = append([]string(nil), sp.Subreddits...)
+	}
+
+	cfg.UpdateSubreddits()
+}
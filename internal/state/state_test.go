@@ -0,0 +1,98 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/engvik/dissic/internal/config"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(os.TempDir(), "dissic-state-does-not-exist.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(s.Playlists) != 0 || len(s.Paused) != 0 {
+		t.Errorf("expected empty state, got %+v", s)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dissic-state")
+	if err != nil {
+		t.Fatalf("error setting up test: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	s := State{
+		Playlists: []Playlist{{Key: "test", Subreddits: []string{"music", "listentothis"}}},
+		Paused:    []string{"music"},
+		Seen:      map[string][]string{"lemmy:music": {"42"}},
+	}
+
+	if err := s.Save(path); err != nil {
+		t.Fatalf("unexpected error saving: %s", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading: %s", err)
+	}
+
+	if len(loaded.Playlists) != 1 || len(loaded.Playlists[0].Subreddits) != 2 {
+		t.Errorf("unexpected playlists: %+v", loaded.Playlists)
+	}
+
+	if len(loaded.Paused) != 1 || loaded.Paused[0] != "music" {
+		t.Errorf("unexpected paused: %+v", loaded.Paused)
+	}
+
+	if ids := loaded.Seen["lemmy:music"]; len(ids) != 1 || ids[0] != "42" {
+		t.Errorf("unexpected seen: %+v", loaded.Seen)
+	}
+}
+
+func TestApply(t *testing.T) {
+	cfg := config.Config{
+		Playlists: []config.Playlist{
+			{Name: "one", Subreddits: []string{"music"}},
+			{ID: "two", Subreddits: []string{"jazz"}},
+		},
+	}
+
+	s := State{
+		Playlists: []Playlist{
+			{Key: "one", Subreddits: []string{"music", "listentothis"}},
+			{Key: "gone", Subreddits: []string{"metal"}},
+		},
+		BlockedArtists: []string{"artist1", "Artist1"},
+	}
+
+	t.Run("should override playlist subreddits", func(t *testing.T) {
+		s.Apply(&cfg)
+
+		if len(cfg.Playlists[0].Subreddits) != 2 {
+			t.Errorf("unexpected subreddits: got %v", cfg.Playlists[0].Subreddits)
+		}
+
+		exp := []string{"music", "listentothis", "jazz"}
+		if len(cfg.Reddit.Subreddits) != len(exp) {
+			t.Fatalf("unexpected slice length: got %d, exp %d", len(cfg.Reddit.Subreddits), len(exp))
+		}
+
+		for i, sub := range cfg.Reddit.Subreddits {
+			if sub != exp[i] {
+				t.Errorf("unexpected value: got %s, exp %s, pos %d", sub, exp[i], i)
+			}
+		}
+	})
+	t.Run("should add blocked artists", func(t *testing.T) {
+		if len(cfg.Block.Artists) != 1 || cfg.Block.Artists[0] != "artist1" {
+			t.Errorf("unexpected block list: %+v", cfg.Block)
+		}
+	})
+}
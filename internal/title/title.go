@@ -0,0 +1,316 @@
+// Package title parses music post titles like
+// "Artist - Track (feat. Someone) [Genre] (2019)" into their parts, and
+// compares them with catalog tracks.
+package title
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrNoSeparator is returned when a title has no artist and track separator.
+var ErrNoSeparator = errors.New("no artist and track separator")
+
+// Title is a parsed post title.
+type Title struct {
+	// Artist is the artist part as written, Artists the artists in it.
+	Artist   string
+	Artists  []string
+	Featured []string
+	Track    string
+	// Version is e.g. "Acoustic", "Live at Reading" or "Chromatics Remix".
+	Version string
+	Year    int
+	// Genre is the square bracket tag used by r/Music and friends.
+	Genre string
+	// Noise is everything ignored, e.g. "Official Video".
+	Noise []string
+}
+
+// separators between artist and track, in order of preference when
+// several are found at the same position.
+var separators = []string{" -- ", " - ", " — ", " – ", " ~ ", " | ", " ー "}
+
+var (
+	bracketRe  = regexp.MustCompile(`\(([^()\[\]]*)\)|\[([^()\[\]]*)\]`)
+	yearRe     = regexp.MustCompile(`^(19|20)\d{2}$`)
+	featRe     = regexp.MustCompile(`(?i)^(?:feat\.?|ft\.?|featuring|with)\s+(.+)$`)
+	inlineFeat = regexp.MustCompile(`(?i)\s+(?:feat\.?|ft\.?|featuring)\s+(.+)$`)
+	artistsRe  = regexp.MustCompile(`\s*(?:,|&|\s+x\s+|\s+vs\.?\s+)\s*`)
+	featuredRe = regexp.MustCompile(`\s*(?:,|&|\s+x\s+|\s+vs\.?\s+|\s+and\s+)\s*`)
+)
+
+// versionWords mark a bracket or suffix as a version of a track.
+var versionWords = map[string]bool{
+	"remix": true, "rmx": true, "mix": true, "edit": true, "live": true,
+	"acoustic": true, "remaster": true, "remastered": true, "version": true,
+	"demo": true, "cover": true, "instrumental": true, "unplugged": true,
+	"session": true, "sessions": true, "rework": true, "bootleg": true,
+	"dub": true, "vip": true, "reprise": true, "extended": true,
+}
+
+// neutralVersionWords don't change the recording, so a track with only
+// these in its version is as good as the original.
+var neutralVersionWords = map[string]bool{
+	"remaster": true, "remastered": true, "version": true, "mono": true, "stereo": true,
+}
+
+// noiseWords mark a bracket as noise.
+var noiseWords = map[string]bool{
+	"official": true, "video": true, "audio": true, "lyric": true, "lyrics": true,
+	"visualizer": true, "visualiser": true, "hd": true, "hq": true, "4k": true,
+	"explicit": true, "premiere": true, "clip": true,
+}
+
+// Parse parses a post title. Brackets are classified as year, featured
+// artists, version, noise or genre; the rest is split into artists and
+// track at the first separator, or at " by " for "Track by Artist".
+func Parse(s string) (Title, error) {
+	var t Title
+
+	s = bracketRe.ReplaceAllStringFunc(s, func(b string) string {
+		t.addBracket(b[1:len(b)-1], b[0] == '[')
+		return " "
+	})
+	s = strings.Join(strings.Fields(s), " ")
+
+	artist, track, rest, ok := split(s)
+	if !ok {
+		return t, ErrNoSeparator
+	}
+
+	for _, r := range rest {
+		t.addBracket(r, false)
+	}
+
+	if m := inlineFeat.FindStringSubmatch(artist); m != nil {
+		artist = strings.TrimSpace(artist[:len(artist)-len(m[0])])
+		t.Featured = append(t.Featured, splitFeatured(m[1])...)
+	}
+
+	if m := inlineFeat.FindStringSubmatch(track); m != nil {
+		track = strings.TrimSpace(track[:len(track)-len(m[0])])
+		t.Featured = append(t.Featured, splitFeatured(m[1])...)
+	}
+
+	t.Artist = artist
+	t.Artists = splitArtists(artist)
+	t.Track = strings.Trim(track, ` "'`)
+
+	if len(t.Artists) == 0 || t.Track == "" {
+		return t, ErrNoSeparator
+	}
+
+	return t, nil
+}
+
+// split splits at the earliest separator. Further parts, as in
+// "Artist - Track - Live", are returned as rest.
+func split(s string) (artist string, track string, rest []string, ok bool) {
+	sep := ""
+	pos := -1
+
+	for _, sp := range separators {
+		if i := strings.Index(s, sp); i > 0 && (pos < 0 || i < pos) {
+			sep, pos = sp, i
+		}
+	}
+
+	if pos < 0 {
+		if i := strings.LastIndex(s, " by "); i > 0 {
+			return strings.TrimSpace(s[i+4:]), strings.TrimSpace(s[:i]), nil, true
+		}
+
+		return "", "", nil, false
+	}
+
+	parts := strings.Split(s[pos+len(sep):], sep)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	return strings.TrimSpace(s[:pos]), parts[0], parts[1:], true
+}
+
+func (t *Title) addBracket(b string, square bool) {
+	b = strings.TrimSpace(b)
+	words := strings.Fields(Normalize(b))
+
+	switch {
+	case b == "":
+	case yearRe.MatchString(b) && t.Year == 0:
+		t.Year, _ = strconv.Atoi(b)
+	case featRe.MatchString(b):
+		t.Featured = append(t.Featured, splitFeatured(featRe.FindStringSubmatch(b)[1])...)
+	case hasAny(words, versionWords):
+		if t.Version != "" {
+			t.Version += " "
+		}
+		t.Version += b
+	case hasAny(words, noiseWords):
+		t.Noise = append(t.Noise, b)
+	case square && t.Genre == "":
+		t.Genre = b
+	default:
+		t.Noise = append(t.Noise, b)
+	}
+}
+
+// splitArtists splits "A & B" and "A x B". Featured artists are also
+// split on "and", which is too common in band names to split on otherwise.
+func splitArtists(s string) []string {
+	return splitOn(artistsRe, s)
+}
+
+func splitFeatured(s string) []string {
+	return splitOn(featuredRe, s)
+}
+
+func splitOn(re *regexp.Regexp, s string) []string {
+	var artists []string
+
+	for _, a := range re.Split(s, -1) {
+		if a = strings.TrimSpace(a); a != "" {
+			artists = append(artists, a)
+		}
+	}
+
+	return artists
+}
+
+// SplitTrackName splits a catalog track name like
+// "Get Lucky - Radio Edit" or "Get Lucky (feat. Pharrell Williams)" into
+// the track, its version and featured artists.
+func SplitTrackName(name string) (track string, version string, featured []string) {
+	var versions []string
+
+	if i := strings.Index(name, " - "); i > 0 {
+		versions = append(versions, strings.TrimSpace(name[i+3:]))
+		name = name[:i]
+	}
+
+	name = bracketRe.ReplaceAllStringFunc(name, func(b string) string {
+		inner := strings.TrimSpace(b[1 : len(b)-1])
+
+		if m := featRe.FindStringSubmatch(inner); m != nil {
+			featured = append(featured, splitFeatured(m[1])...)
+			return " "
+		}
+
+		if hasAny(strings.Fields(Normalize(inner)), versionWords) {
+			versions = append(versions, inner)
+			return " "
+		}
+
+		return b
+	})
+
+	return strings.Join(strings.Fields(name), " "), strings.Join(versions, " "), featured
+}
+
+// Matches reports whether a catalog track is the parsed track by one of
+// the parsed artists. Versions are not compared, see VersionMatches.
+func (t Title) Matches(name string, artists []string) bool {
+	track, _, _ := SplitTrackName(name)
+	want := Normalize(t.Track)
+
+	if want != Normalize(track) && want != Normalize(name) {
+		return false
+	}
+
+	for _, a := range append(append([]string{t.Artist}, t.Artists...), t.Featured...) {
+		for _, b := range artists {
+			if sameArtist(a, b) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// VersionMatches reports whether a catalog track name has the version
+// asked for. With no version asked for, only original recordings and
+// remasters match.
+func (t Title) VersionMatches(name string) bool {
+	_, version, _ := SplitTrackName(name)
+	have := strings.Fields(Normalize(version))
+
+	if t.Version == "" {
+		for _, w := range have {
+			if versionWords[w] && !neutralVersionWords[w] {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, w := range strings.Fields(Normalize(t.Version)) {
+		if versionWords[w] && !contains(have, w) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameArtist(a string, b string) bool {
+	a = strings.TrimPrefix(Normalize(a), "the ")
+	b = strings.TrimPrefix(Normalize(b), "the ")
+
+	return a != "" && a == b
+}
+
+// folder removes diacritics.
+var folder = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c", "&", " and ",
+)
+
+// Normalize lowercases s, removes diacritics, apostrophes and dots, and
+// replaces other punctuation with spaces, for comparing titles.
+func Normalize(s string) string {
+	s = folder.Replace(strings.ToLower(s))
+
+	var b strings.Builder
+
+	for _, r := range s {
+		switch {
+		case r == '\'' || r == '’' || r == '.':
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func hasAny(words []string, set map[string]bool) bool {
+	for _, w := range words {
+		if set[w] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(words []string, w string) bool {
+	for _, v := range words {
+		if v == w {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,160 @@
+package title
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		n     string
+		title string
+		exp   Title
+	}{
+		{
+			"should parse artist, track, genre and year",
+			"Daft Punk - Around the World [House] (1997)",
+			Title{Artist: "Daft Punk", Artists: []string{"Daft Punk"}, Track: "Around the World", Year: 1997, Genre: "House"},
+		},
+		{
+			"should parse separator '~'",
+			"Something ~ Something",
+			Title{Artist: "Something", Artists: []string{"Something"}, Track: "Something"},
+		},
+		{
+			"should parse separator '|'",
+			"Something | Something",
+			Title{Artist: "Something", Artists: []string{"Something"}, Track: "Something"},
+		},
+		{
+			"should parse separator '--'",
+			"Something -- Something",
+			Title{Artist: "Something", Artists: []string{"Something"}, Track: "Something"},
+		},
+		{
+			"should parse separator 'ー'",
+			"Something ー Something",
+			Title{Artist: "Something", Artists: []string{"Something"}, Track: "Something"},
+		},
+		{
+			"should parse em dash",
+			"Portishead — Roads [Trip Hop]",
+			Title{Artist: "Portishead", Artists: []string{"Portishead"}, Track: "Roads", Genre: "Trip Hop"},
+		},
+		{
+			"should parse reversed 'by'",
+			"Glue by Bicep",
+			Title{Artist: "Bicep", Artists: []string{"Bicep"}, Track: "Glue"},
+		},
+		{
+			"should prefer dash over 'by'",
+			"Ben E. King - Stand By Me",
+			Title{Artist: "Ben E. King", Artists: []string{"Ben E. King"}, Track: "Stand By Me"},
+		},
+		{
+			"should parse featured artists and noise",
+			"Gorillaz - Feel Good Inc. (feat. De La Soul) (Official Video)",
+			Title{Artist: "Gorillaz", Artists: []string{"Gorillaz"}, Featured: []string{"De La Soul"}, Track: "Feel Good Inc.", Noise: []string{"Official Video"}},
+		},
+		{
+			"should parse inline featured artists",
+			"Kanye West ft. Jay-Z and Frank Ocean - No Church in the Wild",
+			Title{Artist: "Kanye West", Artists: []string{"Kanye West"}, Featured: []string{"Jay-Z", "Frank Ocean"}, Track: "No Church in the Wild"},
+		},
+		{
+			"should parse multiple artists",
+			"Daft Punk & Pharrell Williams - Get Lucky",
+			Title{Artist: "Daft Punk & Pharrell Williams", Artists: []string{"Daft Punk", "Pharrell Williams"}, Track: "Get Lucky"},
+		},
+		{
+			"should parse version",
+			"The Weeknd - Blinding Lights (Chromatics Remix) [Synthpop]",
+			Title{Artist: "The Weeknd", Artists: []string{"The Weeknd"}, Track: "Blinding Lights", Version: "Chromatics Remix", Genre: "Synthpop"},
+		},
+		{
+			"should parse version after separator",
+			"Radiohead - Creep - Acoustic",
+			Title{Artist: "Radiohead", Artists: []string{"Radiohead"}, Track: "Creep", Version: "Acoustic"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			got, err := Parse(tc.title)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.exp) {
+				t.Errorf("unexpected title:\ngot %+v\nexp %+v", got, tc.exp)
+			}
+		})
+	}
+
+	t.Run("should fail without separator", func(t *testing.T) {
+		if _, err := Parse("this song has been stuck in my head all week"); err != ErrNoSeparator {
+			t.Errorf("unexpected error: got %v, exp %v", err, ErrNoSeparator)
+		}
+	})
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		n       string
+		title   string
+		name    string
+		artists []string
+		exp     bool
+		expVer  bool
+	}{
+		{"should match exact track", "Daft Punk - Around the World", "Around the World", []string{"Daft Punk"}, true, true},
+		{"should not match other artist", "Daft Punk - Around the World", "Around the World", []string{"Red Hot Chili Peppers"}, false, true},
+		{"should not match other track", "Sault - Wildfires", "Wildfire", []string{"SAULT"}, false, true},
+		{"should match without accents", "Khruangbin - Maria Tambien", "Maria También", []string{"Khruangbin"}, true, true},
+		{"should match remaster", "Pink Floyd - Wish You Were Here", "Wish You Were Here - Remastered 2011", []string{"Pink Floyd"}, true, true},
+		{"should match featured artists in name", "Daft Punk - Get Lucky", "Get Lucky (feat. Pharrell Williams and Nile Rodgers)", []string{"Daft Punk"}, true, true},
+		{"should not prefer live version", "Nirvana - Smells Like Teen Spirit", "Smells Like Teen Spirit - Live At Reading", []string{"Nirvana"}, true, false},
+		{"should prefer asked for version", "Radiohead - Creep (Acoustic)", "Creep - Acoustic", []string{"Radiohead"}, true, true},
+		{"should not prefer original when version asked for", "Radiohead - Creep (Acoustic)", "Creep", []string{"Radiohead"}, true, false},
+		{"should ignore leading the", "Strokes - Last Nite", "Last Nite", []string{"The Strokes"}, true, true},
+		{"should match band with ampersand", "Simon & Garfunkel - The Boxer", "The Boxer", []string{"Simon & Garfunkel"}, true, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			title, err := Parse(tc.title)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := title.Matches(tc.name, tc.artists); got != tc.exp {
+				t.Errorf("unexpected match: got %t, exp %t", got, tc.exp)
+			}
+
+			if got := title.VersionMatches(tc.name); got != tc.expVer {
+				t.Errorf("unexpected version match: got %t, exp %t", got, tc.expVer)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		n   string
+		s   string
+		exp string
+	}{
+		{"should lowercase and fold accents", "ROSALÍA", "rosalia"},
+		{"should remove dots and apostrophes", "D.A.N.C.E. Can't", "dance cant"},
+		{"should replace punctuation", "Cap.1: Augurio!", "cap1 augurio"},
+		{"should replace ampersand", "Simon & Garfunkel", "simon and garfunkel"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.n, func(t *testing.T) {
+			if got := Normalize(tc.s); got != tc.exp {
+				t.Errorf("unexpected value: got %s, exp %s", got, tc.exp)
+			}
+		})
+	}
+}